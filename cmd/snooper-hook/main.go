@@ -26,6 +26,7 @@ type Config struct {
 	URL        string
 	ModuleType string
 	ModuleName string
+	Priority   int
 	Config     map[string]interface{}
 	Verbose    bool
 }
@@ -112,8 +113,9 @@ func parseFlags() *Config {
 	configStr := ""
 
 	flag.StringVar(&config.URL, "url", "ws://localhost:8080/_snooper/control", "WebSocket URL of the snooper control endpoint")
-	flag.StringVar(&config.ModuleType, "type", "request_snooper", "Module type (request_snooper, response_snooper, request_counter, response_tracer)")
+	flag.StringVar(&config.ModuleType, "type", "request_snooper", "Module type (request_snooper, response_snooper, request_counter, response_tracer, request_interceptor, response_interceptor)")
 	flag.StringVar(&config.ModuleName, "name", "test-hook", "Module name")
+	flag.IntVar(&config.Priority, "priority", 0, "Module priority, lower priorities process calls first")
 	flag.BoolVar(&config.Verbose, "verbose", false, "Enable verbose logging")
 	flag.StringVar(&configStr, "config", "{}", "Module configuration as JSON string")
 
@@ -128,6 +130,7 @@ func parseFlags() *Config {
 	// Validate module type
 	validTypes := []string{
 		"request_snooper", "response_snooper", "request_counter", "response_tracer",
+		"request_interceptor", "response_interceptor",
 	}
 
 	valid := false
@@ -239,9 +242,10 @@ func (c *TestClient) sendRequest(method string, data interface{}, binaryData []b
 
 func (c *TestClient) RegisterModule() error {
 	regReq := protocol.RegisterModuleRequest{
-		Type:   c.config.ModuleType,
-		Name:   c.config.ModuleName,
-		Config: c.config.Config,
+		Type:     c.config.ModuleType,
+		Name:     c.config.ModuleName,
+		Priority: c.config.Priority,
+		Config:   c.config.Config,
 	}
 
 	response, err := c.sendRequest("register_module", regReq, nil)
@@ -370,6 +374,8 @@ func (c *TestClient) handleJSONMessage(msg *protocol.WSMessageWithBinary) {
 			c.handleCounterEvent(msg)
 		case "tracer_event":
 			c.handleTracerEvent(msg)
+		case "intercept_request", "intercept_response":
+			c.handleInterceptEvent(msg)
 		default:
 			c.logger.WithField("method", msg.Method).Warn("Unknown message method")
 		}
@@ -443,3 +449,33 @@ func (c *TestClient) handleTracerEvent(msg *protocol.WSMessageWithBinary) {
 		"response_data": responseData,
 	}).Info("Tracer event received")
 }
+
+// handleInterceptEvent logs an intercepted call and lets it continue unchanged.
+func (c *TestClient) handleInterceptEvent(msg *protocol.WSMessageWithBinary) {
+	interceptData, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		c.logger.Debug("Invalid intercept event data")
+		return
+	}
+
+	requestID, _ := interceptData["request_id"].(float64)
+	contentType, _ := interceptData["content_type"].(string)
+
+	c.logger.WithFields(logrus.Fields{
+		"hook_type":    msg.Method,
+		"request_id":   requestID,
+		"content_type": contentType,
+		"size":         len(msg.BinaryData),
+	}).Info("Intercept event received, forwarding")
+
+	response := protocol.WSMessage{
+		ResponseID: msg.RequestID,
+		Method:     msg.Method,
+		Data:       protocol.InterceptVerdict{Action: "forward"},
+		Timestamp:  time.Now().UnixNano(),
+	}
+
+	if err := c.conn.WriteJSON(response); err != nil {
+		c.logger.WithError(err).Error("Failed to send intercept verdict")
+	}
+}
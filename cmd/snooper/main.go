@@ -7,9 +7,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ethpandaops/rpc-snooper/modules"
 	"github.com/ethpandaops/rpc-snooper/snooper"
 	"github.com/ethpandaops/rpc-snooper/utils"
 	"github.com/ethpandaops/rpc-snooper/xatu"
+	"github.com/fatih/color"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 )
@@ -18,11 +20,13 @@ type CliArgs struct {
 	verbose     bool
 	version     bool
 	help        bool
-	target      string
+	targets     []string
 	bind        string
 	port        int
 	nocolor     bool
+	logFormat   string
 	truncate    bool
+	decodeSSZ   bool
 	noapi       bool
 	apiPort     int
 	apiBind     string
@@ -35,10 +39,14 @@ type CliArgs struct {
 	xatuName               string
 	xatuNetworkName        string
 	xatuNetworkID          uint64
+	xatuGenesisTime        uint64
+	xatuSecondsPerSlot     uint64
+	xatuSlotsPerEpoch      uint64
 	xatuOutputs            []string
 	xatuLabels             []string
 	xatuTLS                bool
 	xatuHeaders            []string
+	xatuSnooperEvents      string
 	xatuMaxQueueSize       int
 	xatuMaxExportBatchSize int
 	xatuWorkers            int
@@ -52,7 +60,45 @@ type CliArgs struct {
 	hideBodies bool
 
 	// Engine API authentication
-	jwtSecret string
+	jwtSecret       string
+	jwtResign       bool
+	jwtValidate     string
+	jwtClientSecret string
+	jwtMaxDrift     time.Duration
+
+	// Traffic recording
+	record string
+
+	// Call history for the HAR export
+	harSize        int
+	harMaxBodySize int
+
+	// Outbound queue of WebSocket control connections
+	controlQueueSize     int
+	controlQueueOverflow string
+
+	// Engine API schema validation
+	validateEngine bool
+
+	// Engine API payload lifecycle tracking
+	engineTracker      bool
+	syncingStreakLimit int
+
+	// Shadow traffic mirroring
+	mirror       string
+	mirrorIgnore []string
+
+	// Mock upstream rule file
+	mock string
+
+	// Per-call upstream routing rule file
+	routes string
+
+	// Upstream pool
+	upstreamPolicy      string
+	healthCheck         string
+	healthCheckInterval time.Duration
+	failureBackoff      time.Duration
 }
 
 func getEnvBool(key string, defaultValue bool) bool { //nolint:unparam // ignore
@@ -121,7 +167,10 @@ func buildXatuConfig(args *CliArgs) (*xatu.Config, error) {
 		Name:               args.xatuName,
 		NetworkName:        args.xatuNetworkName,
 		NetworkID:          args.xatuNetworkID,
+		SecondsPerSlot:     args.xatuSecondsPerSlot,
+		SlotsPerEpoch:      args.xatuSlotsPerEpoch,
 		TLS:                args.xatuTLS,
+		SnooperEventsFile:  args.xatuSnooperEvents,
 		Labels:             make(map[string]string, len(args.xatuLabels)),
 		Headers:            make(map[string]string, len(args.xatuHeaders)),
 		Outputs:            make([]xatu.OutputConfig, 0, len(args.xatuOutputs)),
@@ -138,6 +187,10 @@ func buildXatuConfig(args *CliArgs) (*xatu.Config, error) {
 	}
 
 	// Parse outputs
+	if args.xatuGenesisTime > 0 {
+		config.GenesisTime = time.Unix(int64(args.xatuGenesisTime), 0) //nolint:gosec // Safe: unix timestamps fit int64
+	}
+
 	for _, out := range args.xatuOutputs {
 		outConfig, err := xatu.ParseOutputFlag(out)
 		if err != nil {
@@ -170,7 +223,41 @@ func buildXatuConfig(args *CliArgs) (*xatu.Config, error) {
 	return config, nil
 }
 
+// newLogger creates the logger with the snooper log formatter, or the JSON formatter
+// for the json log format.
+func newLogger(logFormat string, nocolor, verbose bool) *logrus.Logger {
+	logger := logrus.New()
+
+	if logFormat == "json" {
+		color.NoColor = true
+
+		logger.SetFormatter(&utils.JSONFormatter{})
+	} else {
+		formatter := &utils.SnooperFormatter{}
+		formatter.Formatter.FullTimestamp = true
+
+		if nocolor {
+			formatter.DisableColors()
+		} else {
+			formatter.EnableColors()
+			formatter.Formatter.ForceColors = true
+		}
+
+		logger.SetFormatter(formatter)
+	}
+
+	if verbose {
+		logger.SetLevel(logrus.DebugLevel)
+	}
+
+	return logger
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:]))
+	}
+
 	// Load defaults from environment variables
 	cliArgs := CliArgs{
 		verbose:     getEnvBool("SNOOPER_VERBOSE", false),
@@ -179,7 +266,9 @@ func main() {
 		bind:        getEnvString("SNOOPER_BIND_ADDRESS", "127.0.0.1"),
 		port:        getEnvInt("SNOOPER_PORT", 3000),
 		nocolor:     getEnvBool("SNOOPER_NO_COLOR", false),
+		logFormat:   getEnvString("SNOOPER_LOG_FORMAT", "text"),
 		truncate:    getEnvBool("SNOOPER_TRUNCATE", true),
+		decodeSSZ:   getEnvBool("SNOOPER_DECODE_SSZ", false),
 		noapi:       getEnvBool("SNOOPER_NO_API", false),
 		apiPort:     getEnvInt("SNOOPER_API_PORT", 0),
 		apiBind:     getEnvString("SNOOPER_API_BIND", "0.0.0.0"),
@@ -189,15 +278,49 @@ func main() {
 		jwtSecret:   getEnvString("SNOOPER_JWT_SECRET", ""),
 		hideBodies:  getEnvBool("SNOOPER_HIDE_BODIES", false),
 
+		jwtResign:       getEnvBool("SNOOPER_JWT_RESIGN", false),
+		jwtValidate:     getEnvString("SNOOPER_JWT_VALIDATE", snooper.JWTValidationOff),
+		jwtClientSecret: getEnvString("SNOOPER_JWT_CLIENT_SECRET", ""),
+		jwtMaxDrift:     getEnvDuration("SNOOPER_JWT_MAX_DRIFT", 60*time.Second),
+
+		record:         getEnvString("SNOOPER_RECORD", ""),
+		harSize:        getEnvInt("SNOOPER_HAR_SIZE", 0),
+		harMaxBodySize: getEnvInt("SNOOPER_HAR_MAX_BODY_SIZE", snooper.DefaultHARMaxBodySize),
+
+		controlQueueSize:     getEnvInt("SNOOPER_CONTROL_QUEUE_SIZE", modules.DefaultSendQueueSize),
+		controlQueueOverflow: getEnvString("SNOOPER_CONTROL_QUEUE_OVERFLOW", modules.OverflowDropOldest),
+
+		validateEngine: getEnvBool("SNOOPER_VALIDATE_ENGINE", false),
+
+		engineTracker:      getEnvBool("SNOOPER_ENGINE_TRACKER", false),
+		syncingStreakLimit: getEnvInt("SNOOPER_SYNCING_STREAK", 32),
+
+		// Mirror defaults from environment
+		mirror:       getEnvString("SNOOPER_MIRROR", ""),
+		mirrorIgnore: getEnvStringSlice("SNOOPER_MIRROR_IGNORE"),
+
+		mock:   getEnvString("SNOOPER_MOCK", ""),
+		routes: getEnvString("SNOOPER_ROUTES", ""),
+
+		// Upstream pool defaults from environment
+		upstreamPolicy:      getEnvString("SNOOPER_UPSTREAM_POLICY", snooper.UpstreamPolicyFailover),
+		healthCheck:         getEnvString("SNOOPER_HEALTH_CHECK", ""),
+		healthCheckInterval: getEnvDuration("SNOOPER_HEALTH_CHECK_INTERVAL", 10*time.Second),
+		failureBackoff:      getEnvDuration("SNOOPER_FAILURE_BACKOFF", 30*time.Second),
+
 		// Xatu defaults from environment
 		xatuEnabled:            getEnvBool("SNOOPER_XATU_ENABLED", false),
 		xatuName:               getEnvString("SNOOPER_XATU_NAME", ""),
 		xatuNetworkName:        getEnvString("SNOOPER_XATU_NETWORK_NAME", ""),
 		xatuNetworkID:          getEnvUint64("SNOOPER_XATU_NETWORK_ID", 0),
+		xatuGenesisTime:        getEnvUint64("SNOOPER_XATU_GENESIS_TIME", 0),
+		xatuSecondsPerSlot:     getEnvUint64("SNOOPER_XATU_SECONDS_PER_SLOT", 0),
+		xatuSlotsPerEpoch:      getEnvUint64("SNOOPER_XATU_SLOTS_PER_EPOCH", 0),
 		xatuOutputs:            getEnvStringSlice("SNOOPER_XATU_OUTPUTS"),
 		xatuLabels:             getEnvStringSlice("SNOOPER_XATU_LABELS"),
 		xatuTLS:                getEnvBool("SNOOPER_XATU_TLS", false),
 		xatuHeaders:            getEnvStringSlice("SNOOPER_XATU_HEADERS"),
+		xatuSnooperEvents:      getEnvString("SNOOPER_XATU_SNOOPER_EVENTS", ""),
 		xatuMaxQueueSize:       getEnvInt("SNOOPER_XATU_MAX_QUEUE_SIZE", 0),
 		xatuMaxExportBatchSize: getEnvInt("SNOOPER_XATU_MAX_EXPORT_BATCH_SIZE", 0),
 		xatuWorkers:            getEnvInt("SNOOPER_XATU_WORKERS", 0),
@@ -215,7 +338,9 @@ func main() {
 	flags.StringVarP(&cliArgs.bind, "bind-address", "b", cliArgs.bind, "Address to bind to and listen for incoming requests (env: SNOOPER_BIND_ADDRESS)")
 	flags.IntVarP(&cliArgs.port, "port", "p", cliArgs.port, "Port to listen for incoming requests (env: SNOOPER_PORT)")
 	flags.BoolVar(&cliArgs.nocolor, "no-color", cliArgs.nocolor, "Do not use terminal colors in output (env: SNOOPER_NO_COLOR)")
+	flags.StringVar(&cliArgs.logFormat, "log-format", cliArgs.logFormat, "Log output format: text or json (one JSON object per line) (env: SNOOPER_LOG_FORMAT)")
 	flags.BoolVar(&cliArgs.truncate, "truncate", cliArgs.truncate, "Truncate large hex values in log output (env: SNOOPER_TRUNCATE)")
+	flags.BoolVar(&cliArgs.decodeSSZ, "decode-ssz", cliArgs.decodeSSZ, "Decode SSZ bodies of known beacon API containers to JSON for logs and modules (env: SNOOPER_DECODE_SSZ)")
 	flags.BoolVar(&cliArgs.noapi, "no-api", cliArgs.noapi, "Do not provide management REST api (env: SNOOPER_NO_API)")
 	flags.IntVar(&cliArgs.apiPort, "api-port", cliArgs.apiPort, "Optional separate port for the snooper API endpoints (env: SNOOPER_API_PORT)")
 	flags.StringVar(&cliArgs.apiBind, "api-bind", cliArgs.apiBind, "Optional address to bind to for the snooper API endpoints (env: SNOOPER_API_BIND)")
@@ -223,17 +348,41 @@ func main() {
 	flags.IntVar(&cliArgs.metricsPort, "metrics-port", cliArgs.metricsPort, "Optional port for Prometheus metrics endpoint (env: SNOOPER_METRICS_PORT)")
 	flags.StringVar(&cliArgs.metricsBind, "metrics-bind", cliArgs.metricsBind, "Optional address to bind to for the Prometheus metrics endpoint (env: SNOOPER_METRICS_BIND)")
 	flags.StringVar(&cliArgs.jwtSecret, "jwt-secret", cliArgs.jwtSecret, "JWT secret for Engine API authentication - file path or hex-encoded value (env: SNOOPER_JWT_SECRET)")
+	flags.BoolVar(&cliArgs.jwtResign, "jwt-resign", cliArgs.jwtResign, "Replace the Authorization header of forwarded calls with a fresh token signed with the --jwt-secret (env: SNOOPER_JWT_RESIGN)")
+	flags.StringVar(&cliArgs.jwtValidate, "jwt-validate", cliArgs.jwtValidate, "Validate the JWT tokens of incoming calls: off, log or reject (env: SNOOPER_JWT_VALIDATE)")
+	flags.StringVar(&cliArgs.jwtClientSecret, "jwt-client-secret", cliArgs.jwtClientSecret, "JWT secret incoming tokens are validated with, defaults to the --jwt-secret - file path or hex-encoded value (env: SNOOPER_JWT_CLIENT_SECRET)")
+	flags.DurationVar(&cliArgs.jwtMaxDrift, "jwt-max-drift", cliArgs.jwtMaxDrift, "Maximum difference between the iat claim of incoming tokens and the local clock (env: SNOOPER_JWT_MAX_DRIFT)")
 	flags.BoolVar(&cliArgs.hideBodies, "hide-bodies", cliArgs.hideBodies, "Hide request/response bodies in log output, showing only method, headers, status and timing (env: SNOOPER_HIDE_BODIES)")
+	flags.StringVar(&cliArgs.upstreamPolicy, "upstream-policy", cliArgs.upstreamPolicy, "Upstream selection policy with multiple targets: failover, round-robin or sticky (by client IP) (env: SNOOPER_UPSTREAM_POLICY)")
+	flags.StringVar(&cliArgs.healthCheck, "health-check", cliArgs.healthCheck, "Upstream health check: JSON-RPC method (e.g. engine_exchangeCapabilities) or HTTP path (e.g. /eth/v1/node/health), defaults to eth_syncing with multiple targets, none to disable (env: SNOOPER_HEALTH_CHECK)")
+	flags.DurationVar(&cliArgs.healthCheckInterval, "health-check-interval", cliArgs.healthCheckInterval, "Interval between upstream health checks (env: SNOOPER_HEALTH_CHECK_INTERVAL)")
+	flags.DurationVar(&cliArgs.failureBackoff, "failure-backoff", cliArgs.failureBackoff, "Time a failed target is skipped when health checks are disabled (env: SNOOPER_FAILURE_BACKOFF)")
+	flags.StringVar(&cliArgs.mirror, "mirror", cliArgs.mirror, "Mirror all calls to a secondary upstream and log where its responses differ (env: SNOOPER_MIRROR)")
+	flags.StringSliceVar(&cliArgs.mirrorIgnore, "mirror-ignore", cliArgs.mirrorIgnore, "gojq path ignored when comparing mirror responses (e.g. .result.timestamp, can be repeated) (env: SNOOPER_MIRROR_IGNORE)")
+	flags.StringVar(&cliArgs.mock, "mock", cliArgs.mock, "Answer calls from a mock rule file instead of the target, a mock:<file> target runs without a real target (env: SNOOPER_MOCK)")
+	flags.StringVar(&cliArgs.routes, "routes", cliArgs.routes, "Route calls to other upstreams by JSON-RPC method, path or body from a route file, unmatched calls go to the target (env: SNOOPER_ROUTES)")
+	flags.StringVar(&cliArgs.record, "record", cliArgs.record, "Record all request/response pairs to a JSONL file for later replay (env: SNOOPER_RECORD)")
+	flags.BoolVar(&cliArgs.validateEngine, "validate-engine", cliArgs.validateEngine, "Validate engine_* requests and responses against the execution-apis schemas (env: SNOOPER_VALIDATE_ENGINE)")
+	flags.BoolVar(&cliArgs.engineTracker, "engine-tracker", cliArgs.engineTracker, "Track the Engine API forkchoice and payload lifecycle at /_snooper/engine/state (env: SNOOPER_ENGINE_TRACKER)")
+	flags.IntVar(&cliArgs.syncingStreakLimit, "syncing-streak", cliArgs.syncingStreakLimit, "Number of consecutive SYNCING verdicts reported as engine anomaly (env: SNOOPER_SYNCING_STREAK)")
+	flags.IntVar(&cliArgs.harSize, "har-size", cliArgs.harSize, "Number of recent calls kept in memory for the /_snooper/har export, 0 to disable (env: SNOOPER_HAR_SIZE)")
+	flags.IntVar(&cliArgs.harMaxBodySize, "har-max-body-size", cliArgs.harMaxBodySize, "Number of body bytes kept per request and response for the HAR export, 0 for full bodies (env: SNOOPER_HAR_MAX_BODY_SIZE)")
+	flags.IntVar(&cliArgs.controlQueueSize, "control-queue-size", cliArgs.controlQueueSize, "Number of outbound messages queued per WebSocket control connection (env: SNOOPER_CONTROL_QUEUE_SIZE)")
+	flags.StringVar(&cliArgs.controlQueueOverflow, "control-queue-overflow", cliArgs.controlQueueOverflow, "What to do when a control connection queue is full: drop-oldest, drop-newest or disconnect (env: SNOOPER_CONTROL_QUEUE_OVERFLOW)")
 
 	// Xatu flags
 	flags.BoolVar(&cliArgs.xatuEnabled, "xatu-enabled", cliArgs.xatuEnabled, "Enable Xatu event publishing (env: SNOOPER_XATU_ENABLED)")
 	flags.StringVar(&cliArgs.xatuName, "xatu-name", cliArgs.xatuName, "Instance name for Xatu events (env: SNOOPER_XATU_NAME)")
 	flags.StringVar(&cliArgs.xatuNetworkName, "xatu-network-name", cliArgs.xatuNetworkName, "Ethereum network name (e.g., mainnet, sepolia) (env: SNOOPER_XATU_NETWORK_NAME)")
 	flags.Uint64Var(&cliArgs.xatuNetworkID, "xatu-network-id", cliArgs.xatuNetworkID, "Ethereum network ID (env: SNOOPER_XATU_NETWORK_ID)")
+	flags.Uint64Var(&cliArgs.xatuGenesisTime, "xatu-genesis-time", cliArgs.xatuGenesisTime, "Beacon chain genesis time as unix timestamp, known for public networks (env: SNOOPER_XATU_GENESIS_TIME)")
+	flags.Uint64Var(&cliArgs.xatuSecondsPerSlot, "xatu-seconds-per-slot", cliArgs.xatuSecondsPerSlot, "Beacon chain slot duration in seconds (default 12) (env: SNOOPER_XATU_SECONDS_PER_SLOT)")
+	flags.Uint64Var(&cliArgs.xatuSlotsPerEpoch, "xatu-slots-per-epoch", cliArgs.xatuSlotsPerEpoch, "Beacon chain slots per epoch (default 32) (env: SNOOPER_XATU_SLOTS_PER_EPOCH)")
 	flags.StringSliceVar(&cliArgs.xatuOutputs, "xatu-output", cliArgs.xatuOutputs, "Xatu output sink (format: type:address, can be repeated) (env: SNOOPER_XATU_OUTPUTS)")
 	flags.StringSliceVar(&cliArgs.xatuLabels, "xatu-label", cliArgs.xatuLabels, "Xatu label (format: key=value, can be repeated) (env: SNOOPER_XATU_LABELS)")
-	flags.BoolVar(&cliArgs.xatuTLS, "xatu-tls", cliArgs.xatuTLS, "Enable TLS for xatu:// outputs (env: SNOOPER_XATU_TLS)")
+	flags.BoolVar(&cliArgs.xatuTLS, "xatu-tls", cliArgs.xatuTLS, "Enable TLS for xatu:// and kafka outputs (env: SNOOPER_XATU_TLS)")
 	flags.StringSliceVar(&cliArgs.xatuHeaders, "xatu-header", cliArgs.xatuHeaders, "Xatu output header (format: name=value, can be repeated) (env: SNOOPER_XATU_HEADERS)")
+	flags.StringVar(&cliArgs.xatuSnooperEvents, "xatu-snooper-events", cliArgs.xatuSnooperEvents, "File to append snooper events to, for calls without xatu event type (env: SNOOPER_XATU_SNOOPER_EVENTS)")
 	flags.IntVar(&cliArgs.xatuMaxQueueSize, "xatu-max-queue-size", cliArgs.xatuMaxQueueSize, "Max events to buffer before dropping (env: SNOOPER_XATU_MAX_QUEUE_SIZE)")
 	flags.IntVar(&cliArgs.xatuMaxExportBatchSize, "xatu-max-export-batch-size", cliArgs.xatuMaxExportBatchSize, "Max events per batch export (env: SNOOPER_XATU_MAX_EXPORT_BATCH_SIZE)")
 	flags.IntVar(&cliArgs.xatuWorkers, "xatu-workers", cliArgs.xatuWorkers, "Number of concurrent export workers (env: SNOOPER_XATU_WORKERS)")
@@ -251,22 +400,12 @@ func main() {
 		return
 	}
 
-	logger := logrus.New()
-	formatter := &utils.SnooperFormatter{}
-	formatter.Formatter.FullTimestamp = true
-
-	if cliArgs.nocolor {
-		formatter.DisableColors()
-	} else {
-		formatter.EnableColors()
-		formatter.Formatter.ForceColors = true
+	if cliArgs.logFormat != "text" && cliArgs.logFormat != "json" {
+		fmt.Fprintf(os.Stderr, "invalid log format: %v (expected text or json)\n", cliArgs.logFormat)
+		os.Exit(2)
 	}
 
-	logger.SetFormatter(formatter)
-
-	if cliArgs.verbose {
-		logger.SetLevel(logrus.DebugLevel)
-	}
+	logger := newLogger(cliArgs.logFormat, cliArgs.nocolor, cliArgs.verbose)
 
 	logger.WithFields(logrus.Fields{
 		"version": utils.GetBuildVersion(),
@@ -276,17 +415,27 @@ func main() {
 		return
 	}
 
-	// Get target URL from command line argument or environment variable
+	// Get target URLs from command line arguments or environment variable
 	if flags.NArg() >= 2 && flags.Arg(1) != "" {
-		cliArgs.target = flags.Arg(1)
-	} else if target := os.Getenv("SNOOPER_TARGET"); target != "" {
-		cliArgs.target = target
+		cliArgs.targets = flags.Args()[1:]
+	} else if targets := getEnvStringSlice("SNOOPER_TARGET"); len(targets) > 0 {
+		cliArgs.targets = targets
+	} else if cliArgs.mock != "" {
+		cliArgs.targets = []string{"mock:" + cliArgs.mock}
 	} else {
 		logger.Error("Target URL missing (provide as argument or set SNOOPER_TARGET env var)")
 		return
 	}
 
-	logger.Infof("target url: %v", cliArgs.target)
+	// a mock:<file> target serves all calls from the mock rules, without a real target
+	mockStandalone := false
+
+	if mockFile, ok := strings.CutPrefix(cliArgs.targets[0], "mock:"); ok {
+		cliArgs.mock = mockFile
+		mockStandalone = true
+	}
+
+	logger.Infof("target url: %v", strings.Join(cliArgs.targets, ", "))
 
 	// Build Xatu config from CLI args
 	xatuConfig, err := buildXatuConfig(&cliArgs)
@@ -296,7 +445,7 @@ func main() {
 		return
 	}
 
-	rpcSnooper, err := snooper.NewSnooper(cliArgs.target, logger, xatuConfig, cliArgs.jwtSecret)
+	rpcSnooper, err := snooper.NewSnooper(cliArgs.targets[0], logger, xatuConfig, cliArgs.jwtSecret)
 	if err != nil {
 		logger.Errorf("Failed initializing server: %v", err)
 
@@ -307,10 +456,111 @@ func main() {
 		rpcSnooper.EnableLogTruncation()
 	}
 
+	if cliArgs.decodeSSZ {
+		rpcSnooper.EnableSSZDecoding()
+	}
+
 	if cliArgs.hideBodies {
 		rpcSnooper.EnableHideBodies()
 	}
 
+	if cliArgs.logFormat == "json" {
+		rpcSnooper.EnableJSONLogs()
+	}
+
+	if len(cliArgs.targets) > 1 || cliArgs.healthCheck != "" {
+		err = rpcSnooper.ConfigureUpstreams(cliArgs.targets, &snooper.UpstreamConfig{
+			Policy:              cliArgs.upstreamPolicy,
+			HealthCheck:         cliArgs.healthCheck,
+			HealthCheckInterval: cliArgs.healthCheckInterval,
+			FailureBackoff:      cliArgs.failureBackoff,
+		})
+		if err != nil {
+			logger.Errorf("Failed configuring upstreams: %v", err)
+
+			return
+		}
+	}
+
+	if cliArgs.jwtResign || cliArgs.jwtValidate != snooper.JWTValidationOff {
+		clientSecret, err := utils.ParseJWTSecret(cliArgs.jwtClientSecret)
+		if err != nil {
+			logger.Errorf("Invalid JWT client secret: %v", err)
+
+			return
+		}
+
+		err = rpcSnooper.EnableJWTAuth(&snooper.JWTAuthConfig{
+			Resign:       cliArgs.jwtResign,
+			Validation:   cliArgs.jwtValidate,
+			ClientSecret: clientSecret,
+			MaxDrift:     cliArgs.jwtMaxDrift,
+		})
+		if err != nil {
+			logger.Errorf("Failed enabling JWT auth: %v", err)
+
+			return
+		}
+	}
+
+	if cliArgs.mirror != "" {
+		if err := rpcSnooper.EnableMirror(cliArgs.mirror, cliArgs.mirrorIgnore); err != nil {
+			logger.Errorf("Failed enabling mirror: %v", err)
+
+			return
+		}
+	}
+
+	if cliArgs.mock != "" {
+		if err := rpcSnooper.EnableMock(cliArgs.mock, mockStandalone); err != nil {
+			logger.Errorf("Failed enabling mock upstream: %v", err)
+
+			return
+		}
+	}
+
+	if cliArgs.routes != "" {
+		if err := rpcSnooper.EnableRouting(cliArgs.routes); err != nil {
+			logger.Errorf("Failed enabling routing: %v", err)
+
+			return
+		}
+	}
+
+	if cliArgs.validateEngine {
+		if err := rpcSnooper.EnableEngineValidation(); err != nil {
+			logger.Errorf("Failed enabling engine validation: %v", err)
+
+			return
+		}
+	}
+
+	if cliArgs.engineTracker && !cliArgs.noapi {
+		if err := rpcSnooper.EnableEngineTracking(cliArgs.syncingStreakLimit); err != nil {
+			logger.Errorf("Failed enabling engine tracking: %v", err)
+
+			return
+		}
+	}
+
+	if err := rpcSnooper.ConfigureControlQueue(cliArgs.controlQueueSize, cliArgs.controlQueueOverflow); err != nil {
+		logger.Errorf("Failed configuring control queue: %v", err)
+
+		return
+	}
+
+	if cliArgs.harSize > 0 && !cliArgs.noapi {
+		rpcSnooper.EnableCallHistory(cliArgs.harSize, cliArgs.harMaxBodySize)
+	}
+
+	if cliArgs.record != "" {
+		if err := rpcSnooper.EnableRecording(cliArgs.record); err != nil {
+			logger.Errorf("Failed enabling recording: %v", err)
+
+			return
+		}
+	}
+
 	// Start separate API server if api-port is specified
 	if cliArgs.apiPort > 0 {
 		err = rpcSnooper.StartAPIServer(cliArgs.apiBind, cliArgs.apiPort, cliArgs.apiAuth)
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/recording"
+	"github.com/ethpandaops/rpc-snooper/utils"
+	"github.com/spf13/pflag"
+)
+
+type ReplayArgs struct {
+	verbose   bool
+	help      bool
+	nocolor   bool
+	timing    string
+	jwtSecret string
+	timeout   time.Duration
+}
+
+// runReplay implements the `snooper replay` command and returns the exit code.
+// The exit code is 1 if any replayed response differs from the recording.
+func runReplay(args []string) int {
+	replayArgs := ReplayArgs{
+		verbose:   getEnvBool("SNOOPER_VERBOSE", false),
+		nocolor:   getEnvBool("SNOOPER_NO_COLOR", false),
+		timing:    getEnvString("SNOOPER_REPLAY_TIMING", "fast"),
+		jwtSecret: getEnvString("SNOOPER_JWT_SECRET", ""),
+		timeout:   getEnvDuration("SNOOPER_REPLAY_TIMEOUT", 60*time.Second),
+	}
+
+	flags := pflag.NewFlagSet("replay", pflag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: snooper replay [options] <recording.jsonl> <target>\n\n")
+		flags.PrintDefaults()
+	}
+	flags.BoolVarP(&replayArgs.verbose, "verbose", "v", replayArgs.verbose, "Run with verbose output, logging matching calls too (env: SNOOPER_VERBOSE)")
+	flags.BoolVarP(&replayArgs.help, "help", "h", replayArgs.help, "Print replay usage")
+	flags.BoolVar(&replayArgs.nocolor, "no-color", replayArgs.nocolor, "Do not use terminal colors in output (env: SNOOPER_NO_COLOR)")
+	flags.StringVar(&replayArgs.timing, "timing", replayArgs.timing, "Replay timing: 'original' keeps the recorded spacing, 'fast' sends calls back to back (env: SNOOPER_REPLAY_TIMING)")
+	flags.StringVar(&replayArgs.jwtSecret, "jwt-secret", replayArgs.jwtSecret, "JWT secret to re-sign Engine API calls with - file path or hex-encoded value (env: SNOOPER_JWT_SECRET)")
+	flags.DurationVar(&replayArgs.timeout, "timeout", replayArgs.timeout, "Timeout for each replayed call (env: SNOOPER_REPLAY_TIMEOUT)")
+
+	//nolint:errcheck // ignore
+	flags.Parse(args)
+
+	if replayArgs.help || flags.NArg() != 2 {
+		flags.Usage()
+
+		if replayArgs.help {
+			return 0
+		}
+
+		return 2
+	}
+
+	logger := newLogger("text", replayArgs.nocolor, replayArgs.verbose)
+
+	if replayArgs.timing != "original" && replayArgs.timing != "fast" {
+		logger.Errorf("Invalid replay timing %q (must be 'original' or 'fast')", replayArgs.timing)
+		return 2
+	}
+
+	targetURL, err := url.Parse(flags.Arg(1))
+	if err != nil {
+		logger.Errorf("Invalid target url: %v", err)
+		return 2
+	}
+
+	jwtSecret, err := utils.ParseJWTSecret(replayArgs.jwtSecret)
+	if err != nil {
+		logger.Errorf("Invalid JWT secret: %v", err)
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		logger.Errorf("Failed opening recording: %v", err)
+		return 2
+	}
+	defer file.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	replayer := recording.NewReplayer(&recording.ReplayConfig{
+		Target:     targetURL,
+		KeepTiming: replayArgs.timing == "original",
+		JWTSecret:  jwtSecret,
+		Timeout:    replayArgs.timeout,
+	}, logger)
+
+	logger.Infof("replaying %v against %v", flags.Arg(0), targetURL)
+
+	report, err := replayer.Replay(ctx, recording.NewReader(file))
+	if err != nil {
+		logger.Errorf("Replay aborted: %v", err)
+	}
+
+	logger.Infof("replayed %v calls: %v matched, %v differed, %v failed", report.Total, report.Matched, report.Differed, report.Failed)
+
+	if err != nil || report.Differed > 0 || report.Failed > 0 {
+		return 1
+	}
+
+	return 0
+}
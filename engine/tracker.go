@@ -0,0 +1,410 @@
+// Package engine follows the forkchoice and payload lifecycle of an Engine API
+// session across calls and flags anomalies in it.
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Anomaly types.
+const (
+	AnomalyUnknownPayloadID   = "unknown_payload_id"
+	AnomalyFinalizedBackwards = "finalized_backwards"
+	AnomalyInvalidHead        = "invalid_head"
+	AnomalySyncingStreak      = "syncing_streak"
+)
+
+// Payload statuses returned by engine_newPayload* and engine_forkchoiceUpdated*.
+const (
+	StatusValid            = "VALID"
+	StatusInvalid          = "INVALID"
+	StatusSyncing          = "SYNCING"
+	StatusAccepted         = "ACCEPTED"
+	StatusInvalidBlockHash = "INVALID_BLOCK_HASH"
+)
+
+const (
+	maxTrackedBlocks    = 1024
+	maxTrackedPayloads  = 64
+	maxFinalizedHistory = 64
+	maxAnomalies        = 100
+
+	zeroHash = "0x0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+// Call is a completed Engine API call.
+type Call struct {
+	ID     uint64
+	Method string
+	Params []any
+	Result any
+	// Failed is set for calls answered with a JSON-RPC error.
+	Failed bool
+	Time   time.Time
+}
+
+// ForkchoiceState is the forkchoice state of the last successful forkchoice update.
+type ForkchoiceState struct {
+	HeadBlockHash      string    `json:"head_block_hash"`
+	SafeBlockHash      string    `json:"safe_block_hash"`
+	FinalizedBlockHash string    `json:"finalized_block_hash"`
+	Status             string    `json:"status"`
+	CallID             uint64    `json:"call_id"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// Payload is a payload build started by a forkchoice update with payload attributes.
+type Payload struct {
+	PayloadID     string     `json:"payload_id"`
+	HeadBlockHash string     `json:"head_block_hash"`
+	Timestamp     string     `json:"timestamp,omitempty"`
+	RequestedAt   time.Time  `json:"requested_at"`
+	RetrievedAt   *time.Time `json:"retrieved_at,omitempty"`
+	BlockHash     string     `json:"block_hash,omitempty"`
+}
+
+// Anomaly is an unexpected step in the Engine API session.
+type Anomaly struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	CallID  uint64    `json:"call_id"`
+	Method  string    `json:"method"`
+	Message string    `json:"message"`
+}
+
+// State is a snapshot of the tracked session.
+type State struct {
+	Forkchoice    *ForkchoiceState `json:"forkchoice"`
+	SyncingStreak int              `json:"syncing_streak"`
+	Payloads      []*Payload       `json:"payloads"`
+	Anomalies     []*Anomaly       `json:"anomalies"`
+}
+
+// block is what is known about a block from the calls seen so far.
+type block struct {
+	number    uint64
+	hasNumber bool
+	status    string
+}
+
+// Tracker follows forkchoice updates, payload builds and payload verdicts of an
+// Engine API session. It is safe for concurrent use.
+type Tracker struct {
+	// SyncingStreakThreshold is the number of consecutive SYNCING verdicts that is
+	// reported as an anomaly.
+	SyncingStreakThreshold int
+
+	logger logrus.FieldLogger
+
+	mu            sync.Mutex
+	forkchoice    *ForkchoiceState
+	blocks        map[string]*block
+	blockOrder    []string
+	payloads      map[string]*Payload
+	payloadOrder  []string
+	finalized     []string
+	syncingStreak int
+	anomalies     []*Anomaly
+}
+
+// NewTracker creates a new Tracker.
+func NewTracker(logger logrus.FieldLogger) *Tracker {
+	return &Tracker{
+		SyncingStreakThreshold: 32,
+
+		logger:   logger,
+		blocks:   make(map[string]*block),
+		payloads: make(map[string]*Payload),
+	}
+}
+
+// HandleCall updates the session with a completed call. Calls of methods that are not
+// part of the payload lifecycle are ignored.
+func (t *Tracker) HandleCall(call *Call) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(call.Method, "engine_forkchoiceUpdated"):
+		t.handleForkchoiceUpdated(call)
+	case strings.HasPrefix(call.Method, "engine_getPayloadBodies"):
+		return
+	case strings.HasPrefix(call.Method, "engine_getPayload"):
+		t.handleGetPayload(call)
+	case strings.HasPrefix(call.Method, "engine_newPayload"):
+		t.handleNewPayload(call)
+	}
+}
+
+// GetState returns a snapshot of the tracked session.
+func (t *Tracker) GetState() *State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := &State{
+		SyncingStreak: t.syncingStreak,
+		Payloads:      make([]*Payload, 0, len(t.payloads)),
+		Anomalies:     make([]*Anomaly, len(t.anomalies)),
+	}
+
+	if t.forkchoice != nil {
+		forkchoice := *t.forkchoice
+		state.Forkchoice = &forkchoice
+	}
+
+	for _, payloadID := range t.payloadOrder {
+		payload := *t.payloads[payloadID]
+		state.Payloads = append(state.Payloads, &payload)
+	}
+
+	sort.SliceStable(state.Payloads, func(i, j int) bool {
+		return state.Payloads[i].RequestedAt.Before(state.Payloads[j].RequestedAt)
+	})
+
+	copy(state.Anomalies, t.anomalies)
+
+	return state
+}
+
+func (t *Tracker) handleForkchoiceUpdated(call *Call) {
+	forkchoiceState := getObject(getParam(call.Params, 0))
+	headHash := getString(forkchoiceState, "headBlockHash")
+
+	if headHash == "" {
+		return
+	}
+
+	if head := t.blocks[headHash]; head != nil && isInvalidStatus(head.status) {
+		t.addAnomaly(call, AnomalyInvalidHead, fmt.Sprintf("head set to block %v which was reported %v", headHash, head.status))
+	}
+
+	if call.Failed {
+		return
+	}
+
+	result := getObject(call.Result)
+	status := getString(getObject(result["payloadStatus"]), "status")
+	t.updateSyncingStreak(call, status)
+
+	if isInvalidStatus(status) {
+		t.getBlock(headHash).status = status
+	}
+
+	finalizedHash := getString(forkchoiceState, "finalizedBlockHash")
+	t.checkFinalized(call, finalizedHash)
+
+	t.forkchoice = &ForkchoiceState{
+		HeadBlockHash:      headHash,
+		SafeBlockHash:      getString(forkchoiceState, "safeBlockHash"),
+		FinalizedBlockHash: finalizedHash,
+		Status:             status,
+		CallID:             call.ID,
+		UpdatedAt:          call.Time,
+	}
+
+	if payloadID := getString(result, "payloadId"); payloadID != "" {
+		t.addPayload(&Payload{
+			PayloadID:     payloadID,
+			HeadBlockHash: headHash,
+			Timestamp:     getString(getObject(getParam(call.Params, 1)), "timestamp"),
+			RequestedAt:   call.Time,
+		})
+	}
+}
+
+// checkFinalized flags a finalized block hash that moves back to zero, to a lower
+// block number, or to a block that was finalized before.
+func (t *Tracker) checkFinalized(call *Call, finalizedHash string) {
+	if t.forkchoice == nil || finalizedHash == "" || finalizedHash == t.forkchoice.FinalizedBlockHash {
+		return
+	}
+
+	previousHash := t.forkchoice.FinalizedBlockHash
+	if previousHash == zeroHash {
+		return
+	}
+
+	previous, current := t.blocks[previousHash], t.blocks[finalizedHash]
+
+	switch {
+	case finalizedHash == zeroHash:
+		t.addAnomaly(call, AnomalyFinalizedBackwards, fmt.Sprintf("finalized block reset from %v to zero hash", previousHash))
+	case previous != nil && previous.hasNumber && current != nil && current.hasNumber && current.number < previous.number:
+		t.addAnomaly(call, AnomalyFinalizedBackwards, fmt.Sprintf("finalized block moved back from #%v (%v) to #%v (%v)", previous.number, previousHash, current.number, finalizedHash))
+	case containsString(t.finalized, finalizedHash):
+		t.addAnomaly(call, AnomalyFinalizedBackwards, fmt.Sprintf("finalized block moved back from %v to previously finalized %v", previousHash, finalizedHash))
+	}
+
+	t.finalized = append(t.finalized, previousHash)
+	if len(t.finalized) > maxFinalizedHistory {
+		t.finalized = t.finalized[1:]
+	}
+}
+
+func (t *Tracker) handleGetPayload(call *Call) {
+	payloadID, _ := getParam(call.Params, 0).(string)
+	if payloadID == "" {
+		return
+	}
+
+	// payloads requested before the first forkchoice update seen cannot be known
+	payload := t.payloads[payloadID]
+	if payload == nil && t.forkchoice != nil {
+		t.addAnomaly(call, AnomalyUnknownPayloadID, fmt.Sprintf("payload %v was not returned by any forkchoice update", payloadID))
+	}
+
+	if payload == nil || call.Failed {
+		return
+	}
+
+	// engine_getPayloadV1 returns the execution payload, later versions wrap it
+	result := getObject(call.Result)
+	if executionPayload := getObject(result["executionPayload"]); executionPayload != nil {
+		result = executionPayload
+	}
+
+	retrievedAt := call.Time
+	payload.RetrievedAt = &retrievedAt
+	payload.BlockHash = getString(result, "blockHash")
+
+	if payload.BlockHash != "" {
+		t.setBlockNumber(payload.BlockHash, getString(result, "blockNumber"))
+	}
+}
+
+func (t *Tracker) handleNewPayload(call *Call) {
+	executionPayload := getObject(getParam(call.Params, 0))
+	blockHash := getString(executionPayload, "blockHash")
+
+	if blockHash == "" {
+		return
+	}
+
+	t.setBlockNumber(blockHash, getString(executionPayload, "blockNumber"))
+
+	if call.Failed {
+		return
+	}
+
+	status := getString(getObject(call.Result), "status")
+	t.updateSyncingStreak(call, status)
+
+	if status != "" {
+		t.getBlock(blockHash).status = status
+	}
+}
+
+func (t *Tracker) updateSyncingStreak(call *Call, status string) {
+	if status != StatusSyncing {
+		t.syncingStreak = 0
+		return
+	}
+
+	t.syncingStreak++
+
+	if t.SyncingStreakThreshold > 0 && t.syncingStreak == t.SyncingStreakThreshold {
+		t.addAnomaly(call, AnomalySyncingStreak, fmt.Sprintf("execution client answered SYNCING %v times in a row", t.syncingStreak))
+	}
+}
+
+func (t *Tracker) addPayload(payload *Payload) {
+	if _, exists := t.payloads[payload.PayloadID]; !exists {
+		t.payloadOrder = append(t.payloadOrder, payload.PayloadID)
+	}
+
+	t.payloads[payload.PayloadID] = payload
+
+	if len(t.payloadOrder) > maxTrackedPayloads {
+		delete(t.payloads, t.payloadOrder[0])
+		t.payloadOrder = t.payloadOrder[1:]
+	}
+}
+
+// getBlock returns the tracked block with the given hash, adding it if needed.
+func (t *Tracker) getBlock(blockHash string) *block {
+	if b := t.blocks[blockHash]; b != nil {
+		return b
+	}
+
+	b := &block{}
+	t.blocks[blockHash] = b
+	t.blockOrder = append(t.blockOrder, blockHash)
+
+	if len(t.blockOrder) > maxTrackedBlocks {
+		delete(t.blocks, t.blockOrder[0])
+		t.blockOrder = t.blockOrder[1:]
+	}
+
+	return b
+}
+
+func (t *Tracker) setBlockNumber(blockHash, blockNumber string) {
+	number, err := strconv.ParseUint(strings.TrimPrefix(blockNumber, "0x"), 16, 64)
+	if err != nil {
+		return
+	}
+
+	b := t.getBlock(blockHash)
+	b.number = number
+	b.hasNumber = true
+}
+
+func (t *Tracker) addAnomaly(call *Call, anomalyType, message string) {
+	anomaly := &Anomaly{
+		Type:    anomalyType,
+		Time:    call.Time,
+		CallID:  call.ID,
+		Method:  call.Method,
+		Message: message,
+	}
+
+	t.anomalies = append(t.anomalies, anomaly)
+	if len(t.anomalies) > maxAnomalies {
+		t.anomalies = t.anomalies[1:]
+	}
+
+	t.logger.WithFields(logrus.Fields{
+		"callidx": call.ID,
+		"method":  call.Method,
+		"anomaly": anomalyType,
+	}).Warnf("ENGINE #%v: %v", call.ID, message)
+}
+
+func isInvalidStatus(status string) bool {
+	return status == StatusInvalid || status == StatusInvalidBlockHash
+}
+
+func getParam(params []any, idx int) any {
+	if idx >= len(params) {
+		return nil
+	}
+
+	return params[idx]
+}
+
+func getObject(value any) map[string]any {
+	obj, _ := value.(map[string]any)
+	return obj
+}
+
+func getString(obj map[string]any, key string) string {
+	value, _ := obj[key].(string)
+	return value
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
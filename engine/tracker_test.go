@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testHash(b byte) string {
+	return "0x" + strings.Repeat(fmt.Sprintf("%02x", b), 32)
+}
+
+func newTestTracker() *Tracker {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	return NewTracker(logger)
+}
+
+type testSession struct {
+	tracker *Tracker
+	callID  uint64
+	now     time.Time
+}
+
+func (s *testSession) call(method string, params []any, result any) {
+	s.callID++
+	s.now = s.now.Add(time.Second)
+
+	s.tracker.HandleCall(&Call{
+		ID:     s.callID,
+		Method: method,
+		Params: params,
+		Result: result,
+		Time:   s.now,
+	})
+}
+
+func (s *testSession) forkchoiceUpdated(head, safe, finalized, status, payloadID string, attributes map[string]any) {
+	params := []any{map[string]any{
+		"headBlockHash":      head,
+		"safeBlockHash":      safe,
+		"finalizedBlockHash": finalized,
+	}}
+	if attributes != nil {
+		params = append(params, attributes)
+	}
+
+	result := map[string]any{"payloadStatus": map[string]any{"status": status}, "payloadId": nil}
+	if payloadID != "" {
+		result["payloadId"] = payloadID
+	}
+
+	s.call("engine_forkchoiceUpdatedV3", params, result)
+}
+
+func (s *testSession) newPayload(blockHash, blockNumber, status string) {
+	s.call("engine_newPayloadV4", []any{map[string]any{"blockHash": blockHash, "blockNumber": blockNumber}}, map[string]any{"status": status})
+}
+
+func getAnomalyTypes(state *State) []string {
+	types := make([]string, len(state.Anomalies))
+	for idx, anomaly := range state.Anomalies {
+		types[idx] = anomaly.Type
+	}
+
+	return types
+}
+
+func TestTrackerPayloadLifecycle(t *testing.T) {
+	session := &testSession{tracker: newTestTracker(), now: time.Unix(1700000000, 0)}
+
+	session.newPayload(testHash(1), "0x1", StatusValid)
+	session.forkchoiceUpdated(testHash(1), testHash(1), zeroHash, StatusValid, "0x0000000000000001", map[string]any{"timestamp": "0x6553f100"})
+	session.call("engine_getPayloadV4", []any{"0x0000000000000001"}, map[string]any{
+		"executionPayload": map[string]any{"blockHash": testHash(2), "blockNumber": "0x2"},
+	})
+
+	state := session.tracker.GetState()
+	require.NotNil(t, state.Forkchoice)
+	assert.Equal(t, testHash(1), state.Forkchoice.HeadBlockHash)
+	assert.Equal(t, zeroHash, state.Forkchoice.FinalizedBlockHash)
+	assert.Equal(t, StatusValid, state.Forkchoice.Status)
+	assert.Equal(t, uint64(2), state.Forkchoice.CallID)
+
+	require.Len(t, state.Payloads, 1)
+	assert.Equal(t, "0x0000000000000001", state.Payloads[0].PayloadID)
+	assert.Equal(t, testHash(1), state.Payloads[0].HeadBlockHash)
+	assert.Equal(t, "0x6553f100", state.Payloads[0].Timestamp)
+	assert.Equal(t, testHash(2), state.Payloads[0].BlockHash)
+	assert.NotNil(t, state.Payloads[0].RetrievedAt)
+	assert.Empty(t, state.Anomalies)
+
+	// unknown payload id
+	session.call("engine_getPayloadV4", []any{"0x00000000000000ff"}, nil)
+
+	state = session.tracker.GetState()
+	assert.Equal(t, []string{AnomalyUnknownPayloadID}, getAnomalyTypes(state))
+	assert.Equal(t, uint64(4), state.Anomalies[0].CallID)
+	assert.Equal(t, "engine_getPayloadV4", state.Anomalies[0].Method)
+}
+
+func TestTrackerUnknownPayloadBeforeFirstForkchoiceUpdate(t *testing.T) {
+	session := &testSession{tracker: newTestTracker()}
+
+	session.call("engine_getPayloadV4", []any{"0x00000000000000ff"}, nil)
+
+	assert.Empty(t, session.tracker.GetState().Anomalies)
+}
+
+func TestTrackerFinalizedBackwards(t *testing.T) {
+	session := &testSession{tracker: newTestTracker()}
+
+	session.newPayload(testHash(1), "0x10", StatusValid)
+	session.newPayload(testHash(2), "0x20", StatusValid)
+	session.newPayload(testHash(3), "0x30", StatusValid)
+
+	session.forkchoiceUpdated(testHash(3), testHash(2), zeroHash, StatusValid, "", nil)
+	session.forkchoiceUpdated(testHash(3), testHash(2), testHash(2), StatusValid, "", nil)
+	session.forkchoiceUpdated(testHash(3), testHash(2), testHash(1), StatusValid, "", nil)
+	session.forkchoiceUpdated(testHash(3), testHash(3), testHash(3), StatusValid, "", nil)
+	session.forkchoiceUpdated(testHash(3), testHash(3), testHash(4), StatusValid, "", nil)
+	session.forkchoiceUpdated(testHash(3), testHash(3), testHash(3), StatusValid, "", nil)
+	session.forkchoiceUpdated(testHash(3), testHash(3), zeroHash, StatusValid, "", nil)
+
+	state := session.tracker.GetState()
+	assert.Equal(t, []string{AnomalyFinalizedBackwards, AnomalyFinalizedBackwards, AnomalyFinalizedBackwards}, getAnomalyTypes(state))
+	assert.Contains(t, state.Anomalies[0].Message, "#32")
+	assert.Contains(t, state.Anomalies[1].Message, "previously finalized")
+	assert.Contains(t, state.Anomalies[2].Message, "zero hash")
+}
+
+func TestTrackerInvalidHead(t *testing.T) {
+	session := &testSession{tracker: newTestTracker()}
+
+	session.newPayload(testHash(1), "0x1", StatusValid)
+	session.newPayload(testHash(2), "0x2", StatusInvalid)
+	session.forkchoiceUpdated(testHash(1), testHash(1), zeroHash, StatusValid, "", nil)
+	session.forkchoiceUpdated(testHash(2), testHash(1), zeroHash, StatusInvalid, "", nil)
+
+	// the execution client rejected the head, so setting it again is flagged too
+	session.forkchoiceUpdated(testHash(3), testHash(1), zeroHash, StatusInvalid, "", nil)
+	session.forkchoiceUpdated(testHash(3), testHash(1), zeroHash, StatusInvalid, "", nil)
+
+	state := session.tracker.GetState()
+	assert.Equal(t, []string{AnomalyInvalidHead, AnomalyInvalidHead}, getAnomalyTypes(state))
+	assert.Equal(t, uint64(4), state.Anomalies[0].CallID)
+	assert.Equal(t, uint64(6), state.Anomalies[1].CallID)
+}
+
+func TestTrackerSyncingStreak(t *testing.T) {
+	session := &testSession{tracker: newTestTracker()}
+	session.tracker.SyncingStreakThreshold = 3
+
+	session.newPayload(testHash(1), "0x1", StatusSyncing)
+	session.forkchoiceUpdated(testHash(1), zeroHash, zeroHash, StatusSyncing, "", nil)
+	session.newPayload(testHash(2), "0x2", StatusValid)
+	session.newPayload(testHash(3), "0x3", StatusSyncing)
+	session.newPayload(testHash(4), "0x4", StatusSyncing)
+
+	assert.Empty(t, session.tracker.GetState().Anomalies)
+
+	session.newPayload(testHash(5), "0x5", StatusSyncing)
+	session.newPayload(testHash(6), "0x6", StatusSyncing)
+
+	state := session.tracker.GetState()
+	assert.Equal(t, []string{AnomalySyncingStreak}, getAnomalyTypes(state))
+	assert.Equal(t, 4, state.SyncingStreak)
+}
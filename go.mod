@@ -3,7 +3,9 @@ module github.com/ethpandaops/rpc-snooper
 go 1.25.1
 
 require (
+	github.com/IBM/sarama v1.45.2
 	github.com/andybalholm/brotli v1.2.0
+	github.com/attestantio/go-eth2-client v0.27.1
 	github.com/creasty/defaults v1.8.0
 	github.com/ethpandaops/ethcore v0.0.0-20260112064422-e7fe02956738
 	github.com/ethpandaops/xatu v1.8.1
@@ -22,8 +24,6 @@ require (
 )
 
 require (
-	github.com/IBM/sarama v1.45.2 // indirect
-	github.com/attestantio/go-eth2-client v0.27.1 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cenkalti/backoff/v5 v5.0.3 // indirect
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
@@ -20,6 +20,7 @@ type MetricsEntry struct { //nolint:revive // ignore
 	Status        string
 	URI           string
 	JRPCMethod    string
+	Route         string
 	BytesSent     int64
 	BytesReceived int64
 	Duration      float64
@@ -34,6 +35,7 @@ var (
 		"status",
 		"uri",
 		"jrpc_method",
+		"route",
 	}
 
 	requestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
@@ -55,6 +57,26 @@ var (
 		Name: "ngx_request_duration_seconds",
 		Help: "request serving time in seconds",
 	}, tagNames)
+
+	mirrorResultCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "snooper_mirror_results_total",
+		Help: "mirrored call comparisons by result (match, mismatch, error)",
+	}, []string{"jrpc_method", "result"})
+
+	schemaViolationCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "snooper_schema_violations_total",
+		Help: "Engine API schema violations by method and direction (request, response)",
+	}, []string{"jrpc_method", "direction"})
+
+	controlQueueDepthGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "snooper_control_queue_depth",
+		Help: "outbound messages queued for a WebSocket control connection",
+	}, []string{"connection"})
+
+	controlDroppedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "snooper_control_dropped_events_total",
+		Help: "outbound messages dropped because the queue of a WebSocket control connection was full",
+	}, []string{"connection"})
 )
 
 func init() {
@@ -63,6 +85,10 @@ func init() {
 		requestsSizeCounter,
 		responseSizeCounter,
 		requestDurationHistogramVec,
+		mirrorResultCounter,
+		schemaViolationCounter,
+		controlQueueDepthGauge,
+		controlDroppedCounter,
 	)
 }
 
@@ -75,6 +101,7 @@ func PrometheusMetricsRegister(l *MetricsEntry) {
 		l.Status,
 		l.URI,
 		l.JRPCMethod,
+		l.Route,
 	}
 
 	requestCounter.WithLabelValues(tags...).Inc()
@@ -83,6 +110,33 @@ func PrometheusMetricsRegister(l *MetricsEntry) {
 	requestDurationHistogramVec.WithLabelValues(tags...).Observe(l.Duration)
 }
 
+// MirrorResultRegister counts the comparison result of a mirrored call.
+func MirrorResultRegister(jrpcMethod, result string) {
+	mirrorResultCounter.WithLabelValues(jrpcMethod, result).Inc()
+}
+
+// SchemaViolationRegister counts the schema violations found in a call.
+func SchemaViolationRegister(jrpcMethod, direction string, count int) {
+	schemaViolationCounter.WithLabelValues(jrpcMethod, direction).Add(float64(count))
+}
+
+// ControlQueueDepthRegister sets the outbound queue depth of a control connection.
+func ControlQueueDepthRegister(connection string, depth int) {
+	controlQueueDepthGauge.WithLabelValues(connection).Set(float64(depth))
+}
+
+// ControlDroppedEventRegister counts a message dropped from the outbound queue of a
+// control connection.
+func ControlDroppedEventRegister(connection string) {
+	controlDroppedCounter.WithLabelValues(connection).Inc()
+}
+
+// ControlConnectionClosed removes the metrics of a closed control connection.
+func ControlConnectionClosed(connection string) {
+	controlQueueDepthGauge.DeleteLabelValues(connection)
+	controlDroppedCounter.DeleteLabelValues(connection)
+}
+
 func PrometheusListener(listen string) {
 	r := http.NewServeMux()
 	r.Handle("/metrics", promhttp.Handler())
@@ -0,0 +1,72 @@
+package builtin
+
+import (
+	"strings"
+
+	"github.com/ethpandaops/rpc-snooper/engine"
+	"github.com/ethpandaops/rpc-snooper/types"
+)
+
+// EngineTrackerModule implements types.Module for following the Engine API payload
+// lifecycle with an engine.Tracker.
+type EngineTrackerModule struct {
+	id      uint64
+	tracker *engine.Tracker
+}
+
+// NewEngineTrackerModule creates a new EngineTrackerModule.
+func NewEngineTrackerModule(id uint64, tracker *engine.Tracker) *EngineTrackerModule {
+	return &EngineTrackerModule{
+		id:      id,
+		tracker: tracker,
+	}
+}
+
+// ID returns the module ID.
+func (m *EngineTrackerModule) ID() uint64 {
+	return m.id
+}
+
+// OnRequest keeps the method and params of engine_* calls for the response.
+func (m *EngineTrackerModule) OnRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
+	method := extractMethod(ctx.Body)
+	if !strings.HasPrefix(method, "engine_") {
+		return ctx, nil
+	}
+
+	ctx.CallCtx.SetData(m.id, "engine_call", &engine.Call{
+		ID:     ctx.CallCtx.ID(),
+		Method: method,
+		Params: extractParams(ctx.Body),
+	})
+
+	return ctx, nil
+}
+
+// OnResponse passes the completed call to the tracker.
+func (m *EngineTrackerModule) OnResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	call, ok := ctx.CallCtx.GetData(m.id, "engine_call").(*engine.Call)
+	if !ok {
+		return ctx, nil
+	}
+
+	_, isObject := ctx.Body.(map[string]any)
+
+	call.Result = extractResult(ctx.Body)
+	call.Failed = !isObject || extractRPCError(ctx.Body) != nil
+	call.Time = ctx.Timestamp
+
+	m.tracker.HandleCall(call)
+
+	return ctx, nil
+}
+
+// Configure is a no-op for EngineTrackerModule.
+func (m *EngineTrackerModule) Configure(_ map[string]any) error {
+	return nil
+}
+
+// Close is a no-op for EngineTrackerModule.
+func (m *EngineTrackerModule) Close() error {
+	return nil
+}
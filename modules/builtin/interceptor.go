@@ -0,0 +1,289 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/modules/protocol"
+	"github.com/ethpandaops/rpc-snooper/types"
+)
+
+const defaultInterceptTimeout = 10 * time.Second
+
+// Intercept verdict actions.
+const (
+	InterceptActionForward = "forward"
+	InterceptActionModify  = "modify"
+	InterceptActionRespond = "respond"
+	InterceptActionDrop    = "drop"
+)
+
+// interceptor holds the shared state of the request and response interceptors.
+type interceptor struct {
+	id      uint64
+	connMgr types.ConnectionManager
+	timeout time.Duration
+}
+
+func (ic *interceptor) ID() uint64 {
+	return ic.id
+}
+
+func (ic *interceptor) Configure(config map[string]interface{}) error {
+	if timeoutMs, ok := config["timeout_ms"].(float64); ok {
+		if timeoutMs <= 0 {
+			return fmt.Errorf("invalid timeout_ms: %v", timeoutMs)
+		}
+
+		ic.timeout = time.Duration(timeoutMs) * time.Millisecond
+	}
+
+	return nil
+}
+
+func (ic *interceptor) Close() error {
+	return nil
+}
+
+// awaitVerdict sends the intercepted call to the control client and blocks until it
+// answers, the timeout expires or the proxied call is cancelled.
+func (ic *interceptor) awaitVerdict(callCtx types.ProxyCallContext, method string, event *protocol.InterceptEvent, body []byte) (*protocol.InterceptVerdict, []byte, error) {
+	ctx, cancel := context.WithTimeout(callCtx.Context(), ic.timeout)
+	defer cancel()
+
+	msg := &protocol.WSMessage{
+		ModuleID:  ic.id,
+		Method:    method,
+		Data:      event,
+		Timestamp: time.Now().UnixNano(),
+	}
+
+	if body == nil {
+		body = []byte{}
+	}
+
+	response, err := ic.connMgr.SendRequest(ctx, msg, body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get intercept verdict: %w", err)
+	}
+
+	if response.Error != nil {
+		return nil, nil, fmt.Errorf("intercept rejected by client: %s", *response.Error)
+	}
+
+	verdict := &protocol.InterceptVerdict{}
+
+	dataBytes, err := json.Marshal(response.Data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid intercept verdict: %w", err)
+	}
+
+	if err := json.Unmarshal(dataBytes, verdict); err != nil {
+		return nil, nil, fmt.Errorf("invalid intercept verdict: %w", err)
+	}
+
+	verdictBody := []byte(verdict.Body)
+	if response.BinaryData != nil {
+		verdictBody = response.BinaryData
+	}
+
+	return verdict, verdictBody, nil
+}
+
+// RequestInterceptor holds requests until the control client decides whether to
+// forward them unchanged, forward a modified copy, answer them or drop them.
+type RequestInterceptor struct {
+	interceptor
+}
+
+func NewRequestInterceptor(id uint64, connMgr types.ConnectionManager) *RequestInterceptor {
+	return &RequestInterceptor{
+		interceptor: interceptor{
+			id:      id,
+			connMgr: connMgr,
+			timeout: defaultInterceptTimeout,
+		},
+	}
+}
+
+func (ri *RequestInterceptor) OnRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
+	return ctx, nil
+}
+
+func (ri *RequestInterceptor) OnResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	return ctx, nil
+}
+
+func (ri *RequestInterceptor) InterceptRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
+	event := &protocol.InterceptEvent{
+		ModuleID:    ri.id,
+		HookType:    "request",
+		RequestID:   ctx.CallCtx.ID(),
+		Method:      ctx.Method,
+		Headers:     ctx.Headers,
+		ContentType: ctx.ContentType,
+		Upstream:    ctx.CallCtx.Upstream(),
+	}
+
+	if ctx.URL != nil {
+		event.URL = ctx.URL.String()
+	}
+
+	verdict, body, err := ri.awaitVerdict(ctx.CallCtx, "intercept_request", event, ctx.BodyBytes)
+	if err != nil {
+		return ctx, err
+	}
+
+	switch verdict.Action {
+	case "", InterceptActionForward:
+		return ctx, nil
+	case InterceptActionModify:
+		newCtx := *ctx
+		newCtx.Headers = applyHeaders(ctx.Headers.Clone(), verdict.Headers)
+
+		if len(body) > 0 {
+			newCtx.BodyBytes = body
+			newCtx.Body = decodeBody(body)
+		}
+
+		return &newCtx, nil
+	case InterceptActionRespond:
+		statusCode := verdict.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+
+		headers := applyHeaders(http.Header{}, verdict.Headers)
+		if headers.Get("Content-Type") == "" && json.Valid(body) {
+			headers.Set("Content-Type", "application/json")
+		}
+
+		newCtx := *ctx
+		newCtx.Response = &types.SyntheticResponse{
+			StatusCode: statusCode,
+			Headers:    headers,
+			Body:       body,
+		}
+
+		return &newCtx, nil
+	case InterceptActionDrop:
+		newCtx := *ctx
+		newCtx.Drop = true
+
+		return &newCtx, nil
+	default:
+		return ctx, fmt.Errorf("unknown intercept action: %s", verdict.Action)
+	}
+}
+
+func (ri *RequestInterceptor) InterceptResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	return ctx, nil
+}
+
+// ResponseInterceptor holds responses until the control client decides whether to
+// return them unchanged, return a modified copy, replace them or drop them.
+type ResponseInterceptor struct {
+	interceptor
+}
+
+func NewResponseInterceptor(id uint64, connMgr types.ConnectionManager) *ResponseInterceptor {
+	return &ResponseInterceptor{
+		interceptor: interceptor{
+			id:      id,
+			connMgr: connMgr,
+			timeout: defaultInterceptTimeout,
+		},
+	}
+}
+
+func (ri *ResponseInterceptor) OnRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
+	return ctx, nil
+}
+
+func (ri *ResponseInterceptor) OnResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	return ctx, nil
+}
+
+func (ri *ResponseInterceptor) InterceptRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
+	return ctx, nil
+}
+
+func (ri *ResponseInterceptor) InterceptResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	event := &protocol.InterceptEvent{
+		ModuleID:    ri.id,
+		HookType:    "response",
+		RequestID:   ctx.CallCtx.ID(),
+		StatusCode:  ctx.StatusCode,
+		Headers:     ctx.Headers,
+		ContentType: ctx.ContentType,
+		Upstream:    ctx.CallCtx.Upstream(),
+	}
+
+	verdict, body, err := ri.awaitVerdict(ctx.CallCtx, "intercept_response", event, ctx.BodyBytes)
+	if err != nil {
+		return ctx, err
+	}
+
+	switch verdict.Action {
+	case "", InterceptActionForward:
+		return ctx, nil
+	case InterceptActionModify, InterceptActionRespond:
+		newCtx := *ctx
+
+		if verdict.Action == InterceptActionRespond {
+			newCtx.Headers = applyHeaders(http.Header{}, verdict.Headers)
+			newCtx.BodyBytes = body
+			newCtx.Body = decodeBody(body)
+		} else {
+			newCtx.Headers = applyHeaders(ctx.Headers.Clone(), verdict.Headers)
+
+			if len(body) > 0 {
+				newCtx.BodyBytes = body
+				newCtx.Body = decodeBody(body)
+			}
+		}
+
+		if verdict.StatusCode != 0 {
+			newCtx.StatusCode = verdict.StatusCode
+		}
+
+		return &newCtx, nil
+	case InterceptActionDrop:
+		newCtx := *ctx
+		newCtx.Drop = true
+
+		return &newCtx, nil
+	default:
+		return ctx, fmt.Errorf("unknown intercept action: %s", verdict.Action)
+	}
+}
+
+// applyHeaders sets the given header values, removing headers with an empty value.
+func applyHeaders(headers http.Header, values map[string]string) http.Header {
+	if headers == nil {
+		headers = http.Header{}
+	}
+
+	for name, value := range values {
+		if value == "" {
+			headers.Del(name)
+		} else {
+			headers.Set(name, value)
+		}
+	}
+
+	return headers
+}
+
+// decodeBody returns the parsed JSON body, or the raw bytes if it isn't valid JSON.
+func decodeBody(body []byte) any {
+	var parsed any
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return body
+	}
+
+	return parsed
+}
@@ -30,6 +30,8 @@ func (rs *RequestSnooper) OnRequest(ctx *types.RequestContext) (*types.RequestCo
 		HookType:    "request",
 		RequestID:   ctx.CallCtx.ID(),
 		ContentType: ctx.ContentType,
+		Upstream:    ctx.CallCtx.Upstream(),
+		BatchIndex:  getBatchIndex(ctx.CallCtx),
 	}
 
 	msg := &protocol.WSMessage{
@@ -76,7 +78,6 @@ func (rs *ResponseSnooper) ID() uint64 {
 }
 
 func (rs *ResponseSnooper) OnRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
-	ctx.CallCtx.SetData(rs.id, "wants_response", true)
 	return ctx, nil
 }
 
@@ -86,6 +87,8 @@ func (rs *ResponseSnooper) OnResponse(ctx *types.ResponseContext) (*types.Respon
 		HookType:    "response",
 		RequestID:   ctx.CallCtx.ID(),
 		ContentType: ctx.ContentType,
+		Upstream:    ctx.CallCtx.Upstream(),
+		BatchIndex:  getBatchIndex(ctx.CallCtx),
 	}
 
 	msg := &protocol.WSMessage{
@@ -110,3 +113,14 @@ func (rs *ResponseSnooper) Configure(_ map[string]interface{}) error {
 func (rs *ResponseSnooper) Close() error {
 	return nil
 }
+
+// getBatchIndex returns the batch position of a JSON-RPC batch element sub-call, or
+// nil if the call is not part of a batch.
+func getBatchIndex(callCtx types.ProxyCallContext) *int {
+	batchIndex := callCtx.BatchIndex()
+	if batchIndex < 0 {
+		return nil
+	}
+
+	return &batchIndex
+}
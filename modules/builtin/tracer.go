@@ -16,8 +16,8 @@ type ResponseTracer struct {
 	connMgr        types.ConnectionManager
 	requestSelect  string
 	responseSelect string
-	requestQuery   *gojq.Query
-	responseQuery  *gojq.Query
+	requestQuery   *gojq.Code
+	responseQuery  *gojq.Code
 }
 
 func NewResponseTracer(id uint64, connMgr types.ConnectionManager) *ResponseTracer {
@@ -32,8 +32,6 @@ func (rt *ResponseTracer) ID() uint64 {
 }
 
 func (rt *ResponseTracer) OnRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
-	ctx.CallCtx.SetData(rt.id, "wants_response", true)
-
 	// Extract request data if query is configured
 	if rt.requestQuery != nil && strings.Contains(ctx.ContentType, "json") {
 		requestData := rt.extractData(rt.requestQuery, ctx.Body)
@@ -49,11 +47,12 @@ func (rt *ResponseTracer) OnResponse(ctx *types.ResponseContext) (*types.Respons
 	duration := ctx.Duration
 	requestSize, _ := ctx.CallCtx.GetData(0, "request_size").(int)
 
-	// Extract response data if query is configured
+	// Extract response data if query is configured, the query runs against the
+	// call document to correlate the response with the request
 	var responseData any
 
-	if rt.responseQuery != nil && strings.Contains(ctx.ContentType, "json") {
-		responseData = rt.extractData(rt.responseQuery, ctx.Body)
+	if rt.responseQuery != nil {
+		responseData = rt.extractData(rt.responseQuery, ctx.CallDocument())
 	}
 
 	// Get previously extracted request data
@@ -68,6 +67,8 @@ func (rt *ResponseTracer) OnResponse(ctx *types.ResponseContext) (*types.Respons
 		StatusCode:   ctx.StatusCode,
 		RequestData:  requestData,
 		ResponseData: responseData,
+		Upstream:     ctx.CallCtx.Upstream(),
+		BatchIndex:   getBatchIndex(ctx.CallCtx),
 	}
 
 	msg := &protocol.WSMessage{
@@ -95,7 +96,12 @@ func (rt *ResponseTracer) Configure(config map[string]interface{}) error {
 			return fmt.Errorf("failed to parse request_select query: %w", err)
 		}
 
-		rt.requestQuery = query
+		code, err := gojq.Compile(query)
+		if err != nil {
+			return fmt.Errorf("failed to compile request_select query: %w", err)
+		}
+
+		rt.requestQuery = code
 	}
 
 	// Parse response_select if provided
@@ -107,7 +113,12 @@ func (rt *ResponseTracer) Configure(config map[string]interface{}) error {
 			return fmt.Errorf("failed to parse response_select query: %w", err)
 		}
 
-		rt.responseQuery = query
+		code, err := gojq.Compile(query)
+		if err != nil {
+			return fmt.Errorf("failed to compile response_select query: %w", err)
+		}
+
+		rt.responseQuery = code
 	}
 
 	return nil
@@ -118,7 +129,7 @@ func (rt *ResponseTracer) Close() error {
 }
 
 // extractData runs a gojq query against the provided data and returns the result
-func (rt *ResponseTracer) extractData(query *gojq.Query, body any) any {
+func (rt *ResponseTracer) extractData(query *gojq.Code, body any) any {
 	// Convert body to JSON if it's not already
 	var data any
 	switch v := body.(type) {
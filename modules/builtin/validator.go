@@ -0,0 +1,96 @@
+package builtin
+
+import (
+	"github.com/ethpandaops/rpc-snooper/modules/protocol"
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/ethpandaops/rpc-snooper/validation"
+)
+
+// SchemaValidator implements types.Module for validating Engine API calls against
+// the execution-apis schemas. Violations are passed to the report callback.
+type SchemaValidator struct {
+	id        uint64
+	validator *validation.EngineValidator
+	report    func(event *protocol.SchemaViolationEvent)
+}
+
+// NewSchemaValidator creates a new SchemaValidator.
+func NewSchemaValidator(id uint64, validator *validation.EngineValidator, report func(event *protocol.SchemaViolationEvent)) *SchemaValidator {
+	return &SchemaValidator{
+		id:        id,
+		validator: validator,
+		report:    report,
+	}
+}
+
+// ID returns the module ID.
+func (m *SchemaValidator) ID() uint64 {
+	return m.id
+}
+
+// OnRequest validates the request params and remembers the method for the response.
+func (m *SchemaValidator) OnRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
+	method := extractMethod(ctx.Body)
+	if method == "" || m.validator.Fork(method) == "" {
+		return ctx, nil
+	}
+
+	ctx.CallCtx.SetData(m.id, "engine_method", method)
+
+	violations := m.validator.ValidateRequest(method, extractParams(ctx.Body))
+	if len(violations) > 0 {
+		m.report(newSchemaViolationEvent(ctx.CallCtx, method, "request", violations))
+	}
+
+	return ctx, nil
+}
+
+// OnResponse validates the result of successful responses.
+func (m *SchemaValidator) OnResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	method, ok := ctx.CallCtx.GetData(m.id, "engine_method").(string)
+	if !ok {
+		return ctx, nil
+	}
+
+	// error responses and non-JSON bodies have no result to validate
+	if _, isObject := ctx.Body.(map[string]any); !isObject || extractRPCError(ctx.Body) != nil {
+		return ctx, nil
+	}
+
+	violations := m.validator.ValidateResult(method, extractResult(ctx.Body))
+	if len(violations) > 0 {
+		m.report(newSchemaViolationEvent(ctx.CallCtx, method, "response", violations))
+	}
+
+	return ctx, nil
+}
+
+// Configure is a no-op for SchemaValidator.
+func (m *SchemaValidator) Configure(_ map[string]any) error {
+	return nil
+}
+
+// Close is a no-op for SchemaValidator.
+func (m *SchemaValidator) Close() error {
+	return nil
+}
+
+func newSchemaViolationEvent(callCtx types.ProxyCallContext, method, direction string, violations []validation.Violation) *protocol.SchemaViolationEvent {
+	event := &protocol.SchemaViolationEvent{
+		RequestID:  callCtx.ID(),
+		JRPCMethod: method,
+		Direction:  direction,
+		Upstream:   callCtx.Upstream(),
+		BatchIndex: getBatchIndex(callCtx),
+		Violations: make([]protocol.SchemaViolation, len(violations)),
+	}
+
+	for idx, violation := range violations {
+		event.Violations[idx] = protocol.SchemaViolation{
+			Pointer: violation.Pointer,
+			Message: violation.Message,
+		}
+	}
+
+	return event
+}
@@ -1,6 +1,8 @@
 package builtin
 
 import (
+	"strings"
+
 	"github.com/ethpandaops/rpc-snooper/types"
 	"github.com/ethpandaops/rpc-snooper/xatu"
 )
@@ -37,11 +39,12 @@ func (m *XatuModule) OnRequest(ctx *types.RequestContext) (*types.RequestContext
 	}
 
 	event := &xatu.RequestEvent{
-		CallID:    ctx.CallCtx.ID(),
-		Timestamp: ctx.Timestamp,
-		Method:    method,
-		Params:    extractParams(ctx.Body),
-		BodyBytes: ctx.BodyBytes,
+		CallID:     ctx.CallCtx.ID(),
+		BatchIndex: ctx.CallCtx.BatchIndex(),
+		Timestamp:  ctx.Timestamp,
+		Method:     method,
+		Params:     extractParams(ctx.Body),
+		BodyBytes:  ctx.BodyBytes,
 	}
 
 	// Route to matching handler
@@ -53,20 +56,28 @@ func (m *XatuModule) OnRequest(ctx *types.RequestContext) (*types.RequestContext
 	return ctx, nil
 }
 
-// OnResponse processes the response through the matched handler.
+// OnResponse processes the response through the matched handler. Events of beacon API
+// event streams are routed by their topic instead.
 func (m *XatuModule) OnResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	if m.router != nil && ctx.ContentType == "text/event-stream" {
+		m.routeBeaconEvent(ctx)
+
+		return ctx, nil
+	}
+
 	handler, ok := ctx.CallCtx.GetData(m.id, "xatu_handler").(xatu.EventHandler)
 	if !ok || handler == nil {
 		return ctx, nil
 	}
 
 	event := &xatu.ResponseEvent{
-		CallID:    ctx.CallCtx.ID(),
-		Timestamp: ctx.Timestamp,
-		Duration:  ctx.Duration,
-		Result:    extractResult(ctx.Body),
-		Error:     extractRPCError(ctx.Body),
-		BodyBytes: ctx.BodyBytes,
+		CallID:     ctx.CallCtx.ID(),
+		BatchIndex: ctx.CallCtx.BatchIndex(),
+		Timestamp:  ctx.Timestamp,
+		Duration:   ctx.Duration,
+		Result:     extractResult(ctx.Body),
+		Error:      extractRPCError(ctx.Body),
+		BodyBytes:  ctx.BodyBytes,
 	}
 
 	handler.HandleResponse(event)
@@ -74,6 +85,28 @@ func (m *XatuModule) OnResponse(ctx *types.ResponseContext) (*types.ResponseCont
 	return ctx, nil
 }
 
+// routeBeaconEvent routes a parsed event stream event through the Xatu router.
+func (m *XatuModule) routeBeaconEvent(ctx *types.ResponseContext) {
+	evt, ok := ctx.Body.(map[string]any)
+	if !ok {
+		return
+	}
+
+	topic, _ := evt["event"].(string)
+	data, _ := evt["data"].(map[string]any)
+
+	if topic == "" || data == nil {
+		return
+	}
+
+	m.router.RouteBeaconEvent(&xatu.BeaconEvent{
+		CallID:    ctx.CallCtx.ID(),
+		Timestamp: ctx.Timestamp,
+		Topic:     strings.TrimSpace(topic),
+		Data:      data,
+	})
+}
+
 // Configure is a no-op for XatuModule.
 func (m *XatuModule) Configure(_ map[string]any) error {
 	return nil
@@ -0,0 +1,216 @@
+package modules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethpandaops/rpc-snooper/types"
+)
+
+// ModuleStats counts the messages a module sent to its control client.
+type ModuleStats struct {
+	EventsSent   uint64 `json:"events_sent"`
+	SendFailures uint64 `json:"send_failures"`
+	BytesSent    uint64 `json:"bytes_sent"`
+}
+
+// ModuleDetails is the state of a registered module as reported by list_modules.
+type ModuleDetails struct {
+	ModuleInfo
+	Paused  bool                `json:"paused"`
+	Filters *types.FilterConfig `json:"filters,omitempty"`
+	Stats   ModuleStats         `json:"stats"`
+}
+
+// ListModules returns the state of all registered modules in chain order.
+func (mm *ModuleManager) ListModules() []ModuleDetails {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+
+	details := make([]ModuleDetails, 0, len(mm.modules))
+	for _, entry := range mm.modules {
+		details = append(details, ModuleDetails{
+			ModuleInfo: entry.info,
+			Paused:     entry.paused.Load(),
+			Filters:    mm.filters[entry.info.ID],
+			Stats:      entry.stats(),
+		})
+	}
+
+	return details
+}
+
+// SetModulePaused pauses or resumes a module. Paused modules stay registered but
+// process no calls.
+func (mm *ModuleManager) SetModulePaused(moduleID uint64, paused bool) error {
+	entry := mm.getChainModule(moduleID)
+	if entry == nil {
+		return fmt.Errorf("module %d not found", moduleID)
+	}
+
+	entry.paused.Store(paused)
+
+	return nil
+}
+
+// GetModuleStats returns the send counters of a module.
+func (mm *ModuleManager) GetModuleStats(moduleID uint64) (ModuleStats, error) {
+	entry := mm.getChainModule(moduleID)
+	if entry == nil {
+		return ModuleStats{}, fmt.Errorf("module %d not found", moduleID)
+	}
+
+	return entry.stats(), nil
+}
+
+// UpdateFilter compiles and swaps the filters of a registered module. A nil
+// request or response filter removes it.
+func (m *Manager) UpdateFilter(moduleID uint64, filterConfig *types.FilterConfig) error {
+	for _, filter := range []*types.Filter{filterConfig.RequestFilter, filterConfig.ResponseFilter} {
+		if filter == nil {
+			continue
+		}
+
+		if err := m.filterEngine.CompileFilter(filter); err != nil {
+			return fmt.Errorf("failed to compile filter: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, entry := range m.modules {
+		if entry.info.ID == moduleID {
+			m.filters[moduleID] = filterConfig
+			return nil
+		}
+	}
+
+	return fmt.Errorf("module %d not found", moduleID)
+}
+
+// recordSend counts a message sent on behalf of a module.
+func (mm *ModuleManager) recordSend(moduleID uint64, size int, err error) {
+	if moduleID == 0 {
+		return
+	}
+
+	entry := mm.getChainModule(moduleID)
+	if entry == nil {
+		return
+	}
+
+	if err != nil {
+		entry.sendFailures.Add(1)
+		return
+	}
+
+	entry.eventsSent.Add(1)
+	entry.bytesSent.Add(uint64(size)) //nolint:gosec // size is never negative
+}
+
+func (entry *chainModule) stats() ModuleStats {
+	return ModuleStats{
+		EventsSent:   entry.eventsSent.Load(),
+		SendFailures: entry.sendFailures.Load(),
+		BytesSent:    entry.bytesSent.Load(),
+	}
+}
+
+// registerModuleControlMethods registers the control methods inspecting and
+// reconfiguring registered modules.
+func (m *Manager) registerModuleControlMethods() {
+	m.RegisterControlMethod("list_modules", func(_ json.RawMessage) (any, error) {
+		return map[string]any{
+			"modules": m.ListModules(),
+		}, nil
+	})
+
+	m.RegisterControlMethod("update_filter", func(data json.RawMessage) (any, error) {
+		var req struct {
+			ModuleID uint64 `json:"module_id"`
+		}
+
+		var config map[string]any
+
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, fmt.Errorf("invalid filter update: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("invalid filter update: %w", err)
+		}
+
+		// filters missing in the update are removed
+		filterConfig, err := m.parseFilterConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := m.UpdateFilter(req.ModuleID, filterConfig); err != nil {
+			return nil, err
+		}
+
+		m.logger.WithField("module_id", req.ModuleID).Info("Module filter updated")
+
+		return map[string]any{
+			"success": true,
+			"filters": filterConfig,
+		}, nil
+	})
+
+	for method, paused := range map[string]bool{"pause_module": true, "resume_module": false} {
+		m.RegisterControlMethod(method, func(data json.RawMessage) (any, error) {
+			moduleID, err := parseModuleID(data)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := m.SetModulePaused(moduleID, paused); err != nil {
+				return nil, err
+			}
+
+			m.logger.WithFields(map[string]any{
+				"module_id": moduleID,
+				"paused":    paused,
+			}).Info("Module paused state changed")
+
+			return map[string]any{
+				"success": true,
+				"paused":  paused,
+			}, nil
+		})
+	}
+
+	m.RegisterControlMethod("get_module_stats", func(data json.RawMessage) (any, error) {
+		moduleID, err := parseModuleID(data)
+		if err != nil {
+			return nil, err
+		}
+
+		stats, err := m.GetModuleStats(moduleID)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]any{
+			"module_id": moduleID,
+			"stats":     stats,
+		}, nil
+	})
+}
+
+// parseModuleID parses the module id sent as data of a control method.
+func parseModuleID(data json.RawMessage) (uint64, error) {
+	var moduleID uint64
+	if len(data) == 0 {
+		return 0, errors.New("module id is required")
+	}
+
+	if err := json.Unmarshal(data, &moduleID); err != nil {
+		return 0, fmt.Errorf("invalid module id: %w", err)
+	}
+
+	return moduleID, nil
+}
@@ -2,7 +2,13 @@ package modules
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/ethpandaops/rpc-snooper/types"
 	"github.com/itchyny/gojq"
@@ -13,26 +19,114 @@ type FilterEngine struct {
 	logger logrus.FieldLogger
 }
 
+// compiledFilter holds the compiled queries of a filter.
+type compiledFilter struct {
+	query     *gojq.Code
+	pathRegex *regexp.Regexp
+}
+
+// filterInput holds the call attributes a filter is evaluated against.
+type filterInput struct {
+	response    bool
+	method      string
+	path        string
+	jrpcMethods []string
+	headers     http.Header
+	contentType string
+	bodySize    int
+	statusCode  int
+	duration    time.Duration
+
+	// queryInput builds the document JSON queries run against, nil if queries are
+	// skipped for the call
+	queryInput    func() interface{}
+	queryDocument interface{}
+	queryBuilt    bool
+}
+
+// query returns the document JSON queries run against, built on first use.
+func (input *filterInput) query() interface{} {
+	if !input.queryBuilt {
+		input.queryDocument = input.queryInput()
+		input.queryBuilt = true
+	}
+
+	return input.queryDocument
+}
+
 func NewFilterEngine(logger logrus.FieldLogger) *FilterEngine {
 	return &FilterEngine{
 		logger: logger,
 	}
 }
 
-// CompileFilter compiles the JSON query in a filter
+// CompileFilter validates a filter and compiles its JSON query and path regex,
+// including those of nested filters
 func (fe *FilterEngine) CompileFilter(filter *types.Filter) error {
-	if filter.JSONQuery == "" {
-		return nil
+	compiled := &compiledFilter{}
+
+	if filter.JSONQuery != "" {
+		query, err := gojq.Parse(filter.JSONQuery)
+		if err != nil {
+			return err
+		}
+
+		code, err := gojq.Compile(query)
+		if err != nil {
+			return err
+		}
+
+		compiled.query = code
+	}
+
+	if filter.PathRegex != "" {
+		pathRegex, err := regexp.Compile(filter.PathRegex)
+		if err != nil {
+			return fmt.Errorf("invalid path_regex: %w", err)
+		}
+
+		compiled.pathRegex = pathRegex
+	}
+
+	if filter.SampleRate < 0 || filter.SampleRate > 1 {
+		return fmt.Errorf("invalid sample_rate %v, must be between 0 and 1", filter.SampleRate)
+	}
+
+	if filter.MinBodySize < 0 || filter.MaxBodySize < 0 || filter.MinDurationMs < 0 {
+		return errors.New("min_body_size, max_body_size and min_duration_ms must not be negative")
+	}
+
+	if filter.MaxBodySize > 0 && filter.MaxBodySize < filter.MinBodySize {
+		return errors.New("max_body_size must not be lower than min_body_size")
+	}
+
+	for name := range filter.Headers {
+		if name == "" {
+			return errors.New("empty header name in headers")
+		}
+	}
+
+	nested := make([]*types.Filter, 0, len(filter.All)+len(filter.Any)+1)
+	nested = append(nested, filter.All...)
+	nested = append(nested, filter.Any...)
+
+	if filter.Not != nil {
+		nested = append(nested, filter.Not)
 	}
 
-	query, err := gojq.Parse(filter.JSONQuery)
-	if err != nil {
-		return err
+	for _, nestedFilter := range nested {
+		if nestedFilter == nil {
+			return errors.New("empty nested filter")
+		}
+
+		if err := fe.CompileFilter(nestedFilter); err != nil {
+			return err
+		}
 	}
 
-	// Store the compiled query in the filter
+	// Store the compiled queries in the filter
 	// We use interface{} to avoid import cycles
-	filter.SetCompiled(query)
+	filter.SetCompiled(compiled)
 
 	return nil
 }
@@ -43,44 +137,24 @@ func (fe *FilterEngine) ShouldProcessRequestFilter(filter *types.Filter, ctx *ty
 		return true
 	}
 
-	// Check HTTP method filter
-	if len(filter.Methods) > 0 {
-		matched := false
-
-		for _, method := range filter.Methods {
-			if strings.EqualFold(ctx.Method, method) {
-				matched = true
-				break
-			}
-		}
-
-		if !matched {
-			return false
-		}
+	input := &filterInput{
+		method:      ctx.Method,
+		jrpcMethods: types.JSONRPCMethods(ctx.Body),
+		headers:     ctx.Headers,
+		contentType: ctx.ContentType,
+		bodySize:    len(ctx.BodyBytes),
 	}
 
-	// Check content type filter
-	if len(filter.ContentTypes) > 0 {
-		matched := false
-
-		for _, ct := range filter.ContentTypes {
-			if strings.Contains(ctx.ContentType, ct) {
-				matched = true
-				break
-			}
-		}
-
-		if !matched {
-			return false
-		}
+	// JSON queries of request filters run against JSON request bodies only
+	if strings.Contains(ctx.ContentType, "json") {
+		input.queryInput = func() interface{} { return ctx.Body }
 	}
 
-	// Check JSON query filter
-	if filter.JSONQuery != "" && strings.Contains(ctx.ContentType, "json") {
-		return fe.evaluateJSONQueryFilter(filter, ctx.Body)
+	if ctx.URL != nil {
+		input.path = ctx.URL.Path
 	}
 
-	return true
+	return fe.matchFilter(filter, input)
 }
 
 // ShouldProcessRequest determines if a request should be processed by a module based on filters (legacy method)
@@ -98,68 +172,158 @@ func (fe *FilterEngine) ShouldProcessResponseFilter(filter *types.Filter, ctx *t
 		return true
 	}
 
-	// Check status code filter
-	if len(filter.StatusCodes) > 0 {
-		matched := false
+	// JSON queries of response filters run against the call document, so they can
+	// correlate the response with the request of the call
+	input := &filterInput{
+		response:    true,
+		headers:     ctx.Headers,
+		contentType: ctx.ContentType,
+		bodySize:    len(ctx.BodyBytes),
+		statusCode:  ctx.StatusCode,
+		duration:    ctx.Duration,
+
+		queryInput: func() interface{} { return ctx.CallDocument() },
+	}
 
-		for _, code := range filter.StatusCodes {
-			if ctx.StatusCode == code {
-				matched = true
-				break
-			}
-		}
+	// HTTP method, path and JSON-RPC methods are those of the originating request
+	if ctx.Request != nil {
+		input.method = ctx.Request.Method
+		input.jrpcMethods = types.JSONRPCMethods(ctx.Request.Body)
 
-		if !matched {
-			return false
+		if ctx.Request.URL != nil {
+			input.path = ctx.Request.URL.Path
+		}
+	} else if ctx.CallCtx != nil {
+		if jrpcMethod, ok := ctx.CallCtx.GetData(0, "jrpc_method").(string); ok && jrpcMethod != "" {
+			input.jrpcMethods = []string{jrpcMethod}
 		}
 	}
 
+	return fe.matchFilter(filter, input)
+}
+
+// ShouldProcessResponse determines if a response should be processed by a module based on filters (legacy method)
+func (fe *FilterEngine) ShouldProcessResponse(filter *types.FilterConfig, ctx *types.ResponseContext) bool {
+	if filter == nil || filter.ResponseFilter == nil {
+		return true
+	}
+
+	return fe.ShouldProcessResponseFilter(filter.ResponseFilter, ctx)
+}
+
+// matchFilter evaluates all conditions of a filter and its nested filters. The
+// sample rate is applied last, so it only thins out otherwise matching calls.
+func (fe *FilterEngine) matchFilter(filter *types.Filter, input *filterInput) bool {
+	// Check HTTP method filter
+	if len(filter.Methods) > 0 && !matchAny(filter.Methods, func(method string) bool {
+		return strings.EqualFold(input.method, method)
+	}) {
+		return false
+	}
+
+	// Check status code filter
+	if input.response && len(filter.StatusCodes) > 0 && !matchAny(filter.StatusCodes, func(code int) bool {
+		return input.statusCode == code
+	}) {
+		return false
+	}
+
 	// Check content type filter
-	if len(filter.ContentTypes) > 0 {
-		matched := false
+	if len(filter.ContentTypes) > 0 && !matchAny(filter.ContentTypes, func(ct string) bool {
+		return strings.Contains(input.contentType, ct)
+	}) {
+		return false
+	}
 
-		for _, ct := range filter.ContentTypes {
-			if strings.Contains(ctx.ContentType, ct) {
-				matched = true
-				break
-			}
-		}
+	// Check JSON-RPC method filter, any call of a batch may match
+	if len(filter.JRPCMethods) > 0 && !matchAny(filter.JRPCMethods, func(pattern string) bool {
+		return matchAny(input.jrpcMethods, func(method string) bool {
+			return matchGlob(pattern, method)
+		})
+	}) {
+		return false
+	}
+
+	if !fe.matchPath(filter, input) {
+		return false
+	}
 
-		if !matched {
+	// Check header filter
+	for name, pattern := range filter.Headers {
+		values := input.headers.Values(name)
+		if len(values) == 0 || !matchAny(values, func(value string) bool {
+			return matchGlob(pattern, value)
+		}) {
 			return false
 		}
 	}
 
+	// Check duration and body size filters
+	if input.response && filter.MinDurationMs > 0 && input.duration < time.Duration(filter.MinDurationMs)*time.Millisecond {
+		return false
+	}
+
+	if input.bodySize < filter.MinBodySize || (filter.MaxBodySize > 0 && input.bodySize > filter.MaxBodySize) {
+		return false
+	}
+
 	// Check JSON query filter
-	if filter.JSONQuery != "" && strings.Contains(ctx.ContentType, "json") {
-		return fe.evaluateJSONQueryFilter(filter, ctx.Body)
+	if filter.JSONQuery != "" && input.queryInput != nil && !fe.evaluateJSONQueryFilter(filter, input.query()) {
+		return false
+	}
+
+	// Check nested filters
+	for _, nested := range filter.All {
+		if !fe.matchFilter(nested, input) {
+			return false
+		}
+	}
+
+	if len(filter.Any) > 0 && !matchAny(filter.Any, func(nested *types.Filter) bool {
+		return fe.matchFilter(nested, input)
+	}) {
+		return false
+	}
+
+	if filter.Not != nil && fe.matchFilter(filter.Not, input) {
+		return false
+	}
+
+	if filter.SampleRate > 0 && filter.SampleRate < 1 {
+		return rand.Float64() < filter.SampleRate //nolint:gosec // sampling needs no secure randomness
 	}
 
 	return true
 }
 
-// ShouldProcessResponse determines if a response should be processed by a module based on filters (legacy method)
-func (fe *FilterEngine) ShouldProcessResponse(filter *types.FilterConfig, ctx *types.ResponseContext) bool {
-	if filter == nil || filter.ResponseFilter == nil {
-		return true
+// matchPath checks the path prefix and regex of a filter.
+func (fe *FilterEngine) matchPath(filter *types.Filter, input *filterInput) bool {
+	if filter.PathPrefix != "" && !strings.HasPrefix(input.path, filter.PathPrefix) {
+		return false
 	}
 
-	return fe.ShouldProcessResponseFilter(filter.ResponseFilter, ctx)
+	if filter.PathRegex != "" {
+		compiled, ok := filter.GetCompiled().(*compiledFilter)
+		if !ok || compiled.pathRegex == nil {
+			fe.logger.Warn("Path regex not compiled, skipping filter")
+			return true
+		}
+
+		return compiled.pathRegex.MatchString(input.path)
+	}
+
+	return true
 }
 
 // evaluateJSONQueryFilter evaluates a gojq query against the provided data
 func (fe *FilterEngine) evaluateJSONQueryFilter(filter *types.Filter, body interface{}) bool {
-	compiled := filter.GetCompiled()
-	if compiled == nil {
+	compiled, ok := filter.GetCompiled().(*compiledFilter)
+	if !ok || compiled.query == nil {
 		fe.logger.Warn("JSON query not compiled, skipping filter")
 		return true
 	}
 
-	query, ok := compiled.(*gojq.Query)
-	if !ok {
-		fe.logger.Warn("Invalid compiled query type, skipping filter")
-		return true
-	}
+	query := compiled.query
 
 	// Convert body to JSON if it's not already
 	var data interface{}
@@ -205,3 +369,45 @@ func (fe *FilterEngine) evaluateJSONQueryFilter(filter *types.Filter, body inter
 
 	return false
 }
+
+func matchAny[T any](values []T, match func(T) bool) bool {
+	for _, value := range values {
+		if match(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchGlob matches a value against a pattern where * matches any sequence of
+// characters and ? a single character.
+func matchGlob(pattern, value string) bool {
+	patternIdx, valueIdx := 0, 0
+	starIdx, matchIdx := -1, 0
+
+	for valueIdx < len(value) {
+		switch {
+		case patternIdx < len(pattern) && (pattern[patternIdx] == '?' || pattern[patternIdx] == value[valueIdx]):
+			patternIdx++
+			valueIdx++
+		case patternIdx < len(pattern) && pattern[patternIdx] == '*':
+			starIdx = patternIdx
+			matchIdx = valueIdx
+			patternIdx++
+		case starIdx >= 0:
+			// let the last * consume one more character
+			patternIdx = starIdx + 1
+			matchIdx++
+			valueIdx = matchIdx
+		default:
+			return false
+		}
+	}
+
+	for patternIdx < len(pattern) && pattern[patternIdx] == '*' {
+		patternIdx++
+	}
+
+	return patternIdx == len(pattern)
+}
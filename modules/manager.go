@@ -1,10 +1,13 @@
 package modules
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,7 +20,7 @@ import (
 )
 
 type ModuleManager struct {
-	modules        map[uint64]types.Module
+	modules        []*chainModule // processing chain, see ModuleChain
 	connections    map[*websocket.Conn]*ConnectionManager
 	filters        map[uint64]*types.FilterConfig
 	moduleCounter  uint64
@@ -33,21 +36,49 @@ type ConnectionManager struct {
 	modules         []uint64
 	mu              sync.RWMutex
 	done            chan struct{}
-	writeMu         sync.Mutex
+	queue           *sendQueue
+	name            string // remote address, used as metrics label
 	closed          bool
 	closeMu         sync.Mutex
 }
 
 type Manager struct {
 	*ModuleManager
-	logger       logrus.FieldLogger
-	upgrader     websocket.Upgrader
-	filterEngine *FilterEngine
+	logger          logrus.FieldLogger
+	upgrader        websocket.Upgrader
+	filterEngine    *FilterEngine
+	controlHandlers map[string]ControlHandler
+	controlMu       sync.RWMutex
+	sendQueue       SendQueueConfig
 }
 
+// ModuleInfo describes a module in the processing chain.
+type ModuleInfo struct {
+	ID       uint64 `json:"id"`
+	Type     string `json:"type,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Priority int    `json:"priority"`
+	Owner    string `json:"owner,omitempty"` // remote address of the registering control connection
+}
+
+// chainModule is a registered module and its position in the processing chain.
+type chainModule struct {
+	module types.Module
+	info   ModuleInfo
+	paused atomic.Bool
+
+	eventsSent   atomic.Uint64
+	sendFailures atomic.Uint64
+	bytesSent    atomic.Uint64
+}
+
+// ControlHandler handles a control method sent by a WebSocket client. It receives the
+// raw message data and returns the response data, or an error sent back to the client.
+type ControlHandler func(data json.RawMessage) (any, error)
+
 func NewModuleManager() *ModuleManager {
 	return &ModuleManager{
-		modules:     make(map[uint64]types.Module),
+		modules:     make([]*chainModule, 0),
 		connections: make(map[*websocket.Conn]*ConnectionManager),
 		filters:     make(map[uint64]*types.FilterConfig),
 		enabled:     true,
@@ -55,34 +86,37 @@ func NewModuleManager() *ModuleManager {
 }
 
 func NewManager(logger logrus.FieldLogger) *Manager {
-	return &Manager{
-		ModuleManager: NewModuleManager(),
-		logger:        logger,
-		filterEngine:  NewFilterEngine(logger),
+	m := &Manager{
+		ModuleManager:   NewModuleManager(),
+		logger:          logger,
+		filterEngine:    NewFilterEngine(logger),
+		controlHandlers: make(map[string]ControlHandler),
+		sendQueue: SendQueueConfig{
+			Size:     DefaultSendQueueSize,
+			Overflow: OverflowDropOldest,
+		},
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(_ *http.Request) bool {
 				return true
 			},
 		},
 	}
+
+	m.registerModuleControlMethods()
+
+	return m
 }
 
 // Manager methods that delegate to ModuleManager with filterEngine
+
+// ProcessRequest runs the request through all modules in chain order. Each module
+// sees the context returned by the modules before it.
 func (m *Manager) ProcessRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
 	if !m.IsEnabled() {
 		return ctx, nil
 	}
 
-	m.mu.RLock()
-	modules := make([]types.Module, 0, len(m.modules))
-
-	for _, module := range m.modules {
-		modules = append(modules, module)
-	}
-
-	m.mu.RUnlock()
-
-	for _, module := range modules {
+	for _, module := range m.chainModules() {
 		if m.shouldProcessRequest(module, ctx, m.filterEngine) {
 			newCtx, err := module.OnRequest(ctx)
 			if err != nil {
@@ -98,21 +132,14 @@ func (m *Manager) ProcessRequest(ctx *types.RequestContext) (*types.RequestConte
 	return ctx, nil
 }
 
+// ProcessResponse runs the response through all modules in chain order. Each module
+// sees the context returned by the modules before it.
 func (m *Manager) ProcessResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
 	if !m.IsEnabled() {
 		return ctx, nil
 	}
 
-	m.mu.RLock()
-	modules := make([]types.Module, 0, len(m.modules))
-
-	for _, module := range m.modules {
-		modules = append(modules, module)
-	}
-
-	m.mu.RUnlock()
-
-	for _, module := range modules {
+	for _, module := range m.chainModules() {
 		if m.shouldProcessResponse(module, ctx, m.filterEngine) {
 			newCtx, err := module.OnResponse(ctx)
 			if err != nil {
@@ -128,6 +155,91 @@ func (m *Manager) ProcessResponse(ctx *types.ResponseContext) (*types.ResponseCo
 	return ctx, nil
 }
 
+// HasInterceptors reports whether any registered module intercepts calls. The proxy
+// only buffers request and response bodies when this is the case.
+func (m *Manager) HasInterceptors() bool {
+	if !m.IsEnabled() {
+		return false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, entry := range m.modules {
+		if _, ok := entry.module.(types.InterceptingModule); ok && !entry.paused.Load() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InterceptRequest runs the request through all intercepting modules in chain order.
+// Processing stops at the first module that answers or drops the call.
+func (m *Manager) InterceptRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
+	for _, interceptor := range m.getInterceptors() {
+		if !m.shouldProcessRequest(interceptor, ctx, m.filterEngine) {
+			continue
+		}
+
+		newCtx, err := interceptor.InterceptRequest(ctx)
+		if err != nil {
+			return ctx, err
+		}
+
+		if newCtx != nil {
+			ctx = newCtx
+		}
+
+		if ctx.Response != nil || ctx.Drop {
+			break
+		}
+	}
+
+	return ctx, nil
+}
+
+// InterceptResponse runs the response through all intercepting modules in chain
+// order. Processing stops at the first module that drops the call.
+func (m *Manager) InterceptResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	for _, interceptor := range m.getInterceptors() {
+		if !m.shouldProcessResponse(interceptor, ctx, m.filterEngine) {
+			continue
+		}
+
+		newCtx, err := interceptor.InterceptResponse(ctx)
+		if err != nil {
+			return ctx, err
+		}
+
+		if newCtx != nil {
+			ctx = newCtx
+		}
+
+		if ctx.Drop {
+			break
+		}
+	}
+
+	return ctx, nil
+}
+
+func (m *Manager) getInterceptors() []types.InterceptingModule {
+	if !m.IsEnabled() {
+		return nil
+	}
+
+	interceptors := make([]types.InterceptingModule, 0)
+
+	for _, module := range m.chainModules() {
+		if interceptor, ok := module.(types.InterceptingModule); ok {
+			interceptors = append(interceptors, interceptor)
+		}
+	}
+
+	return interceptors
+}
+
 func (cm *ConnectionManager) WaitForResponse(requestID uint64) (*protocol.WSMessageWithBinary, error) {
 	responseChan := make(chan *protocol.WSMessageWithBinary, 1)
 	cm.RegisterPendingRequest(requestID, responseChan)
@@ -142,6 +254,38 @@ func (cm *ConnectionManager) WaitForResponse(requestID uint64) (*protocol.WSMess
 	}
 }
 
+// SendRequest sends msg to the control client under a new request id and waits for
+// the matching response. The pending request is registered before sending, so a fast
+// reply cannot be missed. It returns early when ctx is done or the connection closes.
+func (cm *ConnectionManager) SendRequest(ctx context.Context, msg *protocol.WSMessage, binaryData []byte) (*protocol.WSMessageWithBinary, error) {
+	msg.RequestID = cm.GenerateRequestID()
+
+	responseChan := make(chan *protocol.WSMessageWithBinary, 1)
+	cm.RegisterPendingRequest(msg.RequestID, responseChan)
+
+	defer cm.UnregisterPendingRequest(msg.RequestID)
+
+	var err error
+	if binaryData != nil {
+		err = cm.SendMessageWithBinary(msg, binaryData)
+	} else {
+		err = cm.SendMessage(msg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case response := <-responseChan:
+		return response, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-cm.done:
+		return nil, context.Canceled
+	}
+}
+
 func (cm *ConnectionManager) GenerateRequestID() uint64 {
 	return atomic.AddUint64(&cm.manager.requestCounter, 1)
 }
@@ -163,29 +307,6 @@ func (cm *ConnectionManager) UnregisterPendingRequest(requestID uint64) {
 	delete(cm.pendingRequests, requestID)
 }
 
-func (cm *ConnectionManager) SendMessage(msg *protocol.WSMessage) error {
-	cm.writeMu.Lock()
-	defer cm.writeMu.Unlock()
-
-	return cm.conn.WriteJSON(msg)
-}
-
-func (cm *ConnectionManager) SendMessageWithBinary(msg *protocol.WSMessage, binaryData []byte) error {
-	cm.writeMu.Lock()
-	defer cm.writeMu.Unlock()
-
-	// Set the Binary flag
-	msg.Binary = true
-
-	// Send the JSON message first
-	if err := cm.conn.WriteJSON(msg); err != nil {
-		return err
-	}
-
-	// Send the binary frame immediately after
-	return cm.conn.WriteMessage(websocket.BinaryMessage, binaryData)
-}
-
 func (cm *ConnectionManager) Close() {
 	cm.closeMu.Lock()
 	defer cm.closeMu.Unlock()
@@ -198,6 +319,13 @@ func (cm *ConnectionManager) Close() {
 	cm.conn.Close()
 }
 
+func (cm *ConnectionManager) isClosed() bool {
+	cm.closeMu.Lock()
+	defer cm.closeMu.Unlock()
+
+	return cm.closed
+}
+
 // ModuleManager methods
 
 func (mm *ModuleManager) GenerateModuleID() uint64 {
@@ -235,11 +363,6 @@ func (mm *ModuleManager) shouldProcessRequest(module types.Module, ctx *types.Re
 }
 
 func (mm *ModuleManager) shouldProcessResponse(module types.Module, ctx *types.ResponseContext, filterEngine *FilterEngine) bool {
-	// Check if module explicitly requested this response
-	if ctx.CallCtx.GetData(module.ID(), "wants_response") == true {
-		return true
-	}
-
 	if ctx.CallCtx.GetData(module.ID(), "skip_response") == true {
 		return false
 	}
@@ -255,11 +378,32 @@ func (mm *ModuleManager) shouldProcessResponse(module types.Module, ctx *types.R
 	return filterEngine.ShouldProcessResponseFilter(filterConfig.ResponseFilter, ctx)
 }
 
+// RegisterModule adds a module with the default priority to the processing chain.
 func (mm *ModuleManager) RegisterModule(module types.Module, filter *types.FilterConfig) error {
+	return mm.RegisterModuleWithInfo(module, filter, ModuleInfo{})
+}
+
+// RegisterModuleWithInfo adds a module to the processing chain. Modules run in
+// ascending priority, modules with the same priority in registration order.
+func (mm *ModuleManager) RegisterModuleWithInfo(module types.Module, filter *types.FilterConfig, info ModuleInfo) error {
 	mm.mu.Lock()
 	defer mm.mu.Unlock()
 
-	mm.modules[module.ID()] = module
+	info.ID = module.ID()
+
+	for _, entry := range mm.modules {
+		if entry.info.ID == info.ID {
+			return fmt.Errorf("module %d is already registered", info.ID)
+		}
+	}
+
+	// insert after all modules with a lower or equal priority
+	pos := sort.Search(len(mm.modules), func(i int) bool {
+		return mm.modules[i].info.Priority > info.Priority
+	})
+
+	mm.modules = slices.Insert(mm.modules, pos, &chainModule{module: module, info: info})
+
 	if filter != nil {
 		mm.filters[module.ID()] = filter
 	}
@@ -271,69 +415,128 @@ func (mm *ModuleManager) UnregisterModule(moduleID uint64) error {
 	mm.mu.Lock()
 	defer mm.mu.Unlock()
 
-	if module, exists := mm.modules[moduleID]; exists {
-		module.Close()
-		delete(mm.modules, moduleID)
+	for idx, entry := range mm.modules {
+		if entry.info.ID != moduleID {
+			continue
+		}
+
+		entry.module.Close()
+		mm.modules = slices.Delete(mm.modules, idx, idx+1)
 		delete(mm.filters, moduleID)
+
+		break
 	}
 
 	return nil
 }
 
-func (mm *ModuleManager) parseFilterConfig(config map[string]interface{}) *types.FilterConfig {
-	filterConfig := &types.FilterConfig{}
+// ModuleChain returns the registered modules in the order they process calls.
+func (mm *ModuleManager) ModuleChain() []ModuleInfo {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
 
-	// Parse request filter
-	if requestFilterData, ok := config["request_filter"].(map[string]interface{}); ok {
-		filterConfig.RequestFilter = mm.parseFilter(requestFilterData)
+	chain := make([]ModuleInfo, 0, len(mm.modules))
+	for _, entry := range mm.modules {
+		chain = append(chain, entry.info)
 	}
 
-	// Parse response filter
-	if responseFilterData, ok := config["response_filter"].(map[string]interface{}); ok {
-		filterConfig.ResponseFilter = mm.parseFilter(responseFilterData)
+	return chain
+}
+
+// chainModules returns a snapshot of the active modules in chain order. Paused
+// modules are skipped.
+func (mm *ModuleManager) chainModules() []types.Module {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+
+	modules := make([]types.Module, 0, len(mm.modules))
+	for _, entry := range mm.modules {
+		if !entry.paused.Load() {
+			modules = append(modules, entry.module)
+		}
 	}
 
-	return filterConfig
+	return modules
 }
 
-func (mm *ModuleManager) parseFilter(config map[string]interface{}) *types.Filter {
-	filter := &types.Filter{}
+// getChainModule returns the registered module with the given id, or nil.
+func (mm *ModuleManager) getChainModule(moduleID uint64) *chainModule {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
 
-	if contentTypes, ok := config["content_types"].([]interface{}); ok {
-		filter.ContentTypes = make([]string, len(contentTypes))
+	for _, entry := range mm.modules {
+		if entry.info.ID == moduleID {
+			return entry
+		}
+	}
 
-		for i, ct := range contentTypes {
-			if str, ok := ct.(string); ok {
-				filter.ContentTypes[i] = str
-			}
+	return nil
+}
+
+func (mm *ModuleManager) parseFilterConfig(config map[string]interface{}) (*types.FilterConfig, error) {
+	filterConfig := &types.FilterConfig{}
+
+	for key, target := range map[string]**types.Filter{
+		"request_filter":  &filterConfig.RequestFilter,
+		"response_filter": &filterConfig.ResponseFilter,
+	} {
+		filterData, ok := config[key]
+		if !ok || filterData == nil {
+			continue
+		}
+
+		filter, err := mm.parseFilter(filterData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
 		}
+
+		*target = filter
 	}
 
-	if jsonQuery, ok := config["json_query"].(string); ok {
-		filter.JSONQuery = jsonQuery
+	return filterConfig, nil
+}
+
+// parseFilter decodes a filter from its JSON representation. Unknown fields are
+// rejected, so typos do not silently widen a filter.
+func (mm *ModuleManager) parseFilter(config interface{}) (*types.Filter, error) {
+	filterJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
 	}
 
-	if methods, ok := config["methods"].([]interface{}); ok {
-		filter.Methods = make([]string, len(methods))
+	decoder := json.NewDecoder(bytes.NewReader(filterJSON))
+	decoder.DisallowUnknownFields()
 
-		for i, method := range methods {
-			if str, ok := method.(string); ok {
-				filter.Methods[i] = str
-			}
-		}
+	filter := &types.Filter{}
+	if err := decoder.Decode(filter); err != nil {
+		return nil, err
 	}
 
-	if statusCodes, ok := config["status_codes"].([]interface{}); ok {
-		filter.StatusCodes = make([]int, len(statusCodes))
+	return filter, nil
+}
 
-		for i, code := range statusCodes {
-			if num, ok := code.(float64); ok {
-				filter.StatusCodes[i] = int(num)
-			}
-		}
+// BroadcastEvent sends an event message to all connected control clients.
+func (m *Manager) BroadcastEvent(method string, data any) {
+	m.mu.RLock()
+
+	connMgrs := make([]*ConnectionManager, 0, len(m.connections))
+	for _, connMgr := range m.connections {
+		connMgrs = append(connMgrs, connMgr)
 	}
 
-	return filter
+	m.mu.RUnlock()
+
+	for _, connMgr := range connMgrs {
+		msg := &protocol.WSMessage{
+			Method:    method,
+			Data:      data,
+			Timestamp: time.Now().UnixNano(),
+		}
+
+		if err := connMgr.SendMessage(msg); err != nil {
+			m.logger.WithError(err).Debugf("Failed to broadcast %s event", method)
+		}
+	}
 }
 
 func (m *Manager) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
@@ -343,12 +546,18 @@ func (m *Manager) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	m.controlMu.RLock()
+	queueConfig := m.sendQueue
+	m.controlMu.RUnlock()
+
 	connMgr := &ConnectionManager{
 		conn:            conn,
 		manager:         m.ModuleManager,
 		pendingRequests: make(map[uint64]chan *protocol.WSMessageWithBinary),
 		modules:         make([]uint64, 0),
 		done:            make(chan struct{}),
+		queue:           newSendQueue(queueConfig),
+		name:            conn.RemoteAddr().String(),
 	}
 
 	m.mu.Lock()
@@ -371,6 +580,7 @@ func (m *Manager) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	m.logger.WithField("remote", conn.RemoteAddr()).Info("WebSocket connection established")
 
+	go connMgr.writeLoop()
 	go m.handleConnection(connMgr)
 
 	<-connMgr.done
@@ -455,8 +665,48 @@ func (m *Manager) handleRequest(connMgr *ConnectionManager, msg *protocol.WSMess
 	case "unregister_module":
 		m.handleModuleUnregistration(connMgr, msg)
 	default:
+		m.handleControlMethod(connMgr, msg)
+	}
+}
+
+// RegisterControlMethod registers a handler for a control method of the WebSocket
+// protocol. Registering a method again replaces its handler.
+func (m *Manager) RegisterControlMethod(method string, handler ControlHandler) {
+	m.controlMu.Lock()
+	defer m.controlMu.Unlock()
+
+	m.controlHandlers[method] = handler
+}
+
+func (m *Manager) handleControlMethod(connMgr *ConnectionManager, msg *protocol.WSMessage) {
+	m.controlMu.RLock()
+	handler, ok := m.controlHandlers[msg.Method]
+	m.controlMu.RUnlock()
+
+	if !ok {
 		m.sendErrorResponse(connMgr, msg, fmt.Sprintf("Unknown method: %s", msg.Method))
+		return
 	}
+
+	var data json.RawMessage
+
+	if msg.Data != nil {
+		dataBytes, err := json.Marshal(msg.Data)
+		if err != nil {
+			m.sendErrorResponse(connMgr, msg, fmt.Sprintf("Invalid message data: %v", err))
+			return
+		}
+
+		data = dataBytes
+	}
+
+	result, err := handler(data)
+	if err != nil {
+		m.sendErrorResponse(connMgr, msg, err.Error())
+		return
+	}
+
+	m.sendResponse(connMgr, msg, result)
 }
 
 func (m *Manager) handleModuleRegistration(connMgr *ConnectionManager, msg *protocol.WSMessage) {
@@ -479,27 +729,33 @@ func (m *Manager) handleModuleRegistration(connMgr *ConnectionManager, msg *prot
 		module = builtin.NewRequestCounter(moduleID, connMgr)
 	case "response_tracer":
 		module = builtin.NewResponseTracer(moduleID, connMgr)
+	case "request_interceptor":
+		module = builtin.NewRequestInterceptor(moduleID, connMgr)
+	case "response_interceptor":
+		module = builtin.NewResponseInterceptor(moduleID, connMgr)
 	default:
 		m.sendErrorResponse(connMgr, msg, fmt.Sprintf("Unknown module type: %s", req.Type))
 		return
 	}
 
-	filterConfig := m.parseFilterConfig(req.Config)
+	filterConfig, err := m.parseFilterConfig(req.Config)
+	if err != nil {
+		m.sendErrorResponse(connMgr, msg, fmt.Sprintf("Failed to parse filters: %v", err))
+		return
+	}
 
-	// Compile the filters if they have JSON queries
-	if filterConfig != nil {
-		if filterConfig.RequestFilter != nil && filterConfig.RequestFilter.JSONQuery != "" {
-			if err := m.filterEngine.CompileFilter(filterConfig.RequestFilter); err != nil {
-				m.sendErrorResponse(connMgr, msg, fmt.Sprintf("Failed to compile request filter: %v", err))
-				return
-			}
+	// Compile the filters
+	if filterConfig.RequestFilter != nil {
+		if err := m.filterEngine.CompileFilter(filterConfig.RequestFilter); err != nil {
+			m.sendErrorResponse(connMgr, msg, fmt.Sprintf("Failed to compile request filter: %v", err))
+			return
 		}
+	}
 
-		if filterConfig.ResponseFilter != nil && filterConfig.ResponseFilter.JSONQuery != "" {
-			if err := m.filterEngine.CompileFilter(filterConfig.ResponseFilter); err != nil {
-				m.sendErrorResponse(connMgr, msg, fmt.Sprintf("Failed to compile response filter: %v", err))
-				return
-			}
+	if filterConfig.ResponseFilter != nil {
+		if err := m.filterEngine.CompileFilter(filterConfig.ResponseFilter); err != nil {
+			m.sendErrorResponse(connMgr, msg, fmt.Sprintf("Failed to compile response filter: %v", err))
+			return
 		}
 	}
 
@@ -508,7 +764,14 @@ func (m *Manager) handleModuleRegistration(connMgr *ConnectionManager, msg *prot
 		return
 	}
 
-	if err := m.RegisterModule(module, filterConfig); err != nil {
+	info := ModuleInfo{
+		Type:     req.Type,
+		Name:     req.Name,
+		Priority: req.Priority,
+		Owner:    connMgr.name,
+	}
+
+	if err := m.RegisterModuleWithInfo(module, filterConfig, info); err != nil {
 		m.sendErrorResponse(connMgr, msg, fmt.Sprintf("Failed to register module: %v", err))
 		return
 	}
@@ -528,6 +791,7 @@ func (m *Manager) handleModuleRegistration(connMgr *ConnectionManager, msg *prot
 		"module_id":   moduleID,
 		"module_type": req.Type,
 		"module_name": req.Name,
+		"priority":    req.Priority,
 	}).Info("Module registered")
 }
 
@@ -1,5 +1,7 @@
 package protocol
 
+import "encoding/json"
+
 type WSMessage struct {
 	RequestID  uint64  `json:"reqid,omitempty"`
 	ResponseID uint64  `json:"rspid,omitempty"`
@@ -17,9 +19,10 @@ type WSMessageWithBinary struct {
 }
 
 type RegisterModuleRequest struct {
-	Type   string         `json:"type"`
-	Name   string         `json:"name"`
-	Config map[string]any `json:"config"`
+	Type     string         `json:"type"`
+	Name     string         `json:"name"`
+	Priority int            `json:"priority,omitempty"` // lower priorities run first, default 0
+	Config   map[string]any `json:"config"`
 }
 
 type RegisterModuleResponse struct {
@@ -34,7 +37,32 @@ type HookEvent struct {
 	RequestID   uint64 `json:"request_id"`
 	Data        any    `json:"data"`
 	ContentType string `json:"content_type"`
+	Upstream    string `json:"upstream,omitempty"`
+	BatchIndex  *int   `json:"batch_index,omitempty"`
+}
+
+type InterceptEvent struct {
+	ModuleID    uint64              `json:"module_id"`
+	HookType    string              `json:"hook_type"`
+	RequestID   uint64              `json:"request_id"`
+	Method      string              `json:"method,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	StatusCode  int                 `json:"status_code,omitempty"`
+	Headers     map[string][]string `json:"headers,omitempty"`
+	ContentType string              `json:"content_type"`
+	Upstream    string              `json:"upstream,omitempty"`
 }
+
+// InterceptVerdict is the control client's answer to an intercept_request or
+// intercept_response message. Action is one of "forward", "modify", "respond" or
+// "drop". A binary frame following the verdict replaces Body.
+type InterceptVerdict struct {
+	Action     string            `json:"action"`
+	StatusCode int               `json:"status_code,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`
+}
+
 type CounterEvent struct {
 	ModuleID    uint64            `json:"module_id"`
 	Count       int64             `json:"count"`
@@ -51,4 +79,41 @@ type TracerEvent struct {
 	StatusCode   int    `json:"status_code"`
 	RequestData  any    `json:"request_data,omitempty"`
 	ResponseData any    `json:"response_data,omitempty"`
+	Upstream     string `json:"upstream,omitempty"`
+	BatchIndex   *int   `json:"batch_index,omitempty"`
+}
+
+// MirrorDiffEvent is broadcast to all control clients when the mirror upstream
+// answered a call differently than the primary target, or failed to answer it.
+type MirrorDiffEvent struct {
+	RequestID     uint64             `json:"request_id"`
+	Method        string             `json:"method"`
+	URL           string             `json:"url"`
+	JRPCMethod    string             `json:"jrpc_method,omitempty"`
+	PrimaryStatus int                `json:"primary_status"`
+	MirrorStatus  int                `json:"mirror_status,omitempty"`
+	Differences   []MirrorDifference `json:"differences,omitempty"`
+	Error         string             `json:"error,omitempty"`
+}
+
+type MirrorDifference struct {
+	Path    string `json:"path"`
+	Primary any    `json:"primary"`
+	Mirror  any    `json:"mirror"`
+}
+
+// SchemaViolationEvent is broadcast to all control clients when an Engine API request
+// or response does not match the execution-apis schema of its method.
+type SchemaViolationEvent struct {
+	RequestID  uint64            `json:"request_id"`
+	JRPCMethod string            `json:"jrpc_method"`
+	Direction  string            `json:"direction"`
+	Upstream   string            `json:"upstream,omitempty"`
+	BatchIndex *int              `json:"batch_index,omitempty"`
+	Violations []SchemaViolation `json:"violations"`
+}
+
+type SchemaViolation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
 }
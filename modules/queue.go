@@ -0,0 +1,235 @@
+package modules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethpandaops/rpc-snooper/metrics"
+	"github.com/ethpandaops/rpc-snooper/modules/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// Overflow policies of the outbound queue of a control connection.
+const (
+	OverflowDropOldest = "drop-oldest"
+	OverflowDropNewest = "drop-newest"
+	OverflowDisconnect = "disconnect"
+)
+
+// DefaultSendQueueSize is the number of outbound messages queued per control
+// connection unless configured otherwise.
+const DefaultSendQueueSize = 1024
+
+var (
+	// ErrSendQueueFull is returned for messages dropped because the outbound queue of
+	// the connection is full.
+	ErrSendQueueFull = errors.New("send queue full")
+
+	// ErrConnectionClosed is returned for messages sent on a closed connection.
+	ErrConnectionClosed = errors.New("connection closed")
+)
+
+// SendQueueConfig configures the outbound queue of control connections.
+type SendQueueConfig struct {
+	// Size is the maximum number of queued messages per connection.
+	Size int
+
+	// Overflow decides what happens when a message is sent to a full queue:
+	// drop-oldest, drop-newest or disconnect.
+	Overflow string
+}
+
+// outboundMessage is a marshaled message waiting in the outbound queue, with the
+// binary frame sent right after it.
+type outboundMessage struct {
+	moduleID uint64
+	data     []byte
+	binary   []byte
+}
+
+// sendQueue is the bounded outbound queue of a control connection. Messages are
+// written by a single writer goroutine, so slow clients never block the senders.
+type sendQueue struct {
+	config   SendQueueConfig
+	mu       sync.Mutex
+	messages []*outboundMessage
+	notify   chan struct{}
+	dropped  uint64
+}
+
+// ConfigureSendQueue sets the outbound queue size and overflow policy of control
+// connections established afterwards.
+func (m *Manager) ConfigureSendQueue(config *SendQueueConfig) error {
+	if config.Size <= 0 {
+		return fmt.Errorf("invalid send queue size: %d", config.Size)
+	}
+
+	switch config.Overflow {
+	case OverflowDropOldest, OverflowDropNewest, OverflowDisconnect:
+	default:
+		return fmt.Errorf("unknown send queue overflow policy: %s", config.Overflow)
+	}
+
+	m.controlMu.Lock()
+	m.sendQueue = *config
+	m.controlMu.Unlock()
+
+	return nil
+}
+
+func newSendQueue(config SendQueueConfig) *sendQueue {
+	return &sendQueue{
+		config:   config,
+		messages: make([]*outboundMessage, 0, min(config.Size, 64)),
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+func (cm *ConnectionManager) SendMessage(msg *protocol.WSMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return cm.enqueue(&outboundMessage{
+		moduleID: msg.ModuleID,
+		data:     data,
+	})
+}
+
+func (cm *ConnectionManager) SendMessageWithBinary(msg *protocol.WSMessage, binaryData []byte) error {
+	// Set the Binary flag
+	msg.Binary = true
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if binaryData == nil {
+		binaryData = []byte{}
+	}
+
+	return cm.enqueue(&outboundMessage{
+		moduleID: msg.ModuleID,
+		data:     data,
+		binary:   binaryData,
+	})
+}
+
+// enqueue adds a message to the outbound queue, applying the overflow policy when
+// the queue is full.
+func (cm *ConnectionManager) enqueue(msg *outboundMessage) error {
+	if cm.isClosed() {
+		return ErrConnectionClosed
+	}
+
+	queue := cm.queue
+	queue.mu.Lock()
+
+	var dropped *outboundMessage
+
+	if len(queue.messages) >= queue.config.Size {
+		switch queue.config.Overflow {
+		case OverflowDropOldest:
+			dropped = queue.messages[0]
+			queue.messages[0] = nil
+			queue.messages = queue.messages[1:]
+		case OverflowDropNewest:
+			dropped = msg
+		case OverflowDisconnect:
+			queue.mu.Unlock()
+
+			cm.manager.recordSend(msg.moduleID, 0, ErrSendQueueFull)
+			cm.Close()
+
+			return ErrSendQueueFull
+		}
+	}
+
+	if dropped != nil {
+		queue.dropped++
+		metrics.ControlDroppedEventRegister(cm.name)
+	}
+
+	if dropped != msg {
+		queue.messages = append(queue.messages, msg)
+	}
+
+	metrics.ControlQueueDepthRegister(cm.name, len(queue.messages))
+	queue.mu.Unlock()
+
+	select {
+	case queue.notify <- struct{}{}:
+	default:
+	}
+
+	if dropped != nil {
+		cm.manager.recordSend(dropped.moduleID, 0, ErrSendQueueFull)
+	}
+
+	if dropped == msg {
+		return ErrSendQueueFull
+	}
+
+	return nil
+}
+
+// dequeue takes the next message from the outbound queue, or returns nil when the
+// queue is empty.
+func (cm *ConnectionManager) dequeue() *outboundMessage {
+	queue := cm.queue
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if len(queue.messages) == 0 {
+		return nil
+	}
+
+	msg := queue.messages[0]
+	queue.messages[0] = nil
+	queue.messages = queue.messages[1:]
+
+	metrics.ControlQueueDepthRegister(cm.name, len(queue.messages))
+
+	return msg
+}
+
+// QueueStats returns the current depth and the number of dropped messages of the
+// outbound queue.
+func (cm *ConnectionManager) QueueStats() (depth int, dropped uint64) {
+	cm.queue.mu.Lock()
+	defer cm.queue.mu.Unlock()
+
+	return len(cm.queue.messages), cm.queue.dropped
+}
+
+// writeLoop writes the queued messages to the connection until it is closed.
+func (cm *ConnectionManager) writeLoop() {
+	defer metrics.ControlConnectionClosed(cm.name)
+
+	for {
+		select {
+		case <-cm.done:
+			return
+		case <-cm.queue.notify:
+		}
+
+		for msg := cm.dequeue(); msg != nil; msg = cm.dequeue() {
+			err := cm.conn.WriteMessage(websocket.TextMessage, msg.data)
+			if err == nil && msg.binary != nil {
+				// the binary frame has to follow its JSON message immediately
+				err = cm.conn.WriteMessage(websocket.BinaryMessage, msg.binary)
+			}
+
+			cm.manager.recordSend(msg.moduleID, len(msg.data)+len(msg.binary), err)
+
+			if err != nil {
+				cm.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Package recording implements the on-disk format for recorded proxy traffic.
+// Recordings are JSONL files with one correlated request/response pair per line.
+package recording
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedactedHeaderValue replaces the values of headers holding credentials in recordings.
+const RedactedHeaderValue = "[redacted]"
+
+// Entry is a single recorded request/response pair.
+type Entry struct {
+	CallIndex    uint64    `json:"call_index"`
+	RequestTime  time.Time `json:"request_time"`
+	ResponseTime time.Time `json:"response_time"`
+	DurationMs   float64   `json:"duration_ms"`
+	Request      Request   `json:"request"`
+	Response     Response  `json:"response"`
+}
+
+// Request is the recorded request of an entry.
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers"`
+	Payload
+}
+
+// Response is the recorded response of an entry.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers"`
+	Payload
+}
+
+// Payload holds a decoded message body. JSON bodies are stored as is, all other
+// bodies (SSZ or anything that isn't valid JSON) are stored hex-encoded.
+type Payload struct {
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	BodyHex     string          `json:"body_hex,omitempty"`
+}
+
+// NewPayload builds the payload for a decompressed message body.
+func NewPayload(data []byte, contentType string) Payload {
+	payload := Payload{
+		ContentType: contentType,
+	}
+
+	switch {
+	case len(data) == 0:
+	case !strings.Contains(contentType, "application/octet-stream") && json.Valid(data):
+		payload.Body = json.RawMessage(append([]byte(nil), data...))
+	default:
+		payload.BodyHex = "0x" + hex.EncodeToString(data)
+	}
+
+	return payload
+}
+
+// Bytes returns the raw body of the payload.
+func (p *Payload) Bytes() ([]byte, error) {
+	if p.BodyHex != "" {
+		data, err := hex.DecodeString(strings.TrimPrefix(p.BodyHex, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex body: %w", err)
+		}
+
+		return data, nil
+	}
+
+	return p.Body, nil
+}
+
+// Writer appends entries to a recording file.
+type Writer struct {
+	mu      sync.Mutex
+	file    *os.File
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+// NewWriter opens the recording file at path, appending to it if it already exists.
+func NewWriter(path string) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gosec // path is provided by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording file: %w", err)
+	}
+
+	buf := bufio.NewWriter(file)
+
+	return &Writer{
+		file:    file,
+		buf:     buf,
+		encoder: json.NewEncoder(buf),
+	}, nil
+}
+
+// Write appends an entry to the recording. Each entry is flushed to disk
+// immediately, so a recording survives an unclean shutdown.
+func (w *Writer) Write(entry *Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return errors.New("recording is closed")
+	}
+
+	if err := w.encoder.Encode(entry); err != nil {
+		return fmt.Errorf("failed to encode entry: %w", err)
+	}
+
+	return w.buf.Flush()
+}
+
+// Close flushes and closes the recording file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	flushErr := w.buf.Flush()
+	closeErr := w.file.Close()
+	w.file = nil
+
+	if flushErr != nil {
+		return flushErr
+	}
+
+	return closeErr
+}
+
+// Reader reads entries from a recording.
+type Reader struct {
+	decoder *json.Decoder
+}
+
+// NewReader creates a reader for a recording stream.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		decoder: json.NewDecoder(r),
+	}
+}
+
+// Next returns the next entry of the recording, or io.EOF at the end of it.
+func (r *Reader) Next() (*Entry, error) {
+	entry := &Entry{}
+
+	if err := r.decoder.Decode(entry); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+
+		return nil, fmt.Errorf("failed to decode entry: %w", err)
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,240 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPayload(t *testing.T) {
+	payload := NewPayload([]byte(`{"result": "0x1"}`), "application/json")
+	assert.JSONEq(t, `{"result":"0x1"}`, string(payload.Body))
+	assert.Empty(t, payload.BodyHex)
+
+	payload = NewPayload([]byte{0x01, 0x02, 0xff}, "application/octet-stream")
+	assert.Nil(t, payload.Body)
+	assert.Equal(t, "0x0102ff", payload.BodyHex)
+
+	data, err := payload.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0x02, 0xff}, data)
+
+	// invalid JSON falls back to hex
+	payload = NewPayload([]byte("not json"), "application/json")
+	assert.Equal(t, "0x6e6f74206a736f6e", payload.BodyHex)
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+
+	writer, err := NewWriter(path)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	for idx := uint64(1); idx <= 2; idx++ {
+		require.NoError(t, writer.Write(&Entry{
+			CallIndex:    idx,
+			RequestTime:  now,
+			ResponseTime: now.Add(5 * time.Millisecond),
+			DurationMs:   5,
+			Request: Request{
+				Method:  http.MethodPost,
+				URL:     "/",
+				Headers: http.Header{"Content-Type": []string{"application/json"}},
+				Payload: NewPayload([]byte("{\n  \"method\": \"eth_chainId\"\n}"), "application/json"),
+			},
+			Response: Response{
+				StatusCode: http.StatusOK,
+				Payload:    NewPayload([]byte{0xaa}, "application/octet-stream"),
+			},
+		}))
+	}
+
+	require.NoError(t, writer.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(data, []byte("\n")), "Each entry must be written as a single line")
+
+	reader := NewReader(bytes.NewReader(data))
+
+	entry, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), entry.CallIndex)
+	assert.True(t, now.Equal(entry.RequestTime))
+	assert.JSONEq(t, `{"method":"eth_chainId"}`, string(entry.Request.Body))
+	assert.Equal(t, "0xaa", entry.Response.BodyHex)
+
+	entry, err = reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), entry.CallIndex)
+
+	_, err = reader.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestDiffResponses(t *testing.T) {
+	recorded := &Response{
+		StatusCode: http.StatusOK,
+		Payload:    NewPayload([]byte(`{"id":1,"result":{"status":"VALID","hashes":["0x1","0x2"]}}`), "application/json"),
+	}
+	replayed := &Response{
+		StatusCode: http.StatusOK,
+		Payload:    NewPayload([]byte(`{"id":1,"result":{"status":"INVALID","hashes":["0x1"],"extra":true}}`), "application/json"),
+	}
+
+	diffs := DiffResponses(recorded, replayed)
+	require.Len(t, diffs, 3)
+	assert.Equal(t, Difference{Path: ".result.extra", Recorded: nil, Replayed: true}, diffs[0])
+	assert.Equal(t, Difference{Path: ".result.hashes[1]", Recorded: "0x2", Replayed: nil}, diffs[1])
+	assert.Equal(t, Difference{Path: ".result.status", Recorded: "VALID", Replayed: "INVALID"}, diffs[2])
+
+	assert.Empty(t, DiffResponses(recorded, recorded))
+
+	sszRecorded := &Response{StatusCode: http.StatusOK, Payload: NewPayload([]byte{0x01}, "application/octet-stream")}
+	sszReplayed := &Response{StatusCode: http.StatusNotFound, Payload: NewPayload([]byte{0x02}, "application/octet-stream")}
+
+	diffs = DiffResponses(sszRecorded, sszReplayed)
+	require.Len(t, diffs, 2)
+	assert.Equal(t, "status_code", diffs[0].Path)
+	assert.Equal(t, "body", diffs[1].Path)
+}
+
+func TestReplayerResignsAndDiffs(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	validTokens := &atomic.Int64{}
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		token, err := jwt.Parse(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), func(*jwt.Token) (any, error) {
+			return secret, nil
+		})
+		if err == nil && token.Valid {
+			validTokens.Add(1)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if strings.Contains(string(body), "engine_newPayloadV4") {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"status":"INVALID"}}`))
+		} else {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+		}
+	}))
+	defer target.Close()
+
+	start := time.Now()
+	recordingData := &bytes.Buffer{}
+
+	for idx, method := range []string{"eth_chainId", "engine_newPayloadV4"} {
+		entry := &Entry{
+			CallIndex:   uint64(idx + 1),
+			RequestTime: start.Add(time.Duration(idx) * 100 * time.Millisecond),
+			Request: Request{
+				Method:  http.MethodPost,
+				URL:     "/",
+				Headers: http.Header{"Authorization": []string{"Bearer expired"}, "Content-Type": []string{"application/json"}},
+				Payload: NewPayload([]byte(`{"jsonrpc":"2.0","id":1,"method":"`+method+`","params":[]}`), "application/json"),
+			},
+			Response: Response{
+				StatusCode: http.StatusOK,
+				Payload:    NewPayload([]byte(`{"jsonrpc":"2.0","id":1,"result":{"status":"VALID"}}`), "application/json"),
+			},
+		}
+
+		if idx == 0 {
+			entry.Response.Payload = NewPayload([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`), "application/json")
+		}
+
+		require.NoError(t, json.NewEncoder(recordingData).Encode(entry))
+	}
+
+	targetURL, err := url.Parse(target.URL)
+	require.NoError(t, err)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	replayer := NewReplayer(&ReplayConfig{
+		Target:     targetURL,
+		KeepTiming: true,
+		JWTSecret:  secret,
+	}, logger)
+
+	replayStart := time.Now()
+
+	report, err := replayer.Replay(context.Background(), NewReader(recordingData))
+	require.NoError(t, err)
+
+	assert.GreaterOrEqual(t, time.Since(replayStart), 100*time.Millisecond, "Original timing should be kept")
+	assert.Equal(t, int64(2), validTokens.Load())
+	assert.Equal(t, 2, report.Total)
+	assert.Equal(t, 1, report.Matched)
+	assert.Equal(t, 1, report.Differed)
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, "engine_newPayloadV4", report.Results[0].JRPCMethod)
+	assert.Equal(t, []Difference{{Path: ".result.status", Recorded: "VALID", Replayed: "INVALID"}}, report.Results[0].Differences)
+}
+
+func TestReplayerSendsDecompressedBody(t *testing.T) {
+	var (
+		contentEncoding string
+		receivedBody    []byte
+	)
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentEncoding = r.Header.Get("Content-Encoding")
+		receivedBody, _ = io.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer target.Close()
+
+	// gzip encoded requests are recorded with their decompressed body
+	recordingData := &bytes.Buffer{}
+	require.NoError(t, json.NewEncoder(recordingData).Encode(&Entry{
+		CallIndex:   1,
+		RequestTime: time.Now(),
+		Request: Request{
+			Method:  http.MethodPost,
+			URL:     "/",
+			Headers: http.Header{"Content-Encoding": []string{"gzip"}, "Content-Type": []string{"application/json"}},
+			Payload: NewPayload([]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`), "application/json"),
+		},
+		Response: Response{
+			StatusCode: http.StatusOK,
+			Payload:    NewPayload([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`), "application/json"),
+		},
+	}))
+
+	targetURL, err := url.Parse(target.URL)
+	require.NoError(t, err)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	report, err := NewReplayer(&ReplayConfig{Target: targetURL}, logger).Replay(context.Background(), NewReader(recordingData))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, report.Matched)
+	assert.Empty(t, contentEncoding)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`, string(receivedBody))
+}
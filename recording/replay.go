@@ -0,0 +1,450 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultReplayTimeout = 60 * time.Second
+
+	// maxDifferences limits the number of differences reported per entry.
+	maxDifferences = 50
+)
+
+// skippedReplayHeaders are recorded headers that must not be sent again. The transport
+// sets them itself; Accept-Encoding is dropped so responses are decompressed for diffing.
+// Content-Encoding is dropped as recorded bodies are stored decompressed.
+var skippedReplayHeaders = []string{
+	"Accept-Encoding",
+	"Connection",
+	"Content-Encoding",
+	"Content-Length",
+	"Keep-Alive",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ReplayConfig configures a Replayer.
+type ReplayConfig struct {
+	// Target is the base URL the recorded calls are sent to.
+	Target *url.URL
+
+	// KeepTiming replays calls with their original spacing instead of back to back.
+	KeepTiming bool
+
+	// JWTSecret re-signs the Authorization header of every call when set.
+	JWTSecret []byte
+
+	// Timeout is the timeout for each replayed call.
+	Timeout time.Duration
+}
+
+// Difference describes a mismatch between a recorded and a replayed response.
+type Difference struct {
+	Path     string `json:"path"`
+	Recorded any    `json:"recorded"`
+	Replayed any    `json:"replayed"`
+}
+
+// Result is the outcome of a single replayed call.
+type Result struct {
+	CallIndex   uint64       `json:"call_index"`
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	JRPCMethod  string       `json:"jrpc_method,omitempty"`
+	Duration    float64      `json:"duration_ms"`
+	Differences []Difference `json:"differences,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// Report summarizes a replay run. Only calls that failed or differed are included
+// in the results.
+type Report struct {
+	Total    int       `json:"total"`
+	Matched  int       `json:"matched"`
+	Differed int       `json:"differed"`
+	Failed   int       `json:"failed"`
+	Results  []*Result `json:"results"`
+}
+
+// Replayer re-sends recorded calls to a target and compares the responses.
+type Replayer struct {
+	config *ReplayConfig
+	client *http.Client
+	logger logrus.FieldLogger
+}
+
+// NewReplayer creates a new Replayer.
+func NewReplayer(config *ReplayConfig, logger logrus.FieldLogger) *Replayer {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultReplayTimeout
+	}
+
+	return &Replayer{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+		logger: logger.WithField("component", "replay"),
+	}
+}
+
+// Replay sends all entries of the recording to the target in order. Calls are sent
+// one at a time; with KeepTiming a call is delayed until its original offset from
+// the first recorded call has passed.
+func (r *Replayer) Replay(ctx context.Context, reader *Reader) (*Report, error) {
+	report := &Report{
+		Results: []*Result{},
+	}
+
+	var (
+		firstRequest time.Time
+		replayStart  time.Time
+	)
+
+	for {
+		entry, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return report, err
+		}
+
+		if r.config.KeepTiming {
+			if replayStart.IsZero() {
+				firstRequest = entry.RequestTime
+				replayStart = time.Now()
+			}
+
+			if err := sleepUntil(ctx, replayStart.Add(entry.RequestTime.Sub(firstRequest))); err != nil {
+				return report, err
+			}
+		}
+
+		result := r.replayEntry(ctx, entry)
+		report.Total++
+
+		logFields := logrus.Fields{
+			"callidx":     result.CallIndex,
+			"duration_ms": result.Duration,
+		}
+
+		if result.JRPCMethod != "" {
+			logFields["method"] = result.JRPCMethod
+		}
+
+		switch {
+		case result.Error != "":
+			report.Failed++
+			report.Results = append(report.Results, result)
+
+			r.logger.WithFields(logFields).Errorf("REPLAY #%v: %v %v failed: %v", result.CallIndex, result.Method, result.URL, result.Error)
+		case len(result.Differences) > 0:
+			report.Differed++
+			report.Results = append(report.Results, result)
+
+			for _, diff := range result.Differences {
+				r.logger.WithFields(logFields).WithFields(logrus.Fields{
+					"path":     diff.Path,
+					"recorded": formatDiffValue(diff.Recorded),
+					"replayed": formatDiffValue(diff.Replayed),
+				}).Warnf("REPLAY #%v: %v %v response differs", result.CallIndex, result.Method, result.URL)
+			}
+		default:
+			report.Matched++
+
+			r.logger.WithFields(logFields).Debugf("REPLAY #%v: %v %v matches", result.CallIndex, result.Method, result.URL)
+		}
+	}
+
+	return report, nil
+}
+
+// replayEntry sends a single recorded call and compares its response.
+func (r *Replayer) replayEntry(ctx context.Context, entry *Entry) *Result {
+	result := &Result{
+		CallIndex:  entry.CallIndex,
+		Method:     entry.Request.Method,
+		URL:        entry.Request.URL,
+		JRPCMethod: getJSONRPCMethod(entry.Request.Body),
+	}
+
+	req, err := r.buildRequest(ctx, entry)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+
+	rsp, err := r.client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer rsp.Body.Close()
+
+	bodyData, err := io.ReadAll(rsp.Body)
+
+	result.Duration = float64(time.Since(start).Microseconds()) / 1000
+
+	if err != nil {
+		result.Error = fmt.Sprintf("failed reading response body: %v", err)
+		return result
+	}
+
+	replayed := Response{
+		StatusCode: rsp.StatusCode,
+		Headers:    rsp.Header,
+		Payload:    NewPayload(bodyData, rsp.Header.Get("Content-Type")),
+	}
+
+	result.Differences = DiffResponses(&entry.Response, &replayed)
+
+	return result
+}
+
+// buildRequest rebuilds a recorded request against the replay target.
+func (r *Replayer) buildRequest(ctx context.Context, entry *Entry) (*http.Request, error) {
+	recordedURL, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded url: %w", err)
+	}
+
+	queryArgs := ""
+	if recordedURL.RawQuery != "" {
+		queryArgs = "?" + recordedURL.RawQuery
+	}
+
+	targetURL, err := url.Parse(fmt.Sprintf("%s%s%s", r.config.Target, recordedURL.EscapedPath(), queryArgs))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing replay url: %w", err)
+	}
+
+	body, err := entry.Request.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, entry.Request.Method, targetURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for name, values := range entry.Request.Headers {
+		for _, value := range values {
+			if value == RedactedHeaderValue {
+				// credentials are not recorded, calls are re-signed with JWTSecret
+				continue
+			}
+
+			req.Header.Add(name, value)
+		}
+	}
+
+	for _, name := range skippedReplayHeaders {
+		req.Header.Del(name)
+	}
+
+	if len(r.config.JWTSecret) > 0 {
+		token, err := utils.CreateJWTToken(r.config.JWTSecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create JWT token: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return req, nil
+}
+
+// DiffResponses compares a recorded response with a replayed one. Headers are not
+// compared, as they usually contain volatile values like dates.
+func DiffResponses(recorded, replayed *Response) []Difference {
+	diffs := []Difference{}
+
+	if recorded.StatusCode != replayed.StatusCode {
+		diffs = append(diffs, Difference{
+			Path:     "status_code",
+			Recorded: recorded.StatusCode,
+			Replayed: replayed.StatusCode,
+		})
+	}
+
+	if recorded.Body != nil && replayed.Body != nil {
+		var recordedBody, replayedBody any
+
+		if json.Unmarshal(recorded.Body, &recordedBody) == nil && json.Unmarshal(replayed.Body, &replayedBody) == nil {
+			diffs = diffValues("", recordedBody, replayedBody, diffs)
+
+			return limitDifferences(diffs)
+		}
+	}
+
+	recordedBody, _ := recorded.Bytes()
+	replayedBody, _ := replayed.Bytes()
+
+	if !bytes.Equal(recordedBody, replayedBody) {
+		diffs = append(diffs, Difference{
+			Path:     "body",
+			Recorded: fmt.Sprintf("<%d bytes>", len(recordedBody)),
+			Replayed: fmt.Sprintf("<%d bytes>", len(replayedBody)),
+		})
+	}
+
+	return diffs
+}
+
+// diffValues recursively compares two decoded JSON values, using jq-style paths.
+func diffValues(path string, recorded, replayed any, diffs []Difference) []Difference {
+	if len(diffs) > maxDifferences {
+		return diffs
+	}
+
+	switch recordedValue := recorded.(type) {
+	case map[string]any:
+		replayedValue, ok := replayed.(map[string]any)
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(recordedValue)+len(replayedValue))
+
+		for key := range recordedValue {
+			keys = append(keys, key)
+		}
+
+		for key := range replayedValue {
+			if _, exists := recordedValue[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			diffs = diffValues(path+"."+key, recordedValue[key], replayedValue[key], diffs)
+		}
+
+		return diffs
+	case []any:
+		replayedValue, ok := replayed.([]any)
+		if !ok {
+			break
+		}
+
+		for idx := 0; idx < len(recordedValue) || idx < len(replayedValue); idx++ {
+			var recordedItem, replayedItem any
+
+			if idx < len(recordedValue) {
+				recordedItem = recordedValue[idx]
+			}
+
+			if idx < len(replayedValue) {
+				replayedItem = replayedValue[idx]
+			}
+
+			diffs = diffValues(path+"["+strconv.Itoa(idx)+"]", recordedItem, replayedItem, diffs)
+		}
+
+		return diffs
+	}
+
+	if reflect.DeepEqual(recorded, replayed) {
+		return diffs
+	}
+
+	if path == "" {
+		path = "."
+	}
+
+	return append(diffs, Difference{
+		Path:     path,
+		Recorded: recorded,
+		Replayed: replayed,
+	})
+}
+
+func limitDifferences(diffs []Difference) []Difference {
+	if len(diffs) > maxDifferences {
+		return diffs[:maxDifferences]
+	}
+
+	return diffs
+}
+
+// formatDiffValue renders a difference value for log output.
+func formatDiffValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(data)
+}
+
+// getJSONRPCMethod returns the method of a JSON-RPC request body, or the
+// comma-separated methods of a batch.
+func getJSONRPCMethod(body json.RawMessage) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	type jrpcRequest struct {
+		Method string `json:"method"`
+	}
+
+	var single jrpcRequest
+	if err := json.Unmarshal(body, &single); err == nil {
+		return single.Method
+	}
+
+	var batch []jrpcRequest
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return ""
+	}
+
+	methods := make([]string, 0, len(batch))
+
+	for _, item := range batch {
+		if item.Method != "" {
+			methods = append(methods, item.Method)
+		}
+	}
+
+	return strings.Join(methods, ", ")
+}
+
+// sleepUntil waits until the given time or until the context is cancelled.
+func sleepUntil(ctx context.Context, deadline time.Time) error {
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
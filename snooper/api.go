@@ -2,9 +2,12 @@ package snooper
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
 type API struct {
@@ -24,6 +27,17 @@ func (api *API) initRouter(router *mux.Router) {
 	router.HandleFunc("/status", api.handleStatus).Methods("GET")
 	router.HandleFunc("/block", api.handleBlock).Methods("GET")
 	router.HandleFunc("/unblock", api.handleUnblock).Methods("GET")
+	router.HandleFunc("/faults", api.handleListFaults).Methods("GET")
+	router.HandleFunc("/faults", api.handleAddFault).Methods("POST")
+	router.HandleFunc("/faults", api.handleClearFaults).Methods("DELETE")
+	router.HandleFunc("/faults/{id}", api.handleDeleteFault).Methods("DELETE")
+	router.HandleFunc("/rewrites", api.handleListRewrites).Methods("GET")
+	router.HandleFunc("/rewrites", api.handleAddRewrite).Methods("POST")
+	router.HandleFunc("/rewrites", api.handleClearRewrites).Methods("DELETE")
+	router.HandleFunc("/rewrites/{id}", api.handleDeleteRewrite).Methods("DELETE")
+	router.HandleFunc("/modules", api.handleListModules).Methods("GET")
+	router.HandleFunc("/har", api.handleHAR).Methods("GET")
+	router.HandleFunc("/engine/state", api.handleEngineState).Methods("GET")
 	router.PathPrefix("/").Handler(http.DefaultServeMux)
 }
 
@@ -142,3 +156,221 @@ func (api *API) handleStatus(w http.ResponseWriter, _ *http.Request) {
 		api.snooper.logger.Errorf("failed writing status response: %v", err)
 	}
 }
+
+func (api *API) handleListFaults(w http.ResponseWriter, _ *http.Request) {
+	api.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"faults": api.snooper.faultEngine.Rules(),
+	})
+}
+
+func (api *API) handleAddFault(w http.ResponseWriter, r *http.Request) {
+	rule := &FaultRule{}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(rule); err != nil {
+		api.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid fault rule: %v", err),
+		})
+
+		return
+	}
+
+	added, err := api.snooper.faultEngine.AddRule(rule)
+	if err != nil {
+		api.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid fault rule: %v", err),
+		})
+
+		return
+	}
+
+	api.snooper.logger.WithFields(logrus.Fields{
+		"rule":   added.ID,
+		"action": added.Action.Type,
+	}).Info("Fault rule added")
+
+	api.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Fault rule added",
+		"fault":   added,
+	})
+}
+
+func (api *API) handleDeleteFault(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !api.snooper.faultEngine.RemoveRule(id) {
+		api.writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": "Fault rule not found",
+		})
+
+		return
+	}
+
+	api.snooper.logger.WithField("rule", id).Info("Fault rule removed")
+
+	api.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Fault rule removed",
+	})
+}
+
+func (api *API) handleClearFaults(w http.ResponseWriter, _ *http.Request) {
+	api.snooper.faultEngine.ClearRules()
+	api.snooper.logger.Info("Fault rules cleared")
+
+	api.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Fault rules cleared",
+	})
+}
+
+func (api *API) handleListRewrites(w http.ResponseWriter, _ *http.Request) {
+	api.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":   "success",
+		"rewrites": api.snooper.rewriteEngine.Rules(),
+	})
+}
+
+func (api *API) handleAddRewrite(w http.ResponseWriter, r *http.Request) {
+	rule := &RewriteRule{}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(rule); err != nil {
+		api.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid rewrite rule: %v", err),
+		})
+
+		return
+	}
+
+	added, err := api.snooper.rewriteEngine.AddRule(rule)
+	if err != nil {
+		api.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": fmt.Sprintf("Invalid rewrite rule: %v", err),
+		})
+
+		return
+	}
+
+	api.snooper.logger.WithFields(logrus.Fields{
+		"rule":  added.ID,
+		"stage": added.Stage,
+	}).Info("Rewrite rule added")
+
+	api.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Rewrite rule added",
+		"rewrite": added,
+	})
+}
+
+func (api *API) handleDeleteRewrite(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if !api.snooper.rewriteEngine.RemoveRule(id) {
+		api.writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": "Rewrite rule not found",
+		})
+
+		return
+	}
+
+	api.snooper.logger.WithField("rule", id).Info("Rewrite rule removed")
+
+	api.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Rewrite rule removed",
+	})
+}
+
+func (api *API) handleClearRewrites(w http.ResponseWriter, _ *http.Request) {
+	api.snooper.rewriteEngine.ClearRules()
+	api.snooper.logger.Info("Rewrite rules cleared")
+
+	api.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Rewrite rules cleared",
+	})
+}
+
+func (api *API) handleListModules(w http.ResponseWriter, _ *http.Request) {
+	api.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"modules": api.snooper.moduleManager.ModuleChain(),
+	})
+}
+
+func (api *API) handleHAR(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := &HARFilter{
+		PathPrefix:  query.Get("path"),
+		JRPCMethods: query["method"],
+		StatusCodes: query["status"],
+	}
+
+	for param, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			api.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": fmt.Sprintf("Invalid %v time (expected RFC3339): %v", param, err),
+			})
+
+			return
+		}
+
+		*target = parsed
+	}
+
+	har := api.snooper.BuildHAR(filter)
+	if har == nil {
+		api.writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": "Call history is disabled",
+		})
+
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="snooper.har"`)
+	api.writeJSON(w, http.StatusOK, har)
+}
+
+func (api *API) handleEngineState(w http.ResponseWriter, _ *http.Request) {
+	if api.snooper.engineTracker == nil {
+		api.writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": "Engine API tracking is disabled",
+		})
+
+		return
+	}
+
+	api.writeJSON(w, http.StatusOK, api.snooper.engineTracker.GetState())
+}
+
+func (api *API) writeJSON(w http.ResponseWriter, statusCode int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		api.snooper.logger.Errorf("failed writing api response: %v", err)
+	}
+}
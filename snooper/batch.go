@@ -0,0 +1,160 @@
+package snooper
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// batchCall is a single element of a JSON-RPC batch request. Modules, filters and
+// metrics handle every element as a call of its own.
+type batchCall struct {
+	callCtx  *ProxyCallContext
+	id       string
+	answered bool
+}
+
+// newBatchCallContext creates the call context of a single batch element. It shares
+// the call index and upstream of the batch call but keeps its own module data. Elements
+// of a batch split by route get the route serving them.
+func (callContext *ProxyCallContext) newBatchCallContext(batchIndex int) *ProxyCallContext {
+	batchCtx := &ProxyCallContext{
+		callIndex:  callContext.callIndex,
+		context:    callContext.context,
+		startTime:  callContext.startTime,
+		upstream:   callContext.upstream,
+		route:      callContext.route,
+		routeName:  callContext.routeName,
+		data:       make(map[string]interface{}),
+		batchIndex: batchIndex,
+	}
+
+	if batchIndex < len(callContext.batchRoutes) {
+		batchCtx.setRoute(callContext.batchRoutes[batchIndex])
+	}
+
+	return batchCtx
+}
+
+// processBatchRequestModules processes each element of a JSON-RPC batch request through
+// modules and keeps the sub-calls for matching them with the batch response.
+func (s *Snooper) processBatchRequestModules(ctx *ProxyCallContext, req *http.Request, batch []interface{}, contentType string) {
+	batchCalls := make([]*batchCall, 0, len(batch))
+
+	for idx, item := range batch {
+		call := &batchCall{
+			callCtx: ctx.newBatchCallContext(idx),
+			id:      getJSONRPCID(item),
+		}
+
+		elementData, err := json.Marshal(item)
+		if err != nil {
+			continue
+		}
+
+		call.callCtx.SetData(0, "request_size", len(elementData))
+
+		if method, ok := item.(map[string]interface{})["method"].(string); ok && s.metricsEnabled {
+			call.callCtx.SetData(0, "jrpc_method", method)
+		}
+
+		s.processCallRequestModules(call.callCtx, req, elementData, item, contentType)
+
+		batchCalls = append(batchCalls, call)
+	}
+
+	ctx.SetData(0, "batch_calls", batchCalls)
+}
+
+// processBatchResponseModules matches the elements of a batch response to the batch
+// sub-calls by id and processes each of them through modules. A batch answered with a
+// single response (e.g. an error for the whole batch) is handed to every sub-call
+// expecting a response.
+func (s *Snooper) processBatchResponseModules(ctx *ProxyCallContext, batchCalls []*batchCall, req *http.Request, rsp *http.Response, bodyData []byte, parsedData interface{}, contentType string) {
+	responses, isBatch := parsedData.([]interface{})
+	if !isBatch {
+		for _, call := range batchCalls {
+			if call.id == "" {
+				continue
+			}
+
+			call.callCtx.callDuration = ctx.callDuration
+			s.processCallResponseModules(call.callCtx, req, rsp, bodyData, parsedData, contentType)
+		}
+
+		return
+	}
+
+	for _, item := range responses {
+		id := getJSONRPCID(item)
+		call := takeBatchCall(batchCalls, id)
+
+		if call == nil {
+			s.logger.WithField("callidx", ctx.callIndex).Debugf("no batch request found for response id %v", id)
+			continue
+		}
+
+		elementData, err := json.Marshal(item)
+		if err != nil {
+			continue
+		}
+
+		call.callCtx.callDuration = ctx.callDuration
+		s.processCallResponseModules(call.callCtx, req, rsp, elementData, item, contentType)
+	}
+}
+
+// takeBatchCall returns the first unanswered sub-call with the given id and marks it
+// as answered.
+func takeBatchCall(batchCalls []*batchCall, id string) *batchCall {
+	if id == "" {
+		return nil
+	}
+
+	for _, call := range batchCalls {
+		if !call.answered && call.id == id {
+			call.answered = true
+			return call
+		}
+	}
+
+	return nil
+}
+
+// getJSONRPCBatch returns the elements of a JSON-RPC batch request, or nil if the
+// parsed body is no batch request. JSON arrays that are no batch (e.g. beacon API
+// request bodies) are not split.
+func getJSONRPCBatch(parsedData interface{}) []interface{} {
+	batch, ok := parsedData.([]interface{})
+	if !ok || len(batch) == 0 {
+		return nil
+	}
+
+	for _, item := range batch {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		if _, ok := obj["method"].(string); !ok {
+			return nil
+		}
+	}
+
+	return batch
+}
+
+// getJSONRPCID returns the id of a JSON-RPC message in its JSON encoding, so 1 and "1"
+// are told apart. It is empty for notifications and messages with a null id.
+func getJSONRPCID(item interface{}) string {
+	obj, ok := item.(map[string]interface{})
+	if !ok || obj["id"] == nil {
+		return ""
+	}
+
+	id, err := json.Marshal(obj["id"])
+	if err != nil {
+		return ""
+	}
+
+	return string(id)
+}
@@ -0,0 +1,131 @@
+package snooper
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/modules"
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchCaptureModule records the sub-calls it sees as "<batch index>:<call id>:<value>".
+type batchCaptureModule struct {
+	mu        sync.Mutex
+	requests  []string
+	responses []string
+}
+
+func (m *batchCaptureModule) ID() uint64 {
+	return 100
+}
+
+func (m *batchCaptureModule) OnRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
+	body, _ := ctx.Body.(map[string]any)
+
+	m.mu.Lock()
+	m.requests = append(m.requests, formatBatchCapture(ctx.CallCtx, body["method"]))
+	m.mu.Unlock()
+
+	return ctx, nil
+}
+
+func (m *batchCaptureModule) OnResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	body, _ := ctx.Body.(map[string]any)
+
+	m.mu.Lock()
+	m.responses = append(m.responses, formatBatchCapture(ctx.CallCtx, body["result"]))
+	m.mu.Unlock()
+
+	return ctx, nil
+}
+
+func (m *batchCaptureModule) Configure(_ map[string]any) error {
+	return nil
+}
+
+func (m *batchCaptureModule) Close() error {
+	return nil
+}
+
+func (m *batchCaptureModule) captured() (requests, responses []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	requests = append([]string{}, m.requests...)
+	responses = append([]string{}, m.responses...)
+
+	sort.Strings(responses)
+
+	return requests, responses
+}
+
+func formatBatchCapture(callCtx types.ProxyCallContext, value any) string {
+	return fmt.Sprintf("%d:%d:%v", callCtx.BatchIndex(), callCtx.ID(), value)
+}
+
+func TestBatchSplitIntoSubCalls(t *testing.T) {
+	snooper, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		// answered out of order, the notification gets no response
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[
+			{"jsonrpc":"2.0","id":"b","result":"0x2"},
+			{"jsonrpc":"2.0","id":99,"result":"0x99"},
+			{"jsonrpc":"2.0","id":1,"result":"0x1"}
+		]`))
+	})
+
+	capture := &batchCaptureModule{}
+	filter := &types.FilterConfig{
+		RequestFilter: &types.Filter{JSONQuery: `.method | startswith("engine_")`},
+	}
+
+	require.NoError(t, modules.NewFilterEngine(snooper.logger).CompileFilter(filter.RequestFilter))
+	require.NoError(t, snooper.moduleManager.RegisterModule(capture, filter))
+
+	rsp, _, err := postJSON(t, proxyURL, `[
+		{"jsonrpc":"2.0","id":1,"method":"engine_newPayloadV4","params":[]},
+		{"jsonrpc":"2.0","id":2,"method":"eth_chainId","params":[]},
+		{"jsonrpc":"2.0","id":"b","method":"engine_getBlobsV1","params":[]},
+		{"jsonrpc":"2.0","method":"engine_notify","params":[]}
+	]`)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+
+	require.Eventually(t, func() bool {
+		_, responses := capture.captured()
+		return len(responses) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	requests, responses := capture.captured()
+
+	// eth_chainId is filtered per element, the unmatched response id 99 is skipped
+	assert.Equal(t, []string{"0:1:engine_newPayloadV4", "2:1:engine_getBlobsV1", "3:1:engine_notify"}, requests)
+	assert.Equal(t, []string{"0:1:0x1", "2:1:0x2"}, responses)
+}
+
+func TestGetJSONRPCBatch(t *testing.T) {
+	batch := []any{
+		map[string]any{"jsonrpc": "2.0", "id": float64(1), "method": "eth_chainId"},
+		map[string]any{"jsonrpc": "2.0", "id": float64(2), "method": "eth_blockNumber"},
+	}
+	assert.Len(t, getJSONRPCBatch(batch), 2)
+
+	// beacon API array bodies are no JSON-RPC batches
+	assert.Nil(t, getJSONRPCBatch([]any{"1", "2"}))
+	assert.Nil(t, getJSONRPCBatch([]any{map[string]any{"index": "1"}}))
+	assert.Nil(t, getJSONRPCBatch(map[string]any{"method": "eth_chainId"}))
+	assert.Nil(t, getJSONRPCBatch([]any{}))
+
+	assert.Equal(t, "1", getJSONRPCID(map[string]any{"id": float64(1)}))
+	assert.Equal(t, `"1"`, getJSONRPCID(map[string]any{"id": "1"}))
+	assert.Empty(t, getJSONRPCID(map[string]any{"id": nil}))
+}
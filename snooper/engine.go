@@ -0,0 +1,27 @@
+package snooper
+
+import (
+	"fmt"
+
+	"github.com/ethpandaops/rpc-snooper/engine"
+	"github.com/ethpandaops/rpc-snooper/modules"
+	"github.com/ethpandaops/rpc-snooper/modules/builtin"
+)
+
+// EnableEngineTracking registers a module following the forkchoice and payload
+// lifecycle of the Engine API session. The tracked state and anomalies are served
+// at /_snooper/engine/state.
+func (s *Snooper) EnableEngineTracking(syncingStreakThreshold int) error {
+	tracker := engine.NewTracker(s.logger)
+	tracker.SyncingStreakThreshold = syncingStreakThreshold
+
+	trackerModule := builtin.NewEngineTrackerModule(s.moduleManager.GenerateModuleID(), tracker)
+
+	if err := s.moduleManager.RegisterModuleWithInfo(trackerModule, nil, modules.ModuleInfo{Type: "engine_tracker"}); err != nil {
+		return fmt.Errorf("failed to register engine tracker module: %w", err)
+	}
+
+	s.engineTracker = tracker
+
+	return nil
+}
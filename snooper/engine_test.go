@@ -0,0 +1,49 @@
+package snooper
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineStateAPI(t *testing.T) {
+	snooper, proxyURL, _ := newFaultTestSnooper(t)
+
+	rsp, err := http.Get(proxyURL + "/_snooper/engine/state") //nolint:noctx // test request
+	require.NoError(t, err)
+	rsp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, rsp.StatusCode)
+
+	require.NoError(t, snooper.EnableEngineTracking(32))
+
+	_, _, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"engine_forkchoiceUpdatedV3","params":[{"headBlockHash":"0x01","safeBlockHash":"0x02","finalizedBlockHash":"0x03"},null],"id":1}`)
+	require.NoError(t, err)
+
+	_, _, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"engine_getPayloadV4","params":["0x0000000000000001"],"id":1}`)
+	require.NoError(t, err)
+
+	state := &engine.State{}
+
+	require.Eventually(t, func() bool {
+		rsp, err := http.Get(proxyURL + "/_snooper/engine/state") //nolint:noctx // test request
+		require.NoError(t, err)
+
+		defer rsp.Body.Close()
+
+		require.Equal(t, http.StatusOK, rsp.StatusCode)
+		require.NoError(t, json.NewDecoder(rsp.Body).Decode(state))
+
+		return len(state.Anomalies) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	require.NotNil(t, state.Forkchoice)
+	assert.Equal(t, "0x01", state.Forkchoice.HeadBlockHash)
+	assert.Equal(t, "0x03", state.Forkchoice.FinalizedBlockHash)
+	assert.Equal(t, engine.AnomalyUnknownPayloadID, state.Anomalies[0].Type)
+}
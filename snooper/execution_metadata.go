@@ -3,20 +3,17 @@ package snooper
 import (
 	"bytes"
 	"context"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/ethpandaops/ethcore/pkg/ethereum/clients"
+	"github.com/ethpandaops/rpc-snooper/utils"
 	"github.com/ethpandaops/rpc-snooper/xatu"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/sirupsen/logrus"
 )
 
@@ -55,7 +52,10 @@ type ExecutionMetadataFetcher struct {
 
 // NewExecutionMetadataFetcher creates a new ExecutionMetadataFetcher.
 func NewExecutionMetadataFetcher(targetURL *url.URL, jwtSecret string, log logrus.FieldLogger) *ExecutionMetadataFetcher {
-	secret := parseJWTSecret(jwtSecret, log)
+	secret, err := utils.ParseJWTSecret(jwtSecret)
+	if err != nil {
+		log.WithError(err).Error("failed to parse JWT secret")
+	}
 
 	return &ExecutionMetadataFetcher{
 		targetURL: targetURL,
@@ -69,58 +69,9 @@ func NewExecutionMetadataFetcher(targetURL *url.URL, jwtSecret string, log logru
 	}
 }
 
-// parseJWTSecret parses a JWT secret from either a file path or hex-encoded string.
-// If the value looks like a file path, it reads the secret from the file.
-// Otherwise, it treats it as a hex-encoded value (with optional "0x" prefix).
-func parseJWTSecret(s string, log logrus.FieldLogger) []byte {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return nil
-	}
-
-	// Check if it looks like a file path
-	if strings.HasPrefix(s, "/") || strings.HasPrefix(s, "./") || strings.HasPrefix(s, "../") {
-		data, err := os.ReadFile(s)
-		if err != nil {
-			log.WithError(err).WithField("path", s).Error("failed to read JWT secret from file")
-
-			return nil
-		}
-
-		// File contents should be hex-encoded
-		return parseHexSecret(strings.TrimSpace(string(data)))
-	}
-
-	return parseHexSecret(s)
-}
-
-// parseHexSecret parses a hex-encoded secret string.
-func parseHexSecret(s string) []byte {
-	s = strings.TrimSpace(s)
-	s = strings.TrimPrefix(s, "0x")
-
-	secret, err := hex.DecodeString(s)
-	if err != nil {
-		return nil
-	}
-
-	return secret
-}
-
 // createJWTToken creates a JWT token for Engine API authentication.
 func (f *ExecutionMetadataFetcher) createJWTToken() (string, error) {
-	if len(f.jwtSecret) == 0 {
-		return "", fmt.Errorf("no JWT secret configured")
-	}
-
-	now := time.Now()
-	claims := jwt.MapClaims{
-		"iat": now.Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	return token.SignedString(f.jwtSecret)
+	return utils.CreateJWTToken(f.jwtSecret)
 }
 
 // Start begins fetching execution metadata. It blocks until initial metadata
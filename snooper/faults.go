@@ -0,0 +1,452 @@
+package snooper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/modules"
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Fault action types.
+const (
+	FaultActionLatency   = "latency"
+	FaultActionStatus    = "status"
+	FaultActionJRPCError = "jrpc_error"
+	FaultActionDrop      = "drop"
+	FaultActionTruncate  = "truncate"
+)
+
+const defaultFaultJRPCErrorCode = -32603
+
+// FaultRule describes a fault injected into matching proxy calls.
+type FaultRule struct {
+	ID         string      `json:"id"`
+	Match      FaultMatch  `json:"match"`
+	Action     FaultAction `json:"action"`
+	Percentage float64     `json:"percentage,omitempty"` // share of matching calls to fail, 0 means all
+	TTL        string      `json:"ttl,omitempty"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	Hits       uint64      `json:"hits"`
+
+	filter *types.Filter
+}
+
+// FaultMatch selects the calls a fault rule applies to. All set fields must match.
+type FaultMatch struct {
+	Path        string   `json:"path,omitempty"` // URL path prefix
+	HTTPMethods []string `json:"http_methods,omitempty"`
+	JRPCMethods []string `json:"jrpc_methods,omitempty"` // any call of a batch may match
+	JSONQuery   string   `json:"json_query,omitempty"`   // gojq expression on the request body
+}
+
+// FaultAction describes the fault to inject.
+type FaultAction struct {
+	Type         string          `json:"type"`
+	LatencyMs    int64           `json:"latency_ms,omitempty"`
+	JitterMs     int64           `json:"jitter_ms,omitempty"`
+	StatusCode   int             `json:"status_code,omitempty"`
+	Body         json.RawMessage `json:"body,omitempty"`
+	ErrorCode    int             `json:"error_code,omitempty"`
+	ErrorMessage string          `json:"error_message,omitempty"`
+	Bytes        *int64          `json:"bytes,omitempty"` // response bytes sent before truncate/drop, defaults to half the body
+}
+
+// FaultEngine holds the active fault rules and matches them against proxy calls.
+type FaultEngine struct {
+	logger       logrus.FieldLogger
+	filterEngine *modules.FilterEngine
+	rules        []*FaultRule
+	mu           sync.Mutex
+}
+
+func NewFaultEngine(logger logrus.FieldLogger) *FaultEngine {
+	return &FaultEngine{
+		logger:       logger,
+		filterEngine: modules.NewFilterEngine(logger),
+		rules:        make([]*FaultRule, 0),
+	}
+}
+
+// AddRule validates a rule, adds it to the engine and returns a copy of the stored rule.
+func (fe *FaultEngine) AddRule(rule *FaultRule) (FaultRule, error) {
+	if err := fe.prepareRule(rule); err != nil {
+		return FaultRule{}, err
+	}
+
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	fe.rules = append(fe.rules, rule)
+
+	return *rule, nil
+}
+
+// RemoveRule removes a rule by id and reports whether it existed.
+func (fe *FaultEngine) RemoveRule(id string) bool {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	for i, rule := range fe.rules {
+		if rule.ID == id {
+			fe.rules = append(fe.rules[:i], fe.rules[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClearRules removes all rules.
+func (fe *FaultEngine) ClearRules() {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	fe.rules = make([]*FaultRule, 0)
+}
+
+// Rules returns a snapshot of the active rules.
+func (fe *FaultEngine) Rules() []FaultRule {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	fe.pruneExpired(time.Now())
+
+	rules := make([]FaultRule, len(fe.rules))
+	for i, rule := range fe.rules {
+		rules[i] = *rule
+	}
+
+	return rules
+}
+
+// HasRules reports whether any rules are configured. Calls are only buffered for
+// matching when this is the case.
+func (fe *FaultEngine) HasRules() bool {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	fe.pruneExpired(time.Now())
+
+	return len(fe.rules) > 0
+}
+
+// Match returns a copy of the first rule matching the request, or nil. Rules with a
+// percentage only match the given share of otherwise matching calls.
+func (fe *FaultEngine) Match(r *http.Request, bodyData []byte) *FaultRule {
+	var parsedBody any
+	if len(bodyData) > 0 {
+		if err := json.Unmarshal(bodyData, &parsedBody); err != nil {
+			parsedBody = nil
+		}
+	}
+
+	reqCtx := &types.RequestContext{
+		Method:      r.Method,
+		URL:         r.URL,
+		Headers:     r.Header,
+		Body:        parsedBody,
+		BodyBytes:   bodyData,
+		ContentType: r.Header.Get("Content-Type"),
+	}
+	jrpcMethods := types.JSONRPCMethods(parsedBody)
+
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	fe.pruneExpired(time.Now())
+
+	for _, rule := range fe.rules {
+		if !fe.matchRule(rule, reqCtx, jrpcMethods) {
+			continue
+		}
+
+		if rule.Percentage > 0 && rule.Percentage < 100 && rand.Float64()*100 >= rule.Percentage { //nolint:gosec // not security sensitive
+			continue
+		}
+
+		rule.Hits++
+		match := *rule
+
+		return &match
+	}
+
+	return nil
+}
+
+func (fe *FaultEngine) matchRule(rule *FaultRule, reqCtx *types.RequestContext, jrpcMethods []string) bool {
+	if rule.Match.Path != "" && !strings.HasPrefix(reqCtx.URL.Path, rule.Match.Path) {
+		return false
+	}
+
+	if len(rule.Match.JRPCMethods) > 0 && !containsAny(rule.Match.JRPCMethods, jrpcMethods) {
+		return false
+	}
+
+	if rule.Match.JSONQuery != "" && reqCtx.Body == nil {
+		return false
+	}
+
+	return fe.filterEngine.ShouldProcessRequestFilter(rule.filter, reqCtx)
+}
+
+func (fe *FaultEngine) pruneExpired(now time.Time) {
+	active := fe.rules[:0]
+
+	for _, rule := range fe.rules {
+		if rule.ExpiresAt != nil && now.After(*rule.ExpiresAt) {
+			fe.logger.WithField("rule", rule.ID).Info("fault rule expired")
+			continue
+		}
+
+		active = append(active, rule)
+	}
+
+	fe.rules = active
+}
+
+// prepareRule validates a new rule, fills in defaults and compiles its filter.
+func (fe *FaultEngine) prepareRule(rule *FaultRule) error {
+	action := &rule.Action
+
+	switch action.Type {
+	case FaultActionLatency:
+		if action.LatencyMs < 0 || action.JitterMs < 0 {
+			return errors.New("latency_ms and jitter_ms must not be negative")
+		}
+
+		if action.LatencyMs == 0 && action.JitterMs == 0 {
+			return errors.New("latency_ms or jitter_ms is required for latency faults")
+		}
+	case FaultActionStatus:
+		if action.StatusCode < 100 || action.StatusCode > 599 {
+			return fmt.Errorf("invalid status_code: %d", action.StatusCode)
+		}
+	case FaultActionJRPCError:
+		if action.ErrorCode == 0 {
+			action.ErrorCode = defaultFaultJRPCErrorCode
+		}
+
+		if action.ErrorMessage == "" {
+			action.ErrorMessage = "Injected fault"
+		}
+
+		if action.StatusCode == 0 {
+			action.StatusCode = http.StatusOK
+		}
+	case FaultActionDrop, FaultActionTruncate:
+		if action.Bytes != nil && *action.Bytes < 0 {
+			return errors.New("bytes must not be negative")
+		}
+	default:
+		return fmt.Errorf("unknown fault action: %q", action.Type)
+	}
+
+	if rule.Percentage < 0 || rule.Percentage > 100 {
+		return fmt.Errorf("invalid percentage: %v", rule.Percentage)
+	}
+
+	now := time.Now()
+
+	if rule.TTL != "" {
+		ttl, err := time.ParseDuration(rule.TTL)
+		if err != nil {
+			return fmt.Errorf("invalid ttl: %w", err)
+		}
+
+		expiresAt := now.Add(ttl)
+		rule.ExpiresAt = &expiresAt
+	}
+
+	if rule.ExpiresAt != nil && !rule.ExpiresAt.After(now) {
+		return errors.New("rule is already expired")
+	}
+
+	rule.filter = &types.Filter{
+		Methods:   rule.Match.HTTPMethods,
+		JSONQuery: rule.Match.JSONQuery,
+	}
+
+	if err := fe.filterEngine.CompileFilter(rule.filter); err != nil {
+		return fmt.Errorf("invalid json_query: %w", err)
+	}
+
+	rule.ID = uuid.NewString()
+	rule.CreatedAt = now
+	rule.Hits = 0
+
+	return nil
+}
+
+// bufferRequestBody reads the full request body and replaces it with an in-memory copy.
+func bufferRequestBody(r *http.Request) ([]byte, error) {
+	bodyData, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading request body: %w", err)
+	}
+
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(bodyData))
+
+	return bodyData, nil
+}
+
+// applyRequestFault applies the request side of a fault. It delays the call for
+// latency faults and returns the response to send for status and JSON-RPC error faults.
+func (s *Snooper) applyRequestFault(callCtx *ProxyCallContext, fault *FaultRule, bodyData []byte) *types.SyntheticResponse {
+	s.logger.WithField("callidx", callCtx.callIndex).Infof("FAULT #%v: %v (rule %v)", callCtx.callIndex, fault.Action.Type, fault.ID)
+
+	switch fault.Action.Type {
+	case FaultActionLatency:
+		delay := time.Duration(fault.Action.LatencyMs) * time.Millisecond
+		if fault.Action.JitterMs > 0 {
+			delay += time.Duration(rand.Int64N(fault.Action.JitterMs+1)) * time.Millisecond //nolint:gosec // not security sensitive
+		}
+
+		sleepWithContext(callCtx.context, delay)
+
+		return nil
+	case FaultActionStatus:
+		headers := http.Header{}
+		if json.Valid(fault.Action.Body) {
+			headers.Set("Content-Type", "application/json")
+		}
+
+		return &types.SyntheticResponse{
+			StatusCode: fault.Action.StatusCode,
+			Headers:    headers,
+			Body:       fault.Action.Body,
+		}
+	case FaultActionJRPCError:
+		headers := http.Header{}
+		headers.Set("Content-Type", "application/json")
+
+		return &types.SyntheticResponse{
+			StatusCode: fault.Action.StatusCode,
+			Headers:    headers,
+			Body:       buildJSONRPCErrorBody(bodyData, fault.Action.ErrorCode, fault.Action.ErrorMessage),
+		}
+	default:
+		return nil
+	}
+}
+
+// truncateResponse cuts the response body short while keeping the response well-formed.
+func truncateResponse(resp *http.Response, fault *FaultRule) error {
+	bodyData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed reading response body: %w", err)
+	}
+
+	resp.Body.Close()
+
+	bodyData = bodyData[:faultCutOffset(fault, int64(len(bodyData)))]
+	resp.Body = io.NopCloser(bytes.NewReader(bodyData))
+	resp.ContentLength = int64(len(bodyData))
+	resp.Header.Set("Content-Length", fmt.Sprintf("%d", len(bodyData)))
+
+	return nil
+}
+
+// faultCutOffset returns the number of body bytes sent before a truncate or drop fault.
+func faultCutOffset(fault *FaultRule, bodyLength int64) int64 {
+	if bodyLength < 0 {
+		bodyLength = 0
+	}
+
+	offset := bodyLength / 2
+	if fault.Action.Bytes != nil {
+		offset = *fault.Action.Bytes
+	}
+
+	if offset > bodyLength && bodyLength > 0 {
+		offset = bodyLength
+	}
+
+	return offset
+}
+
+// buildJSONRPCErrorBody builds a JSON-RPC error response for every call in the request.
+func buildJSONRPCErrorBody(bodyData []byte, code int, message string) []byte {
+	type jrpcError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+
+	type jrpcErrorResponse struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Error   jrpcError       `json:"error"`
+	}
+
+	type jrpcCall struct {
+		ID json.RawMessage `json:"id"`
+	}
+
+	newResponse := func(id json.RawMessage) jrpcErrorResponse {
+		if len(id) == 0 {
+			id = json.RawMessage("null")
+		}
+
+		return jrpcErrorResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error:   jrpcError{Code: code, Message: message},
+		}
+	}
+
+	var result any
+
+	var batch []jrpcCall
+	if err := json.Unmarshal(bodyData, &batch); err == nil {
+		responses := make([]jrpcErrorResponse, len(batch))
+		for i, call := range batch {
+			responses[i] = newResponse(call.ID)
+		}
+
+		result = responses
+	} else {
+		var call jrpcCall
+
+		_ = json.Unmarshal(bodyData, &call)
+		result = newResponse(call.ID)
+	}
+
+	data, _ := json.Marshal(result)
+
+	return data
+}
+
+func containsAny(candidates, values []string) bool {
+	for _, candidate := range candidates {
+		for _, value := range values {
+			if candidate == value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func sleepWithContext(ctx context.Context, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,198 @@
+package snooper
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const faultTestResponse = `{"jsonrpc":"2.0","id":1,"result":"0x0123456789abcdef"}`
+
+// newFaultTestSnooper starts a test snooper in front of an upstream answering every
+// call with faultTestResponse, returning the proxy URL and the upstream call counter.
+func newFaultTestSnooper(t *testing.T) (*Snooper, string, *atomic.Int64) {
+	t.Helper()
+
+	upstreamCalls := &atomic.Int64{}
+	snooper, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		upstreamCalls.Add(1)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(faultTestResponse))
+	})
+
+	return snooper, proxyURL, upstreamCalls
+}
+
+func postJSON(t *testing.T, url, body string) (*http.Response, string, error) {
+	t.Helper()
+
+	rsp, err := http.Post(url, "application/json", strings.NewReader(body)) //nolint:noctx // test request
+	if err != nil {
+		return nil, "", err
+	}
+	defer rsp.Body.Close()
+
+	data, err := io.ReadAll(rsp.Body)
+
+	return rsp, string(data), err
+}
+
+func addFaultRule(t *testing.T, proxyURL, rule string) FaultRule {
+	t.Helper()
+
+	rsp, body, err := postJSON(t, proxyURL+"/_snooper/faults", rule)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode, body)
+
+	var result struct {
+		Fault FaultRule `json:"fault"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(body), &result))
+	require.NotEmpty(t, result.Fault.ID)
+
+	return result.Fault
+}
+
+func TestFaultJSONRPCErrorMatchesMethod(t *testing.T) {
+	_, proxyURL, upstreamCalls := newFaultTestSnooper(t)
+
+	addFaultRule(t, proxyURL, `{"match":{"jrpc_methods":["engine_newPayloadV4"]},"action":{"type":"jrpc_error","error_code":-38001,"error_message":"Unknown payload"}}`)
+
+	rsp, body, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"engine_newPayloadV4","params":[],"id":42}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":42,"error":{"code":-38001,"message":"Unknown payload"}}`, body)
+	assert.Equal(t, int64(0), upstreamCalls.Load())
+
+	// other methods are forwarded
+	_, body, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, faultTestResponse, body)
+	assert.Equal(t, int64(1), upstreamCalls.Load())
+}
+
+func TestFaultStatusMatchesJSONQuery(t *testing.T) {
+	_, proxyURL, _ := newFaultTestSnooper(t)
+
+	addFaultRule(t, proxyURL, `{"match":{"http_methods":["POST"],"json_query":".params[0] == \"latest\""},"action":{"type":"status","status_code":502}}`)
+
+	rsp, _, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["latest",false],"id":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, rsp.StatusCode)
+
+	rsp, _, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x1",false],"id":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+}
+
+func TestFaultLatency(t *testing.T) {
+	_, proxyURL, _ := newFaultTestSnooper(t)
+
+	addFaultRule(t, proxyURL, `{"match":{"path":"/"},"action":{"type":"latency","latency_ms":200,"jitter_ms":50}}`)
+
+	start := time.Now()
+	_, body, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, faultTestResponse, body)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestFaultTruncateResponse(t *testing.T) {
+	_, proxyURL, _ := newFaultTestSnooper(t)
+
+	addFaultRule(t, proxyURL, `{"action":{"type":"truncate","bytes":10}}`)
+
+	rsp, body, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, faultTestResponse[:10], body)
+}
+
+func TestFaultDropConnection(t *testing.T) {
+	_, proxyURL, upstreamCalls := newFaultTestSnooper(t)
+
+	addFaultRule(t, proxyURL, `{"action":{"type":"drop","bytes":10}}`)
+
+	_, _, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+	require.Error(t, err, "Body read should fail on a dropped connection")
+	assert.Equal(t, int64(1), upstreamCalls.Load())
+}
+
+func TestFaultRuleExpiry(t *testing.T) {
+	snooper, proxyURL, _ := newFaultTestSnooper(t)
+
+	addFaultRule(t, proxyURL, `{"action":{"type":"status","status_code":503},"ttl":"100ms"}`)
+
+	rsp, _, err := postJSON(t, proxyURL, `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, rsp.StatusCode)
+
+	time.Sleep(150 * time.Millisecond)
+
+	rsp, _, err = postJSON(t, proxyURL, `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Empty(t, snooper.faultEngine.Rules())
+}
+
+func TestFaultAPIManagement(t *testing.T) {
+	_, proxyURL, _ := newFaultTestSnooper(t)
+
+	rule := addFaultRule(t, proxyURL, `{"match":{"path":"/eth/"},"action":{"type":"status","status_code":500},"percentage":50}`)
+	assert.Equal(t, "/eth/", rule.Match.Path)
+	assert.InDelta(t, 50, rule.Percentage, 0)
+
+	// invalid rules are rejected
+	rsp, _, err := postJSON(t, proxyURL+"/_snooper/faults", `{"action":{"type":"explode"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+
+	rsp, _, err = postJSON(t, proxyURL+"/_snooper/faults", `{"action":{"type":"status","status_code":500},"unknown":true}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+
+	listRsp, err := http.Get(proxyURL + "/_snooper/faults") //nolint:noctx // test request
+	require.NoError(t, err)
+
+	var list struct {
+		Faults []FaultRule `json:"faults"`
+	}
+
+	require.NoError(t, json.NewDecoder(listRsp.Body).Decode(&list))
+	listRsp.Body.Close()
+	require.Len(t, list.Faults, 1)
+	assert.Equal(t, rule.ID, list.Faults[0].ID)
+
+	req, err := http.NewRequest(http.MethodDelete, proxyURL+"/_snooper/faults/"+rule.ID, http.NoBody) //nolint:noctx // test request
+	require.NoError(t, err)
+
+	delRsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	delRsp.Body.Close()
+	assert.Equal(t, http.StatusOK, delRsp.StatusCode)
+
+	delRsp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	delRsp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, delRsp.StatusCode)
+}
+
+func TestBuildJSONRPCErrorBodyBatch(t *testing.T) {
+	body := buildJSONRPCErrorBody([]byte(`[{"jsonrpc":"2.0","method":"a","id":1},{"jsonrpc":"2.0","method":"b","id":"x"}]`), -32000, "boom")
+
+	assert.JSONEq(t, `[
+		{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"boom"}},
+		{"jsonrpc":"2.0","id":"x","error":{"code":-32000,"message":"boom"}}
+	]`, string(body))
+}
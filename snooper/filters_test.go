@@ -0,0 +1,268 @@
+package snooper
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/modules"
+	"github.com/ethpandaops/rpc-snooper/modules/protocol"
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFilterTestRequest(path, method string, headers http.Header) *types.RequestContext {
+	body := `{"jsonrpc":"2.0","method":"` + method + `","params":[],"id":1}`
+
+	return &types.RequestContext{
+		CallCtx:     &ProxyCallContext{data: map[string]any{}},
+		Method:      http.MethodPost,
+		URL:         &url.URL{Path: path},
+		Headers:     headers,
+		Body:        map[string]any{"jsonrpc": "2.0", "method": method, "params": []any{}, "id": 1.0},
+		BodyBytes:   []byte(body),
+		ContentType: "application/json",
+	}
+}
+
+func TestFilterConditions(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	engine := modules.NewFilterEngine(logger)
+	headers := http.Header{"User-Agent": []string{"Lighthouse/v7.0.0"}}
+
+	engineCall := newFilterTestRequest("/", "engine_forkchoiceUpdatedV3", headers)
+	beaconCall := newFilterTestRequest("/eth/v2/beacon/blocks/head", "", http.Header{})
+
+	tests := []struct {
+		name    string
+		filter  *types.Filter
+		request *types.RequestContext
+		match   bool
+	}{
+		{"method glob", &types.Filter{JRPCMethods: []string{"engine_forkchoice*"}}, engineCall, true},
+		{"method glob mismatch", &types.Filter{JRPCMethods: []string{"eth_*"}}, engineCall, false},
+		{"path prefix", &types.Filter{PathPrefix: "/eth/v2/beacon"}, beaconCall, true},
+		{"path prefix mismatch", &types.Filter{PathPrefix: "/eth/v1"}, beaconCall, false},
+		{"path regex", &types.Filter{PathRegex: `^/eth/v\d/beacon/blocks/`}, beaconCall, true},
+		{"header glob", &types.Filter{Headers: map[string]string{"user-agent": "Lighthouse/*"}}, engineCall, true},
+		{"header missing", &types.Filter{Headers: map[string]string{"User-Agent": "*"}}, beaconCall, false},
+		{"body size", &types.Filter{MinBodySize: 10, MaxBodySize: 100}, engineCall, true},
+		{"body size too small", &types.Filter{MinBodySize: 1000}, engineCall, false},
+		{"sample none", &types.Filter{SampleRate: 1}, engineCall, true},
+		{"all", &types.Filter{All: []*types.Filter{{JRPCMethods: []string{"engine_*"}}, {PathPrefix: "/"}}}, engineCall, true},
+		{"all mismatch", &types.Filter{All: []*types.Filter{{JRPCMethods: []string{"engine_*"}}, {PathPrefix: "/eth"}}}, engineCall, false},
+		{"any", &types.Filter{Any: []*types.Filter{{JRPCMethods: []string{"eth_*"}}, {PathPrefix: "/eth"}}}, beaconCall, true},
+		{"any mismatch", &types.Filter{Any: []*types.Filter{{JRPCMethods: []string{"eth_*"}}, {PathPrefix: "/eth"}}}, engineCall, false},
+		{"not", &types.Filter{Not: &types.Filter{JRPCMethods: []string{"engine_*"}}}, engineCall, false},
+		{"nested", &types.Filter{
+			JRPCMethods: []string{"engine_*"},
+			Not:         &types.Filter{Any: []*types.Filter{{JRPCMethods: []string{"engine_exchange*"}}, {Methods: []string{"GET"}}}},
+		}, engineCall, true},
+	}
+
+	for _, test := range tests {
+		require.NoError(t, engine.CompileFilter(test.filter), test.name)
+		assert.Equal(t, test.match, engine.ShouldProcessRequestFilter(test.filter, test.request), test.name)
+	}
+
+	// response filters check the duration and the JSON-RPC method of the request
+	callCtx := &ProxyCallContext{data: map[string]any{}}
+	callCtx.SetData(0, "jrpc_method", "engine_getPayloadV4")
+
+	response := &types.ResponseContext{
+		CallCtx:     callCtx,
+		StatusCode:  http.StatusOK,
+		ContentType: "application/json",
+		Duration:    250 * time.Millisecond,
+	}
+
+	slowFilter := &types.Filter{JRPCMethods: []string{"engine_getPayload*"}, MinDurationMs: 200}
+	require.NoError(t, engine.CompileFilter(slowFilter))
+	assert.True(t, engine.ShouldProcessResponseFilter(slowFilter, response))
+
+	slowFilter.MinDurationMs = 500
+	assert.False(t, engine.ShouldProcessResponseFilter(slowFilter, response))
+
+	// response JSON queries run against the call document with the request, response,
+	// duration and status
+	response.Body = map[string]any{"jsonrpc": "2.0", "id": 1.0, "result": map[string]any{"status": "VALID"}}
+	response.Request = engineCall
+
+	for query, match := range map[string]bool{
+		`.response.result.status == "VALID"`:              true,
+		`.response.result.status == "INVALID"`:            false,
+		`.request.method == "engine_forkchoiceUpdatedV3"`: true,
+		`.duration_ms >= 200 and .status == 200`:          true,
+		`.status != 200`:                                  false,
+	} {
+		queryFilter := &types.Filter{JSONQuery: query}
+		require.NoError(t, engine.CompileFilter(queryFilter), query)
+		assert.Equal(t, match, engine.ShouldProcessResponseFilter(queryFilter, response), query)
+	}
+
+	// the call document is queried for responses that are not JSON as well
+	errorResponse := &types.ResponseContext{
+		CallCtx:     callCtx,
+		StatusCode:  http.StatusInternalServerError,
+		Body:        []byte("internal error"),
+		ContentType: "text/plain",
+		Request:     engineCall,
+	}
+
+	for query, match := range map[string]bool{
+		`.status == 500 and .response == null`: true,
+		`.status == 200`:                       false,
+	} {
+		queryFilter := &types.Filter{JSONQuery: query}
+		require.NoError(t, engine.CompileFilter(queryFilter), query)
+		assert.Equal(t, match, engine.ShouldProcessResponseFilter(queryFilter, errorResponse), query)
+	}
+
+	// response filters check the HTTP method and path of the request
+	for filter, match := range map[*types.Filter]bool{
+		{Methods: []string{"POST"}}:              true,
+		{Methods: []string{"GET"}}:               false,
+		{PathPrefix: "/"}:                        true,
+		{PathRegex: `^/eth/`}:                    false,
+		{Not: &types.Filter{PathPrefix: "/eth"}}: true,
+	} {
+		require.NoError(t, engine.CompileFilter(filter))
+		assert.Equal(t, match, engine.ShouldProcessResponseFilter(filter, response), "%+v", filter)
+	}
+
+	// request queries run against the request body
+	requestQueryFilter := &types.Filter{JSONQuery: `.method == "engine_forkchoiceUpdatedV3"`}
+	require.NoError(t, engine.CompileFilter(requestQueryFilter))
+	assert.True(t, engine.ShouldProcessRequestFilter(requestQueryFilter, engineCall))
+
+	// sampling thins out matching calls
+	sampled := 0
+	sampleFilter := &types.Filter{SampleRate: 0.5}
+	require.NoError(t, engine.CompileFilter(sampleFilter))
+
+	for range 1000 {
+		if engine.ShouldProcessRequestFilter(sampleFilter, engineCall) {
+			sampled++
+		}
+	}
+
+	assert.InDelta(t, 500, sampled, 150)
+}
+
+func TestFilterInvalid(t *testing.T) {
+	engine := modules.NewFilterEngine(logrus.New())
+
+	for name, filter := range map[string]*types.Filter{
+		"path regex":  {PathRegex: "("},
+		"sample rate": {SampleRate: 1.5},
+		"body size":   {MinBodySize: 100, MaxBodySize: 10},
+		"nested":      {Any: []*types.Filter{{JSONQuery: ".method =="}}},
+		"empty":       {Not: &types.Filter{All: []*types.Filter{nil}}},
+	} {
+		assert.Error(t, engine.CompileFilter(filter), name)
+	}
+}
+
+func TestFilterUnknownFields(t *testing.T) {
+	snooper, _, _ := newTestSnooper(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	conn := dialControlTestConn(t, snooper)
+
+	register := func(requestFilter map[string]any) *protocol.WSMessage {
+		require.NoError(t, conn.WriteJSON(&protocol.WSMessage{
+			RequestID: 1,
+			Method:    "register_module",
+			Data: protocol.RegisterModuleRequest{
+				Type:   "request_counter",
+				Config: map[string]any{"request_filter": requestFilter},
+			},
+		}))
+
+		var msg protocol.WSMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+
+		return &msg
+	}
+
+	msg := register(map[string]any{"jrpc_method": []string{"engine_*"}})
+	require.NotNil(t, msg.Error)
+	assert.Contains(t, *msg.Error, `invalid request_filter: json: unknown field "jrpc_method"`)
+
+	msg = register(map[string]any{"any": []any{map[string]any{"path_prefx": "/eth"}}})
+	require.NotNil(t, msg.Error)
+	assert.Contains(t, *msg.Error, `unknown field "path_prefx"`)
+
+	msg = register(map[string]any{"jrpc_methods": []string{"engine_*"}, "not": map[string]any{"path_prefix": "/eth"}})
+	require.Nil(t, msg.Error)
+}
+
+func TestResponseFilterCallDocument(t *testing.T) {
+	snooper, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		status := "VALID"
+		if strings.Contains(string(body), "0xbad") {
+			status = "INVALID"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"status":"` + status + `"}}`))
+	})
+
+	conn := dialControlTestConn(t, snooper)
+
+	require.NoError(t, conn.WriteJSON(&protocol.WSMessage{
+		RequestID: 1,
+		Method:    "register_module",
+		Data: protocol.RegisterModuleRequest{
+			Type: "response_tracer",
+			Config: map[string]any{
+				"response_filter": map[string]any{
+					"json_query": `.request.method == "engine_newPayloadV4" and .response.result.status == "INVALID"`,
+				},
+				"response_select": `{method: .request.method, hash: .request.params[0], status: .response.result.status, code: .status}`,
+			},
+		},
+	}))
+
+	var msg protocol.WSMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	require.Nil(t, msg.Error)
+
+	for _, call := range []string{
+		`{"jsonrpc":"2.0","method":"engine_newPayloadV4","params":["0xbad"],"id":1}`,
+		`{"jsonrpc":"2.0","method":"engine_newPayloadV4","params":["0x01"],"id":1}`,
+		`{"jsonrpc":"2.0","method":"engine_forkchoiceUpdatedV3","params":["0xbad"],"id":1}`,
+		`{"jsonrpc":"2.0","method":"engine_newPayloadV4","params":["0xbad2"],"id":1}`,
+	} {
+		_, _, err := postJSON(t, proxyURL, call)
+		require.NoError(t, err)
+	}
+
+	// only the INVALID engine_newPayloadV4 responses are traced, in call order
+	for _, hash := range []string{"0xbad", "0xbad2"} {
+		var event struct {
+			Method string               `json:"method"`
+			Data   protocol.TracerEvent `json:"data"`
+		}
+
+		require.NoError(t, conn.ReadJSON(&event))
+		require.Equal(t, "tracer_event", event.Method)
+
+		assert.Equal(t, map[string]any{
+			"method": "engine_newPayloadV4",
+			"hash":   hash,
+			"status": "INVALID",
+			"code":   float64(http.StatusOK),
+		}, event.Data.ResponseData)
+	}
+}
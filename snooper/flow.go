@@ -0,0 +1,72 @@
+package snooper
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FlowUpdate is the data of the set_flow control method. Unset fields keep their
+// current state, so an empty update only reports the flow state.
+type FlowUpdate struct {
+	Enabled *bool  `json:"enabled,omitempty"`
+	Block   string `json:"block,omitempty"`
+	Unblock string `json:"unblock,omitempty"`
+}
+
+// applyFlowUpdate applies a flow update and returns the resulting flow state.
+func (s *Snooper) applyFlowUpdate(update *FlowUpdate) (enabled bool, blocked []string) {
+	s.flowMutex.Lock()
+	defer s.flowMutex.Unlock()
+
+	if update.Enabled != nil {
+		s.flowEnabled = *update.Enabled
+	}
+
+	if update.Block != "" {
+		s.flowBlocked[update.Block] = true
+	}
+
+	if update.Unblock != "" {
+		delete(s.flowBlocked, update.Unblock)
+	}
+
+	blocked = make([]string, 0, len(s.flowBlocked))
+	for path := range s.flowBlocked {
+		blocked = append(blocked, path)
+	}
+
+	slices.Sort(blocked)
+
+	return s.flowEnabled, blocked
+}
+
+// registerFlowControlMethods makes the proxy flow controllable through the WebSocket
+// control protocol, mirroring the start, stop, block and unblock API endpoints.
+func (s *Snooper) registerFlowControlMethods() {
+	s.moduleManager.RegisterControlMethod("set_flow", func(data json.RawMessage) (any, error) {
+		update := &FlowUpdate{}
+
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, update); err != nil {
+				return nil, fmt.Errorf("invalid flow update: %w", err)
+			}
+		}
+
+		enabled, blocked := s.applyFlowUpdate(update)
+
+		if update.Enabled != nil || update.Block != "" || update.Unblock != "" {
+			s.logger.WithFields(logrus.Fields{
+				"enabled": enabled,
+				"blocked": blocked,
+			}).Info("Flow updated")
+		}
+
+		return map[string]any{
+			"enabled": enabled,
+			"blocked": blocked,
+		}, nil
+	})
+}
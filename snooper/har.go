@@ -0,0 +1,439 @@
+package snooper
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/recording"
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/ethpandaops/rpc-snooper/utils"
+)
+
+// HAR is an HTTP Archive 1.2 document.
+// See: http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is the request body. SSZ bodies are base64-encoded, which is marked
+// by the custom _encoding field as HAR 1.2 only defines an encoding for responses.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HARFilter selects the calls included in a HAR export. Zero values match all calls.
+type HARFilter struct {
+	From        time.Time
+	To          time.Time
+	PathPrefix  string
+	JRPCMethods []string
+	// StatusCodes holds exact status codes (e.g. "200") or status classes (e.g. "5xx").
+	StatusCodes []string
+}
+
+// DefaultHARMaxBodySize is the number of body bytes kept per request and response
+// in the call history unless configured otherwise.
+const DefaultHARMaxBodySize = 256 * 1024
+
+// redactedHeaders are headers holding credentials, like the Engine API JWTs, whose
+// values are not included in recordings and the HAR export.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"Set-Cookie":          true,
+}
+
+// historyCall is a completed call kept for the HAR export. The bodies are kept
+// apart from the entry, limited to the configured size.
+type historyCall struct {
+	entry       *recording.Entry
+	upstream    string
+	jrpcMethods []string
+	request     historyBody
+	response    historyBody
+}
+
+// historyBody is a raw message body of a call in the call history.
+type historyBody struct {
+	data   []byte
+	size   int
+	binary bool
+}
+
+// callHistory is a bounded ring of the most recently completed calls.
+type callHistory struct {
+	mu          sync.Mutex
+	calls       []*historyCall
+	next        int
+	full        bool
+	maxBodySize int
+}
+
+func newCallHistory(size, maxBodySize int) *callHistory {
+	return &callHistory{
+		calls:       make([]*historyCall, size),
+		maxBodySize: maxBodySize,
+	}
+}
+
+// newHistoryCall copies a recorded call for the call history, keeping at most
+// maxBodySize bytes of each body.
+func (h *callHistory) newHistoryCall(entry *recording.Entry, upstream string) *historyCall {
+	call := &historyCall{
+		entry:    &recording.Entry{},
+		upstream: upstream,
+		request:  h.newHistoryBody(&entry.Request.Payload),
+		response: h.newHistoryBody(&entry.Response.Payload),
+	}
+
+	*call.entry = *entry
+	call.entry.Request.Payload = recording.Payload{ContentType: entry.Request.ContentType}
+	call.entry.Response.Payload = recording.Payload{ContentType: entry.Response.ContentType}
+
+	var parsedData any
+	if len(entry.Request.Body) > 0 && json.Unmarshal(entry.Request.Body, &parsedData) == nil {
+		call.jrpcMethods = types.JSONRPCMethods(parsedData)
+	}
+
+	return call
+}
+
+func (h *callHistory) newHistoryBody(payload *recording.Payload) historyBody {
+	data, err := payload.Bytes()
+	if err != nil {
+		return historyBody{}
+	}
+
+	body := historyBody{
+		size:   len(data),
+		binary: payload.BodyHex != "",
+	}
+
+	if h.maxBodySize > 0 && len(data) > h.maxBodySize {
+		data = data[:h.maxBodySize]
+	}
+
+	body.data = append([]byte(nil), data...)
+
+	return body
+}
+
+// truncated reports whether only a part of the body is kept.
+func (b *historyBody) truncated() bool {
+	return len(b.data) < b.size
+}
+
+// add stores a call, replacing the oldest one when the ring is full.
+func (h *callHistory) add(call *historyCall) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.calls[h.next] = call
+	h.next = (h.next + 1) % len(h.calls)
+
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// snapshot returns the stored calls ordered by request time.
+func (h *callHistory) snapshot() []*historyCall {
+	h.mu.Lock()
+
+	calls := make([]*historyCall, 0, len(h.calls))
+	if h.full {
+		calls = append(calls, h.calls[h.next:]...)
+	}
+
+	calls = append(calls, h.calls[:h.next]...)
+
+	h.mu.Unlock()
+
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].entry.RequestTime.Before(calls[j].entry.RequestTime)
+	})
+
+	return calls
+}
+
+// EnableCallHistory keeps the last size completed calls in memory for the HAR export,
+// with at most maxBodySize bytes of each body (0 keeps full bodies). Call this once
+// at startup before serving requests.
+func (s *Snooper) EnableCallHistory(size, maxBodySize int) {
+	s.history = newCallHistory(size, maxBodySize)
+}
+
+// BuildHAR returns the HTTP Archive of the calls in the call history that match the
+// filter, or nil if the call history is disabled.
+func (s *Snooper) BuildHAR(filter *HARFilter) *HAR {
+	if s.history == nil {
+		return nil
+	}
+
+	har := &HAR{
+		Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{
+				Name:    "rpc-snooper",
+				Version: utils.GetBuildVersion(),
+			},
+			Entries: []HAREntry{},
+		},
+	}
+
+	for _, call := range s.history.snapshot() {
+		if filter != nil && !filter.matches(call) {
+			continue
+		}
+
+		har.Log.Entries = append(har.Log.Entries, newHAREntry(call))
+	}
+
+	return har
+}
+
+func (f *HARFilter) matches(call *historyCall) bool {
+	entry := call.entry
+
+	if !f.From.IsZero() && entry.RequestTime.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && entry.RequestTime.After(f.To) {
+		return false
+	}
+
+	if f.PathPrefix != "" {
+		reqURL, err := url.Parse(entry.Request.URL)
+		if err != nil || !strings.HasPrefix(reqURL.Path, f.PathPrefix) {
+			return false
+		}
+	}
+
+	if len(f.JRPCMethods) > 0 && !containsAny(call.jrpcMethods, f.JRPCMethods) {
+		return false
+	}
+
+	if len(f.StatusCodes) > 0 && !matchesStatusCode(entry.Response.StatusCode, f.StatusCodes) {
+		return false
+	}
+
+	return true
+}
+
+// matchesStatusCode reports whether a status code matches any of the given codes or
+// status classes (e.g. "4xx").
+func matchesStatusCode(statusCode int, codes []string) bool {
+	status := strconv.Itoa(statusCode)
+
+	for _, code := range codes {
+		code = strings.ToLower(strings.TrimSpace(code))
+
+		if code == status || (len(code) == 3 && strings.HasSuffix(code, "xx") && code[0] == status[0]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newHAREntry(call *historyCall) HAREntry {
+	entry := call.entry
+	durationMs := entry.DurationMs
+
+	harEntry := HAREntry{
+		StartedDateTime: entry.RequestTime.Format(time.RFC3339Nano),
+		Time:            durationMs,
+		Request: HARRequest{
+			Method:      entry.Request.Method,
+			URL:         getHARRequestURL(call),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []HARNameValue{},
+			Headers:     getHARHeaders(entry.Request.Headers),
+			QueryString: []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: HARResponse{
+			Status:      entry.Response.StatusCode,
+			StatusText:  http.StatusText(entry.Response.StatusCode),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []HARNameValue{},
+			Headers:     getHARHeaders(entry.Response.Headers),
+			RedirectURL: entry.Response.Headers.Get("Location"),
+			HeadersSize: -1,
+		},
+		Timings: HARTimings{
+			Send:    0,
+			Wait:    durationMs,
+			Receive: 0,
+		},
+		Comment: "call #" + strconv.FormatUint(entry.CallIndex, 10),
+	}
+
+	if reqURL, err := url.Parse(entry.Request.URL); err == nil {
+		harEntry.Request.QueryString = getHARNameValues(reqURL.Query())
+	}
+
+	if call.request.size > 0 {
+		text, encoding := getHARBody(&call.request)
+
+		harEntry.Request.BodySize = call.request.size
+		harEntry.Request.PostData = &HARPostData{
+			MimeType: entry.Request.ContentType,
+			Text:     text,
+			Encoding: encoding,
+		}
+	}
+
+	text, encoding := getHARBody(&call.response)
+	harEntry.Response.Content = HARContent{
+		Size:     call.response.size,
+		MimeType: entry.Response.ContentType,
+		Text:     text,
+		Encoding: encoding,
+	}
+
+	// bodies are stored decompressed, so the transferred size is unknown for encoded responses
+	harEntry.Response.BodySize = call.response.size
+	if entry.Response.Headers.Get("Content-Encoding") != "" {
+		harEntry.Response.BodySize = -1
+	}
+
+	if call.request.truncated() {
+		harEntry.Comment += fmt.Sprintf(", request body truncated to %d of %d bytes", len(call.request.data), call.request.size)
+	}
+
+	if call.response.truncated() {
+		harEntry.Comment += fmt.Sprintf(", response body truncated to %d of %d bytes", len(call.response.data), call.response.size)
+	}
+
+	return harEntry
+}
+
+// getHARRequestURL returns the absolute URL a call was forwarded to.
+func getHARRequestURL(call *historyCall) string {
+	if call.upstream == "" {
+		return call.entry.Request.URL
+	}
+
+	return strings.TrimSuffix(call.upstream, "/") + call.entry.Request.URL
+}
+
+// getHARHeaders converts headers to HAR name/value pairs, redacting credentials.
+func getHARHeaders(headers http.Header) []HARNameValue {
+	pairs := getHARNameValues(headers)
+
+	for idx := range pairs {
+		if redactedHeaders[http.CanonicalHeaderKey(pairs[idx].Name)] {
+			pairs[idx].Value = recording.RedactedHeaderValue
+		}
+	}
+
+	return pairs
+}
+
+// getHARNameValues converts headers or query arguments to HAR name/value pairs in a
+// stable order.
+func getHARNameValues(values map[string][]string) []HARNameValue {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	pairs := make([]HARNameValue, 0, len(values))
+
+	for _, name := range names {
+		for _, value := range values[name] {
+			pairs = append(pairs, HARNameValue{Name: name, Value: value})
+		}
+	}
+
+	return pairs
+}
+
+// getHARBody returns the text and encoding of a body. JSON bodies are returned as
+// is, SSZ and other binary bodies base64-encoded.
+func getHARBody(body *historyBody) (text, encoding string) {
+	if !body.binary {
+		return string(body.data), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body.data), "base64"
+}
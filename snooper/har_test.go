@@ -0,0 +1,141 @@
+package snooper
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getHAR(t *testing.T, proxyURL, query string) *HAR {
+	t.Helper()
+
+	rsp, err := http.Get(proxyURL + "/_snooper/har" + query) //nolint:noctx // test request
+	require.NoError(t, err)
+
+	defer rsp.Body.Close()
+
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+
+	har := &HAR{}
+	require.NoError(t, json.NewDecoder(rsp.Body).Decode(har))
+
+	return har
+}
+
+func TestHARExport(t *testing.T) {
+	snooper, proxyURL, _ := newFaultTestSnooper(t)
+	snooper.EnableCallHistory(2, 0)
+
+	sszBody := []byte{0x01, 0x02, 0xff}
+
+	_, _, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":1}`)
+	require.NoError(t, err)
+
+	rsp, err := http.Post(proxyURL+"/eth/v1/beacon/blocks?x=1", "application/octet-stream", strings.NewReader(string(sszBody))) //nolint:noctx // test request
+	require.NoError(t, err)
+	rsp.Body.Close()
+
+	_, _, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+	require.NoError(t, err)
+
+	var har *HAR
+
+	require.Eventually(t, func() bool {
+		har = getHAR(t, proxyURL, "")
+		return len(har.Log.Entries) == 2 && har.Log.Entries[1].Comment == "call #3"
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Equal(t, "call #2", har.Log.Entries[0].Comment, "The oldest call must be dropped from the ring")
+
+	ssz := har.Log.Entries[0]
+	assert.Equal(t, http.MethodPost, ssz.Request.Method)
+	assert.True(t, strings.HasSuffix(ssz.Request.URL, "/eth/v1/beacon/blocks?x=1"))
+	assert.Equal(t, []HARNameValue{{Name: "x", Value: "1"}}, ssz.Request.QueryString)
+	require.NotNil(t, ssz.Request.PostData)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(sszBody), ssz.Request.PostData.Text)
+	assert.Equal(t, "base64", ssz.Request.PostData.Encoding)
+	assert.Equal(t, len(sszBody), ssz.Request.BodySize)
+
+	rpc := har.Log.Entries[1]
+	assert.Equal(t, http.StatusOK, rpc.Response.Status)
+	assert.Equal(t, "OK", rpc.Response.StatusText)
+	assert.JSONEq(t, faultTestResponse, rpc.Response.Content.Text)
+	assert.Empty(t, rpc.Response.Content.Encoding)
+	assert.Equal(t, rpc.Time, rpc.Timings.Wait)
+	assert.Positive(t, rpc.Time)
+
+	// filters
+	assert.Len(t, getHAR(t, proxyURL, "?method=eth_blockNumber").Log.Entries, 1)
+	assert.Len(t, getHAR(t, proxyURL, "?method=eth_chainId").Log.Entries, 0)
+	assert.Len(t, getHAR(t, proxyURL, "?path=/eth/v1/").Log.Entries, 1)
+	assert.Len(t, getHAR(t, proxyURL, "?status=2xx").Log.Entries, 2)
+	assert.Len(t, getHAR(t, proxyURL, "?status=500").Log.Entries, 0)
+	assert.Len(t, getHAR(t, proxyURL, "?from="+time.Now().Add(time.Minute).UTC().Format(time.RFC3339)).Log.Entries, 0)
+}
+
+func TestHARExportDisabled(t *testing.T) {
+	_, proxyURL, _ := newFaultTestSnooper(t)
+
+	rsp, err := http.Get(proxyURL + "/_snooper/har") //nolint:noctx // test request
+	require.NoError(t, err)
+	rsp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, rsp.StatusCode)
+
+	rsp, err = http.Get(proxyURL + "/_snooper/har?from=yesterday") //nolint:noctx // test request
+	require.NoError(t, err)
+	rsp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+}
+
+func TestHARExportRedactsAndTruncates(t *testing.T) {
+	snooper, proxyURL, _ := newFaultTestSnooper(t)
+	snooper.EnableCallHistory(10, 16)
+
+	body := `{"jsonrpc":"2.0","method":"engine_newPayloadV4","params":[],"id":1}`
+
+	req, err := http.NewRequest(http.MethodPost, proxyURL, strings.NewReader(body)) //nolint:noctx // test request
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Cookie", "session=secret")
+
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	rsp.Body.Close()
+
+	var har *HAR
+
+	require.Eventually(t, func() bool {
+		har = getHAR(t, proxyURL, "?method=engine_newPayloadV4")
+		return len(har.Log.Entries) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	entry := har.Log.Entries[0]
+
+	headers := map[string]string{}
+	for _, header := range entry.Request.Headers {
+		headers[header.Name] = header.Value
+	}
+
+	assert.Equal(t, "[redacted]", headers["Authorization"])
+	assert.Equal(t, "[redacted]", headers["Cookie"])
+	assert.Equal(t, "application/json", headers["Content-Type"])
+
+	// bodies are truncated, but report their full size
+	require.NotNil(t, entry.Request.PostData)
+	assert.Equal(t, body[:16], entry.Request.PostData.Text)
+	assert.Equal(t, len(body), entry.Request.BodySize)
+	assert.Len(t, entry.Response.Content.Text, 16)
+	assert.Equal(t, len(faultTestResponse), entry.Response.Content.Size)
+	assert.Contains(t, entry.Comment, "request body truncated to 16 of")
+	assert.Contains(t, entry.Comment, "response body truncated to 16 of")
+}
@@ -0,0 +1,139 @@
+package snooper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/types"
+)
+
+// interceptRequest buffers the request body and passes it through the intercepting
+// modules before the call is forwarded. Bodies are passed as sent on the wire.
+// On module errors the original request is returned, so the call is forwarded as is.
+func (s *Snooper) interceptRequest(callCtx *ProxyCallContext, r *http.Request, headers http.Header) (*types.RequestContext, error) {
+	bodyData, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading request body: %w", err)
+	}
+
+	r.Body.Close()
+
+	reqCtx := &types.RequestContext{
+		CallCtx:     callCtx,
+		Method:      r.Method,
+		URL:         r.URL,
+		Headers:     headers,
+		Body:        parseModuleBody(bodyData),
+		BodyBytes:   bodyData,
+		ContentType: headers.Get("Content-Type"),
+		Timestamp:   time.Now(),
+	}
+
+	newCtx, err := s.moduleManager.InterceptRequest(reqCtx)
+	if err != nil {
+		s.logger.WithField("callidx", callCtx.callIndex).WithError(err).Warn("request interception failed, forwarding original request")
+
+		return reqCtx, nil
+	}
+
+	return newCtx, nil
+}
+
+// interceptResponse buffers the response body and passes it through the intercepting
+// modules before it is returned to the client. The response is updated in place.
+// It reports whether the call should be dropped.
+func (s *Snooper) interceptResponse(callCtx *ProxyCallContext, resp *http.Response) (bool, error) {
+	bodyData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("failed reading response body: %w", err)
+	}
+
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(bodyData))
+
+	// Wait for the request to be logged and processed by the observing modules, so
+	// interceptors see the call data they stored and the hooks arrive in order.
+	<-callCtx.reqSentChan
+
+	rspCtx := &types.ResponseContext{
+		CallCtx:     callCtx,
+		StatusCode:  resp.StatusCode,
+		Headers:     resp.Header,
+		Body:        parseModuleBody(bodyData),
+		BodyBytes:   bodyData,
+		ContentType: resp.Header.Get("Content-Type"),
+		Timestamp:   time.Now(),
+		Duration:    time.Since(callCtx.startTime),
+		Request:     callCtx.requestCtx,
+	}
+
+	newCtx, err := s.moduleManager.InterceptResponse(rspCtx)
+	if err != nil {
+		s.logger.WithField("callidx", callCtx.callIndex).WithError(err).Warn("response interception failed, returning original response")
+
+		return false, nil
+	}
+
+	if newCtx.Drop {
+		return true, nil
+	}
+
+	resp.StatusCode = newCtx.StatusCode
+	resp.Header = newCtx.Headers
+	resp.Body = io.NopCloser(bytes.NewReader(newCtx.BodyBytes))
+	resp.ContentLength = int64(len(newCtx.BodyBytes))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(newCtx.BodyBytes)))
+
+	return false, nil
+}
+
+// newSyntheticResponse builds the response returned to the client for a call that
+// was answered by an intercepting module.
+func newSyntheticResponse(synthetic *types.SyntheticResponse) *http.Response {
+	headers := synthetic.Headers
+	if headers == nil {
+		headers = http.Header{}
+	}
+
+	headers.Set("Content-Length", strconv.Itoa(len(synthetic.Body)))
+
+	return &http.Response{
+		StatusCode:    synthetic.StatusCode,
+		Header:        headers,
+		Body:          io.NopCloser(bytes.NewReader(synthetic.Body)),
+		ContentLength: int64(len(synthetic.Body)),
+	}
+}
+
+// dropProxyCall closes the client connection without completing the response.
+func (s *Snooper) dropProxyCall(callCtx *ProxyCallContext, w http.ResponseWriter, reason string) error {
+	s.logger.WithField("callidx", callCtx.callIndex).Infof("DROPPED #%v: call dropped by %v", callCtx.callIndex, reason)
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return errors.New("connection does not support dropping calls")
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return fmt.Errorf("failed to hijack connection: %w", err)
+	}
+
+	return conn.Close()
+}
+
+// parseModuleBody returns the parsed JSON body, or the raw bytes if it isn't valid JSON.
+func parseModuleBody(bodyData []byte) any {
+	var parsedData any
+	if err := json.Unmarshal(bodyData, &parsedData); err != nil {
+		return bodyData
+	}
+
+	return parsedData
+}
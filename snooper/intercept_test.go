@@ -0,0 +1,204 @@
+package snooper
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethpandaops/rpc-snooper/modules/protocol"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interceptTestEnv wires an upstream, the snooper proxy and a control client together.
+type interceptTestEnv struct {
+	proxyURL string
+	control  *websocket.Conn
+
+	mu            sync.Mutex
+	upstreamCalls []string
+}
+
+func newInterceptTestEnv(t *testing.T, moduleType string) *interceptTestEnv {
+	t.Helper()
+
+	env := &interceptTestEnv{}
+
+	snooper, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		env.mu.Lock()
+		env.upstreamCalls = append(env.upstreamCalls, string(body))
+		env.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	})
+
+	conn := dialControlTestConn(t, snooper)
+
+	env.proxyURL = proxyURL
+	env.control = conn
+
+	require.NoError(t, conn.WriteJSON(&protocol.WSMessage{
+		RequestID: 1,
+		Method:    "register_module",
+		Data: protocol.RegisterModuleRequest{
+			Type:   moduleType,
+			Name:   "test-interceptor",
+			Config: map[string]any{"timeout_ms": float64(5000)},
+		},
+	}))
+
+	var regRsp protocol.WSMessage
+	require.NoError(t, conn.ReadJSON(&regRsp))
+	require.Nil(t, regRsp.Error)
+	require.Equal(t, uint64(1), regRsp.ResponseID)
+
+	return env
+}
+
+// nextIntercept reads the next intercept message and its binary body frame.
+func (env *interceptTestEnv) nextIntercept(t *testing.T) (*protocol.WSMessage, []byte) {
+	t.Helper()
+
+	var msg protocol.WSMessage
+	require.NoError(t, env.control.ReadJSON(&msg))
+	require.True(t, msg.Binary)
+
+	msgType, body, err := env.control.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, websocket.BinaryMessage, msgType)
+
+	return &msg, body
+}
+
+func (env *interceptTestEnv) sendVerdict(t *testing.T, msg *protocol.WSMessage, verdict *protocol.InterceptVerdict) {
+	t.Helper()
+
+	require.NoError(t, env.control.WriteJSON(&protocol.WSMessage{
+		ResponseID: msg.RequestID,
+		Method:     msg.Method,
+		Data:       verdict,
+	}))
+}
+
+func (env *interceptTestEnv) call(t *testing.T, body string) (<-chan *http.Response, <-chan string) {
+	t.Helper()
+
+	rspChan := make(chan *http.Response, 1)
+	bodyChan := make(chan string, 1)
+
+	go func() {
+		rsp, err := http.Post(env.proxyURL, "application/json", strings.NewReader(body)) //nolint:noctx // test request
+		if err != nil {
+			close(rspChan)
+			close(bodyChan)
+
+			return
+		}
+		defer rsp.Body.Close()
+
+		data, _ := io.ReadAll(rsp.Body)
+		rspChan <- rsp
+		bodyChan <- string(data)
+	}()
+
+	return rspChan, bodyChan
+}
+
+func TestRequestInterceptorModifiesBody(t *testing.T) {
+	env := newInterceptTestEnv(t, "request_interceptor")
+
+	rspChan, bodyChan := env.call(t, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+
+	msg, body := env.nextIntercept(t)
+	assert.Equal(t, "intercept_request", msg.Method)
+	assert.NotZero(t, msg.RequestID)
+	assert.Contains(t, string(body), "eth_blockNumber")
+
+	env.sendVerdict(t, msg, &protocol.InterceptVerdict{
+		Action: "modify",
+		Body:   json.RawMessage(`{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":1}`),
+	})
+
+	rsp := <-rspChan
+	require.NotNil(t, rsp)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, <-bodyChan)
+
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	require.Len(t, env.upstreamCalls, 1)
+	assert.Contains(t, env.upstreamCalls[0], "eth_chainId")
+}
+
+func TestRequestInterceptorSyntheticResponse(t *testing.T) {
+	env := newInterceptTestEnv(t, "request_interceptor")
+
+	rspChan, bodyChan := env.call(t, `{"jsonrpc":"2.0","method":"engine_newPayloadV4","params":[],"id":7}`)
+
+	msg, _ := env.nextIntercept(t)
+	env.sendVerdict(t, msg, &protocol.InterceptVerdict{
+		Action:     "respond",
+		StatusCode: http.StatusOK,
+		Body:       json.RawMessage(`{"jsonrpc":"2.0","id":7,"result":{"status":"SYNCING"}}`),
+	})
+
+	rsp := <-rspChan
+	require.NotNil(t, rsp)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, "application/json", rsp.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":7,"result":{"status":"SYNCING"}}`, <-bodyChan)
+
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	assert.Empty(t, env.upstreamCalls, "Synthetic responses must not reach the upstream")
+}
+
+func TestResponseInterceptorModifiesResponse(t *testing.T) {
+	env := newInterceptTestEnv(t, "response_interceptor")
+
+	rspChan, bodyChan := env.call(t, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+
+	msg, body := env.nextIntercept(t)
+	assert.Equal(t, "intercept_response", msg.Method)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, string(body))
+
+	env.sendVerdict(t, msg, &protocol.InterceptVerdict{
+		Action:     "modify",
+		StatusCode: http.StatusBadGateway,
+		Headers:    map[string]string{"X-Intercepted": "true"},
+		Body:       json.RawMessage(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"injected"}}`),
+	})
+
+	rsp := <-rspChan
+	require.NotNil(t, rsp)
+	assert.Equal(t, http.StatusBadGateway, rsp.StatusCode)
+	assert.Equal(t, "true", rsp.Header.Get("X-Intercepted"))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"injected"}}`, <-bodyChan)
+}
+
+func TestRequestInterceptorDropsCall(t *testing.T) {
+	env := newInterceptTestEnv(t, "request_interceptor")
+
+	rspChan, _ := env.call(t, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+
+	msg, _ := env.nextIntercept(t)
+	env.sendVerdict(t, msg, &protocol.InterceptVerdict{Action: "drop"})
+
+	rsp, ok := <-rspChan
+	assert.False(t, ok, "Dropped calls should fail on the client side")
+	assert.Nil(t, rsp)
+
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	assert.Empty(t, env.upstreamCalls)
+}
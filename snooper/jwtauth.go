@@ -0,0 +1,161 @@
+package snooper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// JWT validation modes for the tokens of incoming calls.
+const (
+	JWTValidationOff    = "off"
+	JWTValidationLog    = "log"
+	JWTValidationReject = "reject"
+)
+
+// defaultJWTMaxDrift is the iat tolerance of the Engine API authentication spec.
+const defaultJWTMaxDrift = 60 * time.Second
+
+// JWTAuthConfig configures the JWT handling on the proxy path.
+type JWTAuthConfig struct {
+	// Resign replaces the Authorization header of forwarded calls with a fresh token
+	// signed with the JWT secret of the snooper.
+	Resign bool
+
+	// Validation checks the tokens of incoming calls: off, log or reject.
+	Validation string
+
+	// ClientSecret validates the tokens of incoming calls. Defaults to the JWT secret
+	// of the snooper, set it when clients use a different secret than the target.
+	ClientSecret []byte
+
+	// MaxDrift is the maximum difference between the iat claim of incoming tokens and
+	// the local clock, defaults to 60s.
+	MaxDrift time.Duration
+}
+
+// jwtAuth re-signs forwarded calls and validates the tokens of incoming calls.
+type jwtAuth struct {
+	config JWTAuthConfig
+	secret []byte
+}
+
+// EnableJWTAuth enables re-signing and/or validation of Engine API JWT tokens on the
+// proxy path. Call this once at startup before serving requests.
+func (s *Snooper) EnableJWTAuth(config *JWTAuthConfig) error {
+	auth := &jwtAuth{
+		config: *config,
+	}
+
+	switch auth.config.Validation {
+	case "":
+		auth.config.Validation = JWTValidationOff
+	case JWTValidationOff, JWTValidationLog, JWTValidationReject:
+	default:
+		return fmt.Errorf("unknown JWT validation mode: %s", auth.config.Validation)
+	}
+
+	if auth.config.MaxDrift <= 0 {
+		auth.config.MaxDrift = defaultJWTMaxDrift
+	}
+
+	secret, err := utils.ParseJWTSecret(s.jwtSecret)
+	if err != nil {
+		return fmt.Errorf("invalid JWT secret: %w", err)
+	}
+
+	auth.secret = secret
+
+	if auth.config.Resign && len(auth.secret) == 0 {
+		return errors.New("re-signing JWT tokens requires a JWT secret")
+	}
+
+	if len(auth.config.ClientSecret) == 0 {
+		auth.config.ClientSecret = auth.secret
+	}
+
+	if auth.config.Validation != JWTValidationOff && len(auth.config.ClientSecret) == 0 {
+		return errors.New("validating JWT tokens requires a JWT secret")
+	}
+
+	s.jwtAuth = auth
+
+	s.logger.Infof("JWT auth enabled (re-sign: %v, validation: %v)", auth.config.Resign, auth.config.Validation)
+
+	return nil
+}
+
+// checkJWT validates the token of an incoming call. Invalid tokens are logged, and
+// answered with 401 Unauthorized in reject mode. Returns false for rejected calls.
+func (s *Snooper) checkJWT(w http.ResponseWriter, r *http.Request) bool {
+	if s.jwtAuth == nil || s.jwtAuth.config.Validation == JWTValidationOff {
+		return true
+	}
+
+	err := validateJWTHeader(r.Header, s.jwtAuth.config.ClientSecret, s.jwtAuth.config.MaxDrift)
+	if err == nil {
+		return true
+	}
+
+	reject := s.jwtAuth.config.Validation == JWTValidationReject
+
+	s.logger.WithFields(logrus.Fields{
+		"remote":   getClientIP(r),
+		"rejected": reject,
+	}).Warnf("JWT: invalid token for %v %v: %v", r.Method, r.URL.String(), err)
+
+	if !reject {
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+
+	response := map[string]interface{}{
+		"status":  "error",
+		"message": fmt.Sprintf("invalid JWT token: %v", err),
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		s.logger.Errorf("failed writing JWT rejection response: %v", err)
+	}
+
+	return false
+}
+
+// resignJWT replaces the Authorization header of a forwarded call with a fresh token.
+func (s *Snooper) resignJWT(headers http.Header) error {
+	if s.jwtAuth == nil || !s.jwtAuth.config.Resign {
+		return nil
+	}
+
+	token, err := utils.CreateJWTToken(s.jwtAuth.secret)
+	if err != nil {
+		return fmt.Errorf("failed to create JWT token: %w", err)
+	}
+
+	headers.Set("Authorization", "Bearer "+token)
+
+	return nil
+}
+
+// validateJWTHeader validates the bearer token of the Authorization header.
+func validateJWTHeader(headers http.Header, secret []byte, maxDrift time.Duration) error {
+	auth := headers.Get("Authorization")
+	if auth == "" {
+		return errors.New("missing Authorization header")
+	}
+
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok {
+		return errors.New("no bearer token")
+	}
+
+	return utils.ValidateJWTToken(strings.TrimSpace(token), secret, maxDrift)
+}
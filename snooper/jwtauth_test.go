@@ -0,0 +1,147 @@
+package snooper
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	jwtTestSecret       = []byte("0123456789abcdef0123456789abcdef")
+	jwtTestClientSecret = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func newJWTTestToken(t *testing.T, secret []byte, issuedAt time.Time) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iat": issuedAt.Unix()}).SignedString(secret)
+	require.NoError(t, err)
+
+	return token
+}
+
+// newJWTTestSnooper starts a snooper proxy in front of an upstream that answers with
+// the result of validating the forwarded token against the snooper secret.
+func newJWTTestSnooper(t *testing.T, config *JWTAuthConfig) (string, *testLogHook) {
+	t.Helper()
+
+	snooper, proxyURL, hook := newTestSnooper(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		result := "valid"
+		if err := validateJWTHeader(r.Header, jwtTestSecret, time.Minute); err != nil {
+			result = "invalid"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + result + `"}`))
+	})
+
+	snooper.jwtSecret = hex.EncodeToString(jwtTestSecret)
+	require.NoError(t, snooper.EnableJWTAuth(config))
+
+	return proxyURL, hook
+}
+
+func postJWT(t *testing.T, proxyURL, token string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, proxyURL, strings.NewReader(`{"jsonrpc":"2.0","method":"engine_exchangeCapabilities","params":[[]],"id":1}`)) //nolint:noctx // test request
+	require.NoError(t, err)
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer rsp.Body.Close()
+
+	body, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+
+	return rsp, string(body)
+}
+
+func TestJWTResign(t *testing.T) {
+	proxyURL, _ := newJWTTestSnooper(t, &JWTAuthConfig{Resign: true})
+
+	// calls without a token or signed with another secret are re-signed
+	_, body := postJWT(t, proxyURL, "")
+	assert.Contains(t, body, `"result":"valid"`)
+
+	_, body = postJWT(t, proxyURL, newJWTTestToken(t, jwtTestClientSecret, time.Now()))
+	assert.Contains(t, body, `"result":"valid"`)
+}
+
+func TestJWTValidateReject(t *testing.T) {
+	proxyURL, _ := newJWTTestSnooper(t, &JWTAuthConfig{
+		Resign:       true,
+		Validation:   JWTValidationReject,
+		ClientSecret: jwtTestClientSecret,
+	})
+
+	rsp, body := postJWT(t, proxyURL, newJWTTestToken(t, jwtTestClientSecret, time.Now()))
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Contains(t, body, `"result":"valid"`)
+
+	for name, token := range map[string]string{
+		"missing":   "",
+		"signature": newJWTTestToken(t, jwtTestSecret, time.Now()),
+		"stale":     newJWTTestToken(t, jwtTestClientSecret, time.Now().Add(-2*time.Minute)),
+		"future":    newJWTTestToken(t, jwtTestClientSecret, time.Now().Add(2*time.Minute)),
+		"garbage":   "not-a-token",
+	} {
+		rsp, body := postJWT(t, proxyURL, token)
+		assert.Equal(t, http.StatusUnauthorized, rsp.StatusCode, name)
+		assert.Contains(t, body, "invalid JWT token", name)
+	}
+}
+
+func TestJWTValidateLog(t *testing.T) {
+	proxyURL, hook := newJWTTestSnooper(t, &JWTAuthConfig{Validation: JWTValidationLog})
+
+	// invalid tokens are forwarded as they are
+	rsp, body := postJWT(t, proxyURL, newJWTTestToken(t, jwtTestSecret, time.Now().Add(-5*time.Minute)))
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Contains(t, body, `"result":"invalid"`)
+
+	found := false
+
+	for _, entry := range hook.Entries() {
+		if strings.HasPrefix(entry.Message, "JWT: invalid token") {
+			assert.Contains(t, entry.Message, "iat is off by 5m0s")
+			assert.Equal(t, false, entry.Data["rejected"])
+
+			found = true
+		}
+	}
+
+	assert.True(t, found, "Invalid tokens must be logged")
+}
+
+func TestJWTAuthInvalidConfig(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	snooper, err := NewSnooper("http://localhost:8551", logger, nil, "")
+	require.NoError(t, err)
+
+	defer snooper.Shutdown()
+
+	assert.Error(t, snooper.EnableJWTAuth(&JWTAuthConfig{Resign: true}))
+	assert.Error(t, snooper.EnableJWTAuth(&JWTAuthConfig{Validation: JWTValidationReject}))
+	assert.Error(t, snooper.EnableJWTAuth(&JWTAuthConfig{Validation: "strict", ClientSecret: jwtTestClientSecret}))
+	assert.NoError(t, snooper.EnableJWTAuth(&JWTAuthConfig{Validation: JWTValidationLog, ClientSecret: jwtTestClientSecret}))
+}
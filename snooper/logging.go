@@ -67,6 +67,44 @@ func (s *Snooper) beautifyJSONForLog(body []byte) []byte {
 	return res
 }
 
+// compactJSONForLog re-encodes JSON on a single line for embedding into JSON log
+// entries, truncating large hex values like beautifyJSONForLog.
+func (s *Snooper) compactJSONForLog(body []byte) json.RawMessage {
+	var obj any
+
+	err := json.Unmarshal(body, &obj)
+	if err != nil {
+		s.logger.Warnf("failed unmarshaling data: %v", err)
+		return nil
+	}
+
+	if s.logTruncationEnabled {
+		obj = truncateHexInTree(obj)
+	}
+
+	res, err := json.Marshal(obj)
+	if err != nil {
+		s.logger.Warnf("failed marshaling data: %v", err)
+		return nil
+	}
+
+	return res
+}
+
+// addJSONLogFields adds the call fields of the JSON log format. The text format
+// carries them in the log message instead.
+func (s *Snooper) addJSONLogFields(ctx *ProxyCallContext, logFields logrus.Fields, direction string, req *http.Request, size int) {
+	if !s.jsonLogs {
+		return
+	}
+
+	logFields["callidx"] = ctx.callIndex
+	logFields["direction"] = direction
+	logFields["http_method"] = req.Method
+	logFields["url"] = req.URL.String()
+	logFields["size"] = size
+}
+
 // formatHexBodyForLog formats a hex-encoded body (e.g. SSZ) for log
 // output, optionally truncating large values when truncation is enabled.
 // When truncation applies, only the first and last preview bytes are
@@ -181,32 +219,60 @@ func (s *Snooper) logRequest(ctx *ProxyCallContext, req *http.Request, bodyBytes
 		return
 	}
 
-	// All heavy allocations (beautifyJSON, Unmarshal, hex encoding) happen after the wait
+	// All heavy allocations (beautifyJSON, Unmarshal, SSZ decoding) happen after the wait
 	contentType := req.Header.Get("Content-Type")
 
+	s.recordRequest(ctx, req, bodyData, contentType)
+
 	logFields := logrus.Fields{
 		"color":  color.FgCyan,
 		"length": req.ContentLength,
 	}
 
+	s.addJSONLogFields(ctx, logFields, "request", req, len(bodyData))
+
+	bodyData, parsedData := s.decodeBodyForLog(logFields, bodyData, contentType, req.ContentLength, s.sszDecodeFunc(req, req.Header, false))
+
+	ctx.SetData(0, "request_size", len(bodyData))
+	s.extractJSONRPCMethods(ctx, logFields, parsedData)
+	s.addUpstreamLogField(ctx, logFields)
+
+	s.processRequestModules(ctx, req, bodyData, parsedData, contentType)
+	s.logger.WithFields(logFields).Infof("REQUEST #%v: %v %v", ctx.callIndex, req.Method, req.URL.String())
+}
+
+// decodeBodyForLog decodes a (decompressed) body for logging and module processing.
+// SSZ bodies are decoded with decodeSSZ (if set) when they are a known container and
+// stay raw bytes for the modules, everything else is parsed as JSON when possible.
+// Log fields for the body are only populated when bodies are not hidden.
+func (s *Snooper) decodeBodyForLog(logFields logrus.Fields, bodyData []byte, contentType string, contentLength int64, decodeSSZ sszDecodeFunc) ([]byte, any) {
 	var parsedData any
 
 	switch {
-	case req.ContentLength == 0:
+	case contentLength == 0:
 		bodyData = []byte{}
 	case strings.Contains(contentType, "application/octet-stream"):
+		if decodeSSZ != nil {
+			parsedData = decodeSSZ(bodyData)
+		}
+
 		if !s.hideBodies {
 			logFields["type"] = "ssz"
-			logFields["body"] = s.formatHexBodyForLog(bodyData)
+			logFields["body"] = s.formatSSZBodyForLog(bodyData, parsedData)
 		}
-
-		hexEncoded := make([]byte, len(bodyData)*2)
-		hex.Encode(hexEncoded, bodyData)
-		bodyData = hexEncoded
 	default:
 		_ = json.Unmarshal(bodyData, &parsedData)
 
 		if !s.hideBodies {
+			if s.jsonLogs && parsedData != nil {
+				if compactJSON := s.compactJSONForLog(bodyData); len(compactJSON) > 0 {
+					logFields["type"] = "json"
+					logFields["body"] = compactJSON
+
+					break
+				}
+			}
+
 			if beautifiedJSON := s.beautifyJSONForLog(bodyData); len(beautifiedJSON) > 0 {
 				logFields["type"] = "json"
 				logFields["body"] = string(beautifiedJSON)
@@ -217,37 +283,36 @@ func (s *Snooper) logRequest(ctx *ProxyCallContext, req *http.Request, bodyBytes
 		}
 	}
 
-	ctx.SetData(0, "request_size", len(bodyData))
+	return bodyData, parsedData
+}
 
-	if parsedData != nil {
-		switch v := parsedData.(type) {
-		case map[string]interface{}:
-			if method, ok := v["method"].(string); ok {
-				logFields["method"] = method
+// extractJSONRPCMethods adds the JSON-RPC method (or batch methods) of a parsed
+// request body to the log fields and stores it on the call context for metrics.
+func (s *Snooper) extractJSONRPCMethods(ctx *ProxyCallContext, logFields logrus.Fields, parsedData any) {
+	switch v := parsedData.(type) {
+	case map[string]interface{}:
+		if method, ok := v["method"].(string); ok {
+			logFields["method"] = method
 
-				if s.metricsEnabled {
-					ctx.SetData(0, "jrpc_method", method)
-				}
+			if s.metricsEnabled {
+				ctx.SetData(0, "jrpc_method", method)
 			}
-		case []interface{}:
-			methods := make([]string, 0, len(v))
-
-			for _, item := range v {
-				if obj, ok := item.(map[string]interface{}); ok {
-					if method, ok := obj["method"].(string); ok {
-						methods = append(methods, method)
-					}
+		}
+	case []interface{}:
+		methods := make([]string, 0, len(v))
+
+		for _, item := range v {
+			if obj, ok := item.(map[string]interface{}); ok {
+				if method, ok := obj["method"].(string); ok {
+					methods = append(methods, method)
 				}
 			}
+		}
 
-			if len(methods) > 0 {
-				logFields["methods"] = strings.Join(methods, ", ")
-			}
+		if len(methods) > 0 {
+			logFields["methods"] = strings.Join(methods, ", ")
 		}
 	}
-
-	s.processRequestModules(ctx, req, bodyData, parsedData, contentType)
-	s.logger.WithFields(logFields).Infof("REQUEST #%v: %v %v", ctx.callIndex, req.Method, req.URL.String())
 }
 
 func (s *Snooper) decompressBody(data []byte, contentEncoding string) ([]byte, error) {
@@ -291,6 +356,8 @@ func (s *Snooper) logResponse(ctx *ProxyCallContext, req *http.Request, rsp *htt
 	// All heavy allocations happen after the wait
 	contentType := rsp.Header.Get("Content-Type")
 
+	s.recordResponse(ctx, rsp, bodyData, contentType)
+
 	logFields := logrus.Fields{
 		"status": rsp.StatusCode,
 		"length": rsp.ContentLength,
@@ -302,38 +369,16 @@ func (s *Snooper) logResponse(ctx *ProxyCallContext, req *http.Request, rsp *htt
 		logFields["color"] = color.FgRed
 	}
 
-	var parsedData any
-
-	switch {
-	case rsp.ContentLength == 0:
-		bodyData = []byte{}
-	case strings.Contains(contentType, "application/octet-stream"):
-		if !s.hideBodies {
-			logFields["type"] = "ssz"
-			logFields["body"] = s.formatHexBodyForLog(bodyData)
-		}
+	s.addJSONLogFields(ctx, logFields, "response", req, len(bodyData))
 
-		hexEncoded := make([]byte, len(bodyData)*2)
-		hex.Encode(hexEncoded, bodyData)
-		bodyData = hexEncoded
-	default:
-		_ = json.Unmarshal(bodyData, &parsedData)
-
-		if !s.hideBodies {
-			if beautifiedJSON := s.beautifyJSONForLog(bodyData); len(beautifiedJSON) > 0 {
-				logFields["type"] = "json"
-				logFields["body"] = string(beautifiedJSON)
-			} else {
-				logFields["type"] = "unknown"
-				logFields["body"] = s.formatHexBodyForLog(bodyData)
-			}
-		}
-	}
+	bodyData, parsedData := s.decodeBodyForLog(logFields, bodyData, contentType, rsp.ContentLength, s.sszDecodeFunc(req, rsp.Header, true))
 
 	if d := ctx.CallDuration(); d > 0 {
 		logFields["duration_ms"] = d.Milliseconds()
 	}
 
+	s.addUpstreamLogField(ctx, logFields)
+
 	s.processResponseModules(ctx, req, rsp, bodyData, parsedData, contentType)
 	s.logger.WithFields(logFields).Infof("RESPONSE #%v: %v %v", ctx.callIndex, req.Method, req.URL.String())
 }
@@ -354,6 +399,12 @@ func (s *Snooper) logEventResponse(ctx *ProxyCallContext, req *http.Request, rsp
 		"color": color.FgGreen,
 	}
 
+	s.addJSONLogFields(ctx, logFields, "event", req, len(body))
+
+	if s.jsonLogs {
+		logFields["status"] = rsp.StatusCode
+	}
+
 	evt := map[string]any{}
 
 	for _, line := range strings.Split(string(body), "\n") {
@@ -390,7 +441,11 @@ func (s *Snooper) logEventResponse(ctx *ProxyCallContext, req *http.Request, rsp
 			s.logger.Warnf("failed parsing event data: %v", err)
 		} else {
 			if !s.hideBodies {
-				logFields["body"] = string(s.beautifyJSONForLog(bodyJSON))
+				if s.jsonLogs {
+					logFields["body"] = s.compactJSONForLog(bodyJSON)
+				} else {
+					logFields["body"] = string(s.beautifyJSONForLog(bodyJSON))
+				}
 			}
 
 			parsedEventData = evt
@@ -399,18 +454,44 @@ func (s *Snooper) logEventResponse(ctx *ProxyCallContext, req *http.Request, rsp
 		logFields["body"] = body
 	}
 
+	s.addUpstreamLogField(ctx, logFields)
+
 	// Process modules in order
 	s.processEventModules(ctx, req, rsp, body, parsedEventData)
 
 	s.logger.WithFields(logFields).Infof("RESPONSE-EVENT %v %v (status: %v, body: %v)", req.Method, req.URL.EscapedPath(), rsp.StatusCode, len(body))
 }
 
-// processRequestModules processes request data through modules using already parsed/decoded data
+// processRequestModules processes request data through modules using already parsed/decoded data.
+// JSON-RPC batches are split into one sub-call per element.
 func (s *Snooper) processRequestModules(ctx *ProxyCallContext, req *http.Request, bodyData []byte, parsedData interface{}, contentType string) {
 	if s.moduleManager == nil || !s.moduleManager.IsEnabled() {
 		return
 	}
 
+	if batch := getJSONRPCBatch(parsedData); batch != nil {
+		// the batch as a whole is the request of intercepted batch responses
+		ctx.requestCtx = &types.RequestContext{
+			CallCtx:     ctx,
+			Method:      req.Method,
+			URL:         req.URL,
+			Headers:     req.Header,
+			Body:        parsedData,
+			BodyBytes:   bodyData,
+			ContentType: contentType,
+			Timestamp:   time.Now(),
+		}
+
+		s.processBatchRequestModules(ctx, req, batch, contentType)
+
+		return
+	}
+
+	s.processCallRequestModules(ctx, req, bodyData, parsedData, contentType)
+}
+
+// processCallRequestModules processes the request of a single call through modules
+func (s *Snooper) processCallRequestModules(ctx *ProxyCallContext, req *http.Request, bodyData []byte, parsedData interface{}, contentType string) {
 	// Create request context for modules with the parsed data
 	// Use parsed JSON data if available, otherwise use raw byte data
 	var bodyForModules interface{}
@@ -431,6 +512,8 @@ func (s *Snooper) processRequestModules(ctx *ProxyCallContext, req *http.Request
 		Timestamp:   time.Now(),
 	}
 
+	ctx.requestCtx = reqCtx
+
 	// Process through modules (non-modifying, observation only)
 	_, err := s.moduleManager.ProcessRequest(reqCtx)
 	if err != nil {
@@ -438,12 +521,23 @@ func (s *Snooper) processRequestModules(ctx *ProxyCallContext, req *http.Request
 	}
 }
 
-// processResponseModules processes response data through modules using already parsed/decoded data
+// processResponseModules processes response data through modules using already parsed/decoded data.
+// Responses to JSON-RPC batches are split and matched to the batch sub-calls by id.
 func (s *Snooper) processResponseModules(ctx *ProxyCallContext, req *http.Request, rsp *http.Response, bodyData []byte, parsedData interface{}, contentType string) {
 	if s.moduleManager == nil || !s.moduleManager.IsEnabled() {
 		return
 	}
 
+	if batchCalls, ok := ctx.GetData(0, "batch_calls").([]*batchCall); ok {
+		s.processBatchResponseModules(ctx, batchCalls, req, rsp, bodyData, parsedData, contentType)
+		return
+	}
+
+	s.processCallResponseModules(ctx, req, rsp, bodyData, parsedData, contentType)
+}
+
+// processCallResponseModules processes the response of a single call through modules
+func (s *Snooper) processCallResponseModules(ctx *ProxyCallContext, req *http.Request, rsp *http.Response, bodyData []byte, parsedData interface{}, contentType string) {
 	// Create response context for modules with the parsed data
 	// Use parsed JSON data if available, otherwise use raw byte data
 	var bodyForModules interface{}
@@ -462,6 +556,7 @@ func (s *Snooper) processResponseModules(ctx *ProxyCallContext, req *http.Reques
 		ContentType: contentType,
 		Timestamp:   time.Now(),
 		Duration:    ctx.CallDuration(),
+		Request:     ctx.requestCtx,
 	}
 
 	// Process through modules (non-modifying, observation only)
@@ -498,6 +593,7 @@ func (s *Snooper) processEventModules(ctx *ProxyCallContext, _ *http.Request, rs
 		Body:        bodyForModules,
 		ContentType: "text/event-stream",
 		Timestamp:   time.Now(),
+		Request:     ctx.requestCtx,
 	}
 
 	// Process through modules (non-modifying, observation only)
@@ -0,0 +1,107 @@
+package snooper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent log writes and reads.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+// entries returns the decoded JSON log entries written so far.
+func (b *syncBuffer) entries(t *testing.T) []map[string]any {
+	t.Helper()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var entries []map[string]any
+
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	for scanner.Scan() {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), "Each log line must be a JSON object: %s", scanner.Text())
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// enableJSONTestLogs switches a test snooper to the JSON log format and returns its
+// log output.
+func enableJSONTestLogs(snooper *Snooper) *syncBuffer {
+	output := &syncBuffer{}
+
+	logger, _ := snooper.logger.(*logrus.Logger)
+	logger.SetOutput(output)
+	logger.SetFormatter(&utils.JSONFormatter{})
+
+	snooper.EnableJSONLogs()
+
+	return output
+}
+
+func TestJSONLogOutput(t *testing.T) {
+	hexValue := "0x" + strings.Repeat("ab", 200)
+
+	snooper, proxyURL, _ := newTestSnooper(t, jsonHandler(`{"jsonrpc":"2.0","id":1,"result":"`+hexValue+`"}`))
+	output := enableJSONTestLogs(snooper)
+	snooper.EnableLogTruncation()
+
+	_, _, err := postJSON(t, proxyURL+"/?x=1", `{"jsonrpc":"2.0","method":"eth_getCode","params":[],"id":1}`)
+	require.NoError(t, err)
+
+	var request, response map[string]any
+
+	require.Eventually(t, func() bool {
+		for _, entry := range output.entries(t) {
+			switch entry["direction"] {
+			case "request":
+				request = entry
+			case "response":
+				response = entry
+			}
+		}
+
+		return request != nil && response != nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, float64(1), request["callidx"])
+	assert.Equal(t, http.MethodPost, request["http_method"])
+	assert.Equal(t, "/?x=1", request["url"])
+	assert.Equal(t, "eth_getCode", request["method"])
+	assert.Equal(t, snooper.upstreams.Primary().URL.String(), request["upstream"])
+	assert.NotContains(t, request, "color")
+	assert.Equal(t, map[string]any{"jsonrpc": "2.0", "method": "eth_getCode", "params": []any{}, "id": float64(1)}, request["body"], "Body must be embedded as JSON")
+
+	assert.Equal(t, float64(1), response["callidx"])
+	assert.Equal(t, float64(http.StatusOK), response["status"])
+	assert.Contains(t, response, "duration_ms")
+	assert.Contains(t, response, "size")
+
+	body, ok := response["body"].(map[string]any)
+	require.True(t, ok, "Body must be embedded as JSON")
+	assert.Contains(t, body["result"], "<200 bytes>", "Large hex values must be truncated")
+}
@@ -0,0 +1,289 @@
+package snooper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/metrics"
+	"github.com/ethpandaops/rpc-snooper/modules/protocol"
+	"github.com/ethpandaops/rpc-snooper/recording"
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/itchyny/gojq"
+	"github.com/sirupsen/logrus"
+)
+
+// Mirror comparison results, used as metric labels.
+const (
+	mirrorResultMatch    = "match"
+	mirrorResultMismatch = "mismatch"
+	mirrorResultError    = "error"
+)
+
+// mirror holds the shadow traffic configuration.
+type mirror struct {
+	target      *url.URL
+	ignoreQuery *gojq.Query
+	client      *http.Client
+}
+
+// mirrorCall tracks a single call sent to the mirror until the primary response
+// is available for comparison.
+type mirrorCall struct {
+	callIndex   uint64
+	method      string
+	url         string
+	jrpcMethod  string
+	primaryChan chan *mirrorResponse
+}
+
+type mirrorResponse struct {
+	statusCode  int
+	contentType string
+	body        []byte
+}
+
+// EnableMirror sends a copy of every proxied call to the mirror upstream and compares
+// its responses with the ones from the primary target. The fields selected by the
+// ignoreFields gojq paths (e.g. ".result.timestamp") are removed before comparing.
+// Call this once at startup before serving requests.
+func (s *Snooper) EnableMirror(target string, ignoreFields []string) error {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid mirror url: %w", err)
+	}
+
+	m := &mirror{
+		target: targetURL,
+		client: &http.Client{Timeout: s.CallTimeout},
+	}
+
+	if len(ignoreFields) > 0 {
+		query, err := gojq.Parse(fmt.Sprintf("del(%s)", strings.Join(ignoreFields, ", ")))
+		if err != nil {
+			return fmt.Errorf("invalid mirror ignore fields: %w", err)
+		}
+
+		m.ignoreQuery = query
+	}
+
+	s.mirror = m
+
+	s.logger.Infof("mirroring calls to: %v", targetURL)
+
+	return nil
+}
+
+// isEventStreamRequest reports whether a request subscribes to an event stream,
+// which cannot be mirrored.
+func isEventStreamRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.EscapedPath(), "/eth/v1/events") || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// startMirrorCall sends the request body captured by the request tee stream to the
+// mirror upstream. The mirror is called asynchronously, so it never delays the
+// primary call; its response is compared once the primary response was captured.
+func (s *Snooper) startMirrorCall(callCtx *ProxyCallContext, r *http.Request, bodyData []byte) *mirrorCall {
+	call := &mirrorCall{
+		callIndex:   callCtx.callIndex,
+		method:      r.Method,
+		url:         r.URL.String(),
+		primaryChan: make(chan *mirrorResponse, 1),
+	}
+
+	if decompressed, err := s.decompressBody(bodyData, r.Header.Get("Content-Encoding")); err == nil {
+		var parsedData any
+		if json.Unmarshal(decompressed, &parsedData) == nil {
+			call.jrpcMethod = getMirrorJRPCMethod(parsedData)
+		}
+	}
+
+	headers := r.Header.Clone()
+	if err := s.resignJWT(headers); err != nil {
+		s.logger.WithField("callidx", call.callIndex).Warnf("mirror: %v", err)
+	}
+
+	go s.runMirrorCall(call, headers, r.URL, bodyData)
+
+	return call
+}
+
+// completePrimary hands the primary response over to the mirror comparison.
+func (call *mirrorCall) completePrimary(s *Snooper, rsp *http.Response, bodyData []byte) {
+	body, err := s.decompressBody(bodyData, rsp.Header.Get("Content-Encoding"))
+	if err != nil {
+		body = bodyData
+	}
+
+	call.primaryChan <- &mirrorResponse{
+		statusCode:  rsp.StatusCode,
+		contentType: rsp.Header.Get("Content-Type"),
+		body:        body,
+	}
+}
+
+func (s *Snooper) runMirrorCall(call *mirrorCall, headers http.Header, reqURL *url.URL, bodyData []byte) {
+	mirrorRsp, mirrorErr := s.sendMirrorRequest(call.method, headers, reqURL, bodyData)
+
+	var primaryRsp *mirrorResponse
+
+	select {
+	case primaryRsp = <-call.primaryChan:
+	case <-time.After(s.CallTimeout):
+		s.logger.WithField("callidx", call.callIndex).Debug("no primary response to compare with mirror response")
+		return
+	}
+
+	event := &protocol.MirrorDiffEvent{
+		RequestID:     call.callIndex,
+		Method:        call.method,
+		URL:           call.url,
+		JRPCMethod:    call.jrpcMethod,
+		PrimaryStatus: primaryRsp.statusCode,
+	}
+
+	logFields := logrus.Fields{
+		"callidx": call.callIndex,
+	}
+
+	if call.jrpcMethod != "" {
+		logFields["method"] = call.jrpcMethod
+	}
+
+	if mirrorErr != nil {
+		event.Error = mirrorErr.Error()
+
+		s.logger.WithFields(logFields).Warnf("MIRROR #%v: %v %v failed: %v", call.callIndex, call.method, call.url, mirrorErr)
+		s.publishMirrorResult(call, mirrorResultError, event)
+
+		return
+	}
+
+	event.MirrorStatus = mirrorRsp.statusCode
+
+	for _, diff := range recording.DiffResponses(s.normalizeMirrorResponse(primaryRsp), s.normalizeMirrorResponse(mirrorRsp)) {
+		event.Differences = append(event.Differences, protocol.MirrorDifference{
+			Path:    diff.Path,
+			Primary: diff.Recorded,
+			Mirror:  diff.Replayed,
+		})
+	}
+
+	if len(event.Differences) == 0 {
+		s.logger.WithFields(logFields).Debugf("MIRROR #%v: %v %v matches", call.callIndex, call.method, call.url)
+		s.publishMirrorResult(call, mirrorResultMatch, nil)
+
+		return
+	}
+
+	diffs := make([]string, 0, len(event.Differences))
+
+	for _, diff := range event.Differences {
+		primary, _ := json.Marshal(diff.Primary)
+		mirrored, _ := json.Marshal(diff.Mirror)
+		diffs = append(diffs, fmt.Sprintf("%s: %s != %s", diff.Path, primary, mirrored))
+	}
+
+	logFields["differences"] = strings.Join(diffs, "; ")
+
+	s.logger.WithFields(logFields).Warnf("MIRROR #%v: %v %v response differs from primary", call.callIndex, call.method, call.url)
+	s.publishMirrorResult(call, mirrorResultMismatch, event)
+}
+
+// sendMirrorRequest sends a call to the mirror upstream and reads its response.
+func (s *Snooper) sendMirrorRequest(method string, headers http.Header, reqURL *url.URL, bodyData []byte) (*mirrorResponse, error) {
+	queryArgs := ""
+	if reqURL.RawQuery != "" {
+		queryArgs = fmt.Sprintf("?%s", reqURL.RawQuery)
+	}
+
+	mirrorURL, err := url.Parse(fmt.Sprintf("%s%s%s", s.mirror.target, reqURL.EscapedPath(), queryArgs))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing mirror url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), method, mirrorURL.String(), bytes.NewReader(bodyData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mirror request: %w", err)
+	}
+
+	req.Header = headers
+
+	rsp, err := s.mirror.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("mirror request error: %w", err)
+	}
+	defer rsp.Body.Close()
+
+	rspData, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading mirror response: %w", err)
+	}
+
+	body, err := s.decompressBody(rspData, rsp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, fmt.Errorf("failed decompressing mirror response: %w", err)
+	}
+
+	return &mirrorResponse{
+		statusCode:  rsp.StatusCode,
+		contentType: rsp.Header.Get("Content-Type"),
+		body:        body,
+	}, nil
+}
+
+// normalizeMirrorResponse removes the ignored fields from a JSON response body.
+func (s *Snooper) normalizeMirrorResponse(rsp *mirrorResponse) *recording.Response {
+	body := rsp.body
+
+	if s.mirror.ignoreQuery != nil {
+		var parsedData any
+		if json.Unmarshal(body, &parsedData) == nil {
+			iter := s.mirror.ignoreQuery.Run(parsedData)
+			if result, ok := iter.Next(); ok {
+				if _, isErr := result.(error); !isErr {
+					if normalized, err := json.Marshal(result); err == nil {
+						body = normalized
+					}
+				}
+			}
+		}
+	}
+
+	return &recording.Response{
+		StatusCode: rsp.statusCode,
+		Payload:    recording.NewPayload(body, rsp.contentType),
+	}
+}
+
+// publishMirrorResult counts the comparison result and pushes mismatches to the
+// control clients.
+func (s *Snooper) publishMirrorResult(call *mirrorCall, result string, event *protocol.MirrorDiffEvent) {
+	if s.metricsEnabled {
+		metrics.MirrorResultRegister(call.jrpcMethod, result)
+	}
+
+	if event != nil && s.moduleManager != nil {
+		s.moduleManager.BroadcastEvent("mirror_diff", event)
+	}
+}
+
+// getMirrorJRPCMethod returns the JSON-RPC method of a request, or "batch" for
+// batch requests to keep the metric cardinality low.
+func getMirrorJRPCMethod(parsedData any) string {
+	if _, isBatch := parsedData.([]any); isBatch {
+		return "batch"
+	}
+
+	if methods := types.JSONRPCMethods(parsedData); len(methods) > 0 {
+		return methods[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,157 @@
+package snooper
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/modules/protocol"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMirrorTestEnv starts a primary and a mirror upstream answering with the given
+// handlers, and a snooper proxy mirroring to the latter. It returns the proxy URL and
+// a control client connection receiving mirror_diff events.
+func newMirrorTestEnv(t *testing.T, primary, mirrored http.HandlerFunc, ignoreFields []string) (string, *websocket.Conn) {
+	t.Helper()
+
+	mirrorServer := httptest.NewServer(mirrored)
+	t.Cleanup(mirrorServer.Close)
+
+	snooper, proxyURL, _ := newTestSnooper(t, primary)
+	require.NoError(t, snooper.EnableMirror(mirrorServer.URL, ignoreFields))
+
+	conn := dialControlTestConn(t, snooper)
+
+	// any answer from the control connection means it is registered for events
+	require.NoError(t, conn.WriteJSON(&protocol.WSMessage{RequestID: 1, Method: "ping"}))
+
+	var pingRsp protocol.WSMessage
+	require.NoError(t, conn.ReadJSON(&pingRsp))
+	require.Equal(t, uint64(1), pingRsp.ResponseID)
+
+	return proxyURL, conn
+}
+
+func jsonHandler(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func readMirrorDiff(t *testing.T, conn *websocket.Conn) *protocol.MirrorDiffEvent {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	var msg struct {
+		Method string                   `json:"method"`
+		Data   protocol.MirrorDiffEvent `json:"data"`
+	}
+
+	require.NoError(t, conn.ReadJSON(&msg))
+	require.Equal(t, "mirror_diff", msg.Method)
+
+	return &msg.Data
+}
+
+func TestMirrorReportsDifferences(t *testing.T) {
+	proxyURL, conn := newMirrorTestEnv(t,
+		jsonHandler(`{"jsonrpc":"2.0","id":1,"result":{"status":"VALID","timestamp":"0x1"}}`),
+		jsonHandler(`{"jsonrpc":"2.0","id":1,"result":{"status":"INVALID","timestamp":"0x2"}}`),
+		[]string{".result.timestamp"},
+	)
+
+	_, body, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"engine_newPayloadV4","params":[],"id":1}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{"status":"VALID","timestamp":"0x1"}}`, body, "Client must be answered by the primary")
+
+	event := readMirrorDiff(t, conn)
+	assert.Equal(t, uint64(1), event.RequestID)
+	assert.Equal(t, "engine_newPayloadV4", event.JRPCMethod)
+	assert.Equal(t, http.StatusOK, event.PrimaryStatus)
+	assert.Equal(t, http.StatusOK, event.MirrorStatus)
+	assert.Equal(t, []protocol.MirrorDifference{{Path: ".result.status", Primary: "VALID", Mirror: "INVALID"}}, event.Differences)
+}
+
+func TestMirrorIgnoresFields(t *testing.T) {
+	proxyURL, conn := newMirrorTestEnv(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			w.Header().Set("Content-Type", "application/json")
+
+			if strings.Contains(string(body), "eth_chainId") {
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+			} else {
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x1","timestamp":"0x1"}}`))
+			}
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			w.Header().Set("Content-Type", "application/json")
+
+			if strings.Contains(string(body), "eth_chainId") {
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x2"}`))
+			} else {
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x1","timestamp":"0x2"}}`))
+			}
+		},
+		[]string{".result.timestamp"},
+	)
+
+	// differs in an ignored field only
+	_, _, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["latest",false],"id":1}`)
+	require.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, _, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":1}`)
+	require.NoError(t, err)
+
+	event := readMirrorDiff(t, conn)
+	assert.Equal(t, uint64(2), event.RequestID, "Only the call with a relevant difference should be reported")
+	assert.Equal(t, "eth_chainId", event.JRPCMethod)
+}
+
+func TestMirrorDoesNotDelayPrimary(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	proxyURL, conn := newMirrorTestEnv(t,
+		jsonHandler(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(io.Discard, r.Body)
+
+			// hang until the primary call has been answered, then fail
+			<-release
+			w.WriteHeader(http.StatusBadGateway)
+		},
+		nil,
+	)
+
+	start := time.Now()
+
+	rsp, body, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, body)
+	assert.Less(t, time.Since(start), time.Second)
+
+	release <- struct{}{}
+
+	event := readMirrorDiff(t, conn)
+	assert.Equal(t, http.StatusOK, event.PrimaryStatus)
+	assert.Equal(t, http.StatusBadGateway, event.MirrorStatus)
+	require.NotEmpty(t, event.Differences)
+	assert.Equal(t, "status_code", event.Differences[0].Path)
+}
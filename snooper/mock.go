@@ -0,0 +1,476 @@
+package snooper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/modules"
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/itchyny/gojq"
+)
+
+const jsonRPCMethodNotFound = -32601
+
+// MockConfig is the rule file of the mock upstream mode.
+type MockConfig struct {
+	Fallthrough bool        `json:"fallthrough"` // forward unmatched calls to the target
+	Rules       []*MockRule `json:"rules"`
+}
+
+// MockRule answers matching calls with a canned response. Rules are matched in order,
+// the first match answers the call.
+type MockRule struct {
+	Match    MockMatch    `json:"match"`
+	Response MockResponse `json:"response"`
+
+	filter   *types.Filter
+	template *gojq.Code
+	sszData  []byte
+}
+
+// MockMatch selects the calls a mock rule answers. All set fields must match.
+type MockMatch struct {
+	Path        string   `json:"path,omitempty"` // URL path prefix
+	HTTPMethods []string `json:"http_methods,omitempty"`
+	JRPCMethods []string `json:"jrpc_methods,omitempty"` // calls of a batch are answered individually
+	JSONQuery   string   `json:"json_query,omitempty"`   // gojq predicate on the request body
+}
+
+// MockResponse describes the response of a mock rule. Exactly one of Body, Template,
+// SSZFile or Events must be set.
+type MockResponse struct {
+	StatusCode int               `json:"status_code,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	DelayMs    int64             `json:"delay_ms,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`     // static JSON body
+	Template   string            `json:"template,omitempty"` // gojq expression over the request body, $path is the URL path
+	SSZFile    string            `json:"ssz_file,omitempty"` // relative to the rule file
+	Events     []MockEvent       `json:"events,omitempty"`   // scripted event stream
+	KeepOpen   bool              `json:"keep_open,omitempty"`
+}
+
+// MockEvent is an event sent on a scripted event stream.
+type MockEvent struct {
+	Event   string          `json:"event"`
+	Data    json.RawMessage `json:"data"`
+	DelayMs int64           `json:"delay_ms,omitempty"`
+}
+
+// mockUpstream answers calls from the mock rules instead of the target.
+type mockUpstream struct {
+	rules            []*MockRule
+	forwardUnmatched bool
+	filterEngine     *modules.FilterEngine
+}
+
+// EnableMock answers calls from the mock rule file instead of forwarding them. In
+// standalone mode there is no real target, so unmatched calls are never forwarded.
+// Call this once at startup before serving requests.
+func (s *Snooper) EnableMock(path string, standalone bool) error {
+	config, err := loadMockConfig(path)
+	if err != nil {
+		return err
+	}
+
+	m := &mockUpstream{
+		rules:            config.Rules,
+		forwardUnmatched: config.Fallthrough && !standalone,
+		filterEngine:     modules.NewFilterEngine(s.logger),
+	}
+
+	if config.Fallthrough && standalone {
+		s.logger.Warn("mock rules fall through, but there is no target to forward unmatched calls to")
+	}
+
+	for idx, rule := range m.rules {
+		if err := m.prepareRule(rule); err != nil {
+			return fmt.Errorf("invalid mock rule %d: %w", idx, err)
+		}
+	}
+
+	s.mock = m
+
+	s.logger.Infof("mocking upstream with %v rules from: %v", len(m.rules), path)
+
+	return nil
+}
+
+func loadMockConfig(path string) (*MockConfig, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // user provided rule file
+	if err != nil {
+		return nil, fmt.Errorf("failed reading mock rules: %w", err)
+	}
+
+	config := &MockConfig{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("invalid mock rules: %w", err)
+	}
+
+	baseDir := filepath.Dir(path)
+
+	for idx, rule := range config.Rules {
+		if rule.Response.SSZFile == "" {
+			continue
+		}
+
+		sszPath := rule.Response.SSZFile
+		if !filepath.IsAbs(sszPath) {
+			sszPath = filepath.Join(baseDir, sszPath)
+		}
+
+		rule.sszData, err = os.ReadFile(sszPath) //nolint:gosec // user provided rule file
+		if err != nil {
+			return nil, fmt.Errorf("invalid mock rule %d: failed reading ssz file: %w", idx, err)
+		}
+	}
+
+	return config, nil
+}
+
+// prepareRule validates a rule and compiles its predicate and template.
+func (m *mockUpstream) prepareRule(rule *MockRule) error {
+	rsp := &rule.Response
+
+	kinds := 0
+
+	for _, set := range []bool{len(rsp.Body) > 0, rsp.Template != "", rsp.SSZFile != "", len(rsp.Events) > 0} {
+		if set {
+			kinds++
+		}
+	}
+
+	if kinds != 1 {
+		return errors.New("exactly one of body, template, ssz_file or events is required")
+	}
+
+	if len(rsp.Body) > 0 && !json.Valid(rsp.Body) {
+		return errors.New("body is not valid JSON")
+	}
+
+	if rsp.StatusCode == 0 {
+		rsp.StatusCode = http.StatusOK
+	}
+
+	if rsp.StatusCode < 100 || rsp.StatusCode > 599 {
+		return fmt.Errorf("invalid status_code: %d", rsp.StatusCode)
+	}
+
+	if rsp.DelayMs < 0 {
+		return errors.New("delay_ms must not be negative")
+	}
+
+	for idx, event := range rsp.Events {
+		if event.Event == "" || !json.Valid(event.Data) {
+			return fmt.Errorf("event %d needs an event name and JSON data", idx)
+		}
+	}
+
+	if rsp.Template != "" {
+		query, err := gojq.Parse(rsp.Template)
+		if err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+
+		rule.template, err = gojq.Compile(query, gojq.WithVariables([]string{"$path"}))
+		if err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+
+	rule.filter = &types.Filter{
+		Methods:   rule.Match.HTTPMethods,
+		JSONQuery: rule.Match.JSONQuery,
+	}
+
+	if err := m.filterEngine.CompileFilter(rule.filter); err != nil {
+		return fmt.Errorf("invalid json_query: %w", err)
+	}
+
+	return nil
+}
+
+// match returns the first rule matching a call, or nil.
+func (m *mockUpstream) match(r *http.Request, call any) *MockRule {
+	reqCtx := &types.RequestContext{
+		Method:      r.Method,
+		URL:         r.URL,
+		Headers:     r.Header,
+		Body:        call,
+		ContentType: r.Header.Get("Content-Type"),
+	}
+	method := types.JSONRPCMethod(call)
+
+	for _, rule := range m.rules {
+		if rule.Match.Path != "" && !strings.HasPrefix(r.URL.Path, rule.Match.Path) {
+			continue
+		}
+
+		if len(rule.Match.JRPCMethods) > 0 && !slices.Contains(rule.Match.JRPCMethods, method) {
+			continue
+		}
+
+		if rule.Match.JSONQuery != "" && call == nil {
+			continue
+		}
+
+		if m.filterEngine.ShouldProcessRequestFilter(rule.filter, reqCtx) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// mockResponse answers a call from the mock rules. It consumes the request body, so the
+// request is logged and processed by the modules as if it was forwarded, and restores
+// it for calls falling through to the target. Returns nil for calls to forward.
+func (s *Snooper) mockResponse(callCtx *ProxyCallContext, r, req *http.Request) (*http.Response, error) {
+	bodyData, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading request body: %w", err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(bodyData))
+
+	parsedBody := s.parseJSONBody(bodyData, r.Header.Get("Content-Encoding"))
+
+	if batch := getJSONRPCBatch(parsedBody); batch != nil {
+		return s.mockBatchResponse(callCtx, r, batch)
+	}
+
+	rule := s.mock.match(r, parsedBody)
+	if rule == nil {
+		if s.mock.forwardUnmatched {
+			return nil, nil
+		}
+
+		s.logger.WithField("callidx", callCtx.callIndex).Infof("MOCK #%v: no rule matched %v %v", callCtx.callIndex, r.Method, r.URL.Path)
+
+		return mockNotFoundResponse(r, parsedBody), nil
+	}
+
+	s.logger.WithField("callidx", callCtx.callIndex).Infof("MOCK #%v: answered %v %v", callCtx.callIndex, r.Method, r.URL.Path)
+
+	sleepWithContext(callCtx.context, time.Duration(rule.Response.DelayMs)*time.Millisecond)
+
+	if len(rule.Response.Events) > 0 {
+		return newMockEventStream(callCtx, rule), nil
+	}
+
+	headers := http.Header{}
+
+	var body []byte
+
+	if rule.sszData != nil {
+		headers.Set("Content-Type", "application/octet-stream")
+
+		body = rule.sszData
+	} else {
+		headers.Set("Content-Type", "application/json")
+
+		body, err = rule.jsonBody(r.URL.Path, parsedBody)
+		if err != nil {
+			s.logger.WithField("callidx", callCtx.callIndex).WithError(err).Warn("mock template failed")
+
+			return mockErrorResponse(http.StatusInternalServerError, fmt.Sprintf("mock template failed: %v", err)), nil
+		}
+	}
+
+	for name, value := range rule.Response.Headers {
+		headers.Set(name, value)
+	}
+
+	return newSyntheticResponse(&types.SyntheticResponse{
+		StatusCode: rule.Response.StatusCode,
+		Headers:    headers,
+		Body:       body,
+	}), nil
+}
+
+// mockBatchResponse answers the calls of a JSON-RPC batch individually. The batch
+// falls through to the target if any of its calls is not matched. Notifications are
+// not answered, like by a JSON-RPC server.
+func (s *Snooper) mockBatchResponse(callCtx *ProxyCallContext, r *http.Request, batch []any) (*http.Response, error) {
+	rules := make([]*MockRule, len(batch))
+	delay := int64(0)
+
+	for idx, call := range batch {
+		rules[idx] = s.mock.match(r, call)
+
+		if rules[idx] == nil && s.mock.forwardUnmatched {
+			return nil, nil
+		}
+
+		if rules[idx] != nil && rules[idx].Response.DelayMs > delay {
+			delay = rules[idx].Response.DelayMs
+		}
+	}
+
+	s.logger.WithField("callidx", callCtx.callIndex).Infof("MOCK #%v: answered batch of %v calls", callCtx.callIndex, len(batch))
+
+	sleepWithContext(callCtx.context, time.Duration(delay)*time.Millisecond)
+
+	responses := make([]json.RawMessage, 0, len(batch))
+
+	for idx, call := range batch {
+		if getJSONRPCID(call) == "" {
+			continue
+		}
+
+		callData, err := json.Marshal(call)
+		if err != nil {
+			return nil, err
+		}
+
+		var response []byte
+
+		switch rule := rules[idx]; {
+		case rule == nil:
+			response = buildJSONRPCErrorBody(callData, jsonRPCMethodNotFound, "Method not found")
+		case rule.template == nil && len(rule.Response.Body) == 0:
+			response = buildJSONRPCErrorBody(callData, defaultFaultJRPCErrorCode, "mock rule does not return JSON")
+		default:
+			response, err = rule.jsonBody(r.URL.Path, call)
+			if err != nil {
+				response = buildJSONRPCErrorBody(callData, defaultFaultJRPCErrorCode, fmt.Sprintf("mock template failed: %v", err))
+			}
+		}
+
+		responses = append(responses, response)
+	}
+
+	// a batch of notifications is answered with an empty body
+	if len(responses) == 0 {
+		return newSyntheticResponse(&types.SyntheticResponse{
+			StatusCode: http.StatusOK,
+			Headers:    http.Header{},
+		}), nil
+	}
+
+	body, err := json.Marshal(responses)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+
+	return newSyntheticResponse(&types.SyntheticResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       body,
+	}), nil
+}
+
+// jsonBody returns the static body of a rule, or runs its template over the request.
+func (rule *MockRule) jsonBody(path string, call any) ([]byte, error) {
+	if rule.template == nil {
+		return rule.Response.Body, nil
+	}
+
+	result, ok := rule.template.Run(call, path).Next()
+	if !ok {
+		return nil, errors.New("template produced no result")
+	}
+
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+
+	return encodeJSONBody(result, "")
+}
+
+// newMockEventStream returns an event stream response sending the scripted events of
+// a rule. The stream ends after the last event unless the rule keeps it open.
+func newMockEventStream(callCtx *ProxyCallContext, rule *MockRule) *http.Response {
+	reader, writer := io.Pipe()
+
+	go func() {
+		defer writer.Close()
+
+		for _, event := range rule.Response.Events {
+			sleepWithContext(callCtx.context, time.Duration(event.DelayMs)*time.Millisecond)
+
+			if callCtx.context.Err() != nil {
+				return
+			}
+
+			data := &bytes.Buffer{}
+			if err := json.Compact(data, event.Data); err != nil {
+				data.Write(event.Data)
+			}
+
+			if _, err := fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event.Event, data.Bytes()); err != nil {
+				return
+			}
+		}
+
+		if rule.Response.KeepOpen {
+			<-callCtx.context.Done()
+		}
+	}()
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "text/event-stream")
+	headers.Set("Cache-Control", "no-cache")
+
+	for name, value := range rule.Response.Headers {
+		headers.Set(name, value)
+	}
+
+	return &http.Response{
+		StatusCode:    rule.Response.StatusCode,
+		Header:        headers,
+		Body:          reader,
+		ContentLength: -1,
+	}
+}
+
+// mockNotFoundResponse answers an unmatched call with a JSON-RPC "method not found"
+// error, or a beacon API style 404 for other calls.
+func mockNotFoundResponse(r *http.Request, parsedBody any) *http.Response {
+	if types.JSONRPCMethod(parsedBody) != "" {
+		callData, err := json.Marshal(parsedBody)
+		if err == nil {
+			headers := http.Header{}
+			headers.Set("Content-Type", "application/json")
+
+			return newSyntheticResponse(&types.SyntheticResponse{
+				StatusCode: http.StatusOK,
+				Headers:    headers,
+				Body:       buildJSONRPCErrorBody(callData, jsonRPCMethodNotFound, "Method not found"),
+			})
+		}
+	}
+
+	return mockErrorResponse(http.StatusNotFound, fmt.Sprintf("no mock rule matched %v %v", r.Method, r.URL.Path))
+}
+
+func mockErrorResponse(statusCode int, message string) *http.Response {
+	body, _ := json.Marshal(map[string]any{
+		"code":    statusCode,
+		"message": message,
+	})
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+
+	return newSyntheticResponse(&types.SyntheticResponse{
+		StatusCode: statusCode,
+		Headers:    headers,
+		Body:       body,
+	})
+}
@@ -0,0 +1,206 @@
+package snooper
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockTestRules = `{
+  "rules": [
+    {"match": {"jrpc_methods": ["eth_chainId"]}, "response": {"body": {"jsonrpc": "2.0", "id": 1, "result": "0x1"}}},
+    {"match": {"jrpc_methods": ["eth_getBlockByNumber"], "json_query": ".params[0] == \"latest\""}, "response": {"template": "{jsonrpc: \"2.0\", id: .id, result: {number: \"0x10\"}}"}},
+    {"match": {"path": "/eth/v2/beacon/blocks/"}, "response": {"ssz_file": "block.ssz", "headers": {"Eth-Consensus-Version": "phase0"}}},
+    {"match": {"path": "/eth/v1/beacon/pool/attestations"}, "response": {"template": "{data: {received: length}}"}},
+    {"match": {"path": "/eth/v1/node/version"}, "response": {"template": "{data: {version: (\"mock\" + $path)}}"}},
+    {"match": {"path": "/eth/v1/events"}, "response": {"events": [
+      {"event": "head", "data": {"slot": "10", "block": "0x9a2f"}},
+      {"event": "block", "data": {"slot": "10", "block": "0x9a2f"}, "delay_ms": 10}
+    ]}}
+  ]
+}`
+
+// newMockTestSnooper writes the mock rules and a SSZ file to a temporary directory
+// and starts a snooper proxy answering from them.
+func newMockTestSnooper(t *testing.T, target, rules string, standalone bool) (*Snooper, string, *testLogHook) {
+	t.Helper()
+
+	dir := t.TempDir()
+	rulesFile := filepath.Join(dir, "mock.json")
+
+	require.NoError(t, os.WriteFile(rulesFile, []byte(rules), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "block.ssz"), []byte{0x01, 0x02, 0x03}, 0o600))
+
+	snooper, proxyURL, hook := newTargetTestSnooper(t, target)
+
+	require.NoError(t, snooper.EnableMock(rulesFile, standalone))
+
+	return snooper, proxyURL, hook
+}
+
+func TestMockJSONRPCResponses(t *testing.T) {
+	_, proxyURL, hook := newMockTestSnooper(t, "mock:mock.json", mockTestRules, true)
+
+	_, body, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":1}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, body)
+
+	// templates echo the request id
+	_, body, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["latest",false],"id":"abc"}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":"abc","result":{"number":"0x10"}}`, body)
+
+	// calls failing the predicate are not matched
+	rsp, body, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x1",false],"id":7}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":7,"error":{"code":-32601,"message":"Method not found"}}`, body)
+
+	// calls of a batch are answered individually
+	_, body, err = postJSON(t, proxyURL, `[{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["latest",false],"id":2},{"jsonrpc":"2.0","method":"eth_gasPrice","params":[],"id":3}]`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":2,"result":{"number":"0x10"}},{"jsonrpc":"2.0","id":3,"error":{"code":-32601,"message":"Method not found"}}]`, body)
+
+	// notifications in a batch are not answered
+	_, body, err = postJSON(t, proxyURL, `[{"jsonrpc":"2.0","method":"eth_chainId","params":[]},{"jsonrpc":"2.0","method":"eth_gasPrice","params":[]},{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":4}]`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":1,"result":"0x1"}]`, body)
+
+	// mocked calls are logged like forwarded ones
+	require.Eventually(t, func() bool {
+		for _, entry := range hook.Entries() {
+			if body, ok := entry.Data["body"].(string); ok && strings.HasPrefix(entry.Message, "RESPONSE") && strings.Contains(body, `"result": "0x1"`) {
+				return true
+			}
+		}
+
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestMockBeaconResponses(t *testing.T) {
+	_, proxyURL, _ := newMockTestSnooper(t, "mock:mock.json", mockTestRules, true)
+
+	get := func(path string) (*http.Response, []byte) {
+		rsp, err := http.Get(proxyURL + path) //nolint:noctx // test request
+		require.NoError(t, err)
+
+		defer rsp.Body.Close()
+
+		data, err := io.ReadAll(rsp.Body)
+		require.NoError(t, err)
+
+		return rsp, data
+	}
+
+	rsp, data := get("/eth/v2/beacon/blocks/head")
+	assert.Equal(t, "application/octet-stream", rsp.Header.Get("Content-Type"))
+	assert.Equal(t, "phase0", rsp.Header.Get("Eth-Consensus-Version"))
+	assert.Equal(t, []byte{0x01, 0x02, 0x03}, data)
+
+	// beacon API array bodies are answered as a whole, not as JSON-RPC batches
+	_, body, err := postJSON(t, proxyURL+"/eth/v1/beacon/pool/attestations", `[{"aggregation_bits":"0x01"},{"aggregation_bits":"0x03"}]`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"received":2}}`, body)
+
+	_, data = get("/eth/v1/node/version")
+	assert.JSONEq(t, `{"data":{"version":"mock/eth/v1/node/version"}}`, string(data))
+
+	rsp, data = get("/eth/v1/node/health")
+	assert.Equal(t, http.StatusNotFound, rsp.StatusCode)
+	assert.Contains(t, string(data), "no mock rule matched GET /eth/v1/node/health")
+}
+
+func TestMockEventStream(t *testing.T) {
+	_, proxyURL, _ := newMockTestSnooper(t, "mock:mock.json", mockTestRules, true)
+
+	rsp, err := http.Get(proxyURL + "/eth/v1/events?topics=head,block") //nolint:noctx // test request
+	require.NoError(t, err)
+
+	defer rsp.Body.Close()
+
+	assert.Equal(t, "text/event-stream", rsp.Header.Get("Content-Type"))
+
+	events := []string{}
+	scanner := bufio.NewScanner(rsp.Body)
+
+	for scanner.Scan() {
+		if event, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			events = append(events, event)
+		}
+	}
+
+	assert.Equal(t, []string{"head", "block"}, events)
+}
+
+func TestMockFallthrough(t *testing.T) {
+	upstreamCalls := &atomic.Int64{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), "eth_gasPrice", "Unmatched calls must be forwarded with their body")
+
+		upstreamCalls.Add(1)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x3b9aca00"}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	rules := `{"fallthrough": true, "rules": [{"match": {"jrpc_methods": ["eth_chainId"]}, "response": {"body": {"jsonrpc": "2.0", "id": 1, "result": "0x1"}}}]}`
+	_, proxyURL, _ := newMockTestSnooper(t, upstream.URL, rules, false)
+
+	_, body, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":1}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, body)
+	assert.Equal(t, int64(0), upstreamCalls.Load())
+
+	_, body, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_gasPrice","params":[],"id":1}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x3b9aca00"}`, body)
+
+	// batches fall through as a whole if any call is not matched
+	_, body, err = postJSON(t, proxyURL, `[{"jsonrpc":"2.0","method":"eth_chainId","id":1},{"jsonrpc":"2.0","method":"eth_gasPrice","id":2}]`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x3b9aca00"}`, body)
+	assert.Equal(t, int64(2), upstreamCalls.Load())
+}
+
+func TestMockInvalidRules(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	snooper, err := NewSnooper("mock:mock.json", logger, nil, "")
+	require.NoError(t, err)
+
+	defer snooper.Shutdown()
+
+	dir := t.TempDir()
+
+	for _, rules := range []string{
+		`{"rules": [{"response": {}}]}`,
+		`{"rules": [{"response": {"body": {}, "template": "."}}]}`,
+		`{"rules": [{"response": {"template": ".id |"}}]}`,
+		`{"rules": [{"match": {"json_query": "(("}, "response": {"body": {}}}]}`,
+		`{"rules": [{"response": {"ssz_file": "missing.ssz"}}]}`,
+		`{"rules": [{"response": {"events": [{"data": {}}]}}]}`,
+		`{"rules": [], "unknown": true}`,
+	} {
+		rulesFile := filepath.Join(dir, "mock.json")
+		require.NoError(t, os.WriteFile(rulesFile, []byte(rules), 0o600))
+
+		assert.Error(t, snooper.EnableMock(rulesFile, true), rules)
+	}
+
+	assert.Error(t, snooper.EnableMock(filepath.Join(dir, "missing.json"), true))
+}
@@ -0,0 +1,365 @@
+package snooper
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/modules"
+	"github.com/ethpandaops/rpc-snooper/modules/protocol"
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chainTestModule appends its name to the X-Chain header of the context it gets and
+// passes a copy of the context on, so later modules only see the tag in the copy.
+type chainTestModule struct {
+	id   uint64
+	name string
+	seen *[]string
+}
+
+func (m *chainTestModule) ID() uint64 {
+	return m.id
+}
+
+func (m *chainTestModule) OnRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
+	*m.seen = append(*m.seen, m.name+"<"+ctx.Headers.Get("X-Chain"))
+
+	newCtx := *ctx
+	newCtx.Headers = ctx.Headers.Clone()
+	newCtx.Headers.Set("X-Chain", strings.TrimPrefix(ctx.Headers.Get("X-Chain")+","+m.name, ","))
+
+	return &newCtx, nil
+}
+
+func (m *chainTestModule) OnResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	return ctx, nil
+}
+
+func (m *chainTestModule) Configure(_ map[string]any) error {
+	return nil
+}
+
+func (m *chainTestModule) Close() error {
+	return nil
+}
+
+func TestModuleChainOrder(t *testing.T) {
+	manager := modules.NewManager(logrus.New())
+	seen := []string{}
+
+	for _, module := range []struct {
+		name     string
+		priority int
+	}{
+		{"snooper", 10},
+		{"counter", 0},
+		{"redact", -5},
+		{"tracer", 0},
+	} {
+		info := modules.ModuleInfo{Type: "test", Name: module.name, Priority: module.priority}
+		chainModule := &chainTestModule{id: manager.GenerateModuleID(), name: module.name, seen: &seen}
+
+		require.NoError(t, manager.RegisterModuleWithInfo(chainModule, nil, info))
+	}
+
+	names := func() []string {
+		chain := []string{}
+		for _, info := range manager.ModuleChain() {
+			chain = append(chain, info.Name)
+		}
+
+		return chain
+	}
+
+	// lower priorities first, equal priorities in registration order
+	assert.Equal(t, []string{"redact", "counter", "tracer", "snooper"}, names())
+
+	reqCtx := &types.RequestContext{
+		CallCtx: &ProxyCallContext{data: map[string]any{}},
+		URL:     &url.URL{Path: "/"},
+		Headers: http.Header{},
+	}
+
+	result, err := manager.ProcessRequest(reqCtx)
+	require.NoError(t, err)
+
+	// every module sees the context returned by the module before it
+	assert.Equal(t, []string{"redact<", "counter<redact", "tracer<redact,counter", "snooper<redact,counter,tracer"}, seen)
+	assert.Equal(t, "redact,counter,tracer,snooper", result.Headers.Get("X-Chain"))
+	assert.Empty(t, reqCtx.Headers.Get("X-Chain"))
+
+	require.NoError(t, manager.UnregisterModule(manager.ModuleChain()[1].ID))
+	assert.Equal(t, []string{"redact", "tracer", "snooper"}, names())
+
+	// modules can only be registered once
+	duplicate := &chainTestModule{id: manager.ModuleChain()[0].ID, seen: &seen}
+	assert.Error(t, manager.RegisterModule(duplicate, nil))
+}
+
+func TestModuleChainAPI(t *testing.T) {
+	snooper, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	conn := dialControlTestConn(t, snooper)
+
+	for idx, priority := range []int{5, -1} {
+		require.NoError(t, conn.WriteJSON(&protocol.WSMessage{
+			RequestID: uint64(idx + 1),
+			Method:    "register_module",
+			Data: protocol.RegisterModuleRequest{
+				Type:     "request_counter",
+				Name:     "counter",
+				Priority: priority,
+				Config:   map[string]any{},
+			},
+		}))
+
+		var regRsp protocol.WSMessage
+		require.NoError(t, conn.ReadJSON(&regRsp))
+		require.Nil(t, regRsp.Error)
+	}
+
+	chainRsp, err := http.Get(proxyURL + "/_snooper/modules") //nolint:noctx // test request
+	require.NoError(t, err)
+
+	defer chainRsp.Body.Close()
+
+	var result struct {
+		Modules []modules.ModuleInfo `json:"modules"`
+	}
+
+	require.NoError(t, json.NewDecoder(chainRsp.Body).Decode(&result))
+	require.Len(t, result.Modules, 2)
+
+	assert.Equal(t, -1, result.Modules[0].Priority)
+	assert.Equal(t, 5, result.Modules[1].Priority)
+	assert.Equal(t, "request_counter", result.Modules[0].Type)
+	assert.Greater(t, result.Modules[0].ID, result.Modules[1].ID)
+}
+
+func TestModuleControlMethods(t *testing.T) {
+	snooper, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	})
+
+	conn := dialControlTestConn(t, snooper)
+
+	requestID := uint64(0)
+	call := func(method string, data any) *protocol.WSMessage {
+		requestID++
+
+		require.NoError(t, conn.WriteJSON(&protocol.WSMessage{
+			RequestID: requestID,
+			Method:    method,
+			Data:      data,
+		}))
+
+		var msg protocol.WSMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		require.Equal(t, requestID, msg.ResponseID, "unexpected message %v", msg.Method)
+
+		return &msg
+	}
+
+	decode := func(msg *protocol.WSMessage, target any) {
+		data, err := json.Marshal(msg.Data)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, target))
+	}
+
+	msg := call("register_module", protocol.RegisterModuleRequest{
+		Type:   "request_counter",
+		Name:   "counter",
+		Config: map[string]any{},
+	})
+	require.Nil(t, msg.Error)
+
+	var registered protocol.RegisterModuleResponse
+
+	decode(msg, &registered)
+
+	var listed struct {
+		Modules []modules.ModuleDetails `json:"modules"`
+	}
+
+	decode(call("list_modules", nil), &listed)
+	require.Len(t, listed.Modules, 1)
+	assert.Equal(t, registered.ModuleID, listed.Modules[0].ID)
+	assert.Equal(t, "request_counter", listed.Modules[0].Type)
+	assert.Equal(t, conn.LocalAddr().String(), listed.Modules[0].Owner)
+	assert.False(t, listed.Modules[0].Paused)
+
+	// every proxied call sends a counter event
+	_, _, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+	require.NoError(t, err)
+
+	var event protocol.WSMessage
+	require.NoError(t, conn.ReadJSON(&event))
+	assert.Equal(t, "counter_event", event.Method)
+
+	var stats struct {
+		Stats modules.ModuleStats `json:"stats"`
+	}
+
+	decode(call("get_module_stats", registered.ModuleID), &stats)
+	assert.Equal(t, uint64(1), stats.Stats.EventsSent)
+	assert.Zero(t, stats.Stats.SendFailures)
+	assert.Positive(t, stats.Stats.BytesSent)
+
+	// paused modules get no calls, the next message is the stats response
+	require.Nil(t, call("pause_module", registered.ModuleID).Error)
+
+	_, _, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+	require.NoError(t, err)
+
+	decode(call("get_module_stats", registered.ModuleID), &stats)
+	assert.Equal(t, uint64(1), stats.Stats.EventsSent)
+
+	require.Nil(t, call("resume_module", registered.ModuleID).Error)
+
+	// filters are swapped without re-registering
+	msg = call("update_filter", map[string]any{
+		"module_id":      registered.ModuleID,
+		"request_filter": map[string]any{"json_query": ".method == \"eth_chainId\""},
+	})
+	require.Nil(t, msg.Error)
+
+	_, _, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+	require.NoError(t, err)
+
+	decode(call("list_modules", nil), &listed)
+	require.NotNil(t, listed.Modules[0].Filters)
+	assert.Equal(t, ".method == \"eth_chainId\"", listed.Modules[0].Filters.RequestFilter.JSONQuery)
+	assert.Equal(t, uint64(1), listed.Modules[0].Stats.EventsSent)
+
+	msg = call("update_filter", map[string]any{
+		"module_id":      registered.ModuleID,
+		"request_filter": map[string]any{"json_query": ".method =="},
+	})
+	require.NotNil(t, msg.Error)
+	assert.Contains(t, *msg.Error, "failed to compile filter")
+
+	for _, method := range []string{"pause_module", "get_module_stats"} {
+		msg = call(method, registered.ModuleID+100)
+		require.NotNil(t, msg.Error)
+		assert.Contains(t, *msg.Error, "not found")
+	}
+}
+
+func TestFlowControlMethod(t *testing.T) {
+	snooper, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	conn := dialControlTestConn(t, snooper)
+
+	var flow struct {
+		Enabled bool     `json:"enabled"`
+		Blocked []string `json:"blocked"`
+	}
+
+	setFlow := func(update map[string]any) {
+		require.NoError(t, conn.WriteJSON(&protocol.WSMessage{RequestID: 1, Method: "set_flow", Data: update}))
+
+		var msg protocol.WSMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		require.Nil(t, msg.Error)
+
+		data, err := json.Marshal(msg.Data)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &flow))
+	}
+
+	setFlow(map[string]any{"enabled": false})
+	assert.False(t, flow.Enabled)
+
+	rsp, _, err := postJSON(t, proxyURL, `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, rsp.StatusCode)
+
+	setFlow(map[string]any{"enabled": true, "block": "/eth"})
+	assert.True(t, flow.Enabled)
+	assert.Equal(t, []string{"/eth"}, flow.Blocked)
+
+	rsp, _, err = postJSON(t, proxyURL+"/eth/v1/node/version", `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, rsp.StatusCode)
+
+	rsp, _, err = postJSON(t, proxyURL, `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+
+	setFlow(map[string]any{"unblock": "/eth"})
+	assert.Empty(t, flow.Blocked)
+
+	// an empty update reports the flow state
+	setFlow(nil)
+	assert.True(t, flow.Enabled)
+}
+
+func TestControlQueueOverflow(t *testing.T) {
+	snooper, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	require.Error(t, snooper.ConfigureControlQueue(0, modules.OverflowDropNewest))
+	require.Error(t, snooper.ConfigureControlQueue(4, "block"))
+	require.NoError(t, snooper.ConfigureControlQueue(4, modules.OverflowDropNewest))
+
+	// a client that stops reading after registering its module
+	slowConn := dialControlTestConn(t, snooper)
+	require.NoError(t, slowConn.WriteJSON(&protocol.WSMessage{
+		RequestID: 1,
+		Method:    "register_module",
+		Data: protocol.RegisterModuleRequest{
+			Type:   "request_snooper",
+			Config: map[string]any{},
+		},
+	}))
+
+	var msg protocol.WSMessage
+	require.NoError(t, slowConn.ReadJSON(&msg))
+	require.Nil(t, msg.Error)
+
+	body := `{"jsonrpc":"2.0","method":"eth_sendRawTransaction","params":["0x` + strings.Repeat("ab", 512*1024) + `"],"id":1}`
+	start := time.Now()
+
+	for range 20 {
+		rsp, _, err := postJSON(t, proxyURL, body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	}
+
+	// proxying never waits for the slow client
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	conn := dialControlTestConn(t, snooper)
+	require.NoError(t, conn.WriteJSON(&protocol.WSMessage{RequestID: 1, Method: "list_modules"}))
+	require.NoError(t, conn.ReadJSON(&msg))
+	require.Nil(t, msg.Error)
+
+	var listed struct {
+		Modules []modules.ModuleDetails `json:"modules"`
+	}
+
+	data, err := json.Marshal(msg.Data)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &listed))
+	require.Len(t, listed.Modules, 1)
+
+	stats := listed.Modules[0].Stats
+	assert.Positive(t, stats.SendFailures, "events for the slow client must be dropped")
+	assert.Less(t, stats.EventsSent, uint64(20))
+}
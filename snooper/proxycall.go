@@ -2,6 +2,7 @@ package snooper
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -10,21 +11,38 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/ethpandaops/rpc-snooper/recording"
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/gorilla/websocket"
 )
 
 type ProxyCallContext struct {
-	callIndex    uint64
-	context      context.Context
-	cancelFn     context.CancelFunc
-	cancelled    bool
-	deadline     time.Time
-	updateChan   chan time.Duration
-	reqSentChan  chan struct{}
-	streamReader io.ReadCloser
-	data         map[string]interface{}
-	callDuration time.Duration
+	callIndex        uint64
+	context          context.Context
+	cancelFn         context.CancelFunc
+	cancelled        bool
+	deadline         time.Time
+	updateChan       chan time.Duration
+	reqSentChan      chan struct{}
+	streamReader     io.ReadCloser
+	data             map[string]interface{}
+	startTime        time.Time
+	callDuration     time.Duration
+	upstream         *Upstream
+	route            *Route
+	routeName        string
+	batchRoutes      []*Route
+	mirrorCall       *mirrorCall
+	rewriteMethods   *rewriteCallMethods // JSON-RPC methods of the request, for response rewrites
+	batchIndex       int
+	requestCtx       *types.RequestContext // request as processed by modules, for response contexts
+	recordingRequest *recording.Request    // recorded request, completed with the response
 }
 
+// newProxyCallContext creates the context of a proxied call, which is cancelled after
+// timeout. A timeout of 0 creates a call context without deadline, that lives until
+// it is cancelled or its parent context ends.
 func (s *Snooper) newProxyCallContext(parent context.Context, timeout time.Duration) *ProxyCallContext {
 	s.callIndexMutex.Lock()
 	s.callIndexCounter++
@@ -33,13 +51,18 @@ func (s *Snooper) newProxyCallContext(parent context.Context, timeout time.Durat
 
 	callCtx := &ProxyCallContext{
 		callIndex:   callIndex,
-		deadline:    time.Now().Add(timeout),
+		startTime:   time.Now(),
 		updateChan:  make(chan time.Duration, 5),
 		reqSentChan: make(chan struct{}),
 		data:        make(map[string]interface{}),
+		batchIndex:  -1,
 	}
 	callCtx.context, callCtx.cancelFn = context.WithCancel(parent)
 
+	if timeout > 0 {
+		callCtx.deadline = time.Now().Add(timeout)
+	}
+
 	go callCtx.processCallContext()
 
 	return callCtx
@@ -48,13 +71,17 @@ func (s *Snooper) newProxyCallContext(parent context.Context, timeout time.Durat
 func (callContext *ProxyCallContext) processCallContext() {
 ctxLoop:
 	for {
-		timeout := time.Until(callContext.deadline)
+		var timeoutChan <-chan time.Time
+		if !callContext.deadline.IsZero() {
+			timeoutChan = time.After(time.Until(callContext.deadline))
+		}
+
 		select {
 		case newTimeout := <-callContext.updateChan:
 			callContext.deadline = time.Now().Add(newTimeout)
 		case <-callContext.context.Done():
 			break ctxLoop
-		case <-time.After(timeout):
+		case <-timeoutChan:
 			callContext.cancelFn()
 			callContext.cancelled = true
 			time.Sleep(10 * time.Millisecond)
@@ -84,6 +111,21 @@ func (callContext *ProxyCallContext) GetData(moduleID uint64, key string) interf
 	return callContext.data[fmt.Sprintf("%d:%s", moduleID, key)]
 }
 
+// Upstream returns the URL of the upstream serving the call.
+func (callContext *ProxyCallContext) Upstream() string {
+	if callContext.upstream == nil {
+		return ""
+	}
+
+	return callContext.upstream.URL.String()
+}
+
+// BatchIndex returns the position of the call in its JSON-RPC batch, or -1 if the
+// call is not part of a batch.
+func (callContext *ProxyCallContext) BatchIndex() int {
+	return callContext.batchIndex
+}
+
 // CallDuration returns the full round-trip duration of the proxied call,
 // including response body transfer.
 func (callContext *ProxyCallContext) CallDuration() time.Duration {
@@ -123,9 +165,26 @@ func (s *Snooper) processProxyCall(w http.ResponseWriter, r *http.Request) error
 		return nil
 	}
 
+	if !s.checkJWT(w, r) {
+		return nil
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		return s.processWebSocketProxyCall(w, r)
+	}
+
 	callContext := s.newProxyCallContext(r.Context(), s.CallTimeout)
 	defer callContext.cancelFn()
 
+	callContext.upstream = s.upstreams.Select(r)
+
+	// Routes pick the upstream of the call from its JSON-RPC method, path or body
+	if s.router != nil {
+		if err := s.selectRoute(callContext, r); err != nil {
+			return err
+		}
+	}
+
 	// pass all headers
 	hh := http.Header{}
 
@@ -144,15 +203,60 @@ func (s *Snooper) processProxyCall(w http.ResponseWriter, r *http.Request) error
 	proxyIPChain = append(proxyIPChain, r.RemoteAddr)
 	hh.Set("X-Forwarded-For", strings.Join(proxyIPChain, ", "))
 
-	// build proxy url
-	queryArgs := ""
-	if r.URL.RawQuery != "" {
-		queryArgs = fmt.Sprintf("?%s", r.URL.RawQuery)
+	if err := s.resignJWT(hh); err != nil {
+		return err
 	}
 
-	proxyURL, err := url.Parse(fmt.Sprintf("%s%s%s", s.target, r.URL.EscapedPath(), queryArgs))
+	proxyURL, err := buildProxyURL(callContext.upstream, r)
 	if err != nil {
-		return fmt.Errorf("error parsing proxy url: %w", err)
+		return err
+	}
+
+	var (
+		fault             *FaultRule
+		syntheticResponse *types.SyntheticResponse
+	)
+
+	// Fault rules simulate a misbehaving target and are applied before interception
+	if s.faultEngine.HasRules() {
+		bodyData, err := bufferRequestBody(r)
+		if err != nil {
+			return err
+		}
+
+		fault = s.faultEngine.Match(r, bodyData)
+		if fault != nil {
+			syntheticResponse = s.applyRequestFault(callContext, fault, bodyData)
+		}
+	}
+
+	// Rewrite rules transform the JSON body of the call before it is forwarded
+	if syntheticResponse == nil && s.rewriteEngine.HasRules("") {
+		if err := s.rewriteRequest(callContext, r); err != nil {
+			return err
+		}
+	}
+
+	// Intercepting modules need the full body before the call is forwarded
+	intercepting := syntheticResponse == nil && s.moduleManager != nil && s.moduleManager.HasInterceptors()
+
+	if intercepting {
+		reqCtx, err := s.interceptRequest(callContext, r, hh)
+		if err != nil {
+			return err
+		}
+
+		if reqCtx.Drop {
+			return s.dropProxyCall(callContext, w, "interceptor")
+		}
+
+		// log the request as it is forwarded
+		r = r.WithContext(r.Context())
+		r.Header = reqCtx.Headers
+		r.Body = io.NopCloser(bytes.NewReader(reqCtx.BodyBytes))
+		r.ContentLength = int64(len(reqCtx.BodyBytes))
+		hh = reqCtx.Headers
+		syntheticResponse = reqCtx.Response
 	}
 
 	// Create body reader with module processing and logging
@@ -172,10 +276,42 @@ func (s *Snooper) processProxyCall(w http.ResponseWriter, r *http.Request) error
 	req = req.WithContext(callContext.context)
 
 	callStart := time.Now()
-	resp, err := client.Do(req)
 
-	if err != nil {
-		return fmt.Errorf("proxy request error: %w", err)
+	var resp *http.Response
+
+	if syntheticResponse == nil && s.mock != nil {
+		// answered by a mock rule instead of the target, unless the call falls through
+		resp, err = s.mockResponse(callContext, r, req)
+		if err != nil {
+			return err
+		}
+	}
+
+	if syntheticResponse != nil {
+		// answered by a fault rule or interceptor, consume the request so it gets logged
+		_, _ = io.Copy(io.Discard, bodyReader)
+		bodyReader.Close()
+
+		resp = newSyntheticResponse(syntheticResponse)
+	} else if resp == nil && callContext.batchRoutes != nil {
+		// mixed batch, forward the calls of each route separately
+		resp, err = s.forwardSplitBatch(callContext, r, req)
+		if err != nil {
+			return fmt.Errorf("proxy request error: %w", err)
+		}
+	} else if resp == nil {
+		resp, err = client.Do(req)
+		if err != nil {
+			if callContext.route == nil && callContext.context.Err() == nil {
+				s.upstreams.MarkFailed(callContext.upstream, err)
+			}
+
+			return fmt.Errorf("proxy request error: %w", err)
+		}
+
+		if callContext.route == nil && resp.StatusCode >= http.StatusInternalServerError {
+			s.upstreams.MarkFailed(callContext.upstream, fmt.Errorf("unexpected status code %v", resp.StatusCode))
+		}
 	}
 
 	if callContext.cancelled {
@@ -188,6 +324,29 @@ func (s *Snooper) processProxyCall(w http.ResponseWriter, r *http.Request) error
 	respContentType := resp.Header.Get("Content-Type")
 	isEventStream := respContentType == "text/event-stream" || strings.HasPrefix(r.URL.EscapedPath(), "/eth/v1/events")
 
+	if syntheticResponse == nil && !isEventStream && s.rewriteEngine.HasRules(RewriteStageResponse) {
+		if err := s.rewriteResponse(callContext, r, resp); err != nil {
+			return err
+		}
+	}
+
+	if intercepting && syntheticResponse == nil && !isEventStream {
+		drop, err := s.interceptResponse(callContext, resp)
+		if err != nil {
+			return err
+		}
+
+		if drop {
+			return s.dropProxyCall(callContext, w, "interceptor")
+		}
+	}
+
+	if fault != nil && fault.Action.Type == FaultActionTruncate && !isEventStream {
+		if err := truncateResponse(resp, fault); err != nil {
+			return err
+		}
+	}
+
 	// For event streams, we can't modify the response through modules (streaming requirement)
 	if isEventStream {
 		// passthru response headers
@@ -230,6 +389,14 @@ func (s *Snooper) processProxyCall(w http.ResponseWriter, r *http.Request) error
 		responseBodyReader := s.createResponseProcessingStream(callContext, r, resp)
 		defer responseBodyReader.Close()
 
+		if fault != nil && fault.Action.Type == FaultActionDrop {
+			// send part of the body, then cut the connection
+			_, _ = io.CopyN(w, responseBodyReader, faultCutOffset(fault, resp.ContentLength))
+			callContext.callDuration = time.Since(callStart)
+
+			return s.dropProxyCall(callContext, w, "fault rule")
+		}
+
 		_, err = io.Copy(w, responseBodyReader)
 
 		// Measure full round-trip duration including response body transfer.
@@ -245,6 +412,21 @@ func (s *Snooper) processProxyCall(w http.ResponseWriter, r *http.Request) error
 	return nil
 }
 
+// buildProxyURL returns the URL a call is forwarded to on an upstream.
+func buildProxyURL(upstream *Upstream, r *http.Request) (*url.URL, error) {
+	queryArgs := ""
+	if r.URL.RawQuery != "" {
+		queryArgs = fmt.Sprintf("?%s", r.URL.RawQuery)
+	}
+
+	proxyURL, err := url.Parse(fmt.Sprintf("%s%s%s", upstream.URL, r.URL.EscapedPath(), queryArgs))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing proxy url: %w", err)
+	}
+
+	return proxyURL, nil
+}
+
 func (s *Snooper) processEventStreamResponse(callContext *ProxyCallContext, r *http.Request, w http.ResponseWriter, rsp *http.Response) (int64, error) {
 	rd := bufio.NewReader(rsp.Body)
 	written := int64(0)
@@ -291,7 +473,13 @@ func (s *Snooper) processEventStreamResponse(callContext *ProxyCallContext, r *h
 
 // createRequestProcessingStream creates a streaming reader that processes request data through logging
 func (s *Snooper) createRequestProcessingStream(callCtx *ProxyCallContext, r *http.Request, stream io.ReadCloser) io.ReadCloser {
+	mirrored := s.mirror != nil && !isEventStreamRequest(r)
+
 	loggedStream := s.createTeeLogStream(stream, func(data []byte) {
+		if mirrored {
+			callCtx.mirrorCall = s.startMirrorCall(callCtx, r, data)
+		}
+
 		s.logRequest(callCtx, r, data)
 		close(callCtx.reqSentChan)
 	})
@@ -308,6 +496,11 @@ func (s *Snooper) createRequestProcessingStream(callCtx *ProxyCallContext, r *ht
 func (s *Snooper) createResponseProcessingStream(callCtx *ProxyCallContext, r *http.Request, resp *http.Response) io.ReadCloser {
 	loggedStream := s.createTeeLogStreamWithSizeHint(resp.Body, resp.ContentLength, func(data []byte) {
 		<-callCtx.reqSentChan
+
+		if callCtx.mirrorCall != nil {
+			callCtx.mirrorCall.completePrimary(s, resp, data)
+		}
+
 		s.logResponse(callCtx, r, resp, data)
 	})
 
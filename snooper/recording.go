@@ -0,0 +1,95 @@
+package snooper
+
+import (
+	"net/http"
+
+	"github.com/ethpandaops/rpc-snooper/recording"
+)
+
+// EnableRecording writes every correlated request/response pair to the JSONL
+// recording at path. Call this once at startup before serving requests.
+func (s *Snooper) EnableRecording(path string) error {
+	recorder, err := recording.NewWriter(path)
+	if err != nil {
+		return err
+	}
+
+	s.recorder = recorder
+
+	s.logger.Infof("recording traffic to: %v", path)
+
+	return nil
+}
+
+// recordRequest stores the recorded request on the call context until the
+// response is logged. The body is expected to be decompressed.
+func (s *Snooper) recordRequest(ctx *ProxyCallContext, req *http.Request, bodyData []byte, contentType string) {
+	if s.recorder == nil && s.history == nil {
+		return
+	}
+
+	ctx.recordingRequest = &recording.Request{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: redactHeaders(req.Header),
+		Payload: recording.NewPayload(bodyData, contentType),
+	}
+}
+
+// recordResponse completes the recording entry of a call, writes it to the recording
+// and adds it to the call history.
+func (s *Snooper) recordResponse(ctx *ProxyCallContext, rsp *http.Response, bodyData []byte, contentType string) {
+	if s.recorder == nil && s.history == nil {
+		return
+	}
+
+	request := ctx.recordingRequest
+	if request == nil {
+		return
+	}
+
+	duration := ctx.CallDuration()
+
+	entry := &recording.Entry{
+		CallIndex:    ctx.callIndex,
+		RequestTime:  ctx.startTime,
+		ResponseTime: ctx.startTime.Add(duration),
+		DurationMs:   float64(duration.Microseconds()) / 1000,
+		Request:      *request,
+		Response: recording.Response{
+			StatusCode: rsp.StatusCode,
+			Headers:    redactHeaders(rsp.Header),
+			Payload:    recording.NewPayload(bodyData, contentType),
+		},
+	}
+
+	if s.history != nil {
+		s.history.add(s.history.newHistoryCall(entry, ctx.Upstream()))
+	}
+
+	if s.recorder == nil {
+		return
+	}
+
+	if err := s.recorder.Write(entry); err != nil {
+		s.logger.WithField("callidx", ctx.callIndex).WithError(err).Warn("failed to record call")
+	}
+}
+
+// redactHeaders returns a copy of headers with the values of the redactedHeaders
+// replaced.
+func redactHeaders(headers http.Header) http.Header {
+	redacted := headers.Clone()
+
+	for name, values := range redacted {
+		if !redactedHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+
+		for idx := range values {
+			values[idx] = recording.RedactedHeaderValue
+		}
+	}
+
+	return redacted
+}
@@ -0,0 +1,61 @@
+package snooper
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/recording"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingWritesCorrelatedCalls(t *testing.T) {
+	snooper, proxyURL, _ := newFaultTestSnooper(t)
+
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+	require.NoError(t, snooper.EnableRecording(path))
+
+	req, err := http.NewRequest(http.MethodPost, proxyURL+"/?q=1", strings.NewReader(`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)) //nolint:noctx // test request
+	require.NoError(t, err)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	rsp.Body.Close()
+	assert.JSONEq(t, faultTestResponse, string(body))
+
+	var entry *recording.Entry
+
+	require.Eventually(t, func() bool {
+		file, err := os.Open(path)
+		if err != nil {
+			return false
+		}
+		defer file.Close()
+
+		entry, err = recording.NewReader(file).Next()
+
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, uint64(1), entry.CallIndex)
+	assert.False(t, entry.RequestTime.IsZero())
+	assert.False(t, entry.ResponseTime.Before(entry.RequestTime))
+	assert.Equal(t, "POST", entry.Request.Method)
+	assert.Equal(t, "/?q=1", entry.Request.URL)
+	assert.Equal(t, "application/json", entry.Request.Headers.Get("Content-Type"))
+	assert.Equal(t, recording.RedactedHeaderValue, entry.Request.Headers.Get("Authorization"), "Credentials must not be recorded")
+	assert.JSONEq(t, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`, string(entry.Request.Body))
+	assert.Equal(t, 200, entry.Response.StatusCode)
+	assert.JSONEq(t, faultTestResponse, string(entry.Response.Body))
+}
@@ -0,0 +1,531 @@
+package snooper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/google/uuid"
+	"github.com/itchyny/gojq"
+	"github.com/sirupsen/logrus"
+)
+
+// Rewrite stages.
+const (
+	RewriteStageRequest  = "request"
+	RewriteStageResponse = "response"
+)
+
+// RewriteRule rewrites the JSON body of matching proxy calls with a gojq expression.
+type RewriteRule struct {
+	ID         string       `json:"id"`
+	Match      RewriteMatch `json:"match"`
+	Stage      string       `json:"stage"`      // request or response
+	Expression string       `json:"expression"` // gojq expression producing the new body
+	TTL        string       `json:"ttl,omitempty"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Hits       uint64       `json:"hits"`
+
+	code *gojq.Code
+}
+
+// RewriteMatch selects the calls a rewrite rule applies to. All set fields must match.
+type RewriteMatch struct {
+	Path        string   `json:"path,omitempty"`         // URL path prefix
+	JRPCMethods []string `json:"jrpc_methods,omitempty"` // calls of a batch are rewritten individually
+}
+
+// RewriteEngine holds the active rewrite rules and applies them to proxy calls.
+type RewriteEngine struct {
+	logger logrus.FieldLogger
+	rules  []*RewriteRule
+	mu     sync.Mutex
+}
+
+func NewRewriteEngine(logger logrus.FieldLogger) *RewriteEngine {
+	return &RewriteEngine{
+		logger: logger,
+		rules:  make([]*RewriteRule, 0),
+	}
+}
+
+// AddRule validates a rule, adds it to the engine and returns a copy of the stored rule.
+func (re *RewriteEngine) AddRule(rule *RewriteRule) (RewriteRule, error) {
+	if err := re.prepareRule(rule); err != nil {
+		return RewriteRule{}, err
+	}
+
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.rules = append(re.rules, rule)
+
+	return *rule, nil
+}
+
+// RemoveRule removes a rule by id and reports whether it existed.
+func (re *RewriteEngine) RemoveRule(id string) bool {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	for i, rule := range re.rules {
+		if rule.ID == id {
+			re.rules = append(re.rules[:i], re.rules[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClearRules removes all rules.
+func (re *RewriteEngine) ClearRules() {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.rules = make([]*RewriteRule, 0)
+}
+
+// Rules returns a snapshot of the active rules.
+func (re *RewriteEngine) Rules() []RewriteRule {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.pruneExpired(time.Now())
+
+	rules := make([]RewriteRule, len(re.rules))
+	for i, rule := range re.rules {
+		rules[i] = *rule
+	}
+
+	return rules
+}
+
+// HasRules reports whether any rules are configured for the given stage, or for any
+// stage if stage is empty. Bodies are only buffered for rewriting when this is the case.
+func (re *RewriteEngine) HasRules(stage string) bool {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.pruneExpired(time.Now())
+
+	for _, rule := range re.rules {
+		if stage == "" || rule.Stage == stage {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Rewrite applies the rules of a stage matching the path to a parsed JSON body and
+// returns the new body with the ids of the applied rules. If batch is set, the body is
+// a JSON-RPC batch (or batch response) and its calls are rewritten individually.
+// callMethod returns the JSON-RPC method of a call.
+func (re *RewriteEngine) Rewrite(stage, path string, body any, batch bool, callMethod func(call any) string) (any, []string) {
+	rules := re.matchPath(stage, path)
+	if len(rules) == 0 {
+		return body, nil
+	}
+
+	applied := []string{}
+
+	rewriteCall := func(call any) any {
+		method := callMethod(call)
+
+		for _, rule := range rules {
+			if len(rule.Match.JRPCMethods) > 0 && !slices.Contains(rule.Match.JRPCMethods, method) {
+				continue
+			}
+
+			result, err := runRewriteExpression(rule.code, call)
+			if err != nil {
+				re.logger.WithField("rule", rule.ID).WithError(err).Warn("rewrite expression failed, keeping body")
+				continue
+			}
+
+			call = result
+
+			re.mu.Lock()
+			rule.Hits++
+			re.mu.Unlock()
+
+			if !slices.Contains(applied, rule.ID) {
+				applied = append(applied, rule.ID)
+			}
+		}
+
+		return call
+	}
+
+	if calls, ok := body.([]any); ok && batch {
+		for i := range calls {
+			calls[i] = rewriteCall(calls[i])
+		}
+
+		return calls, applied
+	}
+
+	return rewriteCall(body), applied
+}
+
+// matchPath returns the active rules of a stage matching the path.
+func (re *RewriteEngine) matchPath(stage, path string) []*RewriteRule {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	re.pruneExpired(time.Now())
+
+	rules := make([]*RewriteRule, 0, len(re.rules))
+
+	for _, rule := range re.rules {
+		if rule.Stage != stage || (rule.Match.Path != "" && !strings.HasPrefix(path, rule.Match.Path)) {
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+func (re *RewriteEngine) pruneExpired(now time.Time) {
+	active := re.rules[:0]
+
+	for _, rule := range re.rules {
+		if rule.ExpiresAt != nil && now.After(*rule.ExpiresAt) {
+			re.logger.WithField("rule", rule.ID).Info("rewrite rule expired")
+			continue
+		}
+
+		active = append(active, rule)
+	}
+
+	re.rules = active
+}
+
+// prepareRule validates a new rule, fills in defaults and compiles its expression.
+func (re *RewriteEngine) prepareRule(rule *RewriteRule) error {
+	switch rule.Stage {
+	case RewriteStageRequest, RewriteStageResponse:
+	default:
+		return fmt.Errorf("unknown rewrite stage: %q", rule.Stage)
+	}
+
+	if rule.Expression == "" {
+		return errors.New("expression is required")
+	}
+
+	query, err := gojq.Parse(rule.Expression)
+	if err != nil {
+		return fmt.Errorf("invalid expression: %w", err)
+	}
+
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return fmt.Errorf("invalid expression: %w", err)
+	}
+
+	now := time.Now()
+
+	if rule.TTL != "" {
+		ttl, err := time.ParseDuration(rule.TTL)
+		if err != nil {
+			return fmt.Errorf("invalid ttl: %w", err)
+		}
+
+		expiresAt := now.Add(ttl)
+		rule.ExpiresAt = &expiresAt
+	}
+
+	if rule.ExpiresAt != nil && !rule.ExpiresAt.After(now) {
+		return errors.New("rule is already expired")
+	}
+
+	rule.code = code
+	rule.ID = uuid.NewString()
+	rule.CreatedAt = now
+	rule.Hits = 0
+
+	return nil
+}
+
+// runRewriteExpression runs a compiled expression and returns its first result.
+func runRewriteExpression(code *gojq.Code, value any) (any, error) {
+	result, ok := code.Run(value).Next()
+	if !ok {
+		return nil, errors.New("expression produced no result")
+	}
+
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// rewriteCallMethods keeps the JSON-RPC methods of a request, so response rules can
+// be matched on the method of the call a response answers.
+type rewriteCallMethods struct {
+	batch  bool
+	method string
+	byID   map[string]string
+}
+
+func newRewriteCallMethods(parsedBody any) *rewriteCallMethods {
+	methods := &rewriteCallMethods{
+		byID: map[string]string{},
+	}
+
+	if batch := getJSONRPCBatch(parsedBody); batch != nil {
+		methods.batch = true
+
+		for _, item := range batch {
+			call, _ := item.(map[string]any)
+			method, _ := call["method"].(string)
+			methods.byID[getJSONRPCID(call)] = method
+		}
+
+		return methods
+	}
+
+	if call, ok := parsedBody.(map[string]any); ok {
+		methods.method, _ = call["method"].(string)
+	}
+
+	return methods
+}
+
+// responseMethod returns the method of the call answered by a response. Batch
+// responses are matched to their calls by id.
+func (m *rewriteCallMethods) responseMethod(response any) string {
+	if m == nil {
+		return ""
+	}
+
+	if m.batch {
+		return m.byID[getJSONRPCID(response)]
+	}
+
+	return m.method
+}
+
+// isBatch returns whether the request was a JSON-RPC batch.
+func (m *rewriteCallMethods) isBatch() bool {
+	return m != nil && m.batch
+}
+
+// rewriteRequest applies the request rewrite rules to the request body before it is
+// forwarded, and keeps the JSON-RPC methods of the call for the response rules.
+func (s *Snooper) rewriteRequest(callCtx *ProxyCallContext, r *http.Request) error {
+	bodyData, err := bufferRequestBody(r)
+	if err != nil {
+		return err
+	}
+
+	contentEncoding := r.Header.Get("Content-Encoding")
+
+	parsedBody := s.parseJSONBody(bodyData, contentEncoding)
+	if parsedBody == nil {
+		return nil
+	}
+
+	callCtx.rewriteMethods = newRewriteCallMethods(parsedBody)
+
+	if !s.rewriteEngine.HasRules(RewriteStageRequest) {
+		return nil
+	}
+
+	newBody, applied := s.rewriteEngine.Rewrite(RewriteStageRequest, r.URL.Path, parsedBody, callCtx.rewriteMethods.isBatch(), types.JSONRPCMethod)
+	if len(applied) == 0 {
+		return nil
+	}
+
+	encoded, err := encodeJSONBody(newBody, contentEncoding)
+	if err != nil {
+		s.logger.WithField("callidx", callCtx.callIndex).WithError(err).Warn("failed encoding rewritten request, forwarding original request")
+		return nil
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(encoded))
+	r.ContentLength = int64(len(encoded))
+
+	s.logger.WithField("callidx", callCtx.callIndex).Infof("REWRITE #%v: request rewritten (rule %v)", callCtx.callIndex, strings.Join(applied, ", "))
+
+	return nil
+}
+
+// rewriteResponse applies the response rewrite rules to the response body before it
+// is returned to the client. The response is updated in place.
+func (s *Snooper) rewriteResponse(callCtx *ProxyCallContext, r *http.Request, resp *http.Response) error {
+	bodyData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed reading response body: %w", err)
+	}
+
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(bodyData))
+
+	contentEncoding := resp.Header.Get("Content-Encoding")
+
+	parsedBody := s.parseJSONBody(bodyData, contentEncoding)
+	if parsedBody == nil {
+		return nil
+	}
+
+	newBody, applied := s.rewriteEngine.Rewrite(RewriteStageResponse, r.URL.Path, parsedBody, callCtx.rewriteMethods.isBatch(), callCtx.rewriteMethods.responseMethod)
+	if len(applied) == 0 {
+		return nil
+	}
+
+	encoded, err := encodeJSONBody(newBody, contentEncoding)
+	if err != nil {
+		s.logger.WithField("callidx", callCtx.callIndex).WithError(err).Warn("failed encoding rewritten response, returning original response")
+		return nil
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(encoded))
+	resp.ContentLength = int64(len(encoded))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(encoded)))
+
+	s.logger.WithField("callidx", callCtx.callIndex).Infof("REWRITE #%v: response rewritten (rule %v)", callCtx.callIndex, strings.Join(applied, ", "))
+
+	return nil
+}
+
+// parseJSONBody decodes a JSON body for the rewrite and mock rules, or returns nil if
+// the body is not JSON or uses an unsupported encoding. Numbers are kept as
+// json.Number, so untouched values keep their precision.
+func (s *Snooper) parseJSONBody(bodyData []byte, contentEncoding string) any {
+	if contentEncoding != "" && contentEncoding != "gzip" && contentEncoding != "br" {
+		return nil
+	}
+
+	data, err := s.decompressBody(bodyData, contentEncoding)
+	if err != nil {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var parsedBody any
+	if err := decoder.Decode(&parsedBody); err != nil || decoder.More() {
+		return nil
+	}
+
+	return parsedBody
+}
+
+// encodeJSONBody encodes a body as JSON with the given Content-Encoding.
+func encodeJSONBody(body any, contentEncoding string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(body); err != nil {
+		return nil, err
+	}
+
+	return compressBody(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), contentEncoding)
+}
+
+// compressBody encodes a body with the given Content-Encoding, mirroring decompressBody.
+func compressBody(data []byte, contentEncoding string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	var writer io.WriteCloser
+
+	switch contentEncoding {
+	case "gzip":
+		writer = gzip.NewWriter(buf)
+	case "br":
+		writer = brotli.NewWriter(buf)
+	default:
+		return data, nil
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// registerRewriteControlMethods makes the rewrite rules manageable through the
+// WebSocket control protocol.
+func (s *Snooper) registerRewriteControlMethods() {
+	s.moduleManager.RegisterControlMethod("list_rewrites", func(_ json.RawMessage) (any, error) {
+		return map[string]any{
+			"rewrites": s.rewriteEngine.Rules(),
+		}, nil
+	})
+
+	s.moduleManager.RegisterControlMethod("add_rewrite", func(data json.RawMessage) (any, error) {
+		rule := &RewriteRule{}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(rule); err != nil {
+			return nil, fmt.Errorf("invalid rewrite rule: %w", err)
+		}
+
+		added, err := s.rewriteEngine.AddRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite rule: %w", err)
+		}
+
+		s.logger.WithFields(logrus.Fields{
+			"rule":  added.ID,
+			"stage": added.Stage,
+		}).Info("Rewrite rule added")
+
+		return map[string]any{
+			"rewrite": added,
+		}, nil
+	})
+
+	s.moduleManager.RegisterControlMethod("remove_rewrite", func(data json.RawMessage) (any, error) {
+		var id string
+		if err := json.Unmarshal(data, &id); err != nil {
+			return nil, fmt.Errorf("invalid rewrite rule id: %w", err)
+		}
+
+		if !s.rewriteEngine.RemoveRule(id) {
+			return nil, errors.New("rewrite rule not found")
+		}
+
+		s.logger.WithField("rule", id).Info("Rewrite rule removed")
+
+		return map[string]any{
+			"success": true,
+		}, nil
+	})
+
+	s.moduleManager.RegisterControlMethod("clear_rewrites", func(_ json.RawMessage) (any, error) {
+		s.rewriteEngine.ClearRules()
+		s.logger.Info("Rewrite rules cleared")
+
+		return map[string]any{
+			"success": true,
+		}, nil
+	})
+}
@@ -0,0 +1,288 @@
+package snooper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethpandaops/rpc-snooper/modules/protocol"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addRewriteRule(t *testing.T, proxyURL, rule string) RewriteRule {
+	t.Helper()
+
+	rsp, body, err := postJSON(t, proxyURL+"/_snooper/rewrites", rule)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode, body)
+
+	var result struct {
+		Rewrite RewriteRule `json:"rewrite"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(body), &result))
+	require.NotEmpty(t, result.Rewrite.ID)
+
+	return result.Rewrite
+}
+
+func TestRewriteRequest(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+	)
+
+	_, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+
+		assert.Equal(t, int64(len(body)), r.ContentLength)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+	})
+
+	addRewriteRule(t, proxyURL, `{"stage":"request","match":{"jrpc_methods":["engine_forkchoiceUpdatedV3"]},"expression":".params[1].timestamp |= . + 12"}`)
+
+	_, _, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"engine_forkchoiceUpdatedV3","params":[{"headBlockHash":"0x01"},{"timestamp":1700000000,"value":123456789012345678901234567890}],"id":1}`)
+	require.NoError(t, err)
+
+	// only matching calls of a batch are rewritten
+	_, _, err = postJSON(t, proxyURL, `[{"jsonrpc":"2.0","method":"eth_blockNumber","params":[{"timestamp":1}],"id":1},{"jsonrpc":"2.0","method":"engine_forkchoiceUpdatedV3","params":[{},{"timestamp":100}],"id":2}]`)
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Len(t, received, 2)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","method":"engine_forkchoiceUpdatedV3","params":[{"headBlockHash":"0x01"},{"timestamp":1700000012,"value":123456789012345678901234567890}],"id":1}`, received[0])
+	assert.Contains(t, received[0], "123456789012345678901234567890", "Numbers must keep their precision")
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","method":"eth_blockNumber","params":[{"timestamp":1}],"id":1},{"jsonrpc":"2.0","method":"engine_forkchoiceUpdatedV3","params":[{},{"timestamp":112}],"id":2}]`, received[1])
+}
+
+func TestRewriteResponseGzip(t *testing.T) {
+	_, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		body := &bytes.Buffer{}
+		writer := gzip.NewWriter(body)
+		_, _ = writer.Write([]byte(`[{"jsonrpc":"2.0","id":2,"result":{"status":"VALID","latestValidHash":"0x02"}},{"jsonrpc":"2.0","id":1,"result":"0x10"}]`))
+		writer.Close()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+		_, _ = w.Write(body.Bytes())
+	})
+
+	addRewriteRule(t, proxyURL, `{"stage":"response","match":{"path":"/","jrpc_methods":["engine_newPayloadV4"]},"expression":".result.status = \"SYNCING\" | .result.latestValidHash = null"}`)
+
+	req, err := http.NewRequest(http.MethodPost, proxyURL, strings.NewReader(`[{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1},{"jsonrpc":"2.0","method":"engine_newPayloadV4","params":[],"id":2}]`)) //nolint:noctx // test request
+	require.NoError(t, err)
+
+	// an explicit Accept-Encoding disables the transparent decompression of the clients
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer rsp.Body.Close()
+
+	compressed, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", rsp.Header.Get("Content-Encoding"))
+	assert.Equal(t, strconv.Itoa(len(compressed)), rsp.Header.Get("Content-Length"))
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	// responses are matched to the method of their call by id
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":2,"result":{"status":"SYNCING","latestValidHash":null}},{"jsonrpc":"2.0","id":1,"result":"0x10"}]`, string(body))
+}
+
+func TestRewriteBeaconArrayBody(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received string
+	)
+
+	_, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		received = string(body)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"index":"1","balance":"32000000000"},{"index":"2","balance":"31000000000"}]`))
+	})
+
+	// beacon API arrays are no JSON-RPC batches, rules see the whole array
+	addRewriteRule(t, proxyURL, `{"stage":"request","match":{"path":"/eth/v1/beacon/pool/attestations"},"expression":".[1:] = []"}`)
+	addRewriteRule(t, proxyURL, `{"stage":"response","match":{"path":"/eth/v1/beacon/pool/attestations"},"expression":"map(.index)"}`)
+
+	_, body, err := postJSON(t, proxyURL+"/eth/v1/beacon/pool/attestations", `[{"data":{"slot":"1"}},{"data":{"slot":"2"}}]`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `["1","2"]`, body)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.JSONEq(t, `[{"data":{"slot":"1"}}]`, received)
+}
+
+func TestRewriteExpressionErrorKeepsBody(t *testing.T) {
+	const upstreamResponse = `{"jsonrpc":"2.0","id":1,"result":"0x10"}`
+
+	snooper, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(upstreamResponse))
+	})
+
+	addRewriteRule(t, proxyURL, `{"stage":"response","expression":".result.status = \"SYNCING\""}`)
+
+	_, body, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, upstreamResponse, body)
+	assert.Equal(t, uint64(0), snooper.rewriteEngine.Rules()[0].Hits)
+}
+
+func TestRewriteAPIManagement(t *testing.T) {
+	_, proxyURL, _ := newTestSnooper(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rule := addRewriteRule(t, proxyURL, `{"stage":"response","match":{"path":"/eth/"},"expression":".data = []","ttl":"1m"}`)
+	assert.Equal(t, "/eth/", rule.Match.Path)
+	require.NotNil(t, rule.ExpiresAt)
+
+	// invalid rules are rejected
+	for _, invalid := range []string{
+		`{"stage":"upstream","expression":"."}`,
+		`{"stage":"request"}`,
+		`{"stage":"request","expression":".result |="}`,
+		`{"stage":"request","expression":".","unknown":true}`,
+	} {
+		rsp, _, err := postJSON(t, proxyURL+"/_snooper/rewrites", invalid)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rsp.StatusCode, invalid)
+	}
+
+	listRsp, err := http.Get(proxyURL + "/_snooper/rewrites") //nolint:noctx // test request
+	require.NoError(t, err)
+
+	var list struct {
+		Rewrites []RewriteRule `json:"rewrites"`
+	}
+
+	require.NoError(t, json.NewDecoder(listRsp.Body).Decode(&list))
+	listRsp.Body.Close()
+	require.Len(t, list.Rewrites, 1)
+	assert.Equal(t, rule.ID, list.Rewrites[0].ID)
+
+	req, err := http.NewRequest(http.MethodDelete, proxyURL+"/_snooper/rewrites/"+rule.ID, http.NoBody) //nolint:noctx // test request
+	require.NoError(t, err)
+
+	delRsp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	delRsp.Body.Close()
+	assert.Equal(t, http.StatusOK, delRsp.StatusCode)
+
+	delRsp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	delRsp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, delRsp.StatusCode)
+}
+
+func TestRewriteWebSocketControl(t *testing.T) {
+	snooper, _, _ := newTestSnooper(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	controlServer := httptest.NewServer(http.HandlerFunc(snooper.moduleManager.HandleWebSocket))
+	t.Cleanup(controlServer.Close)
+
+	conn, rsp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(controlServer.URL, "http"), nil)
+	require.NoError(t, err)
+
+	rsp.Body.Close()
+	t.Cleanup(func() { conn.Close() })
+
+	requestID := uint64(0)
+	call := func(method string, data any) *protocol.WSMessage {
+		requestID++
+
+		require.NoError(t, conn.WriteJSON(&protocol.WSMessage{
+			RequestID: requestID,
+			Method:    method,
+			Data:      data,
+		}))
+
+		var msg protocol.WSMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		require.Equal(t, requestID, msg.ResponseID)
+
+		return &msg
+	}
+
+	msg := call("add_rewrite", map[string]any{
+		"stage":      "response",
+		"match":      map[string]any{"jrpc_methods": []string{"eth_syncing"}},
+		"expression": ".result = true",
+	})
+	require.Nil(t, msg.Error)
+
+	var added struct {
+		Rewrite RewriteRule `json:"rewrite"`
+	}
+
+	data, err := json.Marshal(msg.Data)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &added))
+	assert.Equal(t, []string{"eth_syncing"}, added.Rewrite.Match.JRPCMethods)
+	assert.Len(t, snooper.rewriteEngine.Rules(), 1)
+
+	msg = call("add_rewrite", map[string]any{"stage": "response"})
+	require.NotNil(t, msg.Error)
+	assert.Contains(t, *msg.Error, "expression is required")
+
+	msg = call("list_rewrites", nil)
+	require.Nil(t, msg.Error)
+	assert.Contains(t, msg.Data, "rewrites")
+
+	msg = call("remove_rewrite", added.Rewrite.ID)
+	require.Nil(t, msg.Error)
+	assert.Empty(t, snooper.rewriteEngine.Rules())
+
+	msg = call("remove_rewrite", added.Rewrite.ID)
+	require.NotNil(t, msg.Error)
+
+	_, err = snooper.rewriteEngine.AddRule(&RewriteRule{Stage: RewriteStageRequest, Expression: "."})
+	require.NoError(t, err)
+
+	msg = call("clear_rewrites", nil)
+	require.Nil(t, msg.Error)
+	assert.Empty(t, snooper.rewriteEngine.Rules())
+
+	msg = call("unknown_method", nil)
+	require.NotNil(t, msg.Error)
+	assert.Equal(t, "Unknown method: unknown_method", *msg.Error)
+}
@@ -0,0 +1,434 @@
+package snooper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ethpandaops/rpc-snooper/modules"
+	"github.com/ethpandaops/rpc-snooper/types"
+)
+
+// DefaultRouteName labels calls that match no route and are served by the targets.
+const DefaultRouteName = "default"
+
+// RoutesConfig is the rule file of the upstream routing.
+type RoutesConfig struct {
+	Routes []*Route `json:"routes"`
+}
+
+// Route forwards matching calls to its own target instead of the upstream pool.
+// Routes are matched in order, the first match serves the call.
+type Route struct {
+	Name   string     `json:"name"`
+	Target string     `json:"target"`
+	Match  RouteMatch `json:"match"`
+
+	upstream    *Upstream
+	methodRegex *regexp.Regexp
+	filter      *types.Filter
+}
+
+// RouteMatch selects the calls served by a route. All set fields must match.
+type RouteMatch struct {
+	JRPCMethodPrefix string `json:"jrpc_method_prefix,omitempty"` // calls of a batch are routed individually
+	JRPCMethodRegex  string `json:"jrpc_method_regex,omitempty"`
+	Path             string `json:"path,omitempty"`       // URL path prefix
+	JSONQuery        string `json:"json_query,omitempty"` // gojq predicate on the call
+}
+
+// router picks the route serving each call.
+type router struct {
+	routes       []*Route
+	filterEngine *modules.FilterEngine
+	client       *http.Client
+}
+
+// EnableRouting forwards calls matching the routes of the rule file to their own
+// targets. Unmatched calls are served by the configured targets as before.
+// Call this once at startup before serving requests.
+func (s *Snooper) EnableRouting(path string) error {
+	config, err := loadRoutesConfig(path)
+	if err != nil {
+		return err
+	}
+
+	rt := &router{
+		routes:       config.Routes,
+		filterEngine: modules.NewFilterEngine(s.logger),
+		client:       &http.Client{Timeout: 0},
+	}
+
+	names := map[string]bool{}
+
+	for idx, route := range rt.routes {
+		if err := rt.prepareRoute(route); err != nil {
+			return fmt.Errorf("invalid route %d: %w", idx, err)
+		}
+
+		if names[route.Name] {
+			return fmt.Errorf("invalid route %d: duplicate name %q", idx, route.Name)
+		}
+
+		names[route.Name] = true
+	}
+
+	s.router = rt
+
+	s.logger.Infof("routing calls with %v routes from: %v", len(rt.routes), path)
+
+	return nil
+}
+
+func loadRoutesConfig(path string) (*RoutesConfig, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // user provided rule file
+	if err != nil {
+		return nil, fmt.Errorf("failed reading routes: %w", err)
+	}
+
+	config := &RoutesConfig{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("invalid routes: %w", err)
+	}
+
+	return config, nil
+}
+
+// prepareRoute validates a route and compiles its method regex and predicate.
+func (rt *router) prepareRoute(route *Route) error {
+	if route.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if route.Name == DefaultRouteName {
+		return fmt.Errorf("name %q is reserved for unmatched calls", DefaultRouteName)
+	}
+
+	targetURL, err := url.Parse(strings.TrimSpace(route.Target))
+	if err != nil || targetURL.Scheme == "" || targetURL.Host == "" {
+		return fmt.Errorf("invalid target: %q", route.Target)
+	}
+
+	route.upstream = &Upstream{URL: targetURL}
+	route.upstream.healthy.Store(true)
+
+	match := &route.Match
+	if match.JRPCMethodPrefix == "" && match.JRPCMethodRegex == "" && match.Path == "" && match.JSONQuery == "" {
+		return errors.New("at least one match condition is required")
+	}
+
+	if match.JRPCMethodRegex != "" {
+		route.methodRegex, err = regexp.Compile(match.JRPCMethodRegex)
+		if err != nil {
+			return fmt.Errorf("invalid jrpc_method_regex: %w", err)
+		}
+	}
+
+	route.filter = &types.Filter{
+		JSONQuery: match.JSONQuery,
+	}
+
+	if err := rt.filterEngine.CompileFilter(route.filter); err != nil {
+		return fmt.Errorf("invalid json_query: %w", err)
+	}
+
+	return nil
+}
+
+// match returns the first route matching a call, or nil for the default route.
+func (rt *router) match(r *http.Request, call any) *Route {
+	reqCtx := &types.RequestContext{
+		Method:      r.Method,
+		URL:         r.URL,
+		Headers:     r.Header,
+		Body:        call,
+		ContentType: r.Header.Get("Content-Type"),
+	}
+	method := types.JSONRPCMethod(call)
+
+	for _, route := range rt.routes {
+		if route.Match.Path != "" && !strings.HasPrefix(r.URL.Path, route.Match.Path) {
+			continue
+		}
+
+		if route.Match.JRPCMethodPrefix != "" && (method == "" || !strings.HasPrefix(method, route.Match.JRPCMethodPrefix)) {
+			continue
+		}
+
+		if route.methodRegex != nil && (method == "" || !route.methodRegex.MatchString(method)) {
+			continue
+		}
+
+		if route.Match.JSONQuery != "" && call == nil {
+			continue
+		}
+
+		if rt.filterEngine.ShouldProcessRequestFilter(route.filter, reqCtx) {
+			return route
+		}
+	}
+
+	return nil
+}
+
+// matchBatch returns the route of each call of a batch.
+func (rt *router) matchBatch(r *http.Request, batch []any) []*Route {
+	routes := make([]*Route, len(batch))
+
+	for idx, call := range batch {
+		routes[idx] = rt.match(r, call)
+	}
+
+	return routes
+}
+
+// routeName returns the name of a route, nil is the default route.
+func routeName(route *Route) string {
+	if route == nil {
+		return DefaultRouteName
+	}
+
+	return route.Name
+}
+
+// selectRoute picks the route serving a call. Batches whose calls are all served by
+// the same route are forwarded as they are, mixed batches are split by route.
+func (s *Snooper) selectRoute(callCtx *ProxyCallContext, r *http.Request) error {
+	bodyData, err := bufferRequestBody(r)
+	if err != nil {
+		return err
+	}
+
+	parsedBody := s.parseJSONBody(bodyData, r.Header.Get("Content-Encoding"))
+
+	batch := getJSONRPCBatch(parsedBody)
+	if batch == nil {
+		callCtx.setRoute(s.router.match(r, parsedBody))
+		return nil
+	}
+
+	routes := s.router.matchBatch(r, batch)
+	names := []string{}
+
+	for _, route := range routes {
+		if name := routeName(route); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 1 {
+		callCtx.setRoute(routes[0])
+		return nil
+	}
+
+	callCtx.batchRoutes = routes
+	callCtx.routeName = strings.Join(names, ",")
+
+	return nil
+}
+
+// setRoute makes a route serve the call. The default route keeps the upstream
+// selected from the pool.
+func (callContext *ProxyCallContext) setRoute(route *Route) {
+	callContext.route = route
+	callContext.routeName = routeName(route)
+
+	if route != nil {
+		callContext.upstream = route.upstream
+	}
+}
+
+// routeGroup is the part of a split batch forwarded to a single route.
+type routeGroup struct {
+	route     *Route
+	calls     []any
+	responses map[string][]any
+	err       error
+}
+
+// forwardSplitBatch forwards the calls of a mixed batch to their routes and
+// reassembles the responses in request order. It consumes the request body, so the
+// request is logged and processed by the modules like a forwarded one. Calls without
+// a response from their route are answered with a JSON-RPC error.
+func (s *Snooper) forwardSplitBatch(callCtx *ProxyCallContext, r, req *http.Request) (*http.Response, error) {
+	bodyData, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading request body: %w", err)
+	}
+
+	contentEncoding := req.Header.Get("Content-Encoding")
+
+	batch := getJSONRPCBatch(s.parseJSONBody(bodyData, contentEncoding))
+	if batch == nil {
+		return nil, errors.New("split batch is no JSON-RPC batch anymore")
+	}
+
+	routes := callCtx.batchRoutes
+	if len(routes) != len(batch) {
+		// the batch was changed by a rewrite rule or interceptor
+		routes = s.router.matchBatch(r, batch)
+	}
+
+	groups := []*routeGroup{}
+	callGroups := make([]*routeGroup, len(batch))
+
+	for idx, call := range batch {
+		var group *routeGroup
+
+		for _, g := range groups {
+			if g.route == routes[idx] {
+				group = g
+				break
+			}
+		}
+
+		if group == nil {
+			group = &routeGroup{route: routes[idx]}
+			groups = append(groups, group)
+		}
+
+		group.calls = append(group.calls, call)
+		callGroups[idx] = group
+	}
+
+	wg := sync.WaitGroup{}
+
+	for _, group := range groups {
+		wg.Add(1)
+
+		go func(group *routeGroup) {
+			defer wg.Done()
+
+			group.err = s.forwardRouteGroup(callCtx, r, req, group, contentEncoding)
+		}(group)
+	}
+
+	wg.Wait()
+
+	responses := make([]json.RawMessage, 0, len(batch))
+
+	for idx, call := range batch {
+		id := getJSONRPCID(call)
+		if id == "" {
+			continue
+		}
+
+		group := callGroups[idx]
+
+		if pending := group.responses[id]; len(pending) > 0 {
+			group.responses[id] = pending[1:]
+
+			response, err := encodeJSONBody(pending[0], "")
+			if err != nil {
+				return nil, err
+			}
+
+			responses = append(responses, response)
+
+			continue
+		}
+
+		message := fmt.Sprintf("no response from route %v", routeName(group.route))
+		if group.err != nil {
+			message = fmt.Sprintf("route %v failed: %v", routeName(group.route), group.err)
+		}
+
+		callData, err := json.Marshal(call)
+		if err != nil {
+			return nil, err
+		}
+
+		responses = append(responses, buildJSONRPCErrorBody(callData, defaultFaultJRPCErrorCode, message))
+	}
+
+	body, err := json.Marshal(responses)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithField("callidx", callCtx.callIndex).Infof("ROUTE #%v: split batch of %v calls to routes %v", callCtx.callIndex, len(batch), callCtx.routeName)
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+
+	return newSyntheticResponse(&types.SyntheticResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       body,
+	}), nil
+}
+
+// forwardRouteGroup forwards the calls of a split batch served by the same route and
+// keeps their responses by id.
+func (s *Snooper) forwardRouteGroup(callCtx *ProxyCallContext, r, req *http.Request, group *routeGroup, contentEncoding string) error {
+	upstream := callCtx.upstream
+	if group.route != nil {
+		upstream = group.route.upstream
+	}
+
+	proxyURL, err := buildProxyURL(upstream, r)
+	if err != nil {
+		return err
+	}
+
+	bodyData, err := encodeJSONBody(group.calls, contentEncoding)
+	if err != nil {
+		return err
+	}
+
+	groupReq, err := http.NewRequestWithContext(callCtx.context, req.Method, proxyURL.String(), bytes.NewReader(bodyData))
+	if err != nil {
+		return err
+	}
+
+	groupReq.Header = req.Header.Clone()
+	// let the transport negotiate and decompress the response encoding
+	groupReq.Header.Del("Accept-Encoding")
+
+	rsp, err := s.router.client.Do(groupReq)
+	if err != nil {
+		if group.route == nil && callCtx.context.Err() == nil {
+			s.upstreams.MarkFailed(upstream, err)
+		}
+
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	if group.route == nil && rsp.StatusCode >= http.StatusInternalServerError {
+		s.upstreams.MarkFailed(upstream, fmt.Errorf("unexpected status code %v", rsp.StatusCode))
+	}
+
+	rspData, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return fmt.Errorf("failed reading response: %w", err)
+	}
+
+	responses, ok := s.parseJSONBody(rspData, rsp.Header.Get("Content-Encoding")).([]any)
+	if !ok {
+		return fmt.Errorf("unexpected response with status %v", rsp.StatusCode)
+	}
+
+	group.responses = map[string][]any{}
+
+	for _, response := range responses {
+		id := getJSONRPCID(response)
+		group.responses[id] = append(group.responses[id], response)
+	}
+
+	return nil
+}
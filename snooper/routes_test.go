@@ -0,0 +1,274 @@
+package snooper
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// routeTestUpstream answers every JSON-RPC call with its name as result and keeps the
+// methods it received.
+type routeTestUpstream struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	methods []string
+}
+
+func newRouteTestUpstream(t *testing.T, name string) *routeTestUpstream {
+	t.Helper()
+
+	upstream := &routeTestUpstream{}
+	upstream.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		answer := func(call any) map[string]any {
+			obj := call.(map[string]any)
+
+			upstream.mu.Lock()
+			upstream.methods = append(upstream.methods, obj["method"].(string))
+			upstream.mu.Unlock()
+
+			return map[string]any{"jsonrpc": "2.0", "id": obj["id"], "result": name}
+		}
+
+		var response any
+
+		if batch, ok := body.([]any); ok {
+			responses := []any{}
+
+			// answer in reverse order, responses are matched by id
+			for idx := len(batch) - 1; idx >= 0; idx-- {
+				responses = append(responses, answer(batch[idx]))
+			}
+
+			response = responses
+		} else {
+			response = answer(body)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(upstream.Close)
+
+	return upstream
+}
+
+func (u *routeTestUpstream) received() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return append([]string{}, u.methods...)
+}
+
+func newRoutesTestSnooper(t *testing.T, target, routes string) (string, *testLogHook) {
+	t.Helper()
+
+	routesFile := filepath.Join(t.TempDir(), "routes.json")
+	require.NoError(t, os.WriteFile(routesFile, []byte(routes), 0o600))
+
+	snooper, proxyURL, hook := newTargetTestSnooper(t, target)
+
+	require.NoError(t, snooper.EnableRouting(routesFile))
+
+	return proxyURL, hook
+}
+
+func TestRoutesByMethod(t *testing.T) {
+	engine := newRouteTestUpstream(t, "engine")
+	archive := newRouteTestUpstream(t, "archive")
+	public := newRouteTestUpstream(t, "public")
+
+	proxyURL, hook := newRoutesTestSnooper(t, public.URL, `{"routes": [
+		{"name": "engine", "target": "`+engine.URL+`", "match": {"jrpc_method_prefix": "engine_"}},
+		{"name": "archive", "target": "`+archive.URL+`", "match": {"jrpc_method_regex": "^(debug|trace)_"}},
+		{"name": "old-blocks", "target": "`+archive.URL+`", "match": {"jrpc_method_prefix": "eth_getBlockByNumber", "json_query": ".params[0] == \"earliest\""}}
+	]}`)
+
+	_, body, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"engine_forkchoiceUpdatedV3","params":[],"id":1}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"engine"}`, body)
+
+	_, body, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"debug_traceTransaction","params":[],"id":2}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":"archive"}`, body)
+
+	_, body, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["earliest",false],"id":3}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":3,"result":"archive"}`, body)
+
+	// unmatched calls go to the target
+	_, body, err = postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["latest",false],"id":4}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":4,"result":"public"}`, body)
+
+	// batches of a single route are forwarded as they are
+	_, body, err = postJSON(t, proxyURL, `[{"jsonrpc":"2.0","method":"engine_getPayloadV4","id":5},{"jsonrpc":"2.0","method":"engine_newPayloadV4","id":6}]`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":6,"result":"engine"},{"jsonrpc":"2.0","id":5,"result":"engine"}]`, body)
+
+	assert.Equal(t, []string{"engine_forkchoiceUpdatedV3", "engine_newPayloadV4", "engine_getPayloadV4"}, engine.received())
+	assert.Equal(t, []string{"debug_traceTransaction", "eth_getBlockByNumber"}, archive.received())
+	assert.Equal(t, []string{"eth_getBlockByNumber"}, public.received())
+
+	// calls are labelled with their route and upstream
+	require.Eventually(t, func() bool {
+		for _, entry := range hook.Entries() {
+			if strings.HasPrefix(entry.Message, "RESPONSE") && entry.Data["route"] == "archive" && entry.Data["upstream"] == archive.URL {
+				return true
+			}
+		}
+
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestRoutesSplitBatch(t *testing.T) {
+	engine := newRouteTestUpstream(t, "engine")
+	public := newRouteTestUpstream(t, "public")
+
+	proxyURL, hook := newRoutesTestSnooper(t, public.URL, `{"routes": [
+		{"name": "engine", "target": "`+engine.URL+`", "match": {"jrpc_method_prefix": "engine_"}}
+	]}`)
+
+	_, body, err := postJSON(t, proxyURL, `[
+		{"jsonrpc":"2.0","method":"eth_blockNumber","id":1},
+		{"jsonrpc":"2.0","method":"engine_exchangeCapabilities","params":[[]],"id":2},
+		{"jsonrpc":"2.0","method":"eth_chainId","id":"3"},
+		{"jsonrpc":"2.0","method":"engine_getClientVersionV1","params":[{}],"id":3}
+	]`)
+	require.NoError(t, err)
+
+	// responses are reassembled in request order
+	assert.JSONEq(t, `[
+		{"jsonrpc":"2.0","id":1,"result":"public"},
+		{"jsonrpc":"2.0","id":2,"result":"engine"},
+		{"jsonrpc":"2.0","id":"3","result":"public"},
+		{"jsonrpc":"2.0","id":3,"result":"engine"}
+	]`, body)
+
+	assert.Equal(t, []string{"engine_getClientVersionV1", "engine_exchangeCapabilities"}, engine.received())
+	assert.Equal(t, []string{"eth_chainId", "eth_blockNumber"}, public.received())
+
+	// split batches are labelled with all their routes
+	require.Eventually(t, func() bool {
+		for _, entry := range hook.Entries() {
+			if strings.HasPrefix(entry.Message, "RESPONSE") && entry.Data["route"] == "default,engine" {
+				return true
+			}
+		}
+
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestRoutesBatchCallContext(t *testing.T) {
+	engineRoute := &Route{Name: "engine", upstream: &Upstream{}}
+	poolUpstream := &Upstream{}
+
+	callCtx := &ProxyCallContext{
+		upstream:    poolUpstream,
+		routeName:   "default,engine",
+		batchRoutes: []*Route{nil, engineRoute},
+	}
+
+	// the calls of a split batch are labelled with the route serving them
+	first := callCtx.newBatchCallContext(0)
+	assert.Equal(t, DefaultRouteName, first.routeName)
+	assert.Same(t, poolUpstream, first.upstream)
+
+	second := callCtx.newBatchCallContext(1)
+	assert.Equal(t, "engine", second.routeName)
+	assert.Same(t, engineRoute.upstream, second.upstream)
+}
+
+func TestRoutesSplitBatchRouteFailure(t *testing.T) {
+	public := newRouteTestUpstream(t, "public")
+
+	proxyURL, _ := newRoutesTestSnooper(t, public.URL, `{"routes": [
+		{"name": "engine", "target": "http://127.0.0.1:1", "match": {"jrpc_method_prefix": "engine_"}}
+	]}`)
+
+	rsp, body, err := postJSON(t, proxyURL, `[{"jsonrpc":"2.0","method":"eth_blockNumber","id":1},{"jsonrpc":"2.0","method":"engine_exchangeCapabilities","id":2}]`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+
+	var responses []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(body), &responses))
+	require.Len(t, responses, 2)
+
+	assert.Equal(t, "public", responses[0]["result"])
+
+	rpcErr, ok := responses[1]["error"].(map[string]any)
+	require.True(t, ok, body)
+	assert.InDelta(t, float64(defaultFaultJRPCErrorCode), rpcErr["code"], 0)
+	assert.True(t, strings.HasPrefix(rpcErr["message"].(string), "route engine failed"), rpcErr["message"])
+}
+
+func TestRoutesByPath(t *testing.T) {
+	beacon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"data":{"path":"`+r.URL.Path+`"}}`)
+	}))
+	t.Cleanup(beacon.Close)
+
+	public := newRouteTestUpstream(t, "public")
+
+	proxyURL, _ := newRoutesTestSnooper(t, public.URL, `{"routes": [
+		{"name": "beacon", "target": "`+beacon.URL+`", "match": {"path": "/eth/"}}
+	]}`)
+
+	rsp, err := http.Get(proxyURL + "/eth/v1/node/version") //nolint:noctx // test request
+	require.NoError(t, err)
+
+	defer rsp.Body.Close()
+
+	data, err := io.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"path":"/eth/v1/node/version"}}`, string(data))
+}
+
+func TestRoutesInvalidConfig(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	snooper, err := NewSnooper("http://localhost:8545", logger, nil, "")
+	require.NoError(t, err)
+
+	defer snooper.Shutdown()
+
+	dir := t.TempDir()
+
+	for _, routes := range []string{
+		`{"routes": [{"target": "http://localhost:8551", "match": {"path": "/"}}]}`,
+		`{"routes": [{"name": "default", "target": "http://localhost:8551", "match": {"path": "/"}}]}`,
+		`{"routes": [{"name": "engine", "target": "localhost", "match": {"path": "/"}}]}`,
+		`{"routes": [{"name": "engine", "target": "http://localhost:8551"}]}`,
+		`{"routes": [{"name": "engine", "target": "http://localhost:8551", "match": {"jrpc_method_regex": "("}}]}`,
+		`{"routes": [{"name": "engine", "target": "http://localhost:8551", "match": {"json_query": "(("}}]}`,
+		`{"routes": [{"name": "engine", "target": "http://localhost:8551", "match": {"path": "/"}}, {"name": "engine", "target": "http://localhost:8552", "match": {"path": "/"}}]}`,
+		`{"routes": [], "unknown": true}`,
+	} {
+		routesFile := filepath.Join(dir, "routes.json")
+		require.NoError(t, os.WriteFile(routesFile, []byte(routes), 0o600))
+
+		assert.Error(t, snooper.EnableRouting(routesFile), routes)
+	}
+
+	assert.Error(t, snooper.EnableRouting(filepath.Join(dir, "missing.json")))
+}
@@ -7,15 +7,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ethpandaops/rpc-snooper/engine"
 	"github.com/ethpandaops/rpc-snooper/metrics"
 	"github.com/ethpandaops/rpc-snooper/modules"
 	"github.com/ethpandaops/rpc-snooper/modules/builtin"
+	"github.com/ethpandaops/rpc-snooper/recording"
+	"github.com/ethpandaops/rpc-snooper/ssz"
 	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/ethpandaops/rpc-snooper/utils"
 	"github.com/ethpandaops/rpc-snooper/xatu"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -26,7 +29,7 @@ import (
 type Snooper struct {
 	CallTimeout time.Duration
 
-	target         *url.URL
+	upstreams      *UpstreamPool
 	logger         logrus.FieldLogger
 	api            *API
 	moduleManager  *modules.Manager
@@ -46,11 +49,44 @@ type Snooper struct {
 	// Hide request/response bodies
 	hideBodies bool
 
+	// JSON log output
+	jsonLogs bool
+
+	// SSZ body decoding
+	sszDecoder *ssz.Decoder
+
 	// Flow control
 	flowEnabled bool
 	flowBlocked map[string]bool
 	flowMutex   sync.RWMutex
 
+	// Fault injection
+	faultEngine *FaultEngine
+
+	// Body rewriting
+	rewriteEngine *RewriteEngine
+
+	// Traffic recording
+	recorder *recording.Writer
+
+	// Recent calls for the HAR export
+	history *callHistory
+
+	// Engine API payload lifecycle
+	engineTracker *engine.Tracker
+
+	// Shadow traffic mirroring
+	mirror *mirror
+
+	// Mock upstream mode
+	mock *mockUpstream
+
+	// Per-call upstream routing
+	router *router
+
+	// Engine API JWT re-signing and validation
+	jwtAuth *jwtAuth
+
 	// Xatu integration
 	xatuService     xatu.Service
 	metadataFetcher *ExecutionMetadataFetcher
@@ -58,7 +94,7 @@ type Snooper struct {
 }
 
 func NewSnooper(target string, logger logrus.FieldLogger, xatuConfig *xatu.Config, jwtSecret string) (*Snooper, error) {
-	targetURL, err := url.Parse(target)
+	upstreams, err := NewUpstreamPool([]string{target}, nil, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -72,19 +108,21 @@ func NewSnooper(target string, logger logrus.FieldLogger, xatuConfig *xatu.Confi
 	snooper := &Snooper{
 		CallTimeout: 60 * time.Second,
 
-		target:               targetURL,
+		upstreams:            upstreams,
 		logger:               logger,
 		moduleManager:        modules.NewManager(logger),
 		logTruncationEnabled: false,
 		flowEnabled:          true, // Start with flow enabled by default
 		flowBlocked:          make(map[string]bool),
+		faultEngine:          NewFaultEngine(logger),
+		rewriteEngine:        NewRewriteEngine(logger),
 		xatuService:          xatuService,
 		jwtSecret:            jwtSecret,
 	}
 
 	// Set up metadata fetcher if xatu is enabled
 	if xatuService.IsEnabled() {
-		snooper.metadataFetcher = NewExecutionMetadataFetcher(targetURL, jwtSecret, logger)
+		snooper.metadataFetcher = NewExecutionMetadataFetcher(upstreams.Primary().URL, jwtSecret, logger)
 
 		// Wire up the fetcher as metadata provider for xatu events
 		xatuService.SetMetadataProvider(snooper.metadataFetcher)
@@ -95,18 +133,45 @@ func NewSnooper(target string, logger logrus.FieldLogger, xatuConfig *xatu.Confi
 		// Register Xatu module
 		xatuModule := builtin.NewXatuModule(snooper.moduleManager.GenerateModuleID(), xatuService.Router())
 
-		if err := snooper.moduleManager.RegisterModule(xatuModule, nil); err != nil {
+		if err := snooper.moduleManager.RegisterModuleWithInfo(xatuModule, nil, modules.ModuleInfo{Type: "xatu"}); err != nil {
 			return nil, fmt.Errorf("failed to register xatu module: %w", err)
 		}
 
 		logger.Info("xatu module registered")
 	}
 
+	snooper.registerRewriteControlMethods()
+	snooper.registerFlowControlMethods()
 	snooper.orderedProcessor = NewOrderedProcessor(snooper)
 
 	return snooper, nil
 }
 
+// ConfigureUpstreams replaces the target with a pool of upstreams. The first target is
+// the primary upstream, which is also used to fetch execution metadata. Health checks
+// start right away. Call this once at startup before serving requests.
+func (s *Snooper) ConfigureUpstreams(targets []string, config *UpstreamConfig) error {
+	if config != nil && strings.HasPrefix(config.HealthCheck, "engine_") && len(config.JWTSecret) == 0 {
+		jwtSecret, err := utils.ParseJWTSecret(s.jwtSecret)
+		if err != nil {
+			return fmt.Errorf("invalid JWT secret: %w", err)
+		}
+
+		config.JWTSecret = jwtSecret
+	}
+
+	upstreams, err := NewUpstreamPool(targets, config, s.logger)
+	if err != nil {
+		return err
+	}
+
+	s.upstreams.Stop()
+	s.upstreams = upstreams
+	s.upstreams.Start()
+
+	return nil
+}
+
 // EnableLogTruncation enables hex truncation in log output.
 // Call this once at startup before serving requests.
 func (s *Snooper) EnableLogTruncation() {
@@ -120,15 +185,43 @@ func (s *Snooper) EnableHideBodies() {
 	s.hideBodies = true
 }
 
+// EnableJSONLogs adds the call index, direction, HTTP method, URL and size as fields
+// to every call log entry and embeds JSON bodies as JSON instead of indented text,
+// for use with a JSON log formatter.
+// Call this once at startup before serving requests.
+func (s *Snooper) EnableJSONLogs() {
+	s.jsonLogs = true
+}
+
+// ConfigureControlQueue sets the size and overflow policy (drop-oldest, drop-newest or
+// disconnect) of the outbound queue of each WebSocket control connection.
+// Call this once at startup before serving requests.
+func (s *Snooper) ConfigureControlQueue(size int, overflow string) error {
+	return s.moduleManager.ConfigureSendQueue(&modules.SendQueueConfig{
+		Size:     size,
+		Overflow: overflow,
+	})
+}
+
 func (s *Snooper) Shutdown() {
 	if s.orderedProcessor != nil {
 		s.orderedProcessor.Stop()
 	}
 
+	if s.upstreams != nil {
+		s.upstreams.Stop()
+	}
+
 	if s.metadataFetcher != nil {
 		s.metadataFetcher.Stop()
 	}
 
+	if s.recorder != nil {
+		if err := s.recorder.Close(); err != nil {
+			s.logger.WithError(err).Error("failed to close recording")
+		}
+	}
+
 	if s.xatuService != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -273,11 +366,18 @@ func (s *Snooper) collectMetrics(req *http.Request, respCtx *types.ResponseConte
 		Timestamp: time.Now(),
 	}
 
-	// Create metrics entry
-	metricsEntry := metrics.CreateMetricsEntryFromContexts(s.target, reqCtx, respCtx)
+	// Create metrics entry, labeled with the upstream that served the call
+	target := s.upstreams.Primary().URL
+	if ctx, ok := respCtx.CallCtx.(*ProxyCallContext); ok && ctx.upstream != nil {
+		target = ctx.upstream.URL
+	}
+
+	metricsEntry := metrics.CreateMetricsEntryFromContexts(target, reqCtx, respCtx)
 
-	// Extract jrpc_method from stored context data
+	// Extract jrpc_method and route from stored context data
 	if ctx, ok := respCtx.CallCtx.(*ProxyCallContext); ok {
+		metricsEntry.Route = ctx.routeName
+
 		if jrpcMethod := ctx.GetData(0, "jrpc_method"); jrpcMethod != nil {
 			if method, ok := jrpcMethod.(string); ok {
 				metricsEntry.JRPCMethod = method
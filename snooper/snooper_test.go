@@ -0,0 +1,106 @@
+package snooper
+
+import (
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethpandaops/rpc-snooper/utils"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// testLogHook records the entries logged by a test snooper.
+type testLogHook struct {
+	mu      sync.Mutex
+	entries []*logrus.Entry
+}
+
+func (h *testLogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *testLogHook) Fire(entry *logrus.Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// the formatter removes fields from the entry after the hooks ran
+	h.entries = append(h.entries, &logrus.Entry{
+		Level:   entry.Level,
+		Message: entry.Message,
+		Data:    maps.Clone(entry.Data),
+	})
+
+	return nil
+}
+
+// Entries returns the entries logged so far.
+func (h *testLogHook) Entries() []*logrus.Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]*logrus.Entry{}, h.entries...)
+}
+
+// newTestSnooper starts an upstream serving upstreamHandler and a snooper proxy for
+// it, see newTargetTestSnooper.
+func newTestSnooper(t *testing.T, upstreamHandler http.HandlerFunc) (*Snooper, string, *testLogHook) {
+	t.Helper()
+
+	upstream := httptest.NewServer(upstreamHandler)
+	t.Cleanup(upstream.Close)
+
+	return newTargetTestSnooper(t, upstream.URL)
+}
+
+// newTargetTestSnooper starts a snooper proxy for target with its API mounted under
+// /_snooper/ and returns the snooper, the proxy URL and a hook recording its logs.
+// Logs are formatted like the default text output. Features are enabled on the
+// returned snooper before the first call is sent.
+func newTargetTestSnooper(t *testing.T, target string) (*Snooper, string, *testLogHook) {
+	t.Helper()
+
+	hook := &testLogHook{}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetFormatter(&utils.SnooperFormatter{})
+	logger.AddHook(hook)
+
+	snooper, err := NewSnooper(target, logger, nil, "")
+	require.NoError(t, err)
+	t.Cleanup(snooper.Shutdown)
+
+	snooper.api = newAPI(snooper)
+
+	router := mux.NewRouter()
+	snooper.api.initRouter(router.PathPrefix("/_snooper/").Subrouter())
+	router.PathPrefix("/").Handler(snooper)
+
+	proxy := httptest.NewServer(router)
+	t.Cleanup(proxy.Close)
+
+	return snooper, proxy.URL, hook
+}
+
+// dialControlTestConn opens a control connection to the module manager of snooper.
+func dialControlTestConn(t *testing.T, snooper *Snooper) *websocket.Conn {
+	t.Helper()
+
+	controlServer := httptest.NewServer(http.HandlerFunc(snooper.moduleManager.HandleWebSocket))
+	t.Cleanup(controlServer.Close)
+
+	conn, rsp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(controlServer.URL, "http"), nil)
+	require.NoError(t, err)
+
+	rsp.Body.Close()
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
@@ -0,0 +1,58 @@
+package snooper
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ethpandaops/rpc-snooper/ssz"
+)
+
+// sszDecodeFunc decodes a SSZ body into its JSON value, or returns nil if the body
+// is not a known container.
+type sszDecodeFunc func(data []byte) any
+
+// EnableSSZDecoding decodes SSZ bodies of known beacon API containers, so logs and
+// modules see the JSON representation of the container instead of a hex blob.
+func (s *Snooper) EnableSSZDecoding() {
+	s.sszDecoder = ssz.NewDecoder()
+}
+
+// sszDecodeFunc returns the decoder for the request or response body of a call, or
+// nil if SSZ decoding is disabled.
+func (s *Snooper) sszDecodeFunc(req *http.Request, header http.Header, response bool) sszDecodeFunc {
+	if s.sszDecoder == nil {
+		return nil
+	}
+
+	return func(data []byte) any {
+		value, err := s.sszDecoder.Decode(req.Method, req.URL.Path, header.Get(ssz.ConsensusVersionHeader), response, data)
+		if err != nil {
+			if !errors.Is(err, ssz.ErrUnknownContainer) {
+				s.logger.WithError(err).Debugf("failed decoding ssz body of %v %v", req.Method, req.URL.Path)
+			}
+
+			return nil
+		}
+
+		return value
+	}
+}
+
+// formatSSZBodyForLog formats a SSZ body for logging, as JSON if it was decoded.
+func (s *Snooper) formatSSZBodyForLog(bodyData []byte, decoded any) any {
+	if decoded == nil {
+		return s.formatHexBodyForLog(bodyData)
+	}
+
+	bodyJSON, err := json.Marshal(decoded)
+	if err != nil {
+		return s.formatHexBodyForLog(bodyData)
+	}
+
+	if s.jsonLogs {
+		return s.compactJSONForLog(bodyJSON)
+	}
+
+	return string(s.beautifyJSONForLog(bodyJSON))
+}
@@ -0,0 +1,136 @@
+package snooper
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bodyTestModule keeps the response bodies passed to it.
+type bodyTestModule struct {
+	id uint64
+
+	mu     sync.Mutex
+	bodies [][]byte
+}
+
+func (m *bodyTestModule) ID() uint64 {
+	return m.id
+}
+
+func (m *bodyTestModule) OnRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
+	return ctx, nil
+}
+
+func (m *bodyTestModule) OnResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bodies = append(m.bodies, ctx.BodyBytes)
+
+	return ctx, nil
+}
+
+func (m *bodyTestModule) Configure(_ map[string]any) error {
+	return nil
+}
+
+func (m *bodyTestModule) Close() error {
+	return nil
+}
+
+func (m *bodyTestModule) Bodies() [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([][]byte{}, m.bodies...)
+}
+
+func TestSSZDecoding(t *testing.T) {
+	block := &phase0.SignedBeaconBlock{
+		Message: &phase0.BeaconBlock{
+			Slot:          4242,
+			ProposerIndex: 3,
+			Body: &phase0.BeaconBlockBody{
+				ETH1Data: &phase0.ETH1Data{
+					BlockHash: make([]byte, 32),
+				},
+			},
+		},
+	}
+
+	blockSSZ, err := block.MarshalSSZ()
+	require.NoError(t, err)
+
+	snooper, proxyURL, hook := newTestSnooper(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Eth-Consensus-Version", "phase0")
+		_, _ = w.Write(blockSSZ)
+	})
+
+	snooper.EnableSSZDecoding()
+
+	module := &bodyTestModule{id: snooper.moduleManager.GenerateModuleID()}
+	require.NoError(t, snooper.moduleManager.RegisterModule(module, nil))
+
+	getResponseBody := func(path string) string {
+		rsp, err := http.Get(proxyURL + path) //nolint:noctx // test request
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(rsp.Body)
+		require.NoError(t, err)
+		rsp.Body.Close()
+
+		assert.Equal(t, blockSSZ, data, "SSZ bodies must be proxied unchanged")
+
+		var response *logrus.Entry
+
+		require.Eventually(t, func() bool {
+			for _, entry := range hook.Entries() {
+				if strings.HasPrefix(entry.Message, "RESPONSE") && strings.HasSuffix(entry.Message, " "+path) {
+					response = entry
+				}
+			}
+
+			return response != nil
+		}, 2*time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, "ssz", response.Data["type"])
+
+		body, ok := response.Data["body"].(string)
+		require.True(t, ok)
+
+		return body
+	}
+
+	var body map[string]any
+
+	require.NoError(t, json.Unmarshal([]byte(getResponseBody("/eth/v2/beacon/blocks/head")), &body), "Known containers must be logged as JSON")
+
+	message, ok := body["message"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "4242", message["slot"])
+	assert.Equal(t, "3", message["proposer_index"])
+
+	// unknown containers are still logged as hex
+	assert.Contains(t, getResponseBody("/eth/v1/beacon/light_client/updates"), "0x")
+
+	// modules get the raw SSZ bytes, decoded or not
+	require.Eventually(t, func() bool {
+		return len(module.Bodies()) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	for _, body := range module.Bodies() {
+		assert.Equal(t, blockSSZ, body)
+	}
+}
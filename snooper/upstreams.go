@@ -0,0 +1,395 @@
+package snooper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// Upstream selection policies.
+const (
+	UpstreamPolicyFailover   = "failover"
+	UpstreamPolicyRoundRobin = "round-robin"
+	UpstreamPolicySticky     = "sticky"
+)
+
+// HealthCheckNone disables the active health checks of an upstream pool.
+const HealthCheckNone = "none"
+
+const (
+	defaultHealthCheck         = "eth_syncing"
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 5 * time.Second
+	defaultFailureBackoff      = 30 * time.Second
+)
+
+// UpstreamConfig configures the upstream pool.
+type UpstreamConfig struct {
+	// Policy selects the upstream for each call, defaults to failover.
+	Policy string
+
+	// HealthCheck is a JSON-RPC method (e.g. eth_syncing, engine_exchangeCapabilities)
+	// or an HTTP path (e.g. /eth/v1/node/health) used to probe each upstream.
+	// Defaults to eth_syncing for pools of multiple upstreams, HealthCheckNone
+	// disables health checks.
+	HealthCheck string
+
+	// HealthCheckInterval is the time between two health checks of an upstream.
+	HealthCheckInterval time.Duration
+
+	// FailureBackoff is the time an upstream stays out of rotation after a failed
+	// call when health checks are disabled.
+	FailureBackoff time.Duration
+
+	// JWTSecret authenticates engine_* health checks.
+	JWTSecret []byte
+}
+
+// Upstream is a single target of the upstream pool.
+type Upstream struct {
+	URL      *url.URL
+	healthy  atomic.Bool
+	failedAt atomic.Int64
+}
+
+// Healthy reports whether the upstream passed its last health check, or without
+// health checks, whether it is not out of rotation after a failed call.
+func (u *Upstream) Healthy() bool {
+	return u.healthy.Load()
+}
+
+// UpstreamPool selects the upstream serving each proxied call and takes unhealthy
+// upstreams out of rotation.
+type UpstreamPool struct {
+	upstreams []*Upstream
+	config    UpstreamConfig
+	counter   atomic.Uint64
+	client    *http.Client
+	logger    logrus.FieldLogger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewUpstreamPool creates an upstream pool for the given targets. All upstreams start
+// out healthy until their first health check completes.
+func NewUpstreamPool(targets []string, config *UpstreamConfig, logger logrus.FieldLogger) (*UpstreamPool, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no upstream targets")
+	}
+
+	pool := &UpstreamPool{
+		upstreams: make([]*Upstream, 0, len(targets)),
+		logger:    logger.WithField("component", "upstreams"),
+		client:    &http.Client{Timeout: defaultHealthCheckTimeout},
+	}
+
+	pool.ctx, pool.cancel = context.WithCancel(context.Background())
+
+	if config != nil {
+		pool.config = *config
+	}
+
+	switch pool.config.Policy {
+	case "":
+		pool.config.Policy = UpstreamPolicyFailover
+	case UpstreamPolicyFailover, UpstreamPolicyRoundRobin, UpstreamPolicySticky:
+	default:
+		return nil, fmt.Errorf("unknown upstream policy: %s", pool.config.Policy)
+	}
+
+	switch pool.config.HealthCheck {
+	case "":
+		if len(targets) > 1 {
+			pool.config.HealthCheck = defaultHealthCheck
+		}
+	case HealthCheckNone:
+		pool.config.HealthCheck = ""
+	}
+
+	if pool.config.HealthCheckInterval <= 0 {
+		pool.config.HealthCheckInterval = defaultHealthCheckInterval
+	}
+
+	if pool.config.FailureBackoff <= 0 {
+		pool.config.FailureBackoff = defaultFailureBackoff
+	}
+
+	for _, target := range targets {
+		targetURL, err := url.Parse(strings.TrimSpace(target))
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream url %q: %w", target, err)
+		}
+
+		upstream := &Upstream{URL: targetURL}
+		upstream.healthy.Store(true)
+
+		pool.upstreams = append(pool.upstreams, upstream)
+	}
+
+	return pool, nil
+}
+
+// Upstreams returns all upstreams of the pool in configured order.
+func (p *UpstreamPool) Upstreams() []*Upstream {
+	return p.upstreams
+}
+
+// Primary returns the first configured upstream.
+func (p *UpstreamPool) Primary() *Upstream {
+	return p.upstreams[0]
+}
+
+// Select returns the upstream that should serve a call. If all upstreams are
+// unhealthy, the policy is applied to the full pool instead.
+func (p *UpstreamPool) Select(r *http.Request) *Upstream {
+	if len(p.upstreams) == 1 {
+		return p.upstreams[0]
+	}
+
+	if p.config.HealthCheck == "" {
+		p.restoreFailed()
+	}
+
+	candidates := make([]*Upstream, 0, len(p.upstreams))
+
+	for _, upstream := range p.upstreams {
+		if upstream.Healthy() {
+			candidates = append(candidates, upstream)
+		}
+	}
+
+	if len(candidates) == 0 {
+		candidates = p.upstreams
+	}
+
+	switch p.config.Policy {
+	case UpstreamPolicyRoundRobin:
+		return candidates[(p.counter.Add(1)-1)%uint64(len(candidates))]
+	case UpstreamPolicySticky:
+		// hash over the full pool so clients only move when their upstream fails
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(getClientIP(r)))
+		start := int(hash.Sum32() % uint32(len(p.upstreams))) //nolint:gosec // pool size is small
+
+		for idx := range p.upstreams {
+			upstream := p.upstreams[(start+idx)%len(p.upstreams)]
+			if upstream.Healthy() {
+				return upstream
+			}
+		}
+
+		return p.upstreams[start]
+	default:
+		return candidates[0]
+	}
+}
+
+// MarkFailed takes an upstream out of rotation after a failed call. It is put back
+// by the next successful health check, or without health checks, once the failure
+// back-off has passed.
+func (p *UpstreamPool) MarkFailed(upstream *Upstream, err error) {
+	if len(p.upstreams) == 1 {
+		return
+	}
+
+	upstream.failedAt.Store(time.Now().UnixNano())
+
+	if upstream.healthy.Swap(false) {
+		p.logger.WithError(err).WithField("upstream", upstream.URL.String()).Warn("upstream failed, taking it out of rotation")
+	}
+}
+
+// restoreFailed puts upstreams back into rotation whose last failed call is more
+// than the failure back-off ago. It is used instead of health checks.
+func (p *UpstreamPool) restoreFailed() {
+	retryBefore := time.Now().Add(-p.config.FailureBackoff).UnixNano()
+
+	for _, upstream := range p.upstreams {
+		failedAt := upstream.failedAt.Load()
+		if upstream.Healthy() || failedAt == 0 || failedAt > retryBefore {
+			continue
+		}
+
+		if !upstream.healthy.Swap(true) {
+			p.logger.WithField("upstream", upstream.URL.String()).Info("retrying failed upstream, adding it to rotation")
+		}
+	}
+}
+
+// Start begins the periodic health checks of all upstreams.
+func (p *UpstreamPool) Start() {
+	if p.config.HealthCheck == "" {
+		return
+	}
+
+	for _, upstream := range p.upstreams {
+		p.wg.Add(1)
+
+		go p.healthCheckLoop(upstream)
+	}
+}
+
+// Stop ends the health checks.
+func (p *UpstreamPool) Stop() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+func (p *UpstreamPool) healthCheckLoop(upstream *Upstream) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		p.updateHealth(upstream)
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *UpstreamPool) updateHealth(upstream *Upstream) {
+	ctx, cancel := context.WithTimeout(p.ctx, defaultHealthCheckTimeout)
+	defer cancel()
+
+	err := p.checkHealth(ctx, upstream)
+	if p.ctx.Err() != nil {
+		return
+	}
+
+	healthy := err == nil
+
+	if upstream.healthy.Swap(healthy) == healthy {
+		return
+	}
+
+	logger := p.logger.WithField("upstream", upstream.URL.String())
+
+	if healthy {
+		logger.Info("upstream is healthy, adding it to rotation")
+	} else {
+		logger.WithError(err).Warn("upstream is unhealthy, taking it out of rotation")
+	}
+}
+
+// checkHealth probes an upstream. It is healthy if it answers with a 2xx status and,
+// for JSON-RPC health checks, without a JSON-RPC error.
+func (p *UpstreamPool) checkHealth(ctx context.Context, upstream *Upstream) error {
+	var (
+		req *http.Request
+		err error
+	)
+
+	isRPCCheck := !strings.HasPrefix(p.config.HealthCheck, "/")
+
+	if isRPCCheck {
+		params := []any{}
+		if p.config.HealthCheck == "engine_exchangeCapabilities" {
+			params = []any{[]string{}}
+		}
+
+		body, _ := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"method":  p.config.HealthCheck,
+			"params":  params,
+			"id":      1,
+		})
+
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL.String(), bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+
+		if strings.HasPrefix(p.config.HealthCheck, "engine_") && len(p.config.JWTSecret) > 0 {
+			token, err := utils.CreateJWTToken(p.config.JWTSecret)
+			if err != nil {
+				return fmt.Errorf("failed to create JWT token: %w", err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(upstream.URL.String(), "/")+p.config.HealthCheck, http.NoBody)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+	}
+
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	rspData, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return fmt.Errorf("failed reading response: %w", err)
+	}
+
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %v", rsp.StatusCode)
+	}
+
+	if isRPCCheck {
+		var rpcRsp struct {
+			Error *struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+
+		if err := json.Unmarshal(rspData, &rpcRsp); err != nil {
+			return fmt.Errorf("invalid JSON-RPC response: %w", err)
+		}
+
+		if rpcRsp.Error != nil {
+			return fmt.Errorf("JSON-RPC error %v: %v", rpcRsp.Error.Code, rpcRsp.Error.Message)
+		}
+	}
+
+	return nil
+}
+
+// addUpstreamLogField adds the upstream serving a call to its log fields when the
+// snooper fronts more than one upstream, or always for JSON logs. With routing, the
+// route of the call is added too.
+func (s *Snooper) addUpstreamLogField(ctx *ProxyCallContext, logFields logrus.Fields) {
+	if ctx.upstream != nil && (s.jsonLogs || s.router != nil || len(s.upstreams.Upstreams()) > 1) {
+		logFields["upstream"] = ctx.upstream.URL.String()
+	}
+
+	if ctx.routeName != "" {
+		logFields["route"] = ctx.routeName
+	}
+}
+
+// getClientIP returns the IP of the client that sent a request.
+func getClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,244 @@
+package snooper
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUpstreamServer starts an upstream answering every call with its name. Health
+// checks on /health succeed while healthy is true.
+func newUpstreamServer(t *testing.T, name string, healthy *atomic.Bool) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		if r.URL.Path == "/health" && !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write([]byte(name))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newUpstreamTestSnooper(t *testing.T, targets []string, config *UpstreamConfig) string {
+	t.Helper()
+
+	snooper, proxyURL, _ := newTargetTestSnooper(t, targets[0])
+	require.NoError(t, snooper.ConfigureUpstreams(targets, config))
+
+	return proxyURL
+}
+
+func callUpstream(t *testing.T, proxyURL string) string {
+	t.Helper()
+
+	rsp, body, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"eth_chainId","params":[],"id":1}`)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode, body)
+
+	return body
+}
+
+func TestUpstreamRoundRobin(t *testing.T) {
+	healthy := &atomic.Bool{}
+	healthy.Store(true)
+
+	first := newUpstreamServer(t, "first", healthy)
+	second := newUpstreamServer(t, "second", healthy)
+
+	proxyURL := newUpstreamTestSnooper(t, []string{first.URL, second.URL}, &UpstreamConfig{
+		Policy:      UpstreamPolicyRoundRobin,
+		HealthCheck: HealthCheckNone,
+	})
+
+	assert.Equal(t, "first", callUpstream(t, proxyURL))
+	assert.Equal(t, "second", callUpstream(t, proxyURL))
+	assert.Equal(t, "first", callUpstream(t, proxyURL))
+}
+
+func TestUpstreamFailoverOnHealthCheck(t *testing.T) {
+	primaryHealthy := &atomic.Bool{}
+	primaryHealthy.Store(true)
+
+	secondaryHealthy := &atomic.Bool{}
+	secondaryHealthy.Store(true)
+
+	primary := newUpstreamServer(t, "primary", primaryHealthy)
+	secondary := newUpstreamServer(t, "secondary", secondaryHealthy)
+
+	proxyURL := newUpstreamTestSnooper(t, []string{primary.URL, secondary.URL}, &UpstreamConfig{
+		Policy:              UpstreamPolicyFailover,
+		HealthCheck:         "/health",
+		HealthCheckInterval: 20 * time.Millisecond,
+	})
+
+	assert.Equal(t, "primary", callUpstream(t, proxyURL))
+
+	primaryHealthy.Store(false)
+
+	require.Eventually(t, func() bool {
+		return callUpstream(t, proxyURL) == "secondary"
+	}, 2*time.Second, 20*time.Millisecond)
+
+	primaryHealthy.Store(true)
+
+	require.Eventually(t, func() bool {
+		return callUpstream(t, proxyURL) == "primary"
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestUpstreamFailoverOnCallError(t *testing.T) {
+	healthy := &atomic.Bool{}
+	healthy.Store(true)
+
+	primary := newUpstreamServer(t, "primary", healthy)
+	secondary := newUpstreamServer(t, "secondary", healthy)
+
+	proxyURL := newUpstreamTestSnooper(t, []string{primary.URL, secondary.URL}, &UpstreamConfig{
+		HealthCheck:         "/health",
+		HealthCheckInterval: time.Hour,
+	})
+
+	primary.Close()
+
+	// either the initial health check or the failed call takes the primary out of
+	// rotation until its next health check
+	_, _, err := postJSON(t, proxyURL, `{}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "secondary", callUpstream(t, proxyURL))
+}
+
+func TestUpstreamStickyByClientIP(t *testing.T) {
+	pool, err := NewUpstreamPool([]string{"http://a", "http://b", "http://c"}, &UpstreamConfig{Policy: UpstreamPolicySticky}, logrus.New())
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", http.NoBody)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	selected := pool.Select(req)
+
+	req.RemoteAddr = "10.0.0.1:5678"
+	assert.Same(t, selected, pool.Select(req), "Calls from the same client IP should use the same upstream")
+
+	// an unhealthy upstream moves its clients to the next one
+	selected.healthy.Store(false)
+
+	next := pool.Select(req)
+	assert.NotSame(t, selected, next)
+	assert.True(t, next.Healthy())
+}
+
+func TestUpstreamPoolRejectsUnknownPolicy(t *testing.T) {
+	_, err := NewUpstreamPool([]string{"http://a"}, &UpstreamConfig{Policy: "random"}, logrus.New())
+	require.Error(t, err)
+}
+
+func TestUpstreamFailoverWithoutHealthCheck(t *testing.T) {
+	healthy := &atomic.Bool{}
+	healthy.Store(true)
+
+	primary := newUpstreamServer(t, "primary", healthy)
+	secondary := newUpstreamServer(t, "secondary", healthy)
+
+	primaryURL := primary.URL
+
+	proxyURL := newUpstreamTestSnooper(t, []string{primaryURL, secondary.URL}, &UpstreamConfig{
+		HealthCheck:    HealthCheckNone,
+		FailureBackoff: 200 * time.Millisecond,
+	})
+
+	primary.Close()
+
+	// the failed call takes the primary out of rotation for one back-off
+	_, _, err := postJSON(t, proxyURL, `{}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "secondary", callUpstream(t, proxyURL))
+
+	// once the back-off passed the primary is retried
+	restarted := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte("primary"))
+	}))
+
+	listener, err := net.Listen("tcp", strings.TrimPrefix(primaryURL, "http://"))
+	require.NoError(t, err)
+
+	restarted.Listener = listener
+	restarted.Start()
+	t.Cleanup(restarted.Close)
+
+	require.Eventually(t, func() bool {
+		return callUpstream(t, proxyURL) == "primary"
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestUpstreamDefaultHealthCheck(t *testing.T) {
+	newSyncingServer := func(name string, syncing bool) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			if strings.Contains(string(body), "eth_syncing") && syncing {
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"syncing"}}`))
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + name + `"}`))
+		}))
+		t.Cleanup(server.Close)
+
+		return server
+	}
+
+	primary := newSyncingServer("primary", true)
+	secondary := newSyncingServer("secondary", false)
+
+	proxyURL := newUpstreamTestSnooper(t, []string{primary.URL, secondary.URL}, &UpstreamConfig{
+		HealthCheckInterval: 20 * time.Millisecond,
+	})
+
+	// pools of multiple upstreams are checked with eth_syncing
+	require.Eventually(t, func() bool {
+		return strings.Contains(callUpstream(t, proxyURL), "secondary")
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestUpstreamFailoverOnServerError(t *testing.T) {
+	healthy := &atomic.Bool{}
+	healthy.Store(true)
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(primary.Close)
+
+	secondary := newUpstreamServer(t, "secondary", healthy)
+
+	proxyURL := newUpstreamTestSnooper(t, []string{primary.URL, secondary.URL}, &UpstreamConfig{
+		HealthCheck: HealthCheckNone,
+	})
+
+	// the 5xx response is returned to the client and takes the primary out of rotation
+	rsp, _, err := postJSON(t, proxyURL, `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, rsp.StatusCode)
+
+	assert.Equal(t, "secondary", callUpstream(t, proxyURL))
+}
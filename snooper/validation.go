@@ -0,0 +1,59 @@
+package snooper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethpandaops/rpc-snooper/metrics"
+	"github.com/ethpandaops/rpc-snooper/modules"
+	"github.com/ethpandaops/rpc-snooper/modules/builtin"
+	"github.com/ethpandaops/rpc-snooper/modules/protocol"
+	"github.com/ethpandaops/rpc-snooper/validation"
+	"github.com/sirupsen/logrus"
+)
+
+// EnableEngineValidation registers a module validating all engine_* requests and
+// responses against the embedded execution-apis schemas.
+func (s *Snooper) EnableEngineValidation() error {
+	validator, err := validation.NewEngineValidator()
+	if err != nil {
+		return fmt.Errorf("failed to load engine api schemas: %w", err)
+	}
+
+	validatorModule := builtin.NewSchemaValidator(s.moduleManager.GenerateModuleID(), validator, s.reportSchemaViolation)
+
+	if err := s.moduleManager.RegisterModuleWithInfo(validatorModule, nil, modules.ModuleInfo{Type: "schema_validator"}); err != nil {
+		return fmt.Errorf("failed to register validation module: %w", err)
+	}
+
+	s.logger.Infof("validating engine api calls (%v methods)", len(validator.Methods()))
+
+	return nil
+}
+
+// reportSchemaViolation logs, counts and broadcasts the schema violations of a call.
+func (s *Snooper) reportSchemaViolation(event *protocol.SchemaViolationEvent) {
+	logFields := logrus.Fields{
+		"callidx": event.RequestID,
+		"method":  event.JRPCMethod,
+	}
+
+	if event.BatchIndex != nil {
+		logFields["batch_index"] = *event.BatchIndex
+	}
+
+	violations := make([]string, 0, len(event.Violations))
+	for _, violation := range event.Violations {
+		violations = append(violations, fmt.Sprintf("%s: %s", violation.Pointer, violation.Message))
+	}
+
+	logFields["violations"] = strings.Join(violations, "; ")
+
+	s.logger.WithFields(logFields).Warnf("SCHEMA #%v: %v %v violates the engine api schema", event.RequestID, event.JRPCMethod, event.Direction)
+
+	if s.metricsEnabled {
+		metrics.SchemaViolationRegister(event.JRPCMethod, event.Direction, len(event.Violations))
+	}
+
+	s.moduleManager.BroadcastEvent("schema_violation", event)
+}
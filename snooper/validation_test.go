@@ -0,0 +1,40 @@
+package snooper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineValidationReportsViolations(t *testing.T) {
+	snooper, proxyURL, hook := newTestSnooper(t, jsonHandler(`{"jsonrpc":"2.0","id":1,"result":{"status":"VALID","latestValidHash":"0x1234"}}`))
+
+	require.NoError(t, snooper.EnableEngineValidation())
+
+	_, _, err := postJSON(t, proxyURL, `{"jsonrpc":"2.0","method":"engine_newPayloadV1","params":[{"blockNumber":"0x1"}],"id":1}`)
+	require.NoError(t, err)
+
+	var violations []*logrus.Entry
+
+	require.Eventually(t, func() bool {
+		violations = violations[:0]
+
+		for _, entry := range hook.Entries() {
+			if _, ok := entry.Data["violations"]; ok {
+				violations = append(violations, entry)
+			}
+		}
+
+		return len(violations) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "engine_newPayloadV1", violations[0].Data["method"])
+	assert.Contains(t, violations[0].Message, "request violates")
+	assert.Contains(t, violations[0].Data["violations"], "/params/0/parentHash: required field is missing")
+
+	assert.Contains(t, violations[1].Message, "response violates")
+	assert.Equal(t, "/result/latestValidHash: value \"0x1234\" does not match 32 byte hex value or null", violations[1].Data["violations"])
+}
@@ -0,0 +1,566 @@
+package snooper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/fatih/color"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// wsHandshakeTimeout is the timeout for the upstream websocket handshake.
+const wsHandshakeTimeout = 30 * time.Second
+
+// wsSkipHeaders are request headers that are managed by the websocket dialer
+// and must not be copied to the upstream handshake request.
+var wsSkipHeaders = map[string]bool{
+	"Upgrade":                  true,
+	"Connection":               true,
+	"Sec-Websocket-Key":        true,
+	"Sec-Websocket-Version":    true,
+	"Sec-Websocket-Extensions": true,
+	"Sec-Websocket-Protocol":   true,
+}
+
+// wsRPCEnvelope holds the JSON-RPC fields needed to correlate websocket frames.
+type wsRPCEnvelope struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Params json.RawMessage `json:"params"`
+}
+
+// wsPendingCall is a client frame that is awaiting its response from upstream.
+type wsPendingCall struct {
+	callCtx     *ProxyCallContext
+	sentAt      time.Time
+	subscribe   bool
+	subscribed  bool
+	unsubscribe string
+	remaining   int
+}
+
+// wsProxySession relays frames between a client and an upstream websocket connection.
+// Every client frame gets its own call context. Upstream responses are correlated
+// to their request by JSON-RPC id, and subscription notifications are correlated
+// to the eth_subscribe call that created the subscription. Calls of eth_subscribe
+// frames are bound to the session instead of the call timeout, so they stay alive
+// for the notifications of their subscription.
+type wsProxySession struct {
+	snooper      *Snooper
+	req          *http.Request
+	upstream     *Upstream
+	clientConn   *websocket.Conn
+	upstreamConn *websocket.Conn
+	header       http.Header
+	ctx          context.Context
+	cancelFn     context.CancelFunc
+
+	mu            sync.Mutex
+	pending       map[string]*wsPendingCall
+	subscriptions map[string]*ProxyCallContext
+}
+
+func (s *Snooper) processWebSocketProxyCall(w http.ResponseWriter, r *http.Request) error {
+	upstream := s.upstreams.Select(r)
+
+	upstreamURL, err := s.getWebSocketTargetURL(upstream, r)
+	if err != nil {
+		return fmt.Errorf("error parsing websocket proxy url: %w", err)
+	}
+
+	// pass all headers except the ones managed by the websocket handshake
+	hh := http.Header{}
+
+	for hk, hvs := range r.Header {
+		if wsSkipHeaders[http.CanonicalHeaderKey(hk)] {
+			continue
+		}
+
+		for _, hv := range hvs {
+			hh.Add(hk, hv)
+		}
+	}
+
+	proxyIPChain := []string{}
+
+	if forwaredFor := r.Header.Get("X-Forwarded-For"); forwaredFor != "" {
+		proxyIPChain = strings.Split(forwaredFor, ", ")
+	}
+
+	proxyIPChain = append(proxyIPChain, r.RemoteAddr)
+	hh.Set("X-Forwarded-For", strings.Join(proxyIPChain, ", "))
+
+	if err := s.resignJWT(hh); err != nil {
+		return err
+	}
+
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: wsHandshakeTimeout,
+		Subprotocols:     websocket.Subprotocols(r),
+	}
+
+	upstreamConn, upstreamRsp, err := dialer.DialContext(r.Context(), upstreamURL.String(), hh)
+	if err != nil {
+		if upstreamRsp != nil {
+			upstreamRsp.Body.Close()
+			return fmt.Errorf("websocket proxy dial error (status %v): %w", upstreamRsp.StatusCode, err)
+		}
+
+		return fmt.Errorf("websocket proxy dial error: %w", err)
+	}
+
+	respHeader := http.Header{}
+	if subprotocol := upstreamConn.Subprotocol(); subprotocol != "" {
+		respHeader.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(_ *http.Request) bool {
+			return true
+		},
+	}
+
+	clientConn, err := upgrader.Upgrade(w, r, respHeader)
+	if err != nil {
+		// the upgrader already replied with an error status
+		upstreamConn.Close()
+		s.logger.Warnf("websocket upgrade failed: %v", err)
+
+		return nil
+	}
+
+	sessionCtx, cancelSession := context.WithCancel(r.Context())
+
+	session := &wsProxySession{
+		snooper:       s,
+		req:           r,
+		upstream:      upstream,
+		clientConn:    clientConn,
+		upstreamConn:  upstreamConn,
+		header:        upstreamRsp.Header,
+		ctx:           sessionCtx,
+		cancelFn:      cancelSession,
+		pending:       make(map[string]*wsPendingCall),
+		subscriptions: make(map[string]*ProxyCallContext),
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"remote": r.RemoteAddr,
+		"target": upstreamURL.String(),
+	}).Infof("WS-CONNECT %v", r.URL.String())
+
+	session.run()
+
+	s.logger.WithField("remote", r.RemoteAddr).Infof("WS-DISCONNECT %v", r.URL.String())
+
+	return nil
+}
+
+// getWebSocketTargetURL builds the upstream websocket url for a proxied upgrade request.
+func (s *Snooper) getWebSocketTargetURL(upstream *Upstream, r *http.Request) (*url.URL, error) {
+	queryArgs := ""
+	if r.URL.RawQuery != "" {
+		queryArgs = fmt.Sprintf("?%s", r.URL.RawQuery)
+	}
+
+	targetURL, err := url.Parse(fmt.Sprintf("%s%s%s", upstream.URL, r.URL.EscapedPath(), queryArgs))
+	if err != nil {
+		return nil, err
+	}
+
+	switch targetURL.Scheme {
+	case "https", "wss":
+		targetURL.Scheme = "wss"
+	default:
+		targetURL.Scheme = "ws"
+	}
+
+	return targetURL, nil
+}
+
+func (sess *wsProxySession) run() {
+	done := make(chan struct{}, 2)
+
+	go func() {
+		sess.relay(sess.clientConn, sess.upstreamConn, sess.handleClientFrame)
+		done <- struct{}{}
+	}()
+
+	go func() {
+		sess.relay(sess.upstreamConn, sess.clientConn, sess.handleUpstreamFrame)
+		done <- struct{}{}
+	}()
+
+	go sess.expirePendingLoop()
+
+	// Closing both connections unblocks the other relay direction.
+	<-done
+	sess.clientConn.Close()
+	sess.upstreamConn.Close()
+	<-done
+
+	// cancels the contexts of all pending calls and subscriptions
+	sess.cancelFn()
+}
+
+// expirePendingLoop periodically drops the pending calls of the session that got no
+// response within the call timeout.
+func (sess *wsProxySession) expirePendingLoop() {
+	ticker := time.NewTicker(sess.snooper.CallTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sess.ctx.Done():
+			return
+		case now := <-ticker.C:
+			sess.expirePending(now)
+		}
+	}
+}
+
+// expirePending drops the pending calls sent before the call timeout. Their contexts are
+// cancelled, unless they belong to an established subscription, and late responses
+// are logged as uncorrelated frames.
+func (sess *wsProxySession) expirePending(now time.Time) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	for id, pending := range sess.pending {
+		if now.Sub(pending.sentAt) < sess.snooper.CallTimeout {
+			continue
+		}
+
+		delete(sess.pending, id)
+
+		if !pending.subscribed {
+			pending.callCtx.cancelFn()
+		}
+	}
+}
+
+// relay copies frames from src to dst until either side fails. Each frame is
+// passed to handleFrame right before it is forwarded.
+func (sess *wsProxySession) relay(src, dst *websocket.Conn, handleFrame func(messageType int, data []byte, timestamp time.Time)) {
+	for {
+		messageType, data, err := src.ReadMessage()
+		if err != nil {
+			closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+
+			if closeErr, ok := err.(*websocket.CloseError); ok && closeErr.Code != websocket.CloseNoStatusReceived {
+				closeMsg = websocket.FormatCloseMessage(closeErr.Code, closeErr.Text)
+			}
+
+			_ = dst.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+
+			return
+		}
+
+		// correlate before forwarding, so a fast response can't overtake its request
+		handleFrame(messageType, data, time.Now())
+
+		if err := dst.WriteMessage(messageType, data); err != nil {
+			sess.snooper.logger.Warnf("websocket relay error: %v", err)
+			return
+		}
+	}
+}
+
+// handleClientFrame creates a call context for a client frame and registers
+// all contained JSON-RPC ids for response correlation.
+func (sess *wsProxySession) handleClientFrame(messageType int, data []byte, timestamp time.Time) {
+	pending := &wsPendingCall{
+		sentAt: timestamp,
+	}
+	ids := []string{}
+
+	for _, envelope := range parseWSEnvelopes(messageType, data) {
+		if len(envelope.ID) == 0 || envelope.Method == "" {
+			continue
+		}
+
+		switch envelope.Method {
+		case "eth_subscribe":
+			pending.subscribe = true
+		case "eth_unsubscribe":
+			var params []string
+			if err := json.Unmarshal(envelope.Params, &params); err == nil && len(params) > 0 {
+				pending.unsubscribe = params[0]
+			}
+		}
+
+		ids = append(ids, string(envelope.ID))
+	}
+
+	timeout := sess.snooper.CallTimeout
+	if pending.subscribe {
+		// ended by eth_unsubscribe, the end of the session or if the call expires
+		timeout = 0
+	}
+
+	callCtx := sess.snooper.newProxyCallContext(sess.ctx, timeout)
+	callCtx.upstream = sess.upstream
+	pending.callCtx = callCtx
+	pending.remaining = len(ids)
+
+	sess.mu.Lock()
+
+	for _, id := range ids {
+		sess.pending[id] = pending
+	}
+
+	correlated := len(ids) > 0
+
+	sess.mu.Unlock()
+
+	go func() {
+		sess.snooper.logWebSocketRequest(callCtx, sess.req, messageType, data)
+		close(callCtx.reqSentChan)
+
+		if !correlated {
+			// nothing to correlate (e.g. notification or binary frame)
+			callCtx.cancelFn()
+		}
+	}()
+}
+
+// handleUpstreamFrame correlates an upstream frame with its originating call.
+func (sess *wsProxySession) handleUpstreamFrame(messageType int, data []byte, timestamp time.Time) {
+	envelopes := parseWSEnvelopes(messageType, data)
+
+	var (
+		pending *wsPendingCall
+		subCtx  *ProxyCallContext
+		done    bool
+	)
+
+	sess.mu.Lock()
+
+	for _, envelope := range envelopes {
+		if len(envelope.ID) > 0 && envelope.Method == "" {
+			call, exists := sess.pending[string(envelope.ID)]
+			if !exists {
+				continue
+			}
+
+			delete(sess.pending, string(envelope.ID))
+
+			call.remaining--
+			pending = call
+			done = call.remaining == 0
+
+			if call.subscribe {
+				var subID string
+				if err := json.Unmarshal(envelope.Result, &subID); err == nil && subID != "" {
+					sess.subscriptions[subID] = call.callCtx
+					call.subscribed = true
+				}
+			}
+
+			if call.unsubscribe != "" {
+				if unsubCtx, exists := sess.subscriptions[call.unsubscribe]; exists {
+					delete(sess.subscriptions, call.unsubscribe)
+					unsubCtx.cancelFn()
+				}
+			}
+		} else if strings.HasSuffix(envelope.Method, "_subscription") {
+			var params struct {
+				Subscription string `json:"subscription"`
+			}
+
+			if err := json.Unmarshal(envelope.Params, &params); err == nil {
+				subCtx = sess.subscriptions[params.Subscription]
+			}
+		}
+	}
+
+	sess.mu.Unlock()
+
+	switch {
+	case pending != nil:
+		callCtx := pending.callCtx
+		callCtx.callDuration = timestamp.Sub(pending.sentAt)
+		keepAlive := pending.subscribed
+
+		go func() {
+			<-callCtx.reqSentChan
+			sess.snooper.logWebSocketResponse(callCtx, sess.req, sess.header, messageType, data)
+
+			if done && !keepAlive {
+				callCtx.cancelFn()
+			}
+		}()
+	case subCtx != nil:
+		go sess.snooper.logWebSocketEvent(subCtx, sess.req, sess.header, messageType, data)
+	default:
+		// uncorrelated upstream frame, log it with its own call context
+		callCtx := sess.snooper.newProxyCallContext(sess.ctx, sess.snooper.CallTimeout)
+		callCtx.upstream = sess.upstream
+
+		go func() {
+			defer callCtx.cancelFn()
+			sess.snooper.logWebSocketEvent(callCtx, sess.req, sess.header, messageType, data)
+		}()
+	}
+}
+
+// parseWSEnvelopes extracts the JSON-RPC envelope(s) of a text frame.
+// Batches are returned as multiple envelopes.
+func parseWSEnvelopes(messageType int, data []byte) []wsRPCEnvelope {
+	if messageType != websocket.TextMessage {
+		return nil
+	}
+
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 {
+		return nil
+	}
+
+	if trimmed[0] == '[' {
+		var envelopes []wsRPCEnvelope
+		if err := json.Unmarshal(trimmed, &envelopes); err != nil {
+			return nil
+		}
+
+		return envelopes
+	}
+
+	var envelope wsRPCEnvelope
+	if err := json.Unmarshal(trimmed, &envelope); err != nil {
+		return nil
+	}
+
+	return []wsRPCEnvelope{envelope}
+}
+
+// wsFrameContentType returns the content type used for logging and modules of a websocket frame.
+func wsFrameContentType(messageType int) string {
+	if messageType == websocket.BinaryMessage {
+		return "application/octet-stream"
+	}
+
+	return "application/json"
+}
+
+func (s *Snooper) logWebSocketRequest(ctx *ProxyCallContext, req *http.Request, messageType int, bodyData []byte) {
+	seq := s.orderedProcessor.GetNextSequence()
+	defer s.orderedProcessor.CompleteSequence(seq)
+
+	if !s.orderedProcessor.WaitForSequence(seq) {
+		return
+	}
+
+	contentType := wsFrameContentType(messageType)
+	logFields := logrus.Fields{
+		"color":  color.FgCyan,
+		"length": len(bodyData),
+	}
+
+	s.addJSONLogFields(ctx, logFields, "request", req, len(bodyData))
+
+	bodyData, parsedData := s.decodeBodyForLog(logFields, bodyData, contentType, int64(len(bodyData)), nil)
+
+	ctx.SetData(0, "request_size", len(bodyData))
+	s.extractJSONRPCMethods(ctx, logFields, parsedData)
+
+	s.processRequestModules(ctx, req, bodyData, parsedData, contentType)
+	s.logger.WithFields(logFields).Infof("WS-REQUEST #%v: %v", ctx.callIndex, req.URL.String())
+}
+
+func (s *Snooper) logWebSocketResponse(ctx *ProxyCallContext, req *http.Request, header http.Header, messageType int, bodyData []byte) {
+	seq := s.orderedProcessor.GetNextSequence()
+	defer s.orderedProcessor.CompleteSequence(seq)
+
+	if !s.orderedProcessor.WaitForSequence(seq) {
+		return
+	}
+
+	contentType := wsFrameContentType(messageType)
+	logFields := logrus.Fields{
+		"color":  color.FgGreen,
+		"length": len(bodyData),
+	}
+
+	s.addJSONLogFields(ctx, logFields, "response", req, len(bodyData))
+
+	bodyData, parsedData := s.decodeBodyForLog(logFields, bodyData, contentType, int64(len(bodyData)), nil)
+
+	if d := ctx.CallDuration(); d > 0 {
+		logFields["duration_ms"] = d.Milliseconds()
+	}
+
+	// websocket frames have no status of their own, report them as successful responses
+	frameRsp := &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        header,
+		ContentLength: int64(len(bodyData)),
+	}
+
+	s.processResponseModules(ctx, req, frameRsp, bodyData, parsedData, contentType)
+	s.logger.WithFields(logFields).Infof("WS-RESPONSE #%v: %v", ctx.callIndex, req.URL.String())
+}
+
+func (s *Snooper) logWebSocketEvent(ctx *ProxyCallContext, req *http.Request, header http.Header, messageType int, bodyData []byte) {
+	seq := s.orderedProcessor.GetNextSequence()
+	defer s.orderedProcessor.CompleteSequence(seq)
+
+	if !s.orderedProcessor.WaitForSequence(seq) {
+		return
+	}
+
+	contentType := wsFrameContentType(messageType)
+	logFields := logrus.Fields{
+		"color":  color.FgGreen,
+		"length": len(bodyData),
+	}
+
+	s.addJSONLogFields(ctx, logFields, "event", req, len(bodyData))
+
+	bodyData, parsedData := s.decodeBodyForLog(logFields, bodyData, contentType, int64(len(bodyData)), nil)
+
+	s.processWebSocketEventModules(ctx, header, bodyData, parsedData, contentType)
+	s.logger.WithFields(logFields).Infof("WS-EVENT #%v: %v", ctx.callIndex, req.URL.String())
+}
+
+// processWebSocketEventModules processes subscription notifications through modules.
+// The call context is the one of the originating eth_subscribe call.
+func (s *Snooper) processWebSocketEventModules(ctx *ProxyCallContext, header http.Header, bodyData []byte, parsedData interface{}, contentType string) {
+	if s.moduleManager == nil || !s.moduleManager.IsEnabled() {
+		return
+	}
+
+	var bodyForModules interface{}
+	if parsedData != nil {
+		bodyForModules = parsedData
+	} else {
+		bodyForModules = bodyData
+	}
+
+	respCtx := &types.ResponseContext{
+		CallCtx:     ctx,
+		StatusCode:  http.StatusOK,
+		Headers:     header,
+		Body:        bodyForModules,
+		BodyBytes:   bodyData,
+		ContentType: contentType,
+		Timestamp:   time.Now(),
+		Request:     ctx.requestCtx,
+	}
+
+	// Process through modules (non-modifying, observation only)
+	_, err := s.moduleManager.ProcessResponse(respCtx)
+	if err != nil {
+		s.logger.WithError(err).Warn("Module processing failed for websocket event")
+	}
+}
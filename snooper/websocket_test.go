@@ -0,0 +1,261 @@
+package snooper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethpandaops/rpc-snooper/types"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsCapturingModule is a test module that records the call ids and bodies it observes.
+type wsCapturingModule struct {
+	id        uint64
+	mu        sync.Mutex
+	requests  map[string]uint64
+	responses []wsCapturedResponse
+}
+
+type wsCapturedResponse struct {
+	callID uint64
+	body   any
+	ctxErr error
+}
+
+func (m *wsCapturingModule) ID() uint64 { return m.id }
+
+func (m *wsCapturingModule) OnRequest(ctx *types.RequestContext) (*types.RequestContext, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if body, ok := ctx.Body.(map[string]any); ok {
+		if method, ok := body["method"].(string); ok {
+			m.requests[method] = ctx.CallCtx.ID()
+		}
+	}
+
+	return ctx, nil
+}
+
+func (m *wsCapturingModule) OnResponse(ctx *types.ResponseContext) (*types.ResponseContext, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.responses = append(m.responses, wsCapturedResponse{
+		callID: ctx.CallCtx.ID(),
+		body:   ctx.Body,
+		ctxErr: ctx.CallCtx.Context().Err(),
+	})
+
+	return ctx, nil
+}
+
+func (m *wsCapturingModule) Configure(_ map[string]any) error { return nil }
+func (m *wsCapturingModule) Close() error                     { return nil }
+
+// newWebSocketUpstream starts an upstream JSON-RPC websocket server that answers
+// eth_subscribe with a subscription id followed by a single notification.
+func newWebSocketUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			var req struct {
+				ID     json.RawMessage `json:"id"`
+				Method string          `json:"method"`
+			}
+
+			if err := json.Unmarshal(data, &req); err != nil {
+				return
+			}
+
+			switch req.Method {
+			case "eth_subscribe":
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":`+string(req.ID)+`,"result":"0xabc"}`))
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xabc","result":{"number":"0x1"}}}`))
+			default:
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":`+string(req.ID)+`,"result":"0x1234"}`))
+			}
+		}
+	}))
+}
+
+// TestWebSocketProxyRelaysFrames verifies that websocket frames are relayed in both
+// directions and that subscription notifications are tied to their eth_subscribe call.
+func TestWebSocketProxyRelaysFrames(t *testing.T) {
+	upstream := newWebSocketUpstream(t)
+	defer upstream.Close()
+
+	snooper, proxyURL, _ := newTargetTestSnooper(t, upstream.URL)
+
+	testModule := &wsCapturingModule{
+		id:       snooper.moduleManager.GenerateModuleID(),
+		requests: make(map[string]uint64),
+	}
+
+	err := snooper.moduleManager.RegisterModule(testModule, nil)
+	require.NoError(t, err)
+
+	conn, rsp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(proxyURL, "http"), nil)
+	require.NoError(t, err)
+
+	defer rsp.Body.Close()
+	defer conn.Close()
+
+	// plain request/response
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)))
+
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1234"}`, string(data))
+
+	// subscription with notification
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"eth_subscribe","params":["newHeads"],"id":2}`)))
+
+	_, data, err = conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":"0xabc"}`, string(data))
+
+	_, data, err = conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "eth_subscription")
+
+	// Wait for async logging and module processing to complete
+	require.Eventually(t, func() bool {
+		testModule.mu.Lock()
+		defer testModule.mu.Unlock()
+
+		return len(testModule.requests) == 2 && len(testModule.responses) == 3
+	}, 2*time.Second, 10*time.Millisecond)
+
+	testModule.mu.Lock()
+	defer testModule.mu.Unlock()
+
+	subscribeCallID := testModule.requests["eth_subscribe"]
+	assert.NotEqual(t, testModule.requests["eth_blockNumber"], subscribeCallID)
+
+	notifications := 0
+
+	for _, response := range testModule.responses {
+		body, ok := response.body.(map[string]any)
+		require.True(t, ok)
+
+		if body["method"] == "eth_subscription" {
+			notifications++
+
+			assert.Equal(t, subscribeCallID, response.callID, "Notification should be tied to the eth_subscribe call")
+		}
+	}
+
+	assert.Equal(t, 1, notifications)
+}
+
+// TestWebSocketCallTimeouts verifies that subscriptions outlive the call timeout, while
+// calls without response within the call timeout are dropped from correlation.
+func TestWebSocketCallTimeouts(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			var req struct {
+				ID     json.RawMessage `json:"id"`
+				Method string          `json:"method"`
+			}
+
+			if err := json.Unmarshal(data, &req); err != nil {
+				return
+			}
+
+			// notifications and other responses are sent after the call timeout
+			switch req.Method {
+			case "eth_subscribe":
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":`+string(req.ID)+`,"result":"0xabc"}`))
+
+				time.Sleep(200 * time.Millisecond)
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xabc","result":{"number":"0x1"}}}`))
+			default:
+				time.Sleep(200 * time.Millisecond)
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":`+string(req.ID)+`,"result":"0x1234"}`))
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	snooper, proxyURL, _ := newTargetTestSnooper(t, upstream.URL)
+	snooper.CallTimeout = 50 * time.Millisecond
+
+	testModule := &wsCapturingModule{
+		id:       snooper.moduleManager.GenerateModuleID(),
+		requests: make(map[string]uint64),
+	}
+
+	require.NoError(t, snooper.moduleManager.RegisterModule(testModule, nil))
+
+	conn, rsp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(proxyURL, "http"), nil)
+	require.NoError(t, err)
+
+	defer rsp.Body.Close()
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"eth_subscribe","params":["newHeads"],"id":2}`)))
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)))
+
+	for range 3 {
+		_, _, err = conn.ReadMessage()
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		testModule.mu.Lock()
+		defer testModule.mu.Unlock()
+
+		return len(testModule.requests) == 2 && len(testModule.responses) == 3
+	}, 2*time.Second, 10*time.Millisecond)
+
+	testModule.mu.Lock()
+	defer testModule.mu.Unlock()
+
+	for _, response := range testModule.responses {
+		body, ok := response.body.(map[string]any)
+		require.True(t, ok)
+
+		switch {
+		case body["id"] == 1.0:
+			assert.NotEqual(t, testModule.requests["eth_blockNumber"], response.callID, "Expired calls must not be correlated")
+		case body["method"] == "eth_subscription":
+			assert.Equal(t, testModule.requests["eth_subscribe"], response.callID)
+			assert.NoError(t, response.ctxErr, "Subscriptions must outlive the call timeout")
+		}
+	}
+}
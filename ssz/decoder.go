@@ -0,0 +1,198 @@
+package ssz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	apiv1deneb "github.com/attestantio/go-eth2-client/api/v1/deneb"
+	apiv1electra "github.com/attestantio/go-eth2-client/api/v1/electra"
+	apiv1fulu "github.com/attestantio/go-eth2-client/api/v1/fulu"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/fulu"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+)
+
+// ErrUnknownContainer is returned for calls without a known SSZ container.
+var ErrUnknownContainer = errors.New("unknown ssz container")
+
+// ConsensusVersionHeader is the beacon API header carrying the fork of SSZ bodies.
+const ConsensusVersionHeader = "Eth-Consensus-Version"
+
+type object interface {
+	UnmarshalSSZ(buf []byte) error
+}
+
+type fixedSizeObject interface {
+	object
+	SizeSSZ() int
+}
+
+type forkContainers map[string]func() object
+
+// signedBeaconBlocks are the SignedBeaconBlock containers by fork. Fulu did not
+// change the block, so it shares the electra container.
+var signedBeaconBlocks = forkContainers{
+	"phase0":    func() object { return &phase0.SignedBeaconBlock{} },
+	"altair":    func() object { return &altair.SignedBeaconBlock{} },
+	"bellatrix": func() object { return &bellatrix.SignedBeaconBlock{} },
+	"capella":   func() object { return &capella.SignedBeaconBlock{} },
+	"deneb":     func() object { return &deneb.SignedBeaconBlock{} },
+	"electra":   func() object { return &electra.SignedBeaconBlock{} },
+	"fulu":      func() object { return &electra.SignedBeaconBlock{} },
+}
+
+// signedBlockContents are the containers submitted to the block publishing endpoints.
+// Since deneb, the signed block is published together with its blobs and proofs.
+var signedBlockContents = forkContainers{
+	"phase0":    func() object { return &phase0.SignedBeaconBlock{} },
+	"altair":    func() object { return &altair.SignedBeaconBlock{} },
+	"bellatrix": func() object { return &bellatrix.SignedBeaconBlock{} },
+	"capella":   func() object { return &capella.SignedBeaconBlock{} },
+	"deneb":     func() object { return &apiv1deneb.SignedBlockContents{} },
+	"electra":   func() object { return &apiv1electra.SignedBlockContents{} },
+	"fulu":      func() object { return &apiv1fulu.SignedBlockContents{} },
+}
+
+var beaconStates = forkContainers{
+	"phase0":    func() object { return &phase0.BeaconState{} },
+	"altair":    func() object { return &altair.BeaconState{} },
+	"bellatrix": func() object { return &bellatrix.BeaconState{} },
+	"capella":   func() object { return &capella.BeaconState{} },
+	"deneb":     func() object { return &deneb.BeaconState{} },
+	"electra":   func() object { return &electra.BeaconState{} },
+	"fulu":      func() object { return &fulu.BeaconState{} },
+}
+
+type route struct {
+	method   string
+	segments []string
+	response bool
+	decode   func(version string, data []byte) (any, error)
+}
+
+// Decoder decodes SSZ encoded beacon API bodies into the JSON representation of the
+// beacon API. Containers are selected by the HTTP method and path of the call and the
+// fork given in the Eth-Consensus-Version header.
+type Decoder struct {
+	routes []*route
+}
+
+// NewDecoder creates a Decoder for the known beacon API endpoints.
+func NewDecoder() *Decoder {
+	d := &Decoder{}
+
+	d.addRoute(http.MethodGet, "/eth/v2/beacon/blocks/*", true, decodeVersioned(signedBeaconBlocks))
+	d.addRoute(http.MethodPost, "/eth/v1/beacon/blocks", false, decodeVersioned(signedBlockContents))
+	d.addRoute(http.MethodPost, "/eth/v2/beacon/blocks", false, decodeVersioned(signedBlockContents))
+	d.addRoute(http.MethodGet, "/eth/v2/debug/beacon/states/*", true, decodeVersioned(beaconStates))
+	d.addRoute(http.MethodGet, "/eth/v1/beacon/blob_sidecars/*", true, decodeList(func() fixedSizeObject { return &deneb.BlobSidecar{} }))
+
+	return d
+}
+
+func (d *Decoder) addRoute(method, path string, response bool, decode func(version string, data []byte) (any, error)) {
+	d.routes = append(d.routes, &route{
+		method:   method,
+		segments: strings.Split(strings.Trim(path, "/"), "/"),
+		response: response,
+		decode:   decode,
+	})
+}
+
+// Decode decodes the request (or response) body of a call to the given path. Returns
+// ErrUnknownContainer if the body of the call is not a known container.
+func (d *Decoder) Decode(method, path, version string, response bool, data []byte) (any, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for _, r := range d.routes {
+		if r.method != method || r.response != response || !matchSegments(r.segments, segments) {
+			continue
+		}
+
+		return r.decode(strings.ToLower(version), data)
+	}
+
+	return nil, ErrUnknownContainer
+}
+
+// matchSegments matches path segments against a pattern, where "*" matches any segment.
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) != len(segments) {
+		return false
+	}
+
+	for idx, segment := range pattern {
+		if segment != "*" && segment != segments[idx] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func decodeVersioned(containers forkContainers) func(version string, data []byte) (any, error) {
+	return func(version string, data []byte) (any, error) {
+		newObject, ok := containers[version]
+		if !ok {
+			return nil, fmt.Errorf("unsupported consensus version %q", version)
+		}
+
+		obj := newObject()
+		if err := obj.UnmarshalSSZ(data); err != nil {
+			return nil, err
+		}
+
+		return toJSONValue(obj)
+	}
+}
+
+// decodeList decodes a list of fixed size containers, which SSZ encodes back to back.
+func decodeList(newObject func() fixedSizeObject) func(version string, data []byte) (any, error) {
+	return func(_ string, data []byte) (any, error) {
+		size := newObject().SizeSSZ()
+		if len(data)%size != 0 {
+			return nil, fmt.Errorf("list length %d is not a multiple of the element size %d", len(data), size)
+		}
+
+		list := make([]any, 0, len(data)/size)
+
+		for offset := 0; offset < len(data); offset += size {
+			obj := newObject()
+			if err := obj.UnmarshalSSZ(data[offset : offset+size]); err != nil {
+				return nil, fmt.Errorf("element %d: %w", len(list), err)
+			}
+
+			value, err := toJSONValue(obj)
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, value)
+		}
+
+		return list, nil
+	}
+}
+
+// toJSONValue converts a container to the generic JSON value of its beacon API JSON
+// encoding, as seen by filters and modules for JSON bodies.
+func toJSONValue(obj any) (any, error) {
+	encoded, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,144 @@
+package ssz
+
+import (
+	"encoding/binary"
+	"net/http"
+	"testing"
+
+	apiv1fulu "github.com/attestantio/go-eth2-client/api/v1/fulu"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/fulu"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSignedBeaconBlock(t *testing.T) []byte {
+	t.Helper()
+
+	block := &phase0.SignedBeaconBlock{
+		Message: &phase0.BeaconBlock{
+			Slot:          123,
+			ProposerIndex: 7,
+			ParentRoot:    phase0.Root{0x01},
+			StateRoot:     phase0.Root{0x02},
+			Body: &phase0.BeaconBlockBody{
+				ETH1Data: &phase0.ETH1Data{
+					BlockHash: make([]byte, 32),
+				},
+				Graffiti: [32]byte{0x73, 0x6e, 0x6f, 0x6f, 0x70},
+			},
+		},
+	}
+
+	data, err := block.MarshalSSZ()
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestDecodeSignedBeaconBlock(t *testing.T) {
+	decoder := NewDecoder()
+	data := testSignedBeaconBlock(t)
+
+	value, err := decoder.Decode(http.MethodGet, "/eth/v2/beacon/blocks/head", "phase0", true, data)
+	require.NoError(t, err)
+
+	block, ok := value.(map[string]any)
+	require.True(t, ok)
+
+	message, ok := block["message"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "123", message["slot"])
+	assert.Equal(t, "7", message["proposer_index"])
+	assert.Equal(t, "0x0100000000000000000000000000000000000000000000000000000000000000", message["parent_root"])
+
+	// the consensus version header is case insensitive
+	_, err = decoder.Decode(http.MethodGet, "/eth/v2/beacon/blocks/0x01", "PHASE0", true, data)
+	require.NoError(t, err)
+
+	// published blocks are decoded from the request body
+	_, err = decoder.Decode(http.MethodPost, "/eth/v2/beacon/blocks", "phase0", false, data)
+	require.NoError(t, err)
+}
+
+func TestDecodeUnknownContainer(t *testing.T) {
+	decoder := NewDecoder()
+	data := testSignedBeaconBlock(t)
+
+	tests := []struct {
+		method   string
+		path     string
+		response bool
+	}{
+		{http.MethodGet, "/eth/v1/node/version", true},
+		{http.MethodGet, "/eth/v2/beacon/blocks/head", false},
+		{http.MethodGet, "/eth/v2/beacon/blocks/head/root", true},
+		{http.MethodPost, "/eth/v2/beacon/blocks", true},
+	}
+
+	for _, tt := range tests {
+		_, err := decoder.Decode(tt.method, tt.path, "phase0", tt.response, data)
+		require.ErrorIs(t, err, ErrUnknownContainer, "%v %v", tt.method, tt.path)
+	}
+
+	// known container, but no or an unknown fork
+	_, err := decoder.Decode(http.MethodGet, "/eth/v2/beacon/blocks/head", "", true, data)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrUnknownContainer)
+
+	_, err = decoder.Decode(http.MethodGet, "/eth/v2/beacon/blocks/head", "gloas", true, data)
+	require.Error(t, err)
+}
+
+func TestDecodeForkContainers(t *testing.T) {
+	// block contents and states are known for every fork with a known block
+	for version := range signedBeaconBlocks {
+		assert.Contains(t, signedBlockContents, version)
+		assert.Contains(t, beaconStates, version)
+	}
+
+	assert.IsType(t, &apiv1fulu.SignedBlockContents{}, signedBlockContents["fulu"]())
+	assert.IsType(t, &fulu.BeaconState{}, beaconStates["fulu"]())
+
+	// fulu bodies are decoded, so invalid ones fail to unmarshal
+	decoder := NewDecoder()
+
+	for _, call := range []struct {
+		method   string
+		path     string
+		response bool
+	}{
+		{http.MethodGet, "/eth/v2/beacon/blocks/head", true},
+		{http.MethodPost, "/eth/v2/beacon/blocks", false},
+		{http.MethodGet, "/eth/v2/debug/beacon/states/head", true},
+	} {
+		_, err := decoder.Decode(call.method, call.path, "fulu", call.response, []byte{0x01})
+		require.Error(t, err, "%v %v", call.method, call.path)
+		assert.NotContains(t, err.Error(), "unsupported consensus version", "%v %v", call.method, call.path)
+	}
+}
+
+func TestDecodeBlobSidecars(t *testing.T) {
+	decoder := NewDecoder()
+	size := (&deneb.BlobSidecar{}).SizeSSZ()
+
+	data := make([]byte, 2*size)
+	binary.LittleEndian.PutUint64(data[size:], 1)
+
+	value, err := decoder.Decode(http.MethodGet, "/eth/v1/beacon/blob_sidecars/head", "deneb", true, data)
+	require.NoError(t, err)
+
+	sidecars, ok := value.([]any)
+	require.True(t, ok)
+	require.Len(t, sidecars, 2)
+	assert.Equal(t, "0", sidecars[0].(map[string]any)["index"])
+	assert.Equal(t, "1", sidecars[1].(map[string]any)["index"])
+
+	value, err = decoder.Decode(http.MethodGet, "/eth/v1/beacon/blob_sidecars/head", "deneb", true, []byte{})
+	require.NoError(t, err)
+	assert.Empty(t, value)
+
+	_, err = decoder.Decode(http.MethodGet, "/eth/v1/beacon/blob_sidecars/head", "deneb", true, data[:size+1])
+	require.Error(t, err)
+}
@@ -2,6 +2,7 @@ package types
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"time"
@@ -26,6 +27,13 @@ type RequestContext struct {
 	BodyBytes   []byte
 	ContentType string
 	Timestamp   time.Time
+
+	// Response is set by intercepting modules to answer the call with a synthetic
+	// response instead of forwarding it to the target.
+	Response *SyntheticResponse
+	// Drop is set by intercepting modules to close the client connection without
+	// forwarding the call.
+	Drop bool
 }
 
 type ResponseContext struct {
@@ -37,12 +45,75 @@ type ResponseContext struct {
 	ContentType string
 	Timestamp   time.Time
 	Duration    time.Duration
+
+	// Request is the context of the originating request, nil if the request was not
+	// processed by modules.
+	Request *RequestContext
+
+	// Drop is set by intercepting modules to close the client connection instead
+	// of returning the response.
+	Drop bool
+}
+
+// CallDocument returns the document response queries run against: the JSON bodies
+// of the originating request and the response, the call duration in milliseconds
+// and the response status code. Bodies that are not JSON are null.
+func (ctx *ResponseContext) CallDocument() map[string]interface{} {
+	var request interface{}
+	if ctx.Request != nil {
+		request = jsonDocument(ctx.Request.Body)
+	}
+
+	return map[string]interface{}{
+		"request":     request,
+		"response":    jsonDocument(ctx.Body),
+		"duration_ms": int(ctx.Duration.Milliseconds()),
+		"status":      ctx.StatusCode,
+	}
+}
+
+// jsonDocument converts a module body to a JSON document, raw bodies are decoded.
+func jsonDocument(body interface{}) interface{} {
+	var raw []byte
+
+	switch v := body.(type) {
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return v
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil
+	}
+
+	return data
+}
+
+type SyntheticResponse struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte
+}
+
+// InterceptingModule is implemented by modules that hold proxied calls before they
+// are forwarded and may modify, answer or drop them. Intercept hooks run in the
+// proxy path with the full body buffered, unlike OnRequest/OnResponse which only
+// observe the call after it has been streamed.
+type InterceptingModule interface {
+	Module
+	InterceptRequest(ctx *RequestContext) (*RequestContext, error)
+	InterceptResponse(ctx *ResponseContext) (*ResponseContext, error)
 }
 
 type ConnectionManager interface {
 	SendMessage(msg *protocol.WSMessage) error
 	SendMessageWithBinary(msg *protocol.WSMessage, binaryData []byte) error
 	WaitForResponse(requestID uint64) (*protocol.WSMessageWithBinary, error)
+	SendRequest(ctx context.Context, msg *protocol.WSMessage, binaryData []byte) (*protocol.WSMessageWithBinary, error)
 	GenerateRequestID() uint64
 }
 type FilterConfig struct {
@@ -50,20 +121,38 @@ type FilterConfig struct {
 	ResponseFilter *Filter `json:"response_filter,omitempty"`
 }
 
+// Filter selects the calls a module processes. All set conditions must match, so an
+// empty filter matches every call. Request-only conditions (methods, path) are not
+// checked for responses, response-only conditions (status codes, duration) not for
+// requests.
 type Filter struct {
-	ContentTypes []string    `json:"content_types,omitempty"`
-	JSONQuery    string      `json:"json_query,omitempty"`
-	Methods      []string    `json:"methods,omitempty"`      // HTTP methods to filter on (for requests)
-	StatusCodes  []int       `json:"status_codes,omitempty"` // Response status codes to filter on (for responses)
-	compiled     interface{} // gojq.Query - using interface{} to avoid import cycle
+	ContentTypes []string `json:"content_types,omitempty"`
+	JSONQuery    string   `json:"json_query,omitempty"`
+	Methods      []string `json:"methods,omitempty"`      // HTTP methods of the request to filter on
+	StatusCodes  []int    `json:"status_codes,omitempty"` // Response status codes to filter on (for responses)
+
+	JRPCMethods   []string          `json:"jrpc_methods,omitempty"`    // JSON-RPC method globs, e.g. engine_forkchoiceUpdated*
+	PathPrefix    string            `json:"path_prefix,omitempty"`     // URL path prefix of the request
+	PathRegex     string            `json:"path_regex,omitempty"`      // URL path regex of the request
+	Headers       map[string]string `json:"headers,omitempty"`         // header name to value glob, "*" matches any value
+	MinDurationMs int64             `json:"min_duration_ms,omitempty"` // minimum call duration (for responses)
+	MinBodySize   int               `json:"min_body_size,omitempty"`   // minimum body size in bytes
+	MaxBodySize   int               `json:"max_body_size,omitempty"`   // maximum body size in bytes
+	SampleRate    float64           `json:"sample_rate,omitempty"`     // share of matching calls processed, between 0 and 1
+
+	All []*Filter `json:"all,omitempty"` // all of the nested filters must match
+	Any []*Filter `json:"any,omitempty"` // at least one of the nested filters must match
+	Not *Filter   `json:"not,omitempty"` // the nested filter must not match
+
+	compiled interface{} // compiled queries - using interface{} to avoid import cycle
 }
 
-// GetCompiled returns the compiled gojq query
+// GetCompiled returns the compiled queries of the filter
 func (f *Filter) GetCompiled() interface{} {
 	return f.compiled
 }
 
-// SetCompiled sets the compiled gojq query
+// SetCompiled sets the compiled queries of the filter
 func (f *Filter) SetCompiled(compiled interface{}) {
 	f.compiled = compiled
 }
@@ -73,4 +162,40 @@ type ProxyCallContext interface {
 	ID() uint64
 	SetData(moduleID uint64, key string, value interface{})
 	GetData(moduleID uint64, key string) interface{}
+	// Upstream returns the URL of the upstream serving the call.
+	Upstream() string
+	// BatchIndex returns the position of the call in its JSON-RPC batch, or -1 if
+	// the call is not part of a batch.
+	BatchIndex() int
+}
+
+// JSONRPCMethod returns the method of a parsed JSON-RPC call, or "" if it is none.
+func JSONRPCMethod(call interface{}) string {
+	obj, _ := call.(map[string]interface{})
+	method, _ := obj["method"].(string)
+
+	return method
+}
+
+// JSONRPCMethods returns the methods of a parsed JSON-RPC request body, one per call
+// of a batch.
+func JSONRPCMethods(body interface{}) []string {
+	switch v := body.(type) {
+	case map[string]interface{}:
+		if method := JSONRPCMethod(v); method != "" {
+			return []string{method}
+		}
+	case []interface{}:
+		methods := make([]string, 0, len(v))
+
+		for _, call := range v {
+			if method := JSONRPCMethod(call); method != "" {
+				methods = append(methods, method)
+			}
+		}
+
+		return methods
+	}
+
+	return nil
 }
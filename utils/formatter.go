@@ -59,3 +59,35 @@ func (f *SnooperFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 
 	return lineBuf, nil
 }
+
+// JSONFormatter writes every log entry as a single JSON object for ingestion into
+// log pipelines. Terminal colors are dropped and byte bodies are written as strings,
+// while json.RawMessage bodies are embedded as JSON.
+type JSONFormatter struct {
+	Formatter logrus.JSONFormatter
+}
+
+func (f *JSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(entry.Data))
+
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case color.Attribute:
+			continue
+		case []byte:
+			data[key] = string(v)
+		default:
+			data[key] = value
+		}
+	}
+
+	return f.Formatter.Format(&logrus.Entry{
+		Logger:  entry.Logger,
+		Data:    data,
+		Time:    entry.Time,
+		Level:   entry.Level,
+		Caller:  entry.Caller,
+		Message: entry.Message,
+		Context: entry.Context,
+	})
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ParseJWTSecret parses a JWT secret from either a file path or hex-encoded string.
+// If the value looks like a file path, it reads the secret from the file.
+// Otherwise, it treats it as a hex-encoded value (with optional "0x" prefix).
+// An empty value returns a nil secret.
+func ParseJWTSecret(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	// Check if it looks like a file path
+	if strings.HasPrefix(s, "/") || strings.HasPrefix(s, "./") || strings.HasPrefix(s, "../") {
+		data, err := os.ReadFile(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT secret from file: %w", err)
+		}
+
+		// File contents should be hex-encoded
+		s = string(data)
+	}
+
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "0x")
+
+	secret, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex-encoded JWT secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+// CreateJWTToken creates a JWT token for Engine API authentication.
+func CreateJWTToken(secret []byte) (string, error) {
+	if len(secret) == 0 {
+		return "", fmt.Errorf("no JWT secret configured")
+	}
+
+	claims := jwt.MapClaims{
+		"iat": time.Now().Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString(secret)
+}
+
+// ValidateJWTToken checks an Engine API JWT token: it must be HS256 signed with the
+// secret and its iat claim must be within maxDrift of the local clock.
+func ValidateJWTToken(tokenString string, secret []byte, maxDrift time.Duration) error {
+	token, err := jwt.Parse(tokenString, func(_ *jwt.Token) (any, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return err
+	}
+
+	issuedAt, err := token.Claims.GetIssuedAt()
+	if err != nil {
+		return fmt.Errorf("invalid iat claim: %w", err)
+	}
+
+	if issuedAt == nil {
+		return fmt.Errorf("missing iat claim")
+	}
+
+	drift := time.Since(issuedAt.Time)
+	if drift > maxDrift || drift < -maxDrift {
+		return fmt.Errorf("iat is off by %v (max %v)", drift.Truncate(time.Second), maxDrift)
+	}
+
+	return nil
+}
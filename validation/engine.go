@@ -0,0 +1,172 @@
+package validation
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The OpenRPC documents are split like the execution-apis sources: shared types in
+// base-types.json, and the methods introduced by each fork in a file of its own.
+//
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// openRPCDocument is the subset of an OpenRPC document needed for validation.
+type openRPCDocument struct {
+	Methods    []*openRPCMethod `json:"methods"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openRPCMethod struct {
+	Name   string `json:"name"`
+	Params []struct {
+		Name     string  `json:"name"`
+		Required bool    `json:"required"`
+		Schema   *Schema `json:"schema"`
+	} `json:"params"`
+	Result struct {
+		Name   string  `json:"name"`
+		Schema *Schema `json:"schema"`
+	} `json:"result"`
+
+	fork string
+}
+
+// EngineValidator validates Engine API requests and responses against the embedded
+// schemas. It is safe for concurrent use.
+type EngineValidator struct {
+	methods map[string]*openRPCMethod
+}
+
+// NewEngineValidator loads and compiles the embedded Engine API schemas.
+func NewEngineValidator() (*EngineValidator, error) {
+	files, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schemas: %w", err)
+	}
+
+	validator := &EngineValidator{
+		methods: make(map[string]*openRPCMethod),
+	}
+	components := make(map[string]*Schema)
+
+	for _, file := range files {
+		data, err := schemaFiles.ReadFile(path.Join("schemas", file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema %v: %w", file.Name(), err)
+		}
+
+		doc := &openRPCDocument{}
+		if err := json.Unmarshal(data, doc); err != nil {
+			return nil, fmt.Errorf("failed to parse schema %v: %w", file.Name(), err)
+		}
+
+		for name, schema := range doc.Components.Schemas {
+			if _, exists := components[name]; exists {
+				return nil, fmt.Errorf("duplicate schema %v in %v", name, file.Name())
+			}
+
+			components[name] = schema
+		}
+
+		for _, method := range doc.Methods {
+			if _, exists := validator.methods[method.Name]; exists {
+				return nil, fmt.Errorf("duplicate method %v in %v", method.Name, file.Name())
+			}
+
+			method.fork = strings.TrimSuffix(file.Name(), ".json")
+			validator.methods[method.Name] = method
+		}
+	}
+
+	for _, method := range validator.methods {
+		for _, param := range method.Params {
+			if err := param.Schema.compile(components); err != nil {
+				return nil, fmt.Errorf("invalid schema for %v param %v: %w", method.Name, param.Name, err)
+			}
+		}
+
+		if err := method.Result.Schema.compile(components); err != nil {
+			return nil, fmt.Errorf("invalid schema for %v result: %w", method.Name, err)
+		}
+	}
+
+	return validator, nil
+}
+
+// Methods returns the names of all methods with a schema.
+func (v *EngineValidator) Methods() []string {
+	methods := make([]string, 0, len(v.methods))
+	for name := range v.methods {
+		methods = append(methods, name)
+	}
+
+	sort.Strings(methods)
+
+	return methods
+}
+
+// Fork returns the fork that introduced a method, or an empty string if there is no
+// schema for the method.
+func (v *EngineValidator) Fork(method string) string {
+	if m, ok := v.methods[method]; ok {
+		return m.fork
+	}
+
+	return ""
+}
+
+// ValidateRequest validates the params of a request. It returns nil for methods
+// without a schema.
+func (v *EngineValidator) ValidateRequest(method string, params []any) []Violation {
+	m, ok := v.methods[method]
+	if !ok {
+		return nil
+	}
+
+	var violations []Violation
+
+	for idx, param := range m.Params {
+		pointer := "/params/" + strconv.Itoa(idx)
+
+		if idx >= len(params) {
+			if param.Required {
+				violations = append(violations, Violation{
+					Pointer: pointer,
+					Message: fmt.Sprintf("required param %q is missing", param.Name),
+				})
+			}
+
+			continue
+		}
+
+		violations = append(violations, param.Schema.validate(params[idx], pointer)...)
+	}
+
+	for idx := len(m.Params); idx < len(params); idx++ {
+		violations = append(violations, Violation{
+			Pointer: "/params/" + strconv.Itoa(idx),
+			Message: "unexpected param",
+		})
+	}
+
+	return violations
+}
+
+// ValidateResult validates the result of a successful response. It returns nil for
+// methods without a schema.
+func (v *EngineValidator) ValidateResult(method string, result any) []Violation {
+	m, ok := v.methods[method]
+	if !ok || m.Result.Schema == nil {
+		return nil
+	}
+
+	return m.Result.Schema.validate(result, "/result")
+}
@@ -0,0 +1,146 @@
+package validation
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hexBytes(size int) string {
+	return "0x" + strings.Repeat("ab", size)
+}
+
+func testExecutionPayloadV3() map[string]any {
+	return map[string]any{
+		"parentHash":    hexBytes(32),
+		"feeRecipient":  "0x" + strings.Repeat("Ab", 20),
+		"stateRoot":     hexBytes(32),
+		"receiptsRoot":  hexBytes(32),
+		"logsBloom":     hexBytes(256),
+		"prevRandao":    hexBytes(32),
+		"blockNumber":   "0x10",
+		"gasLimit":      "0x1c9c380",
+		"gasUsed":       "0x0",
+		"timestamp":     "0x6553f100",
+		"extraData":     "0x",
+		"baseFeePerGas": "0x7",
+		"blockHash":     hexBytes(32),
+		"transactions":  []any{"0x02f8"},
+		"withdrawals": []any{
+			map[string]any{"index": "0x0", "validatorIndex": "0x1", "address": hexBytes(20), "amount": "0x64"},
+		},
+		"blobGasUsed":   "0x0",
+		"excessBlobGas": "0x0",
+	}
+}
+
+// decode round-trips a value through JSON, like the proxy does with request bodies.
+func decode(t *testing.T, value any) any {
+	t.Helper()
+
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+
+	var decoded any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	return decoded
+}
+
+func TestEngineValidatorLoadsAllForks(t *testing.T) {
+	validator, err := NewEngineValidator()
+	require.NoError(t, err)
+
+	assert.Equal(t, "paris", validator.Fork("engine_newPayloadV1"))
+	assert.Equal(t, "shanghai", validator.Fork("engine_getPayloadBodiesByRangeV1"))
+	assert.Equal(t, "cancun", validator.Fork("engine_forkchoiceUpdatedV3"))
+	assert.Equal(t, "prague", validator.Fork("engine_newPayloadV4"))
+	assert.Equal(t, "osaka", validator.Fork("engine_getBlobsV2"))
+	assert.Equal(t, "common", validator.Fork("engine_exchangeCapabilities"))
+	assert.Empty(t, validator.Fork("eth_blockNumber"))
+}
+
+func TestEngineValidatorRequest(t *testing.T) {
+	validator, err := NewEngineValidator()
+	require.NoError(t, err)
+
+	params := []any{testExecutionPayloadV3(), []any{hexBytes(32)}, hexBytes(32), []any{"0x00ab"}}
+	assert.Empty(t, validator.ValidateRequest("engine_newPayloadV4", decode(t, params).([]any)))
+
+	// the payload of a V3 call sent with the wrong field set
+	payload := testExecutionPayloadV3()
+	delete(payload, "blobGasUsed")
+	payload["blockNumber"] = "0x010"
+	payload["depositRequests"] = []any{}
+
+	violations := validator.ValidateRequest("engine_newPayloadV4", decode(t, []any{payload, []any{}, hexBytes(32)}).([]any))
+	assert.Equal(t, []string{
+		"/params/0/blobGasUsed",
+		"/params/0/blockNumber",
+		"/params/0/depositRequests",
+		"/params/3",
+	}, getPointers(violations))
+
+	// V1 and V2 payloads are both accepted by engine_newPayloadV2
+	payload = testExecutionPayloadV3()
+	delete(payload, "blobGasUsed")
+	delete(payload, "excessBlobGas")
+	assert.Empty(t, validator.ValidateRequest("engine_newPayloadV2", decode(t, []any{payload}).([]any)))
+
+	delete(payload, "withdrawals")
+	assert.Empty(t, validator.ValidateRequest("engine_newPayloadV2", decode(t, []any{payload}).([]any)))
+
+	// optional params
+	forkchoiceState := map[string]any{"headBlockHash": hexBytes(32), "safeBlockHash": hexBytes(32), "finalizedBlockHash": hexBytes(32)}
+	assert.Empty(t, validator.ValidateRequest("engine_forkchoiceUpdatedV3", decode(t, []any{forkchoiceState}).([]any)))
+	assert.Empty(t, validator.ValidateRequest("engine_forkchoiceUpdatedV3", decode(t, []any{forkchoiceState, nil}).([]any)))
+	assert.Equal(t, []string{"/params/0/safeBlockHash"}, getPointers(validator.ValidateRequest("engine_forkchoiceUpdatedV3", []any{
+		map[string]any{"headBlockHash": hexBytes(32), "safeBlockHash": "0x00", "finalizedBlockHash": hexBytes(32)},
+	})))
+
+	assert.Nil(t, validator.ValidateRequest("eth_blockNumber", nil))
+}
+
+func TestEngineValidatorResult(t *testing.T) {
+	validator, err := NewEngineValidator()
+	require.NoError(t, err)
+
+	valid := map[string]any{"status": "VALID", "latestValidHash": hexBytes(32), "validationError": nil}
+	assert.Empty(t, validator.ValidateResult("engine_newPayloadV4", valid))
+
+	violations := validator.ValidateResult("engine_newPayloadV4", map[string]any{"status": "valid", "latestValidHash": "0x1234"})
+	require.Len(t, violations, 2)
+	assert.Equal(t, "/result/latestValidHash", violations[0].Pointer)
+	assert.Equal(t, `value "0x1234" does not match 32 byte hex value or null`, violations[0].Message)
+	assert.Equal(t, "/result/status", violations[1].Pointer)
+
+	// forkchoice updates must not answer ACCEPTED
+	violations = validator.ValidateResult("engine_forkchoiceUpdatedV3", map[string]any{
+		"payloadStatus": map[string]any{"status": "ACCEPTED"},
+		"payloadId":     nil,
+	})
+	assert.Equal(t, []string{"/result/payloadStatus/status"}, getPointers(violations))
+
+	assert.Equal(t, []string{"/result/1/blob"}, getPointers(validator.ValidateResult("engine_getBlobsV1", []any{
+		nil,
+		map[string]any{"blob": "0x00", "proof": hexBytes(48)},
+	})))
+
+	assert.Equal(t, []string{"/result"}, getPointers(validator.ValidateResult("engine_exchangeCapabilities", nil)))
+}
+
+func TestEscapePointer(t *testing.T) {
+	assert.Equal(t, "a~1b~0c", escapePointer("a/b~c"))
+}
+
+func getPointers(violations []Violation) []string {
+	pointers := make([]string, len(violations))
+	for idx, violation := range violations {
+		pointers[idx] = violation.Pointer
+	}
+
+	return pointers
+}
@@ -0,0 +1,358 @@
+// Package validation checks Engine API calls against the execution-apis OpenRPC
+// schemas. The schemas are embedded per fork, so no network access is needed.
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema used by the execution-apis OpenRPC documents.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 schemaTypes        `json:"type,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+
+	compiled bool
+	pattern  *regexp.Regexp
+	ref      *Schema
+}
+
+// schemaTypes holds the allowed types of a schema, given as a single type or a list.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("invalid schema type: %w", err)
+	}
+
+	*t = list
+
+	return nil
+}
+
+// Violation is a single schema violation. Pointer is the JSON pointer (RFC 6901) to
+// the bad field, relative to the JSON-RPC message.
+type Violation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// compile resolves references and compiles patterns of the schema and all of its
+// subschemas.
+func (s *Schema) compile(components map[string]*Schema) error {
+	if s == nil || s.compiled {
+		return nil
+	}
+
+	s.compiled = true
+
+	if s.Ref != "" {
+		ref, ok := components[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("unknown schema reference %v", s.Ref)
+		}
+
+		s.ref = ref
+
+		return ref.compile(components)
+	}
+
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %v: %w", s.Pattern, err)
+		}
+
+		s.pattern = pattern
+	}
+
+	subschemas := make([]*Schema, 0, len(s.Properties)+len(s.OneOf)+len(s.AnyOf)+1)
+	for _, property := range s.Properties {
+		subschemas = append(subschemas, property)
+	}
+
+	subschemas = append(subschemas, s.Items)
+	subschemas = append(subschemas, s.OneOf...)
+	subschemas = append(subschemas, s.AnyOf...)
+
+	for _, subschema := range subschemas {
+		if err := subschema.compile(components); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate checks value against the schema and returns all violations found, with
+// pointers relative to path.
+func (s *Schema) validate(value any, path string) []Violation {
+	if s.ref != nil {
+		return s.ref.validate(value, path)
+	}
+
+	if len(s.OneOf) > 0 || len(s.AnyOf) > 0 {
+		return s.validateAlternatives(value, path)
+	}
+
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		return []Violation{{
+			Pointer: path,
+			Message: fmt.Sprintf("expected %v, got %v", s.describe(), getJSONType(value)),
+		}}
+	}
+
+	if len(s.Enum) > 0 && !containsValue(s.Enum, value) {
+		return []Violation{{
+			Pointer: path,
+			Message: fmt.Sprintf("value %v is not one of %v", formatValue(value), formatValues(s.Enum)),
+		}}
+	}
+
+	switch typedValue := value.(type) {
+	case string:
+		return s.validateString(typedValue, path)
+	case map[string]any:
+		return s.validateObject(typedValue, path)
+	case []any:
+		return s.validateArray(typedValue, path)
+	}
+
+	return nil
+}
+
+// validateAlternatives checks value against oneOf/anyOf alternatives. If no
+// alternative matches, the violations of the closest one are returned.
+func (s *Schema) validateAlternatives(value any, path string) []Violation {
+	alternatives := s.OneOf
+	if len(alternatives) == 0 {
+		alternatives = s.AnyOf
+	}
+
+	var closest []Violation
+
+	for _, alternative := range alternatives {
+		violations := alternative.validate(value, path)
+		if len(violations) == 0 {
+			return nil
+		}
+
+		if closest == nil || len(violations) < len(closest) {
+			closest = violations
+		}
+	}
+
+	// a value matching none of the alternatives at all is reported as a single violation
+	if len(closest) == 1 && closest[0].Pointer == path {
+		return []Violation{{
+			Pointer: path,
+			Message: fmt.Sprintf("value %v does not match %v", formatValue(value), s.describe()),
+		}}
+	}
+
+	return closest
+}
+
+func (s *Schema) validateString(value, path string) []Violation {
+	if s.MinLength != nil && len(value) < *s.MinLength {
+		return []Violation{{Pointer: path, Message: fmt.Sprintf("string is shorter than %v characters", *s.MinLength)}}
+	}
+
+	if s.MaxLength != nil && len(value) > *s.MaxLength {
+		return []Violation{{Pointer: path, Message: fmt.Sprintf("string is longer than %v characters", *s.MaxLength)}}
+	}
+
+	if s.pattern != nil && !s.pattern.MatchString(value) {
+		return []Violation{{Pointer: path, Message: fmt.Sprintf("value %v does not match %v", formatValue(value), s.describe())}}
+	}
+
+	return nil
+}
+
+func (s *Schema) validateObject(value map[string]any, path string) []Violation {
+	var violations []Violation
+
+	for _, name := range s.Required {
+		if _, ok := value[name]; !ok {
+			violations = append(violations, Violation{
+				Pointer: path + "/" + escapePointer(name),
+				Message: "required field is missing",
+			})
+		}
+	}
+
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				violations = append(violations, Violation{
+					Pointer: path + "/" + escapePointer(name),
+					Message: "unexpected field",
+				})
+			}
+
+			continue
+		}
+
+		violations = append(violations, property.validate(value[name], path+"/"+escapePointer(name))...)
+	}
+
+	return violations
+}
+
+func (s *Schema) validateArray(value []any, path string) []Violation {
+	if s.MinItems != nil && len(value) < *s.MinItems {
+		return []Violation{{Pointer: path, Message: fmt.Sprintf("array has less than %v items", *s.MinItems)}}
+	}
+
+	if s.MaxItems != nil && len(value) > *s.MaxItems {
+		return []Violation{{Pointer: path, Message: fmt.Sprintf("array has more than %v items", *s.MaxItems)}}
+	}
+
+	if s.Items == nil {
+		return nil
+	}
+
+	var violations []Violation
+
+	for idx, item := range value {
+		violations = append(violations, s.Items.validate(item, path+"/"+strconv.Itoa(idx))...)
+	}
+
+	return violations
+}
+
+func (s *Schema) matchesType(value any) bool {
+	valueType := getJSONType(value)
+
+	for _, schemaType := range s.Type {
+		if schemaType == valueType || (schemaType == "number" && valueType == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// describe returns a short description of the expected value for violation messages.
+func (s *Schema) describe() string {
+	if s.ref != nil {
+		return s.ref.describe()
+	}
+
+	if s.Title != "" {
+		return s.Title
+	}
+
+	if len(s.OneOf) > 0 || len(s.AnyOf) > 0 {
+		alternatives := s.OneOf
+		if len(alternatives) == 0 {
+			alternatives = s.AnyOf
+		}
+
+		descriptions := make([]string, len(alternatives))
+		for idx, alternative := range alternatives {
+			descriptions[idx] = alternative.describe()
+		}
+
+		return strings.Join(descriptions, " or ")
+	}
+
+	if s.Pattern != "" {
+		return "string matching " + s.Pattern
+	}
+
+	return strings.Join(s.Type, " or ")
+}
+
+// getJSONType returns the JSON Schema type name of a decoded JSON value.
+func getJSONType(value any) string {
+	switch typedValue := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if typedValue == float64(int64(typedValue)) {
+			return "integer"
+		}
+
+		return "number"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	if len(data) > 80 {
+		return string(data[:77]) + "..."
+	}
+
+	return string(data)
+}
+
+func formatValues(values []any) string {
+	formatted := make([]string, len(values))
+	for idx, value := range values {
+		formatted[idx] = formatValue(value)
+	}
+
+	return strings.Join(formatted, ", ")
+}
+
+// escapePointer escapes a reference token of a JSON pointer.
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,117 @@
+package xatu
+
+import (
+	"strings"
+	"time"
+
+	xatuProto "github.com/ethpandaops/xatu/pkg/proto/xatu"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Beacon chain timing defaults, as used by all public networks.
+const (
+	DefaultSecondsPerSlot = 12
+	DefaultSlotsPerEpoch  = 32
+)
+
+// knownGenesisTimes are the beacon chain genesis times of public networks, by network name.
+var knownGenesisTimes = map[string]int64{
+	"mainnet": 1606824023,
+	"sepolia": 1655733600,
+	"holesky": 1695902400,
+	"hoodi":   1742213400,
+}
+
+// BeaconClock maps beacon chain slots and epochs to their start times.
+type BeaconClock struct {
+	genesis       time.Time
+	slotDuration  time.Duration
+	slotsPerEpoch uint64
+}
+
+// NewBeaconClock creates a beacon clock for a chain started at genesis. With a zero
+// genesis time, only the epochs of slots are known, not their start times.
+func NewBeaconClock(genesis time.Time, secondsPerSlot, slotsPerEpoch uint64) *BeaconClock {
+	if secondsPerSlot == 0 {
+		secondsPerSlot = DefaultSecondsPerSlot
+	}
+
+	if slotsPerEpoch == 0 {
+		slotsPerEpoch = DefaultSlotsPerEpoch
+	}
+
+	return &BeaconClock{
+		genesis:       genesis,
+		slotDuration:  time.Duration(secondsPerSlot) * time.Second, //nolint:gosec // Safe: slot durations are a few seconds
+		slotsPerEpoch: slotsPerEpoch,
+	}
+}
+
+// newBeaconClockFromConfig creates the beacon clock for the configured network,
+// falling back to the known genesis time of public networks.
+func newBeaconClockFromConfig(config *Config) *BeaconClock {
+	genesis := config.GenesisTime
+	if genesis.IsZero() {
+		if genesisTime, ok := knownGenesisTimes[strings.ToLower(config.NetworkName)]; ok {
+			genesis = time.Unix(genesisTime, 0)
+		}
+	}
+
+	return NewBeaconClock(genesis, config.SecondsPerSlot, config.SlotsPerEpoch)
+}
+
+// EpochOfSlot returns the epoch of a slot.
+func (c *BeaconClock) EpochOfSlot(slot uint64) uint64 {
+	return slot / c.slotsPerEpoch
+}
+
+// SlotStart returns the start time of a slot, or the zero time if the genesis time
+// is unknown.
+func (c *BeaconClock) SlotStart(slot uint64) time.Time {
+	if c.genesis.IsZero() {
+		return time.Time{}
+	}
+
+	return c.genesis.Add(time.Duration(slot) * c.slotDuration) //nolint:gosec // Safe: slot numbers cannot overflow in practice
+}
+
+// slotV2 returns the xatu slot data of a slot.
+func (c *BeaconClock) slotV2(slot uint64) *xatuProto.SlotV2 {
+	data := &xatuProto.SlotV2{
+		Number: wrapperspb.UInt64(slot),
+	}
+
+	if start := c.SlotStart(slot); !start.IsZero() {
+		data.StartDateTime = timestamppb.New(start)
+	}
+
+	return data
+}
+
+// epochV2 returns the xatu epoch data of an epoch.
+func (c *BeaconClock) epochV2(epoch uint64) *xatuProto.EpochV2 {
+	data := &xatuProto.EpochV2{
+		Number: wrapperspb.UInt64(epoch),
+	}
+
+	if start := c.SlotStart(epoch * c.slotsPerEpoch); !start.IsZero() {
+		data.StartDateTime = timestamppb.New(start)
+	}
+
+	return data
+}
+
+// propagationV2 returns the xatu propagation data of an event for slot that passed the
+// proxy at timestamp, or nil if the slot start time is unknown. Events seen before
+// their slot started have a propagation delay of zero.
+func (c *BeaconClock) propagationV2(slot uint64, timestamp time.Time) *xatuProto.PropagationV2 {
+	start := c.SlotStart(slot)
+	if start.IsZero() {
+		return nil
+	}
+
+	return &xatuProto.PropagationV2{
+		SlotStartDiff: wrapperspb.UInt64(uint64(max(timestamp.Sub(start).Milliseconds(), 0))), //nolint:gosec // Safe: clamped to zero
+	}
+}
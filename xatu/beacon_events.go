@@ -0,0 +1,353 @@
+package xatu
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	ethv1 "github.com/ethpandaops/xatu/pkg/proto/eth/v1"
+	xatuProto "github.com/ethpandaops/xatu/pkg/proto/xatu"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Beacon API event stream topics.
+const (
+	BeaconTopicHead                = "head"
+	BeaconTopicBlock               = "block"
+	BeaconTopicFinalizedCheckpoint = "finalized_checkpoint"
+	BeaconTopicChainReorg          = "chain_reorg"
+	BeaconTopicBlobSidecar         = "blob_sidecar"
+	BeaconTopicPayloadAttributes   = "payload_attributes"
+)
+
+// PayloadAttributesEventData is the data of SnooperEventBeaconPayloadAttributes events.
+type PayloadAttributesEventData struct {
+	Version           string `json:"version"`
+	ProposerIndex     uint64 `json:"proposer_index"`
+	ProposalSlot      uint64 `json:"proposal_slot"`
+	ParentBlockNumber uint64 `json:"parent_block_number"`
+	ParentBlockRoot   string `json:"parent_block_root"`
+	ParentBlockHash   string `json:"parent_block_hash"`
+
+	// Payload attributes fields
+	Timestamp             uint64 `json:"timestamp"`
+	PrevRandao            string `json:"prev_randao"`
+	SuggestedFeeRecipient string `json:"suggested_fee_recipient"`
+	WithdrawalsCount      uint32 `json:"withdrawals_count"`
+	ParentBeaconBlockRoot string `json:"parent_beacon_block_root,omitempty"`
+}
+
+// BeaconEventsHandler handles events from beacon API /eth/v1/events streams.
+//
+// The events are published as the matching BEACON_API_ETH_V1_EVENTS_* xatu events,
+// timestamped with the time they passed the proxy. Their slot, epoch and propagation
+// delay since the slot start are set as additional data. payload_attributes events have no
+// xatu event, they are published as SnooperEventBeaconPayloadAttributes snooper events
+// if a snooper event sink is set.
+type BeaconEventsHandler struct {
+	publisher     Publisher
+	snooperEvents SnooperEventSink
+	clock         *BeaconClock
+	log           logrus.FieldLogger
+}
+
+// NewBeaconEventsHandler creates a new beacon event stream handler. snooperEvents may
+// be nil, payload_attributes events are not handled then.
+func NewBeaconEventsHandler(
+	publisher Publisher,
+	snooperEvents SnooperEventSink,
+	clock *BeaconClock,
+	log logrus.FieldLogger,
+) *BeaconEventsHandler {
+	return &BeaconEventsHandler{
+		publisher:     publisher,
+		snooperEvents: snooperEvents,
+		clock:         clock,
+		log:           log.WithField("handler", "beacon_events"),
+	}
+}
+
+// Name returns the handler name.
+func (h *BeaconEventsHandler) Name() string {
+	return "beacon_events"
+}
+
+// Topics returns the event stream topics handled by this handler.
+func (h *BeaconEventsHandler) Topics() []string {
+	topics := []string{
+		BeaconTopicHead,
+		BeaconTopicBlock,
+		BeaconTopicFinalizedCheckpoint,
+		BeaconTopicChainReorg,
+		BeaconTopicBlobSidecar,
+	}
+
+	if h.snooperEvents != nil {
+		topics = append(topics, BeaconTopicPayloadAttributes)
+	}
+
+	return topics
+}
+
+// HandleEvent builds and publishes the xatu event for a beacon event.
+func (h *BeaconEventsHandler) HandleEvent(event *BeaconEvent) {
+	if event.Topic == BeaconTopicPayloadAttributes {
+		h.publishPayloadAttributesEvent(event)
+
+		return
+	}
+
+	decoratedEvent := h.buildDecoratedEvent(event)
+	if decoratedEvent == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultPublishTimeout)
+	defer cancel()
+
+	if err := h.publisher.Publish(ctx, decoratedEvent); err != nil {
+		h.log.WithError(err).WithField("topic", event.Topic).Error("failed to publish beacon event")
+
+		return
+	}
+
+	h.log.WithFields(logrus.Fields{
+		"call_id": event.CallID,
+		"topic":   event.Topic,
+	}).Debug("published beacon event")
+}
+
+func (h *BeaconEventsHandler) buildDecoratedEvent(event *BeaconEvent) *xatuProto.DecoratedEvent {
+	clientMeta := h.publisher.ClientMeta()
+	if clientMeta == nil {
+		clientMeta = &xatuProto.ClientMeta{}
+	}
+
+	decoratedEvent := &xatuProto.DecoratedEvent{
+		Event: &xatuProto.Event{
+			DateTime: timestamppb.New(event.Timestamp),
+			Id:       uuid.New().String(),
+		},
+		Meta: &xatuProto.Meta{
+			Client: clientMeta,
+		},
+	}
+
+	data := event.Data
+
+	switch event.Topic {
+	case BeaconTopicHead:
+		head := &ethv1.EventHeadV2{
+			Slot:                      getBeaconUint64(data, "slot"),
+			Block:                     getBeaconString(data, "block"),
+			State:                     getBeaconString(data, "state"),
+			EpochTransition:           getBeaconBool(data, "epoch_transition"),
+			PreviousDutyDependentRoot: getBeaconString(data, "previous_duty_dependent_root"),
+			CurrentDutyDependentRoot:  getBeaconString(data, "current_duty_dependent_root"),
+		}
+		epoch, slot, propagation := h.slotAdditionalData(head.Slot, event.Timestamp)
+
+		decoratedEvent.Event.Name = xatuProto.Event_BEACON_API_ETH_V1_EVENTS_HEAD_V2
+		decoratedEvent.Data = &xatuProto.DecoratedEvent_EthV1EventsHeadV2{
+			EthV1EventsHeadV2: head,
+		}
+		clientMeta.AdditionalData = &xatuProto.ClientMeta_EthV1EventsHeadV2{
+			EthV1EventsHeadV2: &xatuProto.ClientMeta_AdditionalEthV1EventsHeadV2Data{
+				Epoch:       epoch,
+				Slot:        slot,
+				Propagation: propagation,
+			},
+		}
+	case BeaconTopicBlock:
+		block := &ethv1.EventBlockV2{
+			Slot:                getBeaconUint64(data, "slot"),
+			Block:               getBeaconString(data, "block"),
+			ExecutionOptimistic: getBeaconBool(data, "execution_optimistic"),
+		}
+		epoch, slot, propagation := h.slotAdditionalData(block.Slot, event.Timestamp)
+
+		decoratedEvent.Event.Name = xatuProto.Event_BEACON_API_ETH_V1_EVENTS_BLOCK_V2
+		decoratedEvent.Data = &xatuProto.DecoratedEvent_EthV1EventsBlockV2{
+			EthV1EventsBlockV2: block,
+		}
+		clientMeta.AdditionalData = &xatuProto.ClientMeta_EthV1EventsBlockV2{
+			EthV1EventsBlockV2: &xatuProto.ClientMeta_AdditionalEthV1EventsBlockV2Data{
+				Epoch:       epoch,
+				Slot:        slot,
+				Propagation: propagation,
+			},
+		}
+	case BeaconTopicFinalizedCheckpoint:
+		checkpoint := &ethv1.EventFinalizedCheckpointV2{
+			Block: getBeaconString(data, "block"),
+			State: getBeaconString(data, "state"),
+			Epoch: getBeaconUint64(data, "epoch"),
+		}
+
+		var epoch *xatuProto.EpochV2
+		if checkpoint.Epoch != nil {
+			epoch = h.clock.epochV2(checkpoint.Epoch.GetValue())
+		}
+
+		decoratedEvent.Event.Name = xatuProto.Event_BEACON_API_ETH_V1_EVENTS_FINALIZED_CHECKPOINT_V2
+		decoratedEvent.Data = &xatuProto.DecoratedEvent_EthV1EventsFinalizedCheckpointV2{
+			EthV1EventsFinalizedCheckpointV2: checkpoint,
+		}
+		clientMeta.AdditionalData = &xatuProto.ClientMeta_EthV1EventsFinalizedCheckpointV2{
+			EthV1EventsFinalizedCheckpointV2: &xatuProto.ClientMeta_AdditionalEthV1EventsFinalizedCheckpointV2Data{
+				Epoch: epoch,
+			},
+		}
+	case BeaconTopicChainReorg:
+		reorg := &ethv1.EventChainReorgV2{
+			Slot:         getBeaconUint64(data, "slot"),
+			Depth:        getBeaconUint64(data, "depth"),
+			OldHeadBlock: getBeaconString(data, "old_head_block"),
+			NewHeadBlock: getBeaconString(data, "new_head_block"),
+			OldHeadState: getBeaconString(data, "old_head_state"),
+			NewHeadState: getBeaconString(data, "new_head_state"),
+			Epoch:        getBeaconUint64(data, "epoch"),
+		}
+		epoch, slot, propagation := h.slotAdditionalData(reorg.Slot, event.Timestamp)
+
+		decoratedEvent.Event.Name = xatuProto.Event_BEACON_API_ETH_V1_EVENTS_CHAIN_REORG_V2
+		decoratedEvent.Data = &xatuProto.DecoratedEvent_EthV1EventsChainReorgV2{
+			EthV1EventsChainReorgV2: reorg,
+		}
+		clientMeta.AdditionalData = &xatuProto.ClientMeta_EthV1EventsChainReorgV2{
+			EthV1EventsChainReorgV2: &xatuProto.ClientMeta_AdditionalEthV1EventsChainReorgV2Data{
+				Epoch:       epoch,
+				Slot:        slot,
+				Propagation: propagation,
+			},
+		}
+	case BeaconTopicBlobSidecar:
+		sidecar := &ethv1.EventBlobSidecar{
+			BlockRoot:     getBeaconString(data, "block_root"),
+			Slot:          getBeaconUint64(data, "slot"),
+			Index:         getBeaconUint64(data, "index"),
+			KzgCommitment: getBeaconString(data, "kzg_commitment"),
+			VersionedHash: getBeaconString(data, "versioned_hash"),
+		}
+		epoch, slot, propagation := h.slotAdditionalData(sidecar.Slot, event.Timestamp)
+
+		decoratedEvent.Event.Name = xatuProto.Event_BEACON_API_ETH_V1_EVENTS_BLOB_SIDECAR
+		decoratedEvent.Data = &xatuProto.DecoratedEvent_EthV1EventsBlobSidecar{
+			EthV1EventsBlobSidecar: sidecar,
+		}
+		clientMeta.AdditionalData = &xatuProto.ClientMeta_EthV1EventsBlobSidecar{
+			EthV1EventsBlobSidecar: &xatuProto.ClientMeta_AdditionalEthV1EventsBlobSidecarData{
+				Epoch:       epoch,
+				Slot:        slot,
+				Propagation: propagation,
+			},
+		}
+	default:
+		return nil
+	}
+
+	return decoratedEvent
+}
+
+// slotAdditionalData returns the epoch, slot and propagation data of an event for slot
+// that passed the proxy at timestamp, or nils if the event has no valid slot.
+func (h *BeaconEventsHandler) slotAdditionalData(
+	slot *wrapperspb.UInt64Value,
+	timestamp time.Time,
+) (*xatuProto.EpochV2, *xatuProto.SlotV2, *xatuProto.PropagationV2) {
+	if slot == nil {
+		return nil, nil, nil
+	}
+
+	epoch := h.clock.EpochOfSlot(slot.GetValue())
+
+	return h.clock.epochV2(epoch), h.clock.slotV2(slot.GetValue()), h.clock.propagationV2(slot.GetValue(), timestamp)
+}
+
+// publishPayloadAttributesEvent publishes a payload_attributes event as snooper event.
+func (h *BeaconEventsHandler) publishPayloadAttributesEvent(event *BeaconEvent) {
+	if h.snooperEvents == nil {
+		return
+	}
+
+	snooperEvent := newSnooperEvent(h.publisher, SnooperEventBeaconPayloadAttributes, event.Timestamp, buildPayloadAttributesEventData(event))
+
+	if err := h.snooperEvents.PublishSnooperEvent(snooperEvent); err != nil {
+		h.log.WithError(err).WithField("topic", event.Topic).Error("failed to publish beacon event")
+
+		return
+	}
+
+	h.log.WithFields(logrus.Fields{
+		"call_id": event.CallID,
+		"topic":   event.Topic,
+	}).Debug("published beacon event")
+}
+
+func buildPayloadAttributesEventData(event *BeaconEvent) *PayloadAttributesEventData {
+	eventData := &PayloadAttributesEventData{
+		Version: getBeaconString(event.Data, "version"),
+	}
+
+	// payload_attributes events wrap the attributes in a versioned envelope
+	data, ok := event.Data["data"].(map[string]any)
+	if !ok {
+		return eventData
+	}
+
+	eventData.ProposerIndex = getBeaconUint64(data, "proposer_index").GetValue()
+	eventData.ProposalSlot = getBeaconUint64(data, "proposal_slot").GetValue()
+	eventData.ParentBlockNumber = getBeaconUint64(data, "parent_block_number").GetValue()
+	eventData.ParentBlockRoot = getBeaconString(data, "parent_block_root")
+	eventData.ParentBlockHash = getBeaconString(data, "parent_block_hash")
+
+	attributes, ok := data["payload_attributes"].(map[string]any)
+	if !ok {
+		return eventData
+	}
+
+	eventData.Timestamp = getBeaconUint64(attributes, "timestamp").GetValue()
+	eventData.PrevRandao = getBeaconString(attributes, "prev_randao")
+	eventData.SuggestedFeeRecipient = getBeaconString(attributes, "suggested_fee_recipient")
+	eventData.ParentBeaconBlockRoot = getBeaconString(attributes, "parent_beacon_block_root")
+
+	if withdrawals, ok := attributes["withdrawals"].([]any); ok {
+		//nolint:gosec // Safe: withdrawal count cannot exceed uint32 in practice
+		eventData.WithdrawalsCount = uint32(len(withdrawals))
+	}
+
+	return eventData
+}
+
+// getBeaconString returns a string field of beacon event data.
+func getBeaconString(data map[string]any, key string) string {
+	value, _ := data[key].(string)
+
+	return value
+}
+
+// getBeaconBool returns a boolean field of beacon event data.
+func getBeaconBool(data map[string]any, key string) bool {
+	value, _ := data[key].(bool)
+
+	return value
+}
+
+// getBeaconUint64 returns a quoted decimal integer field of beacon event data, or nil
+// if the field is missing or not a valid integer.
+func getBeaconUint64(data map[string]any, key string) *wrapperspb.UInt64Value {
+	str, ok := data[key].(string)
+	if !ok {
+		return nil
+	}
+
+	value, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	return wrapperspb.UInt64(value)
+}
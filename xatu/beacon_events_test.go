@@ -0,0 +1,167 @@
+package xatu
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	xatuProto "github.com/ethpandaops/xatu/pkg/proto/xatu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturingPublisher is a Publisher keeping all published events.
+type capturingPublisher struct {
+	noopPublisher
+
+	mu     sync.Mutex
+	events []*xatuProto.DecoratedEvent
+}
+
+func (p *capturingPublisher) Publish(_ context.Context, event *xatuProto.DecoratedEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, event)
+
+	return nil
+}
+
+func newBeaconEvent(t *testing.T, topic, data string) *BeaconEvent {
+	t.Helper()
+
+	event := &BeaconEvent{
+		CallID:    1,
+		Timestamp: time.Unix(1700000012, 345000000),
+		Topic:     topic,
+	}
+	require.NoError(t, json.Unmarshal([]byte(data), &event.Data))
+
+	return event
+}
+
+func TestBeaconEventsHandlerPublishesEvents(t *testing.T) {
+	logger, _ := newCapturingLogger()
+	publisher := &capturingPublisher{}
+	router := NewRouter(logger)
+	router.RegisterBeaconHandler(NewBeaconEventsHandler(publisher, nil, NewBeaconClock(time.Unix(1699999880, 0), 12, 32), logger))
+
+	events := []*BeaconEvent{
+		newBeaconEvent(t, "head", `{"slot":"10","block":"0x9a2f","state":"0x600e","epoch_transition":true,"previous_duty_dependent_root":"0x5e0a","current_duty_dependent_root":"0x5e0b","execution_optimistic":false}`),
+		newBeaconEvent(t, "block", `{"slot":"10","block":"0x9a2f","execution_optimistic":true}`),
+		newBeaconEvent(t, "finalized_checkpoint", `{"block":"0x9a2f","state":"0x600e","epoch":"2","execution_optimistic":false}`),
+		newBeaconEvent(t, "chain_reorg", `{"slot":"200","depth":"2","old_head_block":"0x9a2f","new_head_block":"0x76f2","old_head_state":"0x600e","new_head_state":"0x600f","epoch":"6","execution_optimistic":false}`),
+		newBeaconEvent(t, "blob_sidecar", `{"block_root":"0x9a2f","index":"1","slot":"10","kzg_commitment":"0x1b66","versioned_hash":"0x01d1"}`),
+	}
+
+	for _, event := range events {
+		assert.True(t, router.RouteBeaconEvent(event), event.Topic)
+	}
+
+	require.Len(t, publisher.events, len(events))
+
+	for _, event := range publisher.events {
+		assert.Equal(t, time.Unix(1700000012, 345000000).UTC(), event.GetEvent().DateTime.AsTime(), "Events must carry the observation time")
+	}
+
+	head, ok := publisher.events[0].Data.(*xatuProto.DecoratedEvent_EthV1EventsHeadV2)
+	require.True(t, ok)
+	assert.Equal(t, xatuProto.Event_BEACON_API_ETH_V1_EVENTS_HEAD_V2, publisher.events[0].GetEvent().GetName())
+	assert.Equal(t, uint64(10), head.EthV1EventsHeadV2.Slot.GetValue())
+	assert.Equal(t, "0x9a2f", head.EthV1EventsHeadV2.Block)
+	assert.True(t, head.EthV1EventsHeadV2.EpochTransition)
+	assert.Equal(t, "0x5e0b", head.EthV1EventsHeadV2.CurrentDutyDependentRoot)
+
+	// slot 10 started at 1700000000, 12.345s before the event passed the proxy
+	headMeta, ok := publisher.events[0].GetMeta().GetClient().AdditionalData.(*xatuProto.ClientMeta_EthV1EventsHeadV2)
+	require.True(t, ok)
+	assert.Equal(t, uint64(10), headMeta.EthV1EventsHeadV2.Slot.Number.GetValue())
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), headMeta.EthV1EventsHeadV2.Slot.StartDateTime.AsTime())
+	assert.Equal(t, uint64(0), headMeta.EthV1EventsHeadV2.Epoch.Number.GetValue())
+	assert.Equal(t, time.Unix(1699999880, 0).UTC(), headMeta.EthV1EventsHeadV2.Epoch.StartDateTime.AsTime())
+	assert.Equal(t, uint64(12345), headMeta.EthV1EventsHeadV2.Propagation.SlotStartDiff.GetValue())
+
+	block, ok := publisher.events[1].Data.(*xatuProto.DecoratedEvent_EthV1EventsBlockV2)
+	require.True(t, ok)
+	assert.True(t, block.EthV1EventsBlockV2.ExecutionOptimistic)
+
+	finalized, ok := publisher.events[2].Data.(*xatuProto.DecoratedEvent_EthV1EventsFinalizedCheckpointV2)
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), finalized.EthV1EventsFinalizedCheckpointV2.Epoch.GetValue())
+
+	finalizedMeta, ok := publisher.events[2].GetMeta().GetClient().AdditionalData.(*xatuProto.ClientMeta_EthV1EventsFinalizedCheckpointV2)
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), finalizedMeta.EthV1EventsFinalizedCheckpointV2.Epoch.Number.GetValue())
+	assert.Equal(t, time.Unix(1699999880+2*32*12, 0).UTC(), finalizedMeta.EthV1EventsFinalizedCheckpointV2.Epoch.StartDateTime.AsTime())
+
+	reorg, ok := publisher.events[3].Data.(*xatuProto.DecoratedEvent_EthV1EventsChainReorgV2)
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), reorg.EthV1EventsChainReorgV2.Depth.GetValue())
+	assert.Equal(t, "0x76f2", reorg.EthV1EventsChainReorgV2.NewHeadBlock)
+
+	// slot 200 starts after the event passed the proxy
+	reorgMeta, ok := publisher.events[3].GetMeta().GetClient().AdditionalData.(*xatuProto.ClientMeta_EthV1EventsChainReorgV2)
+	require.True(t, ok)
+	assert.Equal(t, uint64(6), reorgMeta.EthV1EventsChainReorgV2.Epoch.Number.GetValue())
+	assert.Equal(t, uint64(0), reorgMeta.EthV1EventsChainReorgV2.Propagation.SlotStartDiff.GetValue())
+
+	sidecar, ok := publisher.events[4].Data.(*xatuProto.DecoratedEvent_EthV1EventsBlobSidecar)
+	require.True(t, ok)
+	assert.Equal(t, xatuProto.Event_BEACON_API_ETH_V1_EVENTS_BLOB_SIDECAR, publisher.events[4].GetEvent().GetName())
+	assert.Equal(t, uint64(1), sidecar.EthV1EventsBlobSidecar.Index.GetValue())
+	assert.Equal(t, "0x01d1", sidecar.EthV1EventsBlobSidecar.VersionedHash)
+
+	sidecarMeta, ok := publisher.events[4].GetMeta().GetClient().AdditionalData.(*xatuProto.ClientMeta_EthV1EventsBlobSidecar)
+	require.True(t, ok)
+	assert.Equal(t, uint64(10), sidecarMeta.EthV1EventsBlobSidecar.Slot.Number.GetValue())
+	assert.Equal(t, uint64(12345), sidecarMeta.EthV1EventsBlobSidecar.Propagation.SlotStartDiff.GetValue())
+}
+
+func TestBeaconEventsHandlerPayloadAttributes(t *testing.T) {
+	logger, _ := newCapturingLogger()
+	publisher := &capturingPublisher{}
+	snooperEvents := &capturingSnooperEvents{}
+	router := NewRouter(logger)
+	router.RegisterBeaconHandler(NewBeaconEventsHandler(publisher, snooperEvents, NewBeaconClock(time.Time{}, 0, 0), logger))
+
+	assert.True(t, router.RouteBeaconEvent(newBeaconEvent(t, "payload_attributes", `{"version":"electra","data":{"proposer_index":"5","proposal_slot":"11","parent_block_hash":"0x7c3e","payload_attributes":{"timestamp":"1700000024","suggested_fee_recipient":"0xf97e","withdrawals":[{"index":"1"},{"index":"2"}]}}}`)))
+	assert.False(t, router.RouteBeaconEvent(newBeaconEvent(t, "attestation", `{"aggregation_bits":"0x01"}`)))
+
+	assert.Empty(t, publisher.events, "payload_attributes events must not be sent to the xatu outputs")
+	require.Len(t, snooperEvents.events, 1)
+
+	event := snooperEvents.events[0]
+	assert.Equal(t, SnooperEventBeaconPayloadAttributes, event.Name)
+	assert.Equal(t, time.Unix(1700000012, 345000000).UTC(), event.DateTime, "Events must carry the observation time")
+
+	data, ok := event.Data.(*PayloadAttributesEventData)
+	require.True(t, ok)
+	assert.Equal(t, "electra", data.Version)
+	assert.Equal(t, uint64(5), data.ProposerIndex)
+	assert.Equal(t, uint64(11), data.ProposalSlot)
+	assert.Equal(t, "0x7c3e", data.ParentBlockHash)
+	assert.Equal(t, uint64(1700000024), data.Timestamp)
+	assert.Equal(t, uint32(2), data.WithdrawalsCount)
+}
+
+func TestBeaconEventsHandlerWithoutSnooperEvents(t *testing.T) {
+	logger, _ := newCapturingLogger()
+	publisher := &capturingPublisher{}
+	router := NewRouter(logger)
+	router.RegisterBeaconHandler(NewBeaconEventsHandler(publisher, nil, NewBeaconClock(time.Time{}, 0, 0), logger))
+
+	assert.False(t, router.RouteBeaconEvent(newBeaconEvent(t, "payload_attributes", `{"version":"electra","data":{"proposal_slot":"11"}}`)))
+	assert.True(t, router.RouteBeaconEvent(newBeaconEvent(t, "head", `{"slot":"10","block":"0x9a2f"}`)))
+
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, xatuProto.Event_BEACON_API_ETH_V1_EVENTS_HEAD_V2, publisher.events[0].GetEvent().GetName())
+
+	// without genesis time, only the slot and epoch numbers are known
+	headMeta, ok := publisher.events[0].GetMeta().GetClient().AdditionalData.(*xatuProto.ClientMeta_EthV1EventsHeadV2)
+	require.True(t, ok)
+	assert.Equal(t, uint64(10), headMeta.EthV1EventsHeadV2.Slot.Number.GetValue())
+	assert.Nil(t, headMeta.EthV1EventsHeadV2.Slot.StartDateTime)
+	assert.Nil(t, headMeta.EthV1EventsHeadV2.Propagation)
+}
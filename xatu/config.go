@@ -29,15 +29,31 @@ type Config struct {
 	// NetworkID is the network ID of the Ethereum network.
 	NetworkID uint64
 
+	// GenesisTime is the beacon chain genesis time, used for the slot start times and
+	// propagation delays of beacon events. Defaults to the genesis time of mainnet,
+	// sepolia, holesky and hoodi by NetworkName.
+	GenesisTime time.Time
+
+	// SecondsPerSlot is the beacon chain slot duration (default: 12).
+	SecondsPerSlot uint64
+
+	// SlotsPerEpoch is the number of slots per beacon chain epoch (default: 32).
+	SlotsPerEpoch uint64
+
 	// Labels are custom key-value pairs added to event metadata.
 	Labels map[string]string
 
 	// Outputs defines where events are published.
 	Outputs []OutputConfig
 
-	// TLS enables TLS for xatu:// outputs.
+	// TLS enables TLS for xatu:// and kafka outputs.
 	TLS bool
 
+	// SnooperEventsFile is the file snooper events are appended to, as JSON lines.
+	// Calls and beacon events the xatu event schema has no event for are only
+	// published if it is set.
+	SnooperEventsFile string
+
 	// Headers are custom headers for HTTP/Xatu outputs.
 	Headers map[string]string
 
@@ -118,11 +134,21 @@ func (o *OutputConfig) Validate() error {
 	case OutputTypeStdout:
 		// stdout doesn't require an address
 		return nil
-	case OutputTypeHTTP, OutputTypeXatu, OutputTypeKafka:
+	case OutputTypeHTTP, OutputTypeXatu:
 		if o.Address == "" {
 			return fmt.Errorf("address is required for output type %q", o.Type)
 		}
 
+		return nil
+	case OutputTypeKafka:
+		if o.Address == "" {
+			return fmt.Errorf("address is required for output type %q", o.Type)
+		}
+
+		if _, _, err := ParseKafkaAddress(o.Address); err != nil {
+			return err
+		}
+
 		return nil
 	default:
 		return fmt.Errorf("unknown output type %q (valid: %s, %s, %s, %s)",
@@ -162,6 +188,32 @@ func ParseOutputFlag(s string) (OutputConfig, error) {
 	}, nil
 }
 
+// ParseKafkaAddress splits a kafka output address in "broker1:9092,broker2:9092/topic"
+// format into its comma-separated broker list and topic.
+func ParseKafkaAddress(address string) (brokers, topic string, err error) {
+	idx := strings.LastIndex(address, "/")
+	if idx < 0 {
+		return "", "", fmt.Errorf("invalid kafka address %q (expected brokers/topic)", address)
+	}
+
+	topic = strings.TrimSpace(address[idx+1:])
+	if topic == "" {
+		return "", "", fmt.Errorf("invalid kafka address %q: topic cannot be empty", address)
+	}
+
+	brokerList := strings.Split(address[:idx], ",")
+	for i, broker := range brokerList {
+		broker = strings.TrimSpace(broker)
+		if broker == "" {
+			return "", "", fmt.Errorf("invalid kafka address %q: broker cannot be empty", address)
+		}
+
+		brokerList[i] = broker
+	}
+
+	return strings.Join(brokerList, ","), topic, nil
+}
+
 // ParseLabelFlag parses a label flag value in "key=value" format.
 func ParseLabelFlag(s string) (key, value string, err error) {
 	return parseKeyValueFlag(s, "label")
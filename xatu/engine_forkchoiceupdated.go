@@ -0,0 +1,279 @@
+package xatu
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PendingForkchoiceUpdatedCall stores request data awaiting response correlation.
+type PendingForkchoiceUpdatedCall struct {
+	CallID           uint64
+	RequestTimestamp time.Time
+	MethodVersion    string
+
+	// Forkchoice state fields
+	HeadBlockHash      string
+	SafeBlockHash      string
+	FinalizedBlockHash string
+
+	// Payload attributes fields, only set if HasPayloadAttributes is true
+	HasPayloadAttributes  bool
+	Timestamp             uint64
+	SuggestedFeeRecipient string
+	WithdrawalsCount      uint32
+	ParentBeaconBlockRoot string
+}
+
+// ForkchoiceUpdatedCall is a correlated engine_forkchoiceUpdated request/response pair.
+type ForkchoiceUpdatedCall struct {
+	*PendingForkchoiceUpdatedCall
+
+	Duration time.Duration
+
+	// Response data
+	Status          string
+	LatestValidHash string
+	ValidationError string
+	PayloadID       string
+}
+
+// ForkchoiceUpdatedEventData is the data of SnooperEventEngineForkchoiceUpdated events.
+type ForkchoiceUpdatedEventData struct {
+	RequestedAt        time.Time `json:"requested_at"`
+	DurationMs         int64     `json:"duration_ms"`
+	MethodVersion      string    `json:"method_version"`
+	HeadBlockHash      string    `json:"head_block_hash"`
+	SafeBlockHash      string    `json:"safe_block_hash"`
+	FinalizedBlockHash string    `json:"finalized_block_hash"`
+
+	// PayloadAttributes is nil if the call did not start a payload build
+	PayloadAttributes *ForkchoiceUpdatedPayloadAttributes `json:"payload_attributes,omitempty"`
+
+	Status          string `json:"status"`
+	LatestValidHash string `json:"latest_valid_hash,omitempty"`
+	ValidationError string `json:"validation_error,omitempty"`
+	PayloadID       string `json:"payload_id,omitempty"`
+}
+
+// ForkchoiceUpdatedPayloadAttributes holds the payload attributes of an
+// engine_forkchoiceUpdated call.
+type ForkchoiceUpdatedPayloadAttributes struct {
+	Timestamp             uint64 `json:"timestamp"`
+	SuggestedFeeRecipient string `json:"suggested_fee_recipient"`
+	WithdrawalsCount      uint32 `json:"withdrawals_count"`
+	ParentBeaconBlockRoot string `json:"parent_beacon_block_root,omitempty"`
+}
+
+// EngineForkchoiceUpdatedHandler handles engine_forkchoiceUpdated* events and publishes
+// them as SnooperEventEngineForkchoiceUpdated snooper events, as the xatu event schema
+// has no event for them.
+type EngineForkchoiceUpdatedHandler struct {
+	publisher     Publisher
+	snooperEvents SnooperEventSink
+	log           logrus.FieldLogger
+
+	pending map[callKey]*PendingForkchoiceUpdatedCall
+	mu      sync.Mutex
+}
+
+// NewEngineForkchoiceUpdatedHandler creates a new engine_forkchoiceUpdated handler.
+func NewEngineForkchoiceUpdatedHandler(
+	publisher Publisher,
+	snooperEvents SnooperEventSink,
+	log logrus.FieldLogger,
+) *EngineForkchoiceUpdatedHandler {
+	return &EngineForkchoiceUpdatedHandler{
+		publisher:     publisher,
+		snooperEvents: snooperEvents,
+		log:           log.WithField("handler", "engine_forkchoiceUpdated"),
+		pending:       make(map[callKey]*PendingForkchoiceUpdatedCall, DefaultPendingCapacity),
+	}
+}
+
+// Name returns the handler name.
+func (h *EngineForkchoiceUpdatedHandler) Name() string {
+	return "engine_forkchoiceUpdated"
+}
+
+// MethodMatcher returns a function that checks if a method matches engine_forkchoiceUpdated*.
+func (h *EngineForkchoiceUpdatedHandler) MethodMatcher() func(method string) bool {
+	return func(method string) bool {
+		return strings.HasPrefix(method, "engine_forkchoiceUpdated")
+	}
+}
+
+func (h *EngineForkchoiceUpdatedHandler) cleanupStale() {
+	cutoff := time.Now().Add(-30 * time.Second)
+	for key, pending := range h.pending {
+		if pending.RequestTimestamp.Before(cutoff) {
+			delete(h.pending, key)
+		}
+	}
+}
+
+// HandleRequest processes the request and stores pending data.
+func (h *EngineForkchoiceUpdatedHandler) HandleRequest(event *RequestEvent) bool {
+	pending := h.extractForkchoiceData(event)
+
+	h.mu.Lock()
+	h.cleanupStale()
+	h.pending[event.key()] = pending
+	h.mu.Unlock()
+
+	h.log.WithFields(logrus.Fields{
+		"call_id":            event.CallID,
+		"head_block_hash":    pending.HeadBlockHash,
+		"payload_attributes": pending.HasPayloadAttributes,
+		"method_version":     pending.MethodVersion,
+	}).Debug("captured engine_forkchoiceUpdated request")
+
+	return true // Process response
+}
+
+// HandleResponse processes the response, correlates with request, and publishes the event.
+func (h *EngineForkchoiceUpdatedHandler) HandleResponse(event *ResponseEvent) {
+	h.mu.Lock()
+
+	pending, ok := h.pending[event.key()]
+	if !ok {
+		h.mu.Unlock()
+		h.log.WithField("call_id", event.CallID).Warn("no pending request found for response")
+
+		return
+	}
+
+	delete(h.pending, event.key())
+
+	h.mu.Unlock()
+
+	call := buildForkchoiceUpdatedCall(pending, event)
+
+	snooperEvent := newSnooperEvent(h.publisher, SnooperEventEngineForkchoiceUpdated, event.Timestamp, buildForkchoiceUpdatedEventData(call))
+
+	if err := h.snooperEvents.PublishSnooperEvent(snooperEvent); err != nil {
+		h.log.WithError(err).Error("failed to publish engine_forkchoiceUpdated event")
+
+		return
+	}
+
+	h.log.WithFields(logrus.Fields{
+		"call_id":         event.CallID,
+		"duration_ms":     call.Duration.Milliseconds(),
+		"head_block_hash": call.HeadBlockHash,
+		"status":          call.Status,
+		"payload_id":      call.PayloadID,
+	}).Debug("published engine_forkchoiceUpdated event")
+}
+
+func buildForkchoiceUpdatedEventData(call *ForkchoiceUpdatedCall) *ForkchoiceUpdatedEventData {
+	data := &ForkchoiceUpdatedEventData{
+		RequestedAt:        call.RequestTimestamp.UTC(),
+		DurationMs:         call.Duration.Milliseconds(),
+		MethodVersion:      call.MethodVersion,
+		HeadBlockHash:      call.HeadBlockHash,
+		SafeBlockHash:      call.SafeBlockHash,
+		FinalizedBlockHash: call.FinalizedBlockHash,
+		Status:             call.Status,
+		LatestValidHash:    call.LatestValidHash,
+		ValidationError:    call.ValidationError,
+		PayloadID:          call.PayloadID,
+	}
+
+	if call.HasPayloadAttributes {
+		data.PayloadAttributes = &ForkchoiceUpdatedPayloadAttributes{
+			Timestamp:             call.Timestamp,
+			SuggestedFeeRecipient: call.SuggestedFeeRecipient,
+			WithdrawalsCount:      call.WithdrawalsCount,
+			ParentBeaconBlockRoot: call.ParentBeaconBlockRoot,
+		}
+	}
+
+	return data
+}
+
+func (h *EngineForkchoiceUpdatedHandler) extractForkchoiceData(event *RequestEvent) *PendingForkchoiceUpdatedCall {
+	pending := &PendingForkchoiceUpdatedCall{
+		CallID:           event.CallID,
+		RequestTimestamp: event.Timestamp,
+		MethodVersion:    extractForkchoiceUpdatedMethodVersion(event.Method),
+	}
+
+	// params[0] is the ForkchoiceState
+	if len(event.Params) == 0 {
+		return pending
+	}
+
+	if state, ok := event.Params[0].(map[string]any); ok {
+		pending.HeadBlockHash, _ = state["headBlockHash"].(string)
+		pending.SafeBlockHash, _ = state["safeBlockHash"].(string)
+		pending.FinalizedBlockHash, _ = state["finalizedBlockHash"].(string)
+	}
+
+	// params[1] is the optional PayloadAttributes (null if no payload is built)
+	if len(event.Params) < 2 {
+		return pending
+	}
+
+	attributes, ok := event.Params[1].(map[string]any)
+	if !ok {
+		return pending
+	}
+
+	pending.HasPayloadAttributes = true
+
+	if timestamp, ok := attributes["timestamp"].(string); ok {
+		pending.Timestamp = hexToUint64(timestamp)
+	}
+
+	pending.SuggestedFeeRecipient, _ = attributes["suggestedFeeRecipient"].(string)
+	pending.ParentBeaconBlockRoot, _ = attributes["parentBeaconBlockRoot"].(string)
+
+	if withdrawals, ok := attributes["withdrawals"].([]any); ok {
+		//nolint:gosec // Safe: withdrawal count cannot exceed uint32 in practice
+		pending.WithdrawalsCount = uint32(len(withdrawals))
+	}
+
+	return pending
+}
+
+func buildForkchoiceUpdatedCall(pending *PendingForkchoiceUpdatedCall, resp *ResponseEvent) *ForkchoiceUpdatedCall {
+	call := &ForkchoiceUpdatedCall{
+		PendingForkchoiceUpdatedCall: pending,
+		Duration:                     max(resp.Duration, 0),
+	}
+
+	if resp.Error != nil {
+		call.Status = statusError
+		call.ValidationError = resp.Error.Message
+
+		return call
+	}
+
+	// Result should be a ForkchoiceUpdatedResponseV1 object
+	result, ok := resp.Result.(map[string]any)
+	if !ok {
+		call.Status = statusUnknown
+
+		return call
+	}
+
+	call.Status, call.LatestValidHash, call.ValidationError = extractNewPayloadResponseData(&ResponseEvent{
+		Result: result["payloadStatus"],
+	})
+	call.PayloadID, _ = result["payloadId"].(string)
+
+	return call
+}
+
+// extractForkchoiceUpdatedMethodVersion extracts the version suffix from the method name.
+// e.g., "engine_forkchoiceUpdatedV3" -> "V3"
+func extractForkchoiceUpdatedMethodVersion(method string) string {
+	if version, found := strings.CutPrefix(method, "engine_forkchoiceUpdated"); found && version != "" {
+		return version
+	}
+
+	return ""
+}
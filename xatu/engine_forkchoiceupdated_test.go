@@ -0,0 +1,197 @@
+package xatu
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// engineFixture is a captured Engine API request/response pair from testdata.
+type engineFixture struct {
+	Request struct {
+		Method string `json:"method"`
+		Params []any  `json:"params"`
+	} `json:"request"`
+	Response struct {
+		Result any `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"response"`
+}
+
+// loadEngineFixture returns the request and response events of a captured call.
+func loadEngineFixture(t *testing.T, name string, callID uint64) (*RequestEvent, *ResponseEvent) {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	fixture := &engineFixture{}
+	require.NoError(t, json.Unmarshal(data, fixture))
+
+	requestTime := time.Now()
+
+	req := &RequestEvent{
+		CallID:     callID,
+		BatchIndex: -1,
+		Timestamp:  requestTime,
+		Method:     fixture.Request.Method,
+		Params:     fixture.Request.Params,
+	}
+
+	rsp := &ResponseEvent{
+		CallID:     callID,
+		BatchIndex: -1,
+		Timestamp:  requestTime.Add(42 * time.Millisecond),
+		Duration:   42 * time.Millisecond,
+		Result:     fixture.Response.Result,
+	}
+
+	if fixture.Response.Error != nil {
+		rsp.Error = &RPCError{
+			Code:    fixture.Response.Error.Code,
+			Message: fixture.Response.Error.Message,
+		}
+	}
+
+	return req, rsp
+}
+
+// newCapturingLogger returns a logger recording all entries, including debug logs.
+func newCapturingLogger() (*logrus.Logger, *test.Hook) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	return logger, hook
+}
+
+func TestEngineForkchoiceUpdatedHandlerExtractsRequest(t *testing.T) {
+	logger, _ := newCapturingLogger()
+	handler := NewEngineForkchoiceUpdatedHandler(&capturingPublisher{}, &capturingSnooperEvents{}, logger)
+
+	req, _ := loadEngineFixture(t, "engine_forkchoiceUpdatedV3.json", 1)
+	require.True(t, handler.MethodMatcher()(req.Method))
+
+	pending := handler.extractForkchoiceData(req)
+	assert.Equal(t, "V3", pending.MethodVersion)
+	assert.Equal(t, "0x3559e851470f6e7bbed1db474980683e8c315bfce99b2a6ef47c057c04de7858", pending.HeadBlockHash)
+	assert.Equal(t, "0x2bd4ec8ff8e8a8fd6bb1a6a5a2d3b9ba5a7a1e8d5f7a0b8e7c3e0a2e6c5d9f11", pending.SafeBlockHash)
+	assert.Equal(t, "0x1c5b3f8c2e6a4d9b7f0e3a5c8d2b6f4e9a1c7d3b5f8e2a6c4d9b7f1e3a5c8d22", pending.FinalizedBlockHash)
+	assert.True(t, pending.HasPayloadAttributes)
+	assert.Equal(t, uint64(0x6705d3e0), pending.Timestamp)
+	assert.Equal(t, "0xf97e180c050e5ab072211ad2c213eb5aee4df134", pending.SuggestedFeeRecipient)
+	assert.Equal(t, uint32(2), pending.WithdrawalsCount)
+	assert.Equal(t, "0x9b2a8d1f4c7e0a3b6d9f2c5e8a1b4d7f0c3e6a9b2d5f8c1e4a7b0d3f6c9e2a58", pending.ParentBeaconBlockRoot)
+
+	req, _ = loadEngineFixture(t, "engine_forkchoiceUpdatedV1_syncing.json", 2)
+
+	pending = handler.extractForkchoiceData(req)
+	assert.Equal(t, "V1", pending.MethodVersion)
+	assert.False(t, pending.HasPayloadAttributes)
+	assert.Zero(t, pending.Timestamp)
+}
+
+func TestEngineForkchoiceUpdatedHandlerCorrelatesResponse(t *testing.T) {
+	tests := []struct {
+		fixture         string
+		status          string
+		latestValidHash string
+		validationError string
+		payloadID       string
+	}{
+		{
+			fixture:         "engine_forkchoiceUpdatedV3.json",
+			status:          "VALID",
+			latestValidHash: "0x3559e851470f6e7bbed1db474980683e8c315bfce99b2a6ef47c057c04de7858",
+			payloadID:       "0x0327fd4f1a8d3e21",
+		},
+		{
+			fixture: "engine_forkchoiceUpdatedV1_syncing.json",
+			status:  "SYNCING",
+		},
+		{
+			fixture:         "engine_forkchoiceUpdatedV3_error.json",
+			status:          statusError,
+			validationError: "Invalid forkchoice state",
+		},
+	}
+
+	for idx, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			logger, _ := newCapturingLogger()
+			snooperEvents := &capturingSnooperEvents{}
+			handler := NewEngineForkchoiceUpdatedHandler(&capturingPublisher{}, snooperEvents, logger)
+
+			req, rsp := loadEngineFixture(t, tt.fixture, uint64(idx+1))
+			require.True(t, handler.HandleRequest(req))
+
+			handler.mu.Lock()
+			pending := handler.pending[req.key()]
+			handler.mu.Unlock()
+			require.NotNil(t, pending)
+
+			call := buildForkchoiceUpdatedCall(pending, rsp)
+			assert.Equal(t, tt.status, call.Status)
+			assert.Equal(t, tt.latestValidHash, call.LatestValidHash)
+			assert.Equal(t, tt.validationError, call.ValidationError)
+			assert.Equal(t, tt.payloadID, call.PayloadID)
+			assert.Equal(t, 42*time.Millisecond, call.Duration)
+
+			handler.HandleResponse(rsp)
+
+			handler.mu.Lock()
+			assert.Empty(t, handler.pending, "Pending call must be removed after the response")
+			handler.mu.Unlock()
+
+			require.Len(t, snooperEvents.events, 1)
+
+			event := snooperEvents.events[0]
+			assert.Equal(t, SnooperEventEngineForkchoiceUpdated, event.Name)
+			assert.Equal(t, rsp.Timestamp.UTC(), event.DateTime)
+
+			data, ok := event.Data.(*ForkchoiceUpdatedEventData)
+			require.True(t, ok)
+			assert.Equal(t, tt.status, data.Status)
+			assert.Equal(t, tt.payloadID, data.PayloadID)
+			assert.Equal(t, pending.HeadBlockHash, data.HeadBlockHash)
+			assert.Equal(t, int64(42), data.DurationMs)
+			assert.Equal(t, pending.HasPayloadAttributes, data.PayloadAttributes != nil)
+		})
+	}
+}
+
+func TestEngineForkchoiceUpdatedHandlerKeepsBatchElementsApart(t *testing.T) {
+	logger, _ := newCapturingLogger()
+	snooperEvents := &capturingSnooperEvents{}
+	handler := NewEngineForkchoiceUpdatedHandler(&capturingPublisher{}, snooperEvents, logger)
+
+	req1, rsp1 := loadEngineFixture(t, "engine_forkchoiceUpdatedV3.json", 7)
+	req2, rsp2 := loadEngineFixture(t, "engine_forkchoiceUpdatedV1_syncing.json", 7)
+	req1.BatchIndex, rsp1.BatchIndex = 0, 0
+	req2.BatchIndex, rsp2.BatchIndex = 1, 1
+
+	handler.HandleRequest(req1)
+	handler.HandleRequest(req2)
+	handler.HandleResponse(rsp2)
+	handler.HandleResponse(rsp1)
+
+	var statuses []string
+
+	for _, event := range snooperEvents.events {
+		data, ok := event.Data.(*ForkchoiceUpdatedEventData)
+		require.True(t, ok)
+
+		statuses = append(statuses, data.Status)
+	}
+
+	assert.Equal(t, []string{"SYNCING", "VALID"}, statuses)
+}
@@ -26,7 +26,7 @@ type EngineGetBlobsHandler struct {
 	publisher Publisher
 	log       logrus.FieldLogger
 
-	pending map[uint64]*PendingGetBlobsCall
+	pending map[callKey]*PendingGetBlobsCall
 	mu      sync.Mutex
 }
 
@@ -35,7 +35,7 @@ func NewEngineGetBlobsHandler(publisher Publisher, log logrus.FieldLogger) *Engi
 	return &EngineGetBlobsHandler{
 		publisher: publisher,
 		log:       log.WithField("handler", "engine_getBlobs"),
-		pending:   make(map[uint64]*PendingGetBlobsCall, DefaultPendingCapacity),
+		pending:   make(map[callKey]*PendingGetBlobsCall, DefaultPendingCapacity),
 	}
 }
 
@@ -53,9 +53,9 @@ func (h *EngineGetBlobsHandler) MethodMatcher() func(method string) bool {
 
 func (h *EngineGetBlobsHandler) cleanupStale() {
 	cutoff := time.Now().Add(-30 * time.Second)
-	for id, pending := range h.pending {
+	for key, pending := range h.pending {
 		if pending.RequestTimestamp.Before(cutoff) {
-			delete(h.pending, id)
+			delete(h.pending, key)
 		}
 	}
 }
@@ -67,7 +67,7 @@ func (h *EngineGetBlobsHandler) HandleRequest(event *RequestEvent) bool {
 
 	h.mu.Lock()
 	h.cleanupStale()
-	h.pending[event.CallID] = &PendingGetBlobsCall{
+	h.pending[event.key()] = &PendingGetBlobsCall{
 		CallID:           event.CallID,
 		RequestTimestamp: event.Timestamp,
 		VersionedHashes:  hashes,
@@ -88,7 +88,7 @@ func (h *EngineGetBlobsHandler) HandleRequest(event *RequestEvent) bool {
 func (h *EngineGetBlobsHandler) HandleResponse(event *ResponseEvent) {
 	h.mu.Lock()
 
-	pending, ok := h.pending[event.CallID]
+	pending, ok := h.pending[event.key()]
 	if !ok {
 		h.mu.Unlock()
 		h.log.WithField("call_id", event.CallID).Warn("no pending request found for response")
@@ -96,7 +96,7 @@ func (h *EngineGetBlobsHandler) HandleResponse(event *ResponseEvent) {
 		return
 	}
 
-	delete(h.pending, event.CallID)
+	delete(h.pending, event.key())
 
 	h.mu.Unlock()
 
@@ -0,0 +1,300 @@
+package xatu
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PendingGetPayloadCall stores request data awaiting response correlation.
+type PendingGetPayloadCall struct {
+	CallID           uint64
+	RequestTimestamp time.Time
+	MethodVersion    string
+	PayloadID        string
+}
+
+// GetPayloadCall is a correlated engine_getPayload request/response pair.
+type GetPayloadCall struct {
+	*PendingGetPayloadCall
+
+	Duration time.Duration
+	Status   string
+	Error    string
+
+	// Execution payload fields
+	BlockNumber uint64
+	BlockHash   string
+	GasUsed     uint64
+	GasLimit    uint64
+	TxCount     uint32
+
+	// Block value in wei, as returned by the execution client (hex encoded)
+	BlockValue string
+
+	// Blobs bundle stats
+	BlobCommitmentsCount uint32
+	BlobProofsCount      uint32
+	BlobBytes            uint64
+
+	ExecutionRequestsCount uint32
+	ShouldOverrideBuilder  bool
+}
+
+// GetPayloadEventData is the data of SnooperEventEngineGetPayload events.
+type GetPayloadEventData struct {
+	RequestedAt   time.Time `json:"requested_at"`
+	DurationMs    int64     `json:"duration_ms"`
+	MethodVersion string    `json:"method_version"`
+	PayloadID     string    `json:"payload_id"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+	GasUsed     uint64 `json:"gas_used"`
+	GasLimit    uint64 `json:"gas_limit"`
+	TxCount     uint32 `json:"tx_count"`
+	BlockValue  string `json:"block_value,omitempty"`
+
+	BlobCommitmentsCount   uint32 `json:"blob_commitments_count"`
+	BlobProofsCount        uint32 `json:"blob_proofs_count"`
+	BlobBytes              uint64 `json:"blob_bytes"`
+	ExecutionRequestsCount uint32 `json:"execution_requests_count"`
+	ShouldOverrideBuilder  bool   `json:"should_override_builder"`
+}
+
+// EngineGetPayloadHandler handles engine_getPayload* events and publishes them as
+// SnooperEventEngineGetPayload snooper events, as the xatu event schema has no event
+// for them.
+type EngineGetPayloadHandler struct {
+	publisher     Publisher
+	snooperEvents SnooperEventSink
+	log           logrus.FieldLogger
+
+	pending map[callKey]*PendingGetPayloadCall
+	mu      sync.Mutex
+}
+
+// NewEngineGetPayloadHandler creates a new engine_getPayload handler.
+func NewEngineGetPayloadHandler(
+	publisher Publisher,
+	snooperEvents SnooperEventSink,
+	log logrus.FieldLogger,
+) *EngineGetPayloadHandler {
+	return &EngineGetPayloadHandler{
+		publisher:     publisher,
+		snooperEvents: snooperEvents,
+		log:           log.WithField("handler", "engine_getPayload"),
+		pending:       make(map[callKey]*PendingGetPayloadCall, DefaultPendingCapacity),
+	}
+}
+
+// Name returns the handler name.
+func (h *EngineGetPayloadHandler) Name() string {
+	return "engine_getPayload"
+}
+
+// MethodMatcher returns a function that checks if a method matches engine_getPayload*.
+// engine_getPayloadBodies* calls are not matched.
+func (h *EngineGetPayloadHandler) MethodMatcher() func(method string) bool {
+	return func(method string) bool {
+		return strings.HasPrefix(method, "engine_getPayload") && !strings.HasPrefix(method, "engine_getPayloadBodies")
+	}
+}
+
+func (h *EngineGetPayloadHandler) cleanupStale() {
+	cutoff := time.Now().Add(-30 * time.Second)
+	for key, pending := range h.pending {
+		if pending.RequestTimestamp.Before(cutoff) {
+			delete(h.pending, key)
+		}
+	}
+}
+
+// HandleRequest processes the request and stores pending data.
+func (h *EngineGetPayloadHandler) HandleRequest(event *RequestEvent) bool {
+	pending := &PendingGetPayloadCall{
+		CallID:           event.CallID,
+		RequestTimestamp: event.Timestamp,
+		MethodVersion:    extractGetPayloadMethodVersion(event.Method),
+	}
+
+	// params[0] is the payload id
+	if len(event.Params) > 0 {
+		pending.PayloadID, _ = event.Params[0].(string)
+	}
+
+	h.mu.Lock()
+	h.cleanupStale()
+	h.pending[event.key()] = pending
+	h.mu.Unlock()
+
+	h.log.WithFields(logrus.Fields{
+		"call_id":        event.CallID,
+		"payload_id":     pending.PayloadID,
+		"method_version": pending.MethodVersion,
+	}).Debug("captured engine_getPayload request")
+
+	return true // Process response
+}
+
+// HandleResponse processes the response, correlates with request, and publishes the event.
+func (h *EngineGetPayloadHandler) HandleResponse(event *ResponseEvent) {
+	h.mu.Lock()
+
+	pending, ok := h.pending[event.key()]
+	if !ok {
+		h.mu.Unlock()
+		h.log.WithField("call_id", event.CallID).Warn("no pending request found for response")
+
+		return
+	}
+
+	delete(h.pending, event.key())
+
+	h.mu.Unlock()
+
+	call := buildGetPayloadCall(pending, event)
+
+	snooperEvent := newSnooperEvent(h.publisher, SnooperEventEngineGetPayload, event.Timestamp, buildGetPayloadEventData(call))
+
+	if err := h.snooperEvents.PublishSnooperEvent(snooperEvent); err != nil {
+		h.log.WithError(err).Error("failed to publish engine_getPayload event")
+
+		return
+	}
+
+	h.log.WithFields(logrus.Fields{
+		"call_id":      event.CallID,
+		"duration_ms":  call.Duration.Milliseconds(),
+		"payload_id":   call.PayloadID,
+		"status":       call.Status,
+		"block_number": call.BlockNumber,
+		"block_hash":   call.BlockHash,
+		"block_value":  call.BlockValue,
+		"tx_count":     call.TxCount,
+		"blob_count":   call.BlobCommitmentsCount,
+	}).Debug("published engine_getPayload event")
+}
+
+func buildGetPayloadEventData(call *GetPayloadCall) *GetPayloadEventData {
+	return &GetPayloadEventData{
+		RequestedAt:            call.RequestTimestamp.UTC(),
+		DurationMs:             call.Duration.Milliseconds(),
+		MethodVersion:          call.MethodVersion,
+		PayloadID:              call.PayloadID,
+		Status:                 call.Status,
+		Error:                  call.Error,
+		BlockNumber:            call.BlockNumber,
+		BlockHash:              call.BlockHash,
+		GasUsed:                call.GasUsed,
+		GasLimit:               call.GasLimit,
+		TxCount:                call.TxCount,
+		BlockValue:             call.BlockValue,
+		BlobCommitmentsCount:   call.BlobCommitmentsCount,
+		BlobProofsCount:        call.BlobProofsCount,
+		BlobBytes:              call.BlobBytes,
+		ExecutionRequestsCount: call.ExecutionRequestsCount,
+		ShouldOverrideBuilder:  call.ShouldOverrideBuilder,
+	}
+}
+
+func buildGetPayloadCall(pending *PendingGetPayloadCall, resp *ResponseEvent) *GetPayloadCall {
+	call := &GetPayloadCall{
+		PendingGetPayloadCall: pending,
+		Duration:              max(resp.Duration, 0),
+	}
+
+	if resp.Error != nil {
+		call.Status = statusError
+		call.Error = resp.Error.Message
+
+		return call
+	}
+
+	result, ok := resp.Result.(map[string]any)
+	if !ok {
+		call.Status = statusUnknown
+
+		return call
+	}
+
+	call.Status = "OK"
+
+	// engine_getPayloadV1 returns the ExecutionPayload, later versions wrap it in an
+	// envelope with the block value, blobs bundle and execution requests
+	payload := result
+	if executionPayload, ok := result["executionPayload"].(map[string]any); ok {
+		payload = executionPayload
+	}
+
+	if blockNumber, ok := payload["blockNumber"].(string); ok {
+		call.BlockNumber = hexToUint64(blockNumber)
+	}
+
+	call.BlockHash, _ = payload["blockHash"].(string)
+
+	if gasUsed, ok := payload["gasUsed"].(string); ok {
+		call.GasUsed = hexToUint64(gasUsed)
+	}
+
+	if gasLimit, ok := payload["gasLimit"].(string); ok {
+		call.GasLimit = hexToUint64(gasLimit)
+	}
+
+	if transactions, ok := payload["transactions"].([]any); ok {
+		//nolint:gosec // Safe: transaction count cannot exceed uint32 in practice
+		call.TxCount = uint32(len(transactions))
+	}
+
+	call.BlockValue, _ = result["blockValue"].(string)
+	call.ShouldOverrideBuilder, _ = result["shouldOverrideBuilder"].(bool)
+
+	if blobsBundle, ok := result["blobsBundle"].(map[string]any); ok {
+		call.BlobCommitmentsCount, call.BlobProofsCount, call.BlobBytes = extractBlobsBundleStats(blobsBundle)
+	}
+
+	if executionRequests, ok := result["executionRequests"].([]any); ok {
+		//nolint:gosec // Safe: request count cannot exceed uint32 in practice
+		call.ExecutionRequestsCount = uint32(len(executionRequests))
+	}
+
+	return call
+}
+
+// extractBlobsBundleStats returns the number of commitments and proofs and the total
+// decoded size of the blobs in a blobs bundle.
+func extractBlobsBundleStats(blobsBundle map[string]any) (commitments, proofs uint32, blobBytes uint64) {
+	if list, ok := blobsBundle["commitments"].([]any); ok {
+		//nolint:gosec // Safe: commitment count cannot exceed uint32 in practice
+		commitments = uint32(len(list))
+	}
+
+	if list, ok := blobsBundle["proofs"].([]any); ok {
+		//nolint:gosec // Safe: proof count cannot exceed uint32 in practice
+		proofs = uint32(len(list))
+	}
+
+	if list, ok := blobsBundle["blobs"].([]any); ok {
+		for _, blob := range list {
+			if blobHex, ok := blob.(string); ok {
+				blobBytes += uint64(len(strings.TrimPrefix(blobHex, "0x")) / 2)
+			}
+		}
+	}
+
+	return commitments, proofs, blobBytes
+}
+
+// extractGetPayloadMethodVersion extracts the version suffix from the method name.
+// e.g., "engine_getPayloadV4" -> "V4"
+func extractGetPayloadMethodVersion(method string) string {
+	if version, found := strings.CutPrefix(method, "engine_getPayload"); found && version != "" {
+		return version
+	}
+
+	return ""
+}
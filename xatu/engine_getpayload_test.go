@@ -0,0 +1,115 @@
+package xatu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineGetPayloadHandlerMethodMatcher(t *testing.T) {
+	logger, _ := newCapturingLogger()
+	matcher := NewEngineGetPayloadHandler(&capturingPublisher{}, &capturingSnooperEvents{}, logger).MethodMatcher()
+
+	for _, method := range []string{"engine_getPayloadV1", "engine_getPayloadV3", "engine_getPayloadV5"} {
+		assert.True(t, matcher(method), method)
+	}
+
+	for _, method := range []string{"engine_getPayloadBodiesByHashV1", "engine_getPayloadBodiesByRangeV1", "engine_newPayloadV4"} {
+		assert.False(t, matcher(method), method)
+	}
+}
+
+func TestEngineGetPayloadHandlerCorrelatesResponse(t *testing.T) {
+	logger, _ := newCapturingLogger()
+	snooperEvents := &capturingSnooperEvents{}
+	handler := NewEngineGetPayloadHandler(&capturingPublisher{}, snooperEvents, logger)
+
+	req, rsp := loadEngineFixture(t, "engine_getPayloadV4.json", 1)
+	require.True(t, handler.HandleRequest(req))
+
+	handler.mu.Lock()
+	pending := handler.pending[req.key()]
+	handler.mu.Unlock()
+	require.NotNil(t, pending)
+
+	assert.Equal(t, "V4", pending.MethodVersion)
+	assert.Equal(t, "0x0327fd4f1a8d3e21", pending.PayloadID)
+
+	call := buildGetPayloadCall(pending, rsp)
+	assert.Equal(t, "OK", call.Status)
+	assert.Equal(t, 42*time.Millisecond, call.Duration)
+	assert.Equal(t, uint64(0x1b4), call.BlockNumber)
+	assert.Equal(t, "0x9f3b2a1c8d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a", call.BlockHash)
+	assert.Equal(t, uint64(0xa410), call.GasUsed)
+	assert.Equal(t, uint64(0x1c9c380), call.GasLimit)
+	assert.Equal(t, uint32(2), call.TxCount)
+	assert.Equal(t, "0x1bc16d674ec80000", call.BlockValue)
+	assert.Equal(t, uint32(2), call.BlobCommitmentsCount)
+	assert.Equal(t, uint32(2), call.BlobProofsCount)
+	assert.Equal(t, uint64(128), call.BlobBytes)
+	assert.Equal(t, uint32(2), call.ExecutionRequestsCount)
+	assert.False(t, call.ShouldOverrideBuilder)
+
+	handler.HandleResponse(rsp)
+
+	handler.mu.Lock()
+	assert.Empty(t, handler.pending, "Pending call must be removed after the response")
+	handler.mu.Unlock()
+
+	require.Len(t, snooperEvents.events, 1)
+	assert.Equal(t, SnooperEventEngineGetPayload, snooperEvents.events[0].Name)
+
+	data, ok := snooperEvents.events[0].Data.(*GetPayloadEventData)
+	require.True(t, ok)
+	assert.Equal(t, "0x0327fd4f1a8d3e21", data.PayloadID)
+	assert.Equal(t, uint64(436), data.BlockNumber)
+	assert.Equal(t, "0x1bc16d674ec80000", data.BlockValue)
+	assert.Equal(t, uint32(2), data.BlobCommitmentsCount)
+	assert.Equal(t, uint64(128), data.BlobBytes)
+	assert.Equal(t, int64(42), data.DurationMs)
+}
+
+func TestEngineGetPayloadHandlerV1Payload(t *testing.T) {
+	logger, _ := newCapturingLogger()
+	handler := NewEngineGetPayloadHandler(&capturingPublisher{}, &capturingSnooperEvents{}, logger)
+
+	req, rsp := loadEngineFixture(t, "engine_getPayloadV1.json", 2)
+	handler.HandleRequest(req)
+
+	handler.mu.Lock()
+	pending := handler.pending[req.key()]
+	handler.mu.Unlock()
+	require.NotNil(t, pending)
+
+	// engine_getPayloadV1 returns the bare execution payload
+	call := buildGetPayloadCall(pending, rsp)
+	assert.Equal(t, "V1", call.MethodVersion)
+	assert.Equal(t, "OK", call.Status)
+	assert.Equal(t, uint64(0x1b4), call.BlockNumber)
+	assert.Equal(t, uint32(2), call.TxCount)
+	assert.Empty(t, call.BlockValue)
+	assert.Zero(t, call.BlobCommitmentsCount)
+	assert.Zero(t, call.BlobBytes)
+	assert.Zero(t, call.ExecutionRequestsCount)
+}
+
+func TestEngineGetPayloadHandlerUnknownPayload(t *testing.T) {
+	logger, _ := newCapturingLogger()
+	snooperEvents := &capturingSnooperEvents{}
+	handler := NewEngineGetPayloadHandler(&capturingPublisher{}, snooperEvents, logger)
+
+	req, rsp := loadEngineFixture(t, "engine_getPayloadV5_unknown.json", 3)
+	handler.HandleRequest(req)
+	handler.HandleResponse(rsp)
+
+	require.Len(t, snooperEvents.events, 1)
+
+	data, ok := snooperEvents.events[0].Data.(*GetPayloadEventData)
+	require.True(t, ok)
+	assert.Equal(t, statusError, data.Status)
+	assert.Equal(t, "0x00000000000000ff", data.PayloadID)
+	assert.Zero(t, data.BlockNumber)
+	assert.NotEmpty(t, data.Error)
+}
@@ -42,7 +42,7 @@ type EngineNewPayloadHandler struct {
 	publisher Publisher
 	log       logrus.FieldLogger
 
-	pending map[uint64]*PendingNewPayloadCall
+	pending map[callKey]*PendingNewPayloadCall
 	mu      sync.Mutex
 }
 
@@ -51,7 +51,7 @@ func NewEngineNewPayloadHandler(publisher Publisher, log logrus.FieldLogger) *En
 	return &EngineNewPayloadHandler{
 		publisher: publisher,
 		log:       log.WithField("handler", "engine_newPayload"),
-		pending:   make(map[uint64]*PendingNewPayloadCall, DefaultPendingCapacity),
+		pending:   make(map[callKey]*PendingNewPayloadCall, DefaultPendingCapacity),
 	}
 }
 
@@ -69,9 +69,9 @@ func (h *EngineNewPayloadHandler) MethodMatcher() func(method string) bool {
 
 func (h *EngineNewPayloadHandler) cleanupStale() {
 	cutoff := time.Now().Add(-30 * time.Second)
-	for id, pending := range h.pending {
+	for key, pending := range h.pending {
 		if pending.RequestTimestamp.Before(cutoff) {
-			delete(h.pending, id)
+			delete(h.pending, key)
 		}
 	}
 }
@@ -82,7 +82,7 @@ func (h *EngineNewPayloadHandler) HandleRequest(event *RequestEvent) bool {
 
 	h.mu.Lock()
 	h.cleanupStale()
-	h.pending[event.CallID] = pending
+	h.pending[event.key()] = pending
 	h.mu.Unlock()
 
 	h.log.WithFields(logrus.Fields{
@@ -101,7 +101,7 @@ func (h *EngineNewPayloadHandler) HandleRequest(event *RequestEvent) bool {
 func (h *EngineNewPayloadHandler) HandleResponse(event *ResponseEvent) {
 	h.mu.Lock()
 
-	pending, ok := h.pending[event.CallID]
+	pending, ok := h.pending[event.key()]
 	if !ok {
 		h.mu.Unlock()
 		h.log.WithField("call_id", event.CallID).Warn("no pending request found for response")
@@ -109,7 +109,7 @@ func (h *EngineNewPayloadHandler) HandleResponse(event *ResponseEvent) {
 		return
 	}
 
-	delete(h.pending, event.CallID)
+	delete(h.pending, event.key())
 
 	h.mu.Unlock()
 
@@ -31,11 +31,43 @@ type EventHandler interface {
 	HandleResponse(ctx *ResponseEvent)
 }
 
+// BeaconEventHandler defines the interface for handling beacon API event stream topics.
+// Beacon events are not request/response pairs, so each event is handled on its own.
+type BeaconEventHandler interface {
+	// Name returns the handler name for logging and metrics.
+	Name() string
+
+	// Topics returns the event stream topics handled by this handler (e.g., "head").
+	Topics() []string
+
+	// HandleEvent processes a single event and publishes the corresponding event.
+	HandleEvent(event *BeaconEvent)
+}
+
+// BeaconEvent contains a single event from an intercepted /eth/v1/events stream.
+type BeaconEvent struct {
+	// CallID is the unique identifier of the event stream call.
+	CallID uint64
+
+	// Timestamp is when the event was observed at the proxy.
+	Timestamp time.Time
+
+	// Topic is the event stream topic (e.g., "head", "finalized_checkpoint").
+	Topic string
+
+	// Data is the parsed event data.
+	Data map[string]any
+}
+
 // RequestEvent contains data from an intercepted JSON-RPC request.
 type RequestEvent struct {
 	// CallID is the unique identifier for this request/response pair.
 	CallID uint64
 
+	// BatchIndex is the position of the call in its JSON-RPC batch, or -1 if the
+	// call is not part of a batch. Batch elements share the CallID of the batch.
+	BatchIndex int
+
 	// Timestamp is when the request was received.
 	Timestamp time.Time
 
@@ -54,6 +86,10 @@ type ResponseEvent struct {
 	// CallID is the unique identifier for this request/response pair.
 	CallID uint64
 
+	// BatchIndex is the position of the call in its JSON-RPC batch, or -1 if the
+	// call is not part of a batch.
+	BatchIndex int
+
 	// Timestamp is when the response was received.
 	Timestamp time.Time
 
@@ -75,3 +111,17 @@ type RPCError struct {
 	Code    int
 	Message string
 }
+
+// callKey identifies a pending request/response pair across batch elements.
+type callKey struct {
+	callID     uint64
+	batchIndex int
+}
+
+func (e *RequestEvent) key() callKey {
+	return callKey{callID: e.CallID, batchIndex: e.BatchIndex}
+}
+
+func (e *ResponseEvent) key() callKey {
+	return callKey{callID: e.CallID, batchIndex: e.BatchIndex}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/ethpandaops/rpc-snooper/utils"
 	"github.com/ethpandaops/xatu/pkg/output"
 	"github.com/ethpandaops/xatu/pkg/output/http"
+	"github.com/ethpandaops/xatu/pkg/output/kafka"
 	"github.com/ethpandaops/xatu/pkg/output/stdout"
 	xatuOutput "github.com/ethpandaops/xatu/pkg/output/xatu"
 	"github.com/ethpandaops/xatu/pkg/processor"
@@ -224,6 +225,32 @@ func (p *publisher) createSink(outConfig OutputConfig, index int) (output.Sink,
 
 		return xatuOutput.New(name, conf, p.log.WithField("sink", name), filterConfig, shippingMethod)
 
+	case OutputTypeKafka:
+		brokers, topic, err := ParseKafkaAddress(outConfig.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		// The kafka sink produces synchronously per exported batch, so the producer
+		// flush thresholds follow the batch settings instead of sarama's defaults.
+		// There is no per-export timeout on the kafka sink; ExportTimeout is unused.
+		conf := &kafka.Config{
+			Brokers:            brokers,
+			Topic:              topic,
+			TLS:                p.config.TLS,
+			MaxQueueSize:       p.getMaxQueueSize(),
+			BatchTimeout:       p.getBatchTimeout(),
+			MaxExportBatchSize: p.getMaxExportBatchSize(),
+			Workers:            p.getWorkers(),
+			FlushFrequency:     p.getBatchTimeout(),
+			FlushMessages:      p.getMaxExportBatchSize(),
+		}
+		if err := defaults.Set(conf); err != nil {
+			return nil, fmt.Errorf("failed to set kafka defaults: %w", err)
+		}
+
+		return kafka.New(name, conf, p.log.WithField("sink", name), filterConfig, shippingMethod)
+
 	default:
 		return nil, fmt.Errorf("unknown output type: %s", outConfig.Type)
 	}
@@ -0,0 +1,168 @@
+package xatu
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	xatu "github.com/ethpandaops/xatu/pkg/proto/xatu"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// staticMetadataProvider returns fixed execution metadata so events are not dropped.
+type staticMetadataProvider struct{}
+
+func (staticMetadataProvider) Get() *ExecutionMetadata {
+	return &ExecutionMetadata{Implementation: "geth", Version: "1.0.0"}
+}
+
+func TestParseKafkaAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		brokers string
+		topic   string
+		wantErr bool
+	}{
+		{
+			name:    "single broker",
+			address: "localhost:9092/events",
+			brokers: "localhost:9092",
+			topic:   "events",
+		},
+		{
+			name:    "multiple brokers",
+			address: "broker1:9092,broker2:9092/events",
+			brokers: "broker1:9092,broker2:9092",
+			topic:   "events",
+		},
+		{
+			name:    "whitespace around brokers",
+			address: "broker1:9092, broker2:9092/events",
+			brokers: "broker1:9092,broker2:9092",
+			topic:   "events",
+		},
+		{
+			name:    "missing topic separator",
+			address: "broker1:9092",
+			wantErr: true,
+		},
+		{
+			name:    "empty topic",
+			address: "broker1:9092/",
+			wantErr: true,
+		},
+		{
+			name:    "empty broker list",
+			address: "/events",
+			wantErr: true,
+		},
+		{
+			name:    "empty broker in list",
+			address: "broker1:9092,,broker2:9092/events",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			brokers, topic, err := ParseKafkaAddress(tt.address)
+			if tt.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.brokers, brokers)
+			assert.Equal(t, tt.topic, topic)
+		})
+	}
+}
+
+func TestOutputConfigValidateKafka(t *testing.T) {
+	valid := OutputConfig{Type: OutputTypeKafka, Address: "broker1:9092/events"}
+	require.NoError(t, valid.Validate())
+
+	invalid := OutputConfig{Type: OutputTypeKafka, Address: "broker1:9092"}
+	require.Error(t, invalid.Validate())
+}
+
+func TestPublisherKafkaSink(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("xatu-events", 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t),
+	})
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	pub := NewPublisher(&Config{
+		Enabled:     true,
+		Name:        "test-snooper",
+		NetworkName: "testnet",
+		NetworkID:   1,
+		Outputs: []OutputConfig{
+			{Type: OutputTypeKafka, Address: broker.Addr() + "/xatu-events"},
+		},
+		MaxExportBatchSize: 1,
+		BatchTimeout:       10 * time.Millisecond,
+		Workers:            1,
+	}, logger)
+	pub.SetMetadataProvider(staticMetadataProvider{})
+
+	ctx := context.Background()
+
+	require.NoError(t, pub.Start(ctx))
+
+	defer func() {
+		assert.NoError(t, pub.Stop(ctx))
+	}()
+
+	event := &xatu.DecoratedEvent{
+		Event: &xatu.Event{
+			Name:     xatu.Event_EXECUTION_ENGINE_NEW_PAYLOAD,
+			DateTime: timestamppb.Now(),
+			Id:       "test-event",
+		},
+		Meta: &xatu.Meta{Client: pub.ClientMeta()},
+	}
+
+	require.NoError(t, pub.Publish(ctx, event))
+
+	require.Eventually(t, func() bool {
+		for _, rr := range broker.History() {
+			if _, ok := rr.Request.(*sarama.ProduceRequest); ok {
+				return true
+			}
+		}
+
+		return false
+	}, 5*time.Second, 20*time.Millisecond, "event should be produced to the kafka broker")
+}
+
+func TestPublisherKafkaSinkInvalidAddress(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	pub := NewPublisher(&Config{
+		Enabled: true,
+		Outputs: []OutputConfig{
+			{Type: OutputTypeKafka, Address: "localhost:9092"},
+		},
+	}, logger)
+
+	err := pub.Start(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "kafka")
+}
@@ -4,17 +4,20 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// Router routes JSON-RPC methods to their corresponding event handlers.
+// Router routes JSON-RPC methods and beacon event stream topics to their corresponding
+// event handlers.
 type Router struct {
-	handlers []EventHandler
-	log      logrus.FieldLogger
+	handlers       []EventHandler
+	beaconHandlers map[string]BeaconEventHandler
+	log            logrus.FieldLogger
 }
 
 // NewRouter creates a new Router instance.
 func NewRouter(log logrus.FieldLogger) *Router {
 	return &Router{
-		handlers: make([]EventHandler, 0, 8),
-		log:      log.WithField("component", "xatu_router"),
+		handlers:       make([]EventHandler, 0, 8),
+		beaconHandlers: make(map[string]BeaconEventHandler, 8),
+		log:            log.WithField("component", "xatu_router"),
 	}
 }
 
@@ -24,6 +27,15 @@ func (r *Router) Register(handler EventHandler) {
 	r.log.WithField("handler", handler.Name()).Debug("registered event handler")
 }
 
+// RegisterBeaconHandler adds a beacon event handler for all its topics to the router.
+func (r *Router) RegisterBeaconHandler(handler BeaconEventHandler) {
+	for _, topic := range handler.Topics() {
+		r.beaconHandlers[topic] = handler
+	}
+
+	r.log.WithField("handler", handler.Name()).Debug("registered beacon event handler")
+}
+
 // RouteRequest finds a matching handler for the request and calls HandleRequest.
 // Returns the matched handler (or nil) and whether a handler was matched.
 func (r *Router) RouteRequest(event *RequestEvent) (EventHandler, bool) {
@@ -41,6 +53,19 @@ func (r *Router) RouteRequest(event *RequestEvent) (EventHandler, bool) {
 	return nil, false
 }
 
+// RouteBeaconEvent passes the event to the handler registered for its topic.
+// Returns whether a handler was matched.
+func (r *Router) RouteBeaconEvent(event *BeaconEvent) bool {
+	handler, ok := r.beaconHandlers[event.Topic]
+	if !ok {
+		return false
+	}
+
+	handler.HandleEvent(event)
+
+	return true
+}
+
 // HandlerCount returns the number of registered handlers.
 func (r *Router) HandlerCount() int {
 	return len(r.handlers)
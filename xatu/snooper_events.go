@@ -0,0 +1,136 @@
+package xatu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Snooper event names, for calls and beacon events the xatu event schema has no event
+// for. Snooper events are not sent to the xatu outputs, but to the snooper event sink.
+const (
+	SnooperEventEngineForkchoiceUpdated = "SNOOPER_ENGINE_FORKCHOICE_UPDATED"
+	SnooperEventEngineGetPayload        = "SNOOPER_ENGINE_GET_PAYLOAD"
+	SnooperEventBeaconPayloadAttributes = "SNOOPER_BEACON_API_ETH_V1_EVENTS_PAYLOAD_ATTRIBUTES"
+)
+
+// SnooperEvent is an event for a call or beacon event the xatu event schema has no
+// event for. Data holds the event type specific fields.
+type SnooperEvent struct {
+	Name     string             `json:"name"`
+	ID       string             `json:"id"`
+	DateTime time.Time          `json:"date_time"`
+	Client   SnooperEventClient `json:"client"`
+	Data     any                `json:"data"`
+}
+
+// SnooperEventClient identifies the snooper instance and execution client of an event,
+// like the client meta of xatu events.
+type SnooperEventClient struct {
+	Name                    string            `json:"name"`
+	Version                 string            `json:"version"`
+	Implementation          string            `json:"implementation"`
+	NetworkName             string            `json:"network_name"`
+	NetworkID               uint64            `json:"network_id"`
+	ExecutionImplementation string            `json:"execution_implementation,omitempty"`
+	ExecutionVersion        string            `json:"execution_version,omitempty"`
+	Labels                  map[string]string `json:"labels,omitempty"`
+}
+
+// SnooperEventSink receives the snooper events.
+type SnooperEventSink interface {
+	PublishSnooperEvent(event *SnooperEvent) error
+}
+
+// newSnooperEvent builds a snooper event, with the client meta of publisher.
+func newSnooperEvent(publisher Publisher, name string, timestamp time.Time, data any) *SnooperEvent {
+	event := &SnooperEvent{
+		Name:     name,
+		ID:       uuid.New().String(),
+		DateTime: timestamp.UTC(),
+		Data:     data,
+	}
+
+	meta := publisher.ClientMeta()
+	if meta == nil {
+		return event
+	}
+
+	event.Client = SnooperEventClient{
+		Name:           meta.Name,
+		Version:        meta.Version,
+		Implementation: meta.Implementation,
+		Labels:         meta.Labels,
+	}
+
+	if meta.Ethereum != nil {
+		if meta.Ethereum.Network != nil {
+			event.Client.NetworkName = meta.Ethereum.Network.Name
+			event.Client.NetworkID = meta.Ethereum.Network.Id
+		}
+
+		if meta.Ethereum.Execution != nil {
+			event.Client.ExecutionImplementation = meta.Ethereum.Execution.Implementation
+			event.Client.ExecutionVersion = meta.Ethereum.Execution.Version
+		}
+	}
+
+	return event
+}
+
+// SnooperEventFile is a SnooperEventSink appending the events to a file, one JSON
+// document per line.
+type SnooperEventFile struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewSnooperEventFile opens path for appending snooper events, creating it if needed.
+func NewSnooperEventFile(path string) (*SnooperEventFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gosec // path is provided by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snooper event file: %w", err)
+	}
+
+	return &SnooperEventFile{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+// PublishSnooperEvent appends event to the file.
+func (f *SnooperEventFile) PublishSnooperEvent(event *SnooperEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return errors.New("snooper event file is closed")
+	}
+
+	if err := f.encoder.Encode(event); err != nil {
+		return fmt.Errorf("failed to write snooper event: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the file.
+func (f *SnooperEventFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err
+}
@@ -0,0 +1,73 @@
+package xatu
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturingSnooperEvents is a SnooperEventSink keeping all published snooper events.
+type capturingSnooperEvents struct {
+	mu     sync.Mutex
+	events []*SnooperEvent
+}
+
+func (s *capturingSnooperEvents) PublishSnooperEvent(event *SnooperEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+
+	return nil
+}
+
+func TestSnooperEventFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snooper-events.jsonl")
+
+	logger, _ := newCapturingLogger()
+
+	file, err := NewSnooperEventFile(path)
+	require.NoError(t, err)
+
+	timestamp := time.Unix(1700000012, 345000000)
+	publisher := NewPublisher(&Config{
+		Name:        "snooper-1",
+		NetworkName: "hoodi",
+		NetworkID:   560048,
+		Labels:      map[string]string{"client": "geth"},
+	}, logger)
+
+	require.NoError(t, file.PublishSnooperEvent(newSnooperEvent(publisher, SnooperEventEngineForkchoiceUpdated, timestamp, map[string]any{"status": "VALID"})))
+	require.NoError(t, file.PublishSnooperEvent(newSnooperEvent(publisher, SnooperEventEngineForkchoiceUpdated, timestamp, map[string]any{"status": "SYNCING"})))
+	require.NoError(t, file.Close())
+	require.Error(t, file.PublishSnooperEvent(newSnooperEvent(publisher, SnooperEventEngineForkchoiceUpdated, timestamp, nil)))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	event := map[string]any{}
+	require.NoError(t, json.Unmarshal(lines[0], &event))
+
+	assert.Equal(t, SnooperEventEngineForkchoiceUpdated, event["name"])
+	assert.NotEmpty(t, event["id"])
+	assert.Equal(t, "2023-11-14T22:13:32.345Z", event["date_time"])
+	assert.Equal(t, map[string]any{"status": "VALID"}, event["data"])
+
+	client, ok := event["client"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "snooper-1", client["name"])
+	assert.Equal(t, "rpc-snooper", client["implementation"])
+	assert.Equal(t, "hoodi", client["network_name"])
+	assert.InDelta(t, 560048, client["network_id"], 0)
+	assert.Equal(t, map[string]any{"client": "geth"}, client["labels"])
+}
@@ -40,6 +40,9 @@ type service struct {
 	publisher Publisher
 	router    *Router
 
+	// snooperEvents is the snooper event sink, nil if no snooper event file is configured
+	snooperEvents *SnooperEventFile
+
 	metadataCallback MetadataUpdateFunc
 
 	mu      sync.RWMutex
@@ -65,6 +68,15 @@ func NewService(config *Config, log logrus.FieldLogger) (Service, error) {
 		router:    NewRouter(log),
 	}
 
+	if config.SnooperEventsFile != "" {
+		snooperEvents, err := NewSnooperEventFile(config.SnooperEventsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		s.snooperEvents = snooperEvents
+	}
+
 	// Register event handlers (except engine_getClientVersion which needs callback)
 	s.registerHandlers()
 
@@ -94,6 +106,24 @@ func (s *service) registerHandlers() {
 	// Register engine_newPayload handler
 	s.router.Register(NewEngineNewPayloadHandler(s.publisher, s.log))
 
+	// engine_forkchoiceUpdated and engine_getPayload have no xatu event, they are
+	// published as snooper events
+	if s.snooperEvents != nil {
+		s.router.Register(NewEngineForkchoiceUpdatedHandler(s.publisher, s.snooperEvents, s.log))
+		s.router.Register(NewEngineGetPayloadHandler(s.publisher, s.snooperEvents, s.log))
+	}
+
+	// Register beacon API event stream handler, payload_attributes events have no xatu
+	// event and are only handled if they can be published as snooper events
+	beaconClock := newBeaconClockFromConfig(s.config)
+
+	beaconHandler := NewBeaconEventsHandler(s.publisher, nil, beaconClock, s.log)
+	if s.snooperEvents != nil {
+		beaconHandler = NewBeaconEventsHandler(s.publisher, s.snooperEvents, beaconClock, s.log)
+	}
+
+	s.router.RegisterBeaconHandler(beaconHandler)
+
 	// Note: engine_getClientVersion handler is registered via RegisterMetadataUpdateCallback
 
 	s.log.WithField("handler_count", s.router.HandlerCount()).Info("registered xatu event handlers")
@@ -131,6 +161,12 @@ func (s *service) Stop(ctx context.Context) error {
 		return fmt.Errorf("failed to stop publisher: %w", err)
 	}
 
+	if s.snooperEvents != nil {
+		if err := s.snooperEvents.Close(); err != nil {
+			return fmt.Errorf("failed to close snooper event file: %w", err)
+		}
+	}
+
 	s.started = false
 	s.log.Info("xatu service stopped")
 